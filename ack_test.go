@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// The ack reaction is on the message while the task runs and removed once it finishes
+func TestAckReactionBeforeExecution(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		wantEmoji string // "" when no reaction is expected
+	}{
+		{name: "default", wantEmoji: "eyes"},
+		{name: "configured", config: Config{AckReaction: "hourglass_flowing_sand"}, wantEmoji: "hourglass_flowing_sand"},
+		{name: "disabled", config: Config{AckReaction: "none"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messenger := newFakeMessenger()
+			var duringRequest []string
+			target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				duringRequest = messenger.currentReactions()
+			}))
+			defer target.Close()
+
+			store := newConfigTaskStore(map[string]Task{"restart": {Command: "restart", URL: target.URL, Method: "POST"}})
+			handleMessageEvent(context.Background(), messenger, messageEvent("U1", "restart"), &test.config, store, newBotState(&test.config))
+
+			if test.wantEmoji == "" {
+				if len(duringRequest) != 0 || len(messenger.currentReactions()) != 0 {
+					t.Errorf("reactions %v with ack_reaction none", duringRequest)
+				}
+				return
+			}
+			if strings.Join(duringRequest, ",") != test.wantEmoji {
+				t.Errorf("reactions while executing = %v, want %s", duringRequest, test.wantEmoji)
+			}
+			calls := messenger.calls()
+			if len(calls) == 0 || calls[0] != "+"+test.wantEmoji || len(messenger.currentReactions()) != 0 {
+				t.Errorf("calls = %q, want the reaction added first and removed when the task finishes", calls)
+			}
+		})
+	}
+}
+
+// Unknown commands get no acknowledgement
+func TestAckReactionOnlyForKnownCommands(t *testing.T) {
+	messenger := newFakeMessenger()
+	config := &Config{}
+	handleMessageEvent(context.Background(), messenger, messageEvent("U1", "retsart"), config, newConfigTaskStore(nil), newBotState(config))
+
+	for _, call := range messenger.calls() {
+		if strings.HasPrefix(call, "+") {
+			t.Errorf("unknown command got reaction %s", call)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Request body for creating or replacing a task through the admin API
+type adminTaskRequest struct {
+	Name string `json:"name"` // Command users type in Slack
+	Task Task   `json:"task"`
+}
+
+// Register the admin API routes for managing tasks at runtime
+func registerAdminRoutes(mux *http.ServeMux, config *Config, store TaskStore, state *botState) {
+	if config.AdminToken == "" {
+		return
+	}
+	if config.TaskDB == "" {
+		log.Println("Admin API enabled without task_db: task changes will not survive a restart.")
+	}
+
+	handler := requireBearerToken(config.AdminToken, adminTasksHandler(store))
+	mux.Handle("/admin/tasks", handler)
+	mux.Handle("/admin/tasks/", handler)
+	mux.Handle("/admin/config", requireBearerToken(config.AdminToken, adminConfigHandler(store, state)))
+}
+
+// Reject requests that don't carry the expected token as "Authorization: Bearer <token>"
+func requireBearerToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Handle GET/POST /admin/tasks and DELETE /admin/tasks/{command}
+func adminTasksHandler(store TaskStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		command := normalizeTaskName(strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/admin/tasks"), "/"))
+
+		switch {
+		case command == "" && r.Method == http.MethodGet:
+			tasks, err := store.ListTasks()
+			if err != nil {
+				log.Printf("Error listing tasks: %v", err)
+				writeJSONError(w, http.StatusInternalServerError, "can't list tasks")
+				return
+			}
+			// Never hand credentials back out of the API
+			for name, task := range tasks {
+				tasks[name] = redactTask(task)
+			}
+			writeJSON(w, http.StatusOK, tasks)
+
+		case command == "" && r.Method == http.MethodPost:
+			var req adminTaskRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeJSONError(w, http.StatusBadRequest, "can't parse JSON")
+				return
+			}
+			req.Name = normalizeTaskName(req.Name)
+			if req.Name == "" || (req.Task.URL == "" && len(req.Task.URLs) == 0 && len(req.Task.Steps) == 0 && req.Task.GitHubWorkflow == nil) {
+				writeJSONError(w, http.StatusBadRequest, "name and task.url, task.urls, task.steps or task.github_workflow are required")
+				return
+			}
+			if err := validateTask(req.Name, req.Task); err != nil {
+				writeJSONError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if err := store.AddTask(req.Name, req.Task); err != nil {
+				log.Printf("Error adding task '%s': %v", req.Name, err)
+				writeJSONError(w, http.StatusInternalServerError, "can't save task")
+				return
+			}
+			log.Printf("Task '%s' saved through admin API", req.Name)
+			req.Task = redactTask(req.Task)
+			writeJSON(w, http.StatusCreated, req)
+
+		case command != "" && r.Method == http.MethodDelete:
+			err := store.RemoveTask(command)
+			if errors.Is(err, errTaskNotFound) {
+				writeJSONError(w, http.StatusNotFound, "task not found")
+				return
+			}
+			if err != nil {
+				log.Printf("Error removing task '%s': %v", command, err)
+				writeJSONError(w, http.StatusInternalServerError, "can't remove task")
+				return
+			}
+			log.Printf("Task '%s' removed through admin API", command)
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	})
+}
+
+// Task names are stored trimmed and lower case, the way commands are matched,
+// so every route finds a task under the name it was saved with
+func normalizeTaskName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
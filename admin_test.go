@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testAdminToken = "admin-token"
+
+// Admin API server over a config store seeded with config.Tasks
+func newAdminServer(t *testing.T, config *Config) (*httptest.Server, TaskStore, *botState) {
+	t.Helper()
+	store := newConfigTaskStore(config.Tasks)
+	state := newBotState(config)
+	mux := http.NewServeMux()
+	registerAdminRoutes(mux, config, store, state)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, store, state
+}
+
+// Send a request to the admin API, returning the status and body
+func adminRequest(t *testing.T, server *httptest.Server, method, path, token, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+// Create, list and delete a task; changes are visible in the store immediately
+func TestAdminTasksLifecycle(t *testing.T) {
+	server, store, _ := newAdminServer(t, &Config{AdminToken: testAdminToken, Tasks: map[string]Task{}})
+
+	status, body := adminRequest(t, server, http.MethodPost, "/admin/tasks", testAdminToken,
+		`{"name": " Restart ", "task": {"url": "https://example.com/restart", "method": "POST", "token": "secret", "signing_secret": "s3cret"}}`)
+	if status != http.StatusCreated {
+		t.Fatalf("create = %d %s, want 201", status, body)
+	}
+	if strings.Contains(body, `"secret"`) || strings.Contains(body, "s3cret") {
+		t.Errorf("create reply %s echoes the credentials", body)
+	}
+	if task, exists, _ := store.GetTask("restart"); !exists || task.URL != "https://example.com/restart" {
+		t.Fatalf("created task not in the store under its normalised name: %+v, %v", task, exists)
+	}
+
+	status, body = adminRequest(t, server, http.MethodGet, "/admin/tasks", testAdminToken, "")
+	var tasks map[string]Task
+	if err := json.Unmarshal([]byte(body), &tasks); status != http.StatusOK || err != nil {
+		t.Fatalf("list = %d %s (%v)", status, body, err)
+	}
+	if tasks["restart"].Token != "***" || tasks["restart"].SigningSecret != "***" {
+		t.Errorf("listed token %q and signing secret %q, want both redacted", tasks["restart"].Token, tasks["restart"].SigningSecret)
+	}
+
+	// The path is normalised like the name it was created with
+	if status, body = adminRequest(t, server, http.MethodDelete, "/admin/tasks/%20Restart", testAdminToken, ""); status != http.StatusNoContent {
+		t.Fatalf("delete = %d %s, want 204", status, body)
+	}
+	if _, exists, _ := store.GetTask("restart"); exists {
+		t.Error("deleted task still in the store")
+	}
+	if status, _ = adminRequest(t, server, http.MethodDelete, "/admin/tasks/restart", testAdminToken, ""); status != http.StatusNotFound {
+		t.Errorf("second delete = %d, want 404", status)
+	}
+}
+
+func TestAdminTasksRejectsBadRequests(t *testing.T) {
+	server, store, _ := newAdminServer(t, &Config{AdminToken: testAdminToken, Tasks: map[string]Task{"health": {URL: "https://example.com/health"}}})
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       string
+		wantStatus int
+	}{
+		{name: "list without token", method: http.MethodGet, path: "/admin/tasks", wantStatus: http.StatusUnauthorized},
+		{name: "list with wrong token", method: http.MethodGet, path: "/admin/tasks", token: "nope", wantStatus: http.StatusUnauthorized},
+		{name: "create without token", method: http.MethodPost, path: "/admin/tasks", body: `{"name": "x", "task": {"url": "https://example.com"}}`, wantStatus: http.StatusUnauthorized},
+		{name: "delete without token", method: http.MethodDelete, path: "/admin/tasks/health", wantStatus: http.StatusUnauthorized},
+		{name: "create without url", method: http.MethodPost, path: "/admin/tasks", token: testAdminToken, body: `{"name": "x"}`, wantStatus: http.StatusBadRequest},
+		{name: "create with bad JSON", method: http.MethodPost, path: "/admin/tasks", token: testAdminToken, body: `{`, wantStatus: http.StatusBadRequest},
+		{name: "delete the collection", method: http.MethodDelete, path: "/admin/tasks", token: testAdminToken, wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if status, body := adminRequest(t, server, test.method, test.path, test.token, test.body); status != test.wantStatus {
+				t.Errorf("%s %s = %d %s, want %d", test.method, test.path, status, body, test.wantStatus)
+			}
+		})
+	}
+	if _, exists, _ := store.GetTask("health"); !exists {
+		t.Error("an unauthorized delete removed the task")
+	}
+}
+
+// Without admin_token the routes are never registered
+func TestAdminRoutesDisabledWithoutToken(t *testing.T) {
+	server, _, _ := newAdminServer(t, &Config{})
+
+	if status, _ := adminRequest(t, server, http.MethodGet, "/admin/tasks", "", ""); status != http.StatusNotFound {
+		t.Errorf("GET /admin/tasks = %d, want 404", status)
+	}
+}
+
+// Task definitions failing validation are refused by the admin API
+func TestAdminTasksValidatesTask(t *testing.T) {
+	server, store, _ := newAdminServer(t, &Config{AdminToken: testAdminToken, Tasks: map[string]Task{}})
+
+	status, body := adminRequest(t, server, http.MethodPost, "/admin/tasks", testAdminToken,
+		`{"name": "legacy", "task": {"url": "https://example.com", "method": "POST", "body": "{}", "form_data": {"a": "1"}}}`)
+	if status != http.StatusBadRequest || !strings.Contains(body, "mutually exclusive") {
+		t.Errorf("create = %d %s, want 400 with the validation error", status, body)
+	}
+	if _, exists, _ := store.GetTask("legacy"); exists {
+		t.Error("invalid task saved")
+	}
+}
+
+// Only "Authorization: Bearer <token>" is accepted, not a bare token or another scheme
+func TestRequireBearerToken(t *testing.T) {
+	server, _, _ := newAdminServer(t, &Config{AdminToken: testAdminToken})
+
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+	}{
+		{name: "no header", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", header: "Bearer nope", wantStatus: http.StatusUnauthorized},
+		{name: "token without scheme", header: testAdminToken, wantStatus: http.StatusUnauthorized},
+		{name: "basic scheme", header: "Basic " + testAdminToken, wantStatus: http.StatusUnauthorized},
+		{name: "bearer token", header: "Bearer " + testAdminToken, wantStatus: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", server.URL+"/admin/tasks", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.header != "" {
+				req.Header.Set("Authorization", test.header)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != test.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, test.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Reply of POST /admin/config: the validation result and what the new
+// configuration would change
+type configPreview struct {
+	Valid           bool     `json:"valid"`
+	Errors          []string `json:"errors,omitempty"`
+	Added           []string `json:"added"`            // Commands only in the new configuration
+	Removed         []string `json:"removed"`          // Commands only in the current configuration
+	Changed         []string `json:"changed"`          // Commands whose task definition differs
+	SettingsChanged bool     `json:"settings_changed"` // Anything besides tasks differs
+	Applied         bool     `json:"applied"`
+}
+
+// Handle POST /admin/config: validate the posted configuration and return
+// its diff against the current one. It is only applied with ?confirm=true.
+func adminConfigHandler(store TaskStore, state *botState) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, 4<<20))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "can't read body")
+			return
+		}
+		var fresh Config
+		if err := json.Unmarshal(body, &fresh); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "can't parse JSON: "+err.Error())
+			return
+		}
+
+		// Resolve file:// and env:// tokens as a load or reload does, so the
+		// applied config holds the secrets and compares with the live one
+		if err := resolveConfigSecrets(&fresh); err != nil {
+			writeJSON(w, http.StatusBadRequest, configPreview{Errors: []string{err.Error()}, Added: []string{}, Removed: []string{}, Changed: []string{}})
+			return
+		}
+
+		preview := diffConfigs(state.config.Load(), &fresh)
+		if err := validateConfig(&fresh); err != nil {
+			preview.Errors = strings.Split(err.Error(), "\n")
+			writeJSON(w, http.StatusBadRequest, preview)
+			return
+		}
+		preview.Valid = true
+
+		if r.URL.Query().Get("confirm") == "true" {
+			applyConfig(&fresh, store, state)
+			preview.Applied = true
+			log.Printf("Configuration applied through admin API: %d added, %d removed, %d changed", len(preview.Added), len(preview.Removed), len(preview.Changed))
+			state.notify("Configuration applied through the admin API: %d commands added, %d removed, %d changed.", len(preview.Added), len(preview.Removed), len(preview.Changed))
+		}
+		writeJSON(w, http.StatusOK, preview)
+	})
+}
+
+// Compare the tasks and other settings of two configurations
+func diffConfigs(current, fresh *Config) configPreview {
+	preview := configPreview{Added: []string{}, Removed: []string{}, Changed: []string{}}
+	for command, task := range fresh.Tasks {
+		old, ok := current.Tasks[command]
+		if !ok {
+			preview.Added = append(preview.Added, command)
+		} else if !reflect.DeepEqual(old, task) {
+			preview.Changed = append(preview.Changed, command)
+		}
+	}
+	for command := range current.Tasks {
+		if _, ok := fresh.Tasks[command]; !ok {
+			preview.Removed = append(preview.Removed, command)
+		}
+	}
+	sort.Strings(preview.Added)
+	sort.Strings(preview.Removed)
+	sort.Strings(preview.Changed)
+
+	currentSettings, freshSettings := *current, *fresh
+	currentSettings.Tasks, freshSettings.Tasks = nil, nil
+	preview.SettingsChanged = !reflect.DeepEqual(currentSettings, freshSettings)
+	return preview
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// Tasks are compared one by one, everything else as a whole
+func TestDiffConfigs(t *testing.T) {
+	current := &Config{
+		LogLevel: "info",
+		Tasks: map[string]Task{
+			"health": {URL: "https://example.com/health"},
+			"broken": {URL: "https://example.com/fail"},
+			"old":    {URL: "https://example.com/old"},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		fresh *Config
+		want  configPreview
+	}{
+		{
+			name:  "identical",
+			fresh: &Config{LogLevel: "info", Tasks: current.Tasks},
+			want:  configPreview{Added: []string{}, Removed: []string{}, Changed: []string{}},
+		},
+		{
+			name: "tasks added, removed and changed",
+			fresh: &Config{LogLevel: "info", Tasks: map[string]Task{
+				"health": {URL: "https://example.com/health"},
+				"broken": {URL: "https://example.com/fixed"},
+				"new":    {URL: "https://example.com/new"},
+				"also":   {URL: "https://example.com/also"},
+			}},
+			want: configPreview{Added: []string{"also", "new"}, Removed: []string{"old"}, Changed: []string{"broken"}},
+		},
+		{
+			name:  "settings only",
+			fresh: &Config{LogLevel: "debug", Tasks: current.Tasks},
+			want:  configPreview{Added: []string{}, Removed: []string{}, Changed: []string{}, SettingsChanged: true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := diffConfigs(current, test.fresh); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("diffConfigs() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+// The new configuration is previewed, and only swapped in with confirm=true
+func TestAdminConfigHandler(t *testing.T) {
+	t.Setenv("BOT_TEST_TOKEN", "xoxb-from-env")
+
+	tests := []struct {
+		name        string
+		method      string
+		query       string
+		body        string
+		wantStatus  int
+		wantPreview configPreview
+		wantApplied []string // Commands in the store afterwards
+	}{
+		{name: "GET not allowed", method: "GET", wantStatus: http.StatusMethodNotAllowed, wantApplied: []string{"health"}},
+		{name: "invalid JSON", method: "POST", body: `{`, wantStatus: http.StatusBadRequest, wantApplied: []string{"health"}},
+		{
+			name: "unresolvable secret", method: "POST", body: `{"admin_token":"env://BOT_TEST_UNSET"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantPreview: configPreview{Errors: []string{"admin_token: resolving env://BOT_TEST_UNSET: environment variable BOT_TEST_UNSET is not set"}, Added: []string{}, Removed: []string{}, Changed: []string{}},
+			wantApplied: []string{"health"},
+		},
+		{
+			name: "invalid config", method: "POST", body: `{"tasks":{"bad":{"url":"https://example.com","method":"GET","body":"{}"}}}`,
+			wantStatus:  http.StatusBadRequest,
+			wantPreview: configPreview{Errors: []string{"task 'bad': body and form_data require method POST"}, Added: []string{"bad"}, Removed: []string{"health"}, Changed: []string{}, SettingsChanged: true},
+			wantApplied: []string{"health"},
+		},
+		{
+			name: "preview only", method: "POST", body: `{"admin_token":"admin-token","slack_token":"xoxb-from-env","tasks":{"fresh":{"url":"https://example.com"}}}`,
+			wantStatus:  http.StatusOK,
+			wantPreview: configPreview{Valid: true, Added: []string{"fresh"}, Removed: []string{"health"}, Changed: []string{}},
+			wantApplied: []string{"health"},
+		},
+		{
+			name: "secrets resolved before comparing", method: "POST", body: `{"admin_token":"admin-token","slack_token":"env://BOT_TEST_TOKEN","tasks":{"health":{"url":"https://example.com/health"}}}`,
+			wantStatus:  http.StatusOK,
+			wantPreview: configPreview{Valid: true, Added: []string{}, Removed: []string{}, Changed: []string{}},
+			wantApplied: []string{"health"},
+		},
+		{
+			name: "confirmed", method: "POST", query: "?confirm=true", body: `{"admin_token":"admin-token","slack_token":"xoxb-from-env","tasks":{"fresh":{"url":"https://example.com"}}}`,
+			wantStatus:  http.StatusOK,
+			wantPreview: configPreview{Valid: true, Added: []string{"fresh"}, Removed: []string{"health"}, Changed: []string{}, Applied: true},
+			wantApplied: []string{"fresh"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, store, _ := newAdminServer(t, &Config{
+				AdminToken: testAdminToken,
+				SlackToken: "xoxb-from-env",
+				Tasks:      map[string]Task{"health": {URL: "https://example.com/health"}},
+			})
+			status, body := adminRequest(t, server, test.method, "/admin/config"+test.query, testAdminToken, test.body)
+			if status != test.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", status, test.wantStatus, body)
+			}
+			if test.wantPreview.Added != nil {
+				var got configPreview
+				if err := json.Unmarshal([]byte(body), &got); err != nil {
+					t.Fatalf("decoding %s: %v", body, err)
+				}
+				if !reflect.DeepEqual(got, test.wantPreview) {
+					t.Errorf("preview = %+v, want %+v", got, test.wantPreview)
+				}
+			}
+
+			tasks, err := store.ListTasks()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var commands []string
+			for command := range tasks {
+				commands = append(commands, command)
+			}
+			if !reflect.DeepEqual(commands, test.wantApplied) {
+				t.Errorf("tasks = %v, want %v", commands, test.wantApplied)
+			}
+		})
+	}
+}
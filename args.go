@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// Slack turns typed quotes into typographic ones, treat them the same
+var smartQuotes = strings.NewReplacer("\u201c", `"`, "\u201d", `"`, "\u2018", "'", "\u2019", "'")
+
+// Split message text into arguments like a shell: whitespace separates
+// arguments, single or double quotes group words, backslash escapes the next
+// character outside single quotes.
+func splitArgs(text string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, r := range smartQuotes.Replace(text) {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, errors.New("unbalanced quote in command")
+	}
+	if escaped {
+		return nil, errors.New("command ends with a dangling backslash")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
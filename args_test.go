@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    []string
+		wantErr string
+	}{
+		{text: "deploy api prod", want: []string{"deploy", "api", "prod"}},
+		{text: `deploy api "feature branch"`, want: []string{"deploy", "api", "feature branch"}},
+		{text: `deploy 'my api'   staging`, want: []string{"deploy", "my api", "staging"}},
+		{text: "deploy api “feature branch”", want: []string{"deploy", "api", "feature branch"}},
+		{text: `deploy api "it's fine"`, want: []string{"deploy", "api", "it's fine"}},
+		{text: `deploy feature\ branch prod`, want: []string{"deploy", "feature branch", "prod"}},
+		{text: `deploy api ""`, want: []string{"deploy", "api", ""}},
+		{text: "  deploy\tapi  ", want: []string{"deploy", "api"}},
+		{text: `deploy api "feature branch`, wantErr: "unbalanced quote in command"},
+		{text: `deploy api prod\`, wantErr: "command ends with a dangling backslash"},
+	}
+	for _, test := range tests {
+		got, err := splitArgs(test.text)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("splitArgs(%q) error = %v, want %q", test.text, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitArgs(%q) = %q, %v, want %q", test.text, got, err, test.want)
+		}
+	}
+}
+
+// Quoted deploy arguments reach Jenkins intact; unbalanced quotes get a usage error
+func TestDeployQuotedArguments(t *testing.T) {
+	target, hits := newStubTarget(t)
+	config := &Config{Jenkins: JenkinsConfig{URLFormat: target.URL + "/ok/{service-name}/{env}"}}
+
+	messenger := newFakeMessenger()
+	handleMessageEvent(context.Background(), messenger, messageEvent("U1", `deploy api "feature branch"`), config, newConfigTaskStore(nil), newBotState(config))
+	if got := hits(); len(got) != 1 || got[0] != "/ok/api/feature branch" {
+		t.Errorf("Jenkins calls = %q, want the quoted env as one argument", got)
+	}
+
+	messenger = newFakeMessenger()
+	handleMessageEvent(context.Background(), messenger, messageEvent("U1", `deploy api "feature branch`), config, newConfigTaskStore(nil), newBotState(config))
+	sent := messenger.sent()
+	if len(sent) != 1 || sent[0].UserID != "U1" || sent[0].Text != "Invalid deploy command: unbalanced quote in command." {
+		t.Errorf("sent %+v, want an ephemeral unbalanced-quote error", sent)
+	}
+}
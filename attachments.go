@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// Largest response posted inline for attach_response_as_file tasks unless
+// attach_threshold_bytes is set; longer ones are uploaded as a file
+const defaultAttachThreshold = 3000
+
+func attachThreshold(config *Config) int {
+	if config.AttachThresholdBytes <= 0 {
+		return defaultAttachThreshold
+	}
+	return config.AttachThresholdBytes
+}
+
+// fileUploader is implemented by backends that can attach files to replies
+type fileUploader interface {
+	UploadFile(channelID, filename string, content []byte) error
+}
+
+func (m *slackMessenger) UploadFile(channelID, filename string, content []byte) error {
+	_, err := m.api.UploadFileV2(slack.UploadFileV2Parameters{
+		Channel:  channelID,
+		Filename: filename,
+		Title:    filename,
+		Reader:   bytes.NewReader(content),
+		FileSize: len(content),
+	})
+	return warnOnAuthError(err)
+}
+
+// Post a task's response body: inline when short, as a file upload when it is
+// over the threshold and the backend supports files, truncated otherwise
+func postResponseBody(messenger Messenger, config *Config, channelID, userID, command string, body []byte) {
+	threshold := attachThreshold(config)
+	var err error
+	if uploader, ok := messenger.(fileUploader); ok && len(body) > threshold {
+		err = uploader.UploadFile(channelID, command+"-response.txt", body)
+	} else if len(body) > threshold {
+		preview := truncateUTF8(string(body), threshold)
+		cut := localize(config, userID, "response_cut_of", "bytes", strconv.Itoa(len(preview)), "total", strconv.Itoa(len(body)))
+		err = messenger.PostMessage(channelID, codeBlock(preview)+"\n"+cut)
+	} else {
+		err = messenger.PostMessage(channelID, codeBlock(string(body)))
+	}
+	if err != nil {
+		log.Printf("Error sending message to Slack: %v", err)
+	}
+}
+
+// Wrap text in a code block. Backtick runs in the text are broken up with a
+// zero-width space so they can't close the block early.
+func codeBlock(text string) string {
+	return "```\n" + strings.ReplaceAll(text, "``", "`\u200b`") + "\n```"
+}
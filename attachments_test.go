@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeUploader is a fakeMessenger that can attach files
+type fakeUploader struct {
+	*fakeMessenger
+	files map[string][]byte
+}
+
+func (u *fakeUploader) UploadFile(channelID, filename string, content []byte) error {
+	u.files[filename] = content
+	return nil
+}
+
+// A code fence in the response can't close the block early
+func TestCodeBlock(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "ok", want: "```\nok\n```"},
+		{text: "a `b` c", want: "```\na `b` c\n```"},
+		{text: "```\nrm -rf /\n```", want: "```\n`\u200b``\nrm -rf /\n`\u200b``\n```"},
+	}
+	for _, test := range tests {
+		got := codeBlock(test.text)
+		if got != test.want {
+			t.Errorf("codeBlock(%q) = %q, want %q", test.text, got, test.want)
+		}
+		if inner := strings.TrimSuffix(strings.TrimPrefix(got, "```\n"), "\n```"); strings.Contains(inner, "```") {
+			t.Errorf("codeBlock(%q) can be closed early: %q", test.text, got)
+		}
+	}
+}
+
+// Long responses are uploaded when the backend can, truncated otherwise
+func TestPostResponseBody(t *testing.T) {
+	config := &Config{AttachThresholdBytes: 10}
+
+	tests := []struct {
+		name      string
+		body      string
+		uploader  bool
+		wantText  string // Prefix of the posted message, empty when a file is uploaded
+		wantFiles int
+	}{
+		{name: "short inline", body: "short", wantText: "```\nshort\n```"},
+		{name: "long truncated", body: "0123456789abcdef", wantText: "```\n0123456789\n```\n(response truncated at 10 of 16 bytes)"},
+		{name: "truncated on a rune boundary", body: "012345678é", wantText: "```\n012345678\n```\n(response truncated at 9 of 11 bytes)"},
+		{name: "short inline with uploads", body: "short", uploader: true, wantText: "```\nshort\n```"},
+		{name: "long uploaded", body: "0123456789abcdef", uploader: true, wantFiles: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeMessenger()
+			var messenger Messenger = fake
+			uploader := &fakeUploader{fakeMessenger: fake, files: map[string][]byte{}}
+			if test.uploader {
+				messenger = uploader
+			}
+
+			postResponseBody(messenger, config, "C1", "U1", "logs", []byte(test.body))
+
+			if len(uploader.files) != test.wantFiles {
+				t.Errorf("uploaded %d files, want %d", len(uploader.files), test.wantFiles)
+			}
+			if test.wantFiles > 0 && string(uploader.files["logs-response.txt"]) != test.body {
+				t.Errorf("uploaded %q, want the whole body", uploader.files["logs-response.txt"])
+			}
+			messages := fake.sent()
+			if test.wantText == "" {
+				if len(messages) != 0 {
+					t.Errorf("posted %v, want only the upload", messages)
+				}
+				return
+			}
+			if len(messages) != 1 || messages[0].Text != test.wantText {
+				t.Errorf("posted %v, want %q", messages, test.wantText)
+			}
+		})
+	}
+}
+
+// The response body follows the reply only for attach_response_as_file tasks
+func TestHandleMessageAttachesResponse(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("log line\n", 10)))
+	}))
+	defer target.Close()
+
+	for _, attach := range []bool{false, true} {
+		config := &Config{AttachThresholdBytes: 20}
+		fake := newFakeMessenger()
+		uploader := &fakeUploader{fakeMessenger: fake, files: map[string][]byte{}}
+		tasks := map[string]Task{"logs": {Command: "logs", URL: target.URL, Method: "GET", AttachResponseAsFile: attach}}
+		handleMessageEvent(context.Background(), uploader, messageEvent("U1", "logs"), config, newConfigTaskStore(tasks), newBotState(config))
+
+		sent := fake.sent()
+		if last := sent[len(sent)-1]; !strings.HasPrefix(last.Text, "Task 'logs' executed successfully") {
+			t.Errorf("attach %v: last reply = %+v, want the result", attach, last)
+		}
+		if got := len(uploader.files["logs-response.txt"]); (got == 90) != attach || (!attach && len(uploader.files) > 0) {
+			t.Errorf("attach %v: uploaded %v", attach, uploader.files)
+		}
+	}
+}
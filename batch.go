@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Flag that runs the commands of a batch in parallel instead of one by one
+const batchParallelFlag = "--parallel"
+
+// Result of one command in a batch
+type batchResult struct {
+	Command string
+	Known   bool
+	Outcome taskOutcome
+}
+
+// Handle "run [--parallel] <cmd> <cmd> ...": execute each known task and post one summary.
+// Unknown commands are reported without stopping the rest of the batch.
+func handleBatchCommand(ctx context.Context, messenger Messenger, msg incomingMessage, config *Config, store TaskStore, state *botState) {
+	args, err := splitArgs(msg.Text)
+	if err != nil {
+		err = messenger.PostEphemeral(msg.ChannelID, msg.UserID, localize(config, msg.UserID, "invalid_run", "error", err.Error()))
+		if err != nil {
+			log.Printf("Error sending message to Slack: %v", err)
+		}
+		return
+	}
+
+	parallel := false
+	var commands []string
+	for _, arg := range args[1:] {
+		if strings.ToLower(arg) == batchParallelFlag {
+			parallel = true
+			continue
+		}
+		commands = append(commands, arg)
+	}
+	if len(commands) == 0 {
+		err := messenger.PostEphemeral(msg.ChannelID, msg.UserID, localize(config, msg.UserID, "run_usage"))
+		if err != nil {
+			log.Printf("Error sending message to Slack: %v", err)
+		}
+		return
+	}
+
+	// Acknowledge the batch once; the runs get a message context of their own
+	// without the timestamp, so they don't add or remove the reaction themselves
+	removeAck := acknowledgeMessage(messenger, config, msg)
+	defer removeAck()
+
+	results := make([]batchResult, len(commands))
+	run := func(i int) {
+		// Each entry is parsed like a single command, so arguments and workflow
+		// inputs are checked: run "scale api 3" "release env=prod"
+		command, task, args, exists, rejection := resolveTask(config, store, msg.UserID, commands[i])
+		results[i].Command = command
+		if !exists {
+			log.Printf("Unknown command in batch: %s", command)
+			return
+		}
+		results[i].Known = true
+		if rejection != "" {
+			results[i].Outcome = taskOutcome{Response: rejection}
+			return
+		}
+		runMsg := msg
+		runMsg.Text, runMsg.Timestamp, runMsg.Args = commands[i], "", args
+		results[i].Outcome = runTask(ctx, messenger, runMsg, config, state, command, task)
+	}
+
+	log.Printf("Running batch of %d commands (parallel: %t)", len(commands), parallel)
+	if parallel {
+		var wg sync.WaitGroup
+		for i := range commands {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				run(i)
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		for i := range commands {
+			run(i)
+		}
+	}
+
+	err = messenger.PostMessage(msg.ChannelID, formatBatchResults(config, msg.UserID, results))
+	if err != nil {
+		log.Printf("Error sending message to Slack: %v", err)
+	}
+	for _, result := range results {
+		// The summary covers every run, so their running messages go away
+		if result.Outcome.InterimTS != "" {
+			if err := messenger.DeleteMessage(msg.ChannelID, result.Outcome.InterimTS); err != nil {
+				log.Printf("Error deleting running message of '%s': %v", result.Command, err)
+			}
+		}
+		escalateFailures(messenger, config, msg.ChannelID, msg.UserID, result.Command, result.Outcome.FailureStreak)
+	}
+}
+
+// Format the consolidated summary of a batch run
+func formatBatchResults(config *Config, userID string, results []batchResult) string {
+	succeeded := 0
+	var b strings.Builder
+	for _, result := range results {
+		var status string
+		switch {
+		case !result.Known:
+			status = localize(config, userID, "batch_unknown")
+		case !result.Outcome.Executed:
+			status = localize(config, userID, "batch_skipped", "reason", result.Outcome.Response)
+		case result.Outcome.Success:
+			succeeded++
+			status = localize(config, userID, "run_success")
+		default:
+			status = localize(config, userID, "run_failed")
+		}
+		fmt.Fprintf(&b, "\n- %s: %s", result.Command, status)
+	}
+	return localize(config, userID, "batch_finished", "succeeded", strconv.Itoa(succeeded), "total", strconv.Itoa(len(results))) + b.String()
+}
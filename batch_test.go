@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFormatBatchResults(t *testing.T) {
+	results := []batchResult{
+		{Command: "a", Known: true, Outcome: taskOutcome{Executed: true, Success: true}},
+		{Command: "b", Known: true, Outcome: taskOutcome{Executed: true}},
+		{Command: "c", Known: true, Outcome: taskOutcome{Response: "cooling down"}},
+		{Command: "d"},
+	}
+	want := "Batch finished: 1 of 4 commands succeeded.\n- a: success\n- b: failed\n- c: skipped (cooling down)\n- d: unknown command"
+	if got := formatBatchResults(&Config{}, "U1", results); got != want {
+		t.Errorf("formatBatchResults() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// A mixed batch runs every known command and reports the unknown ones in one summary
+func TestBatchCommand(t *testing.T) {
+	target, hits := newStubTarget(t)
+	tasks := map[string]Task{
+		"good": {Command: "good", URL: target.URL + "/ok", Method: "GET"},
+		"bad":  {Command: "bad", URL: target.URL + "/fail", Method: "GET"},
+	}
+
+	tests := []struct {
+		name        string
+		text        string
+		wantSummary string
+		wantPrivate bool
+		wantHits    int
+	}{
+		{name: "sequential", text: "run good bad nope", wantSummary: "Batch finished: 1 of 3 commands succeeded.\n- good: success\n- bad: failed\n- nope: unknown command", wantHits: 2},
+		{name: "parallel keeps the order", text: "run --parallel bad good", wantSummary: "Batch finished: 1 of 2 commands succeeded.\n- bad: failed\n- good: success", wantHits: 2},
+		{name: "quoted commands", text: `run "GOOD"`, wantSummary: "Batch finished: 1 of 1 commands succeeded.\n- good: success", wantHits: 1},
+		{name: "no commands", text: "run --parallel", wantSummary: "Invalid run command format.", wantPrivate: true},
+		{name: "unbalanced quote", text: `run "good`, wantSummary: "Invalid run command:", wantPrivate: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &Config{}
+			messenger := newFakeMessenger()
+			before := len(hits())
+			handleMessageEvent(context.Background(), messenger, messageEvent("U1", test.text), config, newConfigTaskStore(tasks), newBotState(config))
+
+			sent := messenger.sent()
+			last := sent[len(sent)-1]
+			if !strings.HasPrefix(last.Text, test.wantSummary) || (last.UserID != "") != test.wantPrivate {
+				t.Errorf("last reply = %+v, want %q (ephemeral %v)", last, test.wantSummary, test.wantPrivate)
+			}
+			if got := len(hits()) - before; got != test.wantHits {
+				t.Errorf("%d requests sent, want %d", got, test.wantHits)
+			}
+		})
+	}
+}
+
+// Batch entries go through argument parsing, so a task's argument specs are
+// enforced exactly as for a single command
+func TestBatchCommandArguments(t *testing.T) {
+	target, hits := newStubTarget(t)
+	tasks := map[string]Task{
+		"restart": {Command: "restart", URL: target.URL + "/ok/{service}", Method: "POST", Args: []ArgSpec{{Name: "service", Type: "enum", Values: []string{"api", "web"}}}},
+		"good":    {Command: "good", URL: target.URL + "/ok", Method: "GET"},
+	}
+
+	tests := []struct {
+		name        string
+		text        string
+		wantSummary string
+		wantHits    []string
+	}{
+		{
+			name: "valid arguments", text: `run "restart api" good`,
+			wantSummary: "Batch finished: 2 of 2 commands succeeded.\n- restart: success\n- good: success",
+			wantHits:    []string{"/ok/api", "/ok"},
+		},
+		{
+			name: "missing argument", text: "run restart good",
+			wantSummary: "Batch finished: 1 of 2 commands succeeded.\n- restart: skipped (Invalid arguments for 'restart': expected 1 arguments, got 0. Use: restart <service>)\n- good: success",
+			wantHits:    []string{"/ok"},
+		},
+		{
+			name: "invalid argument", text: `run "restart db"`,
+			wantSummary: "Batch finished: 0 of 1 commands succeeded.\n- restart: skipped (Invalid arguments for 'restart': <service> must be one of api, web, got 'db'. Use: restart <service>)",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &Config{DebounceMillis: -1}
+			messenger := newFakeMessenger()
+			before := len(hits())
+			handleMessageEvent(context.Background(), messenger, messageEvent("U1", test.text), config, newConfigTaskStore(tasks), newBotState(config))
+
+			sent := messenger.sent()
+			if last := sent[len(sent)-1]; last.Text != test.wantSummary {
+				t.Errorf("summary =\n%s\nwant\n%s", last.Text, test.wantSummary)
+			}
+			if got := hits()[before:]; strings.Join(got, ",") != strings.Join(test.wantHits, ",") {
+				t.Errorf("requests = %q, want %q", got, test.wantHits)
+			}
+		})
+	}
+}
+
+// Workflow entries in a batch take key=value inputs, checked against the
+// workflow's allowed_inputs before anything is dispatched
+func TestBatchCommandWorkflowInputs(t *testing.T) {
+	target, hits := newStubTarget(t)
+	tasks := map[string]Task{
+		"release": {Command: "release", GitHubWorkflow: &GitHubWorkflow{Repo: "acme/api", Workflow: "release.yml", Ref: "main", APIURL: target.URL + "/ok", AllowedInputs: []string{"env"}}},
+	}
+
+	tests := []struct {
+		name        string
+		text        string
+		wantSummary string
+		wantHits    int
+	}{
+		{name: "allowed input", text: `run "release env=prod"`, wantSummary: "Batch finished: 1 of 1 commands succeeded.\n- release: success", wantHits: 1},
+		{
+			name: "unknown input", text: `run "release version=2"`,
+			wantSummary: "Batch finished: 0 of 1 commands succeeded.\n- release: skipped (Invalid inputs for 'release': unknown inputs: version (allowed: env).)",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &Config{DebounceMillis: -1}
+			messenger := newFakeMessenger()
+			before := len(hits())
+			handleMessageEvent(context.Background(), messenger, messageEvent("U1", test.text), config, newConfigTaskStore(tasks), newBotState(config))
+
+			sent := messenger.sent()
+			if last := sent[len(sent)-1]; last.Text != test.wantSummary {
+				t.Errorf("summary =\n%s\nwant\n%s", last.Text, test.wantSummary)
+			}
+			if got := len(hits()) - before; got != test.wantHits {
+				t.Errorf("%d workflow dispatches, want %d", got, test.wantHits)
+			}
+		})
+	}
+}
+
+// The batch is acknowledged once and its summary replaces the runs' running messages
+func TestBatchAcknowledgedOnce(t *testing.T) {
+	target, _ := newStubTarget(t)
+	tasks := map[string]Task{
+		"good": {Command: "good", URL: target.URL + "/ok", Method: "GET"},
+		"bad":  {Command: "bad", URL: target.URL + "/fail", Method: "GET"},
+	}
+	config := &Config{UpdateRunningMessage: true}
+	fake := newFakeMessenger()
+	messenger := &fakeUpdater{fakeCards: &fakeCards{fakeMessenger: fake}}
+
+	handleMessageEvent(context.Background(), messenger, messageEvent("U1", "run good bad"), config, newConfigTaskStore(tasks), newBotState(config))
+
+	counts := map[string]int{}
+	for _, call := range fake.calls() {
+		if !strings.HasPrefix(call, "post ") {
+			counts[call]++
+		}
+	}
+	if counts["+eyes"] != 1 || counts["-eyes"] != 1 {
+		t.Errorf("calls = %q, want the eyes reaction added and removed once", fake.calls())
+	}
+	if counts["delete 1.1"] != 2 {
+		t.Errorf("calls = %q, want both running messages deleted", fake.calls())
+	}
+	if got := fake.currentReactions(); len(got) != 0 {
+		t.Errorf("reactions left = %v, want none", got)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Most responses kept by the task response cache
+const responseCacheSize = 256
+
+// A cached response of a read-only task
+type cachedResponse struct {
+	result  taskResult
+	fetched time.Time
+}
+
+// responseCache keeps recent GET task results keyed by the resolved request
+type responseCache struct {
+	entries *ttlCache[string, cachedResponse]
+}
+
+var taskResponseCache = &responseCache{entries: newTTLCache[string, cachedResponse](responseCacheSize)}
+
+// Cache key for a task's request: the resolved URL, headers and body, so
+// replies fetched with one user's {user_id} or {user_name} aren't served to
+// another. Maps marshal with sorted keys, so the key is stable.
+func responseCacheKey(task Task) string {
+	request, _ := json.Marshal(struct {
+		Method   string
+		URL      string
+		Headers  map[string]string
+		Body     string
+		FormData map[string]string
+	}{task.Method, task.URL, task.Headers, task.Body, task.FormData})
+	sum := sha256.Sum256(request)
+	return hex.EncodeToString(sum[:])
+}
+
+// Return the cached result for key if it is younger than maxAge
+func (c *responseCache) Get(key string, maxAge time.Duration) (taskResult, time.Duration, bool) {
+	entry, ok := c.entries.Get(key)
+	if !ok {
+		return taskResult{}, 0, false
+	}
+	age := time.Since(entry.fetched)
+	if age >= maxAge {
+		return taskResult{}, 0, false
+	}
+	return entry.result, age, true
+}
+
+// Store a result for maxAge, dropping expired or the oldest entries when the cache is full
+func (c *responseCache) Put(key string, result taskResult, maxAge time.Duration) {
+	c.entries.Set(key, cachedResponse{result: result, fetched: time.Now()}, maxAge)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResponseCache(t *testing.T) {
+	cache := &responseCache{entries: newTTLCache[string, cachedResponse](responseCacheSize)}
+	cache.Put("https://example.com/a", taskResult{Success: true, Body: []byte("a")}, time.Minute)
+	time.Sleep(time.Millisecond)
+
+	if result, age, ok := cache.Get("https://example.com/a", time.Minute); !ok || string(result.Body) != "a" || age <= 0 {
+		t.Errorf("fresh entry = %+v, %s, %v", result, age, ok)
+	}
+	if _, _, ok := cache.Get("https://example.com/b", time.Minute); ok {
+		t.Error("missing entry found")
+	}
+	if _, _, ok := cache.Get("https://example.com/a", time.Nanosecond); ok {
+		t.Error("entry older than maxAge returned")
+	}
+	if _, _, ok := cache.Get("https://example.com/a", time.Minute); !ok {
+		t.Error("entry dropped by a Get with a shorter maxAge")
+	}
+}
+
+// A full cache drops its oldest entry to make room
+func TestResponseCacheEvictsOldest(t *testing.T) {
+	cache := &responseCache{entries: newTTLCache[string, cachedResponse](responseCacheSize)}
+	for i := 0; i < responseCacheSize; i++ {
+		cache.Put(fmt.Sprintf("https://example.com/%d", i), taskResult{Success: true}, time.Hour)
+	}
+	cache.Put("https://example.com/new", taskResult{Success: true}, time.Hour)
+
+	if got := cache.entries.Len(); got != responseCacheSize {
+		t.Errorf("%d entries, want %d", got, responseCacheSize)
+	}
+	if _, _, ok := cache.Get("https://example.com/0", time.Hour); ok {
+		t.Error("oldest entry kept")
+	}
+	if _, _, ok := cache.Get("https://example.com/new", time.Hour); !ok {
+		t.Error("new entry missing")
+	}
+}
+
+func TestExecuteTaskCache(t *testing.T) {
+	var calls atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path == "/fail" {
+			http.Error(w, "broken", http.StatusInternalServerError)
+		}
+	}))
+	defer target.Close()
+
+	tests := []struct {
+		name       string
+		task       Task
+		wantCalls  int32
+		wantCached bool // Whether the second run is served from the cache
+	}{
+		{name: "GET cached", task: Task{Command: "health", URL: target.URL + "/health", Method: "GET", CacheSeconds: 60}, wantCalls: 1, wantCached: true},
+		{name: "no cache_seconds", task: Task{Command: "health", URL: target.URL + "/nocache", Method: "GET"}, wantCalls: 2},
+		{name: "POST never cached", task: Task{Command: "restart", URL: target.URL + "/restart", Method: "POST", CacheSeconds: 60}, wantCalls: 2},
+		{name: "failures not cached", task: Task{Command: "purge", URL: target.URL + "/fail", Method: "GET", CacheSeconds: 60}, wantCalls: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls.Store(0)
+			first := executeTask(context.Background(), &Config{}, test.task)
+			second := executeTask(context.Background(), &Config{}, test.task)
+			if got := calls.Load(); got != test.wantCalls {
+				t.Errorf("target called %d times, want %d", got, test.wantCalls)
+			}
+			if first.CachedAge != 0 {
+				t.Errorf("first run CachedAge = %s, want 0", first.CachedAge)
+			}
+			if cached := second.CachedAge > 0; cached != test.wantCached {
+				t.Errorf("second run cached = %v, want %v", cached, test.wantCached)
+			}
+		})
+	}
+}
+
+// Requests that differ only in their resolved headers, such as one carrying
+// {user_id}, are cached apart
+func TestExecuteTaskCacheKeyedByRequest(t *testing.T) {
+	var calls atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		fmt.Fprintf(w, "tickets of %s", r.Header.Get("X-User"))
+	}))
+	defer target.Close()
+
+	task := Task{Command: "tickets", URL: target.URL + "/tickets", Method: "GET", CacheSeconds: 60, Headers: map[string]string{"X-User": "{user_id}"}}
+	bodies := map[string]string{}
+	for _, user := range []string{"U1", "U2", "U1"} {
+		result := executeTask(context.Background(), &Config{}, applyUserVariables(task, map[string]string{"user_id": user}))
+		if body, seen := bodies[user]; seen && string(result.Body) != body {
+			t.Errorf("%s got %q, want %q", user, result.Body, body)
+		}
+		bodies[user] = string(result.Body)
+	}
+	if bodies["U2"] != "tickets of U2" {
+		t.Errorf("U2 got %q, want its own response", bodies["U2"])
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("target called %d times, want 2", got)
+	}
+}
+
+// A reply served from the cache says how old it is
+func TestHandleMessageCachedReply(t *testing.T) {
+	target, hits := newStubTarget(t)
+	config := &Config{DebounceMillis: -1}
+	store := newConfigTaskStore(map[string]Task{"health": {Command: "health", URL: target.URL + "/ok/cached", Method: "GET", CacheSeconds: 60}})
+	state := newBotState(config)
+
+	var replies []string
+	for i := 0; i < 2; i++ {
+		messenger := newFakeMessenger()
+		handleMessageEvent(context.Background(), messenger, messageEvent("U1", "health"), config, store, state)
+		replies = append(replies, messenger.results()...)
+	}
+	want := []string{"Task 'health' executed successfully.", "Task 'health' executed successfully.\n(cached 0s ago)"}
+	if fmt.Sprint(replies) != fmt.Sprint(want) || len(hits()) != 1 {
+		t.Errorf("replies = %q after %d requests, want %q after one", replies, len(hits()), want)
+	}
+}
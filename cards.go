@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// Result of a command shown as a card when rich_replies is on
+type resultCard struct {
+	Text     string // Plain-text reply, shown above the card
+	Command  string
+	User     string
+	Success  bool
+	Duration time.Duration
+	LinkURL  string // Target the card's button opens, such as the Jenkins build
+}
+
+// cardPoster is implemented by backends that can render result cards
+type cardPoster interface {
+	PostResultCard(channelID string, card resultCard) error
+}
+
+// Post a command result as a card when rich_replies is on and the backend
+// supports it, as plain text otherwise
+func postResult(messenger Messenger, config *Config, channelID string, card resultCard) error {
+	if poster, ok := messenger.(cardPoster); ok && config.RichReplies {
+		return poster.PostResultCard(channelID, card)
+	}
+	return messenger.PostMessage(channelID, card.Text)
+}
+
+// How long editing the running message into the result may take before the
+// result is posted as a new message instead
+const defaultUpdateTimeout = 5 * time.Second
+
+// messageUpdater is implemented by backends that can edit a posted message
+type messageUpdater interface {
+	PostMessageTS(channelID, text string) (string, error)
+	UpdateResult(ctx context.Context, channelID, timestamp string, card resultCard, rich bool) error
+}
+
+// Edit the running message at interimTS into the result, or post the result
+// as a new message when there is none or the edit fails or times out, so
+// the result is never lost
+func replaceResult(ctx context.Context, messenger Messenger, config *Config, channelID, interimTS string, timeout time.Duration, card resultCard, rich bool) error {
+	if updater, ok := messenger.(messageUpdater); ok && interimTS != "" {
+		if timeout <= 0 {
+			timeout = defaultUpdateTimeout
+		}
+		updateCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := updater.UpdateResult(updateCtx, channelID, interimTS, card, rich && config.RichReplies)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		log.Printf("Error updating running message %s, posting the result instead: %v", interimTS, err)
+	}
+	if !rich {
+		return messenger.PostMessage(channelID, card.Text)
+	}
+	return postResult(messenger, config, channelID, card)
+}
+
+func (m *slackMessenger) PostMessageTS(channelID, text string) (string, error) {
+	_, timestamp, err := m.api.PostMessage(channelID, m.messageOptions(slack.MsgOptionText(text, false))...)
+	return timestamp, warnOnAuthError(err)
+}
+
+func (m *slackMessenger) UpdateResult(ctx context.Context, channelID, timestamp string, card resultCard, rich bool) error {
+	options := []slack.MsgOption{slack.MsgOptionText(card.Text, false)}
+	if rich {
+		options = append(options, slack.MsgOptionAttachments(resultAttachment(card)))
+	}
+	_, _, _, err := m.api.UpdateMessageContext(ctx, channelID, timestamp, options...)
+	return warnOnAuthError(err)
+}
+
+func (m *slackMessenger) PostResultCard(channelID string, card resultCard) error {
+	return m.sendOrQueue(channelID, func() error {
+		_, _, err := m.api.PostMessage(channelID, m.messageOptions(
+			slack.MsgOptionText(card.Text, false),
+			slack.MsgOptionAttachments(resultAttachment(card)))...)
+		return err
+	})
+}
+
+// Build the colored attachment holding the card's Block Kit fields and link button
+func resultAttachment(card resultCard) slack.Attachment {
+	color := "good"
+	if !card.Success {
+		color = "danger"
+	}
+
+	fields := []*slack.TextBlockObject{
+		slack.NewTextBlockObject(slack.MarkdownType, "*Command*\n"+card.Command, false, false),
+		slack.NewTextBlockObject(slack.MarkdownType, "*User*\n<@"+card.User+">", false, false),
+		slack.NewTextBlockObject(slack.MarkdownType, "*Duration*\n"+card.Duration.Round(time.Millisecond).String(), false, false),
+		slack.NewTextBlockObject(slack.MarkdownType, "*Status*\n"+statusText(card.Success), false, false),
+	}
+	blocks := []slack.Block{slack.NewSectionBlock(nil, fields, nil)}
+	if card.LinkURL != "" {
+		button := slack.NewButtonBlockElement("open_target", "", slack.NewTextBlockObject(slack.PlainTextType, "Open", false, false))
+		button.URL = card.LinkURL
+		blocks = append(blocks, slack.NewActionBlock("result_actions", button))
+	}
+
+	return slack.Attachment{
+		Color:    color,
+		Fallback: fmt.Sprintf("%s: %s", card.Command, statusText(card.Success)),
+		Blocks:   slack.Blocks{BlockSet: blocks},
+	}
+}
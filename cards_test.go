@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// fakeCards is a fakeMessenger that can render result cards
+type fakeCards struct {
+	*fakeMessenger
+	cards []resultCard
+}
+
+func (c *fakeCards) PostResultCard(channelID string, card resultCard) error {
+	c.cards = append(c.cards, card)
+	return nil
+}
+
+func TestPostResult(t *testing.T) {
+	card := resultCard{Text: "Task 'health' executed successfully.", Command: "health", User: "U1", Success: true}
+
+	tests := []struct {
+		name      string
+		rich      bool
+		cards     bool
+		wantCards int
+		wantTexts int
+	}{
+		{name: "card", rich: true, cards: true, wantCards: 1},
+		{name: "rich_replies off", cards: true, wantTexts: 1},
+		{name: "backend without cards", rich: true, wantTexts: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backend := &fakeCards{fakeMessenger: newFakeMessenger()}
+			var messenger Messenger = backend.fakeMessenger
+			if test.cards {
+				messenger = backend
+			}
+			if err := postResult(messenger, &Config{RichReplies: test.rich}, "C1", card); err != nil {
+				t.Fatal(err)
+			}
+			if len(backend.cards) != test.wantCards || len(backend.sent()) != test.wantTexts {
+				t.Errorf("posted %d cards and %d texts, want %d and %d", len(backend.cards), len(backend.sent()), test.wantCards, test.wantTexts)
+			}
+			if test.wantTexts == 1 && backend.sent()[0].Text != card.Text {
+				t.Errorf("text = %q, want %q", backend.sent()[0].Text, card.Text)
+			}
+		})
+	}
+}
+
+func TestResultAttachment(t *testing.T) {
+	tests := []struct {
+		name         string
+		card         resultCard
+		wantColor    string
+		wantFallback string
+		wantFields   []string
+		wantButton   string
+	}{
+		{
+			name:         "success with link",
+			card:         resultCard{Command: "deploy api prod", User: "U1", Success: true, Duration: 1234567 * time.Microsecond, LinkURL: "https://ci.example.com/job/api/7/"},
+			wantColor:    "good",
+			wantFallback: "deploy api prod: success",
+			wantFields:   []string{"*Command*\ndeploy api prod", "*User*\n<@U1>", "*Duration*\n1.235s", "*Status*\nsuccess"},
+			wantButton:   "https://ci.example.com/job/api/7/",
+		},
+		{
+			name:         "failure without link",
+			card:         resultCard{Command: "broken", User: "U2", Duration: 20 * time.Millisecond},
+			wantColor:    "danger",
+			wantFallback: "broken: failure",
+			wantFields:   []string{"*Command*\nbroken", "*User*\n<@U2>", "*Duration*\n20ms", "*Status*\nfailure"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attachment := resultAttachment(test.card)
+			if attachment.Color != test.wantColor || attachment.Fallback != test.wantFallback {
+				t.Errorf("color %q, fallback %q, want %q, %q", attachment.Color, attachment.Fallback, test.wantColor, test.wantFallback)
+			}
+			blocks := attachment.Blocks.BlockSet
+			section := blocks[0].(*slack.SectionBlock)
+			for i, want := range test.wantFields {
+				if got := section.Fields[i].Text; got != want {
+					t.Errorf("field %d = %q, want %q", i, got, want)
+				}
+			}
+			var button string
+			if len(blocks) > 1 {
+				button = blocks[1].(*slack.ActionBlock).Elements.ElementSet[0].(*slack.ButtonBlockElement).URL
+			}
+			if button != test.wantButton {
+				t.Errorf("button URL = %q, want %q", button, test.wantButton)
+			}
+		})
+	}
+}
+
+// With rich_replies on, a finished task posts a card and rejections stay plain text
+func TestHandleMessageRichReplies(t *testing.T) {
+	target, _ := newStubTarget(t)
+	tasks := map[string]Task{
+		"health": {Command: "health", URL: target.URL + "/ok", Method: "GET", LinkURL: "https://status.example.com"},
+		"purge":  {Command: "purge", URL: target.URL + "/ok", Method: "POST", AllowedUsers: []string{"UOPS"}},
+	}
+	config := &Config{RichReplies: true}
+
+	backend := &fakeCards{fakeMessenger: newFakeMessenger()}
+	handleMessageEvent(context.Background(), backend, messageEvent("U1", "health"), config, newConfigTaskStore(tasks), newBotState(config))
+	if len(backend.cards) != 1 {
+		t.Fatalf("posted cards %+v, want one", backend.cards)
+	}
+	card := backend.cards[0]
+	if card.Text != "Task 'health' executed successfully." || card.Command != "health" || card.User != "U1" || !card.Success || card.LinkURL != "https://status.example.com" {
+		t.Errorf("card = %+v", card)
+	}
+
+	backend = &fakeCards{fakeMessenger: newFakeMessenger()}
+	handleMessageEvent(context.Background(), backend, messageEvent("U1", "purge"), config, newConfigTaskStore(tasks), newBotState(config))
+	if sent := backend.sent(); len(backend.cards) != 0 || len(sent) != 1 || sent[0].Text != "You are not allowed to run 'purge'." {
+		t.Errorf("rejection posted cards %+v and messages %+v, want only the text", backend.cards, sent)
+	}
+}
+
+// fakeUpdater is a fakeCards that can edit posted messages. The edit fails
+// with err, or waits for the context when block is set.
+type fakeUpdater struct {
+	*fakeCards
+	err     error
+	block   bool
+	updated []resultCard
+	rich    []bool
+}
+
+func (u *fakeUpdater) PostMessageTS(channelID, text string) (string, error) {
+	return "1.1", u.PostMessage(channelID, text)
+}
+
+func (u *fakeUpdater) UpdateResult(ctx context.Context, channelID, timestamp string, card resultCard, rich bool) error {
+	if u.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if u.err != nil {
+		return u.err
+	}
+	u.updated = append(u.updated, card)
+	u.rich = append(u.rich, rich)
+	return nil
+}
+
+// The result replaces the running message, falling back to a new post
+func TestReplaceResult(t *testing.T) {
+	card := resultCard{Text: "Task 'health' executed successfully.", Command: "health", Success: true}
+
+	tests := []struct {
+		name        string
+		interimTS   string
+		richReplies bool
+		rich        bool
+		err         error
+		block       bool
+		wantUpdated bool
+		wantRich    bool // Whether the edit carries the card
+		wantCards   int
+		wantTexts   int
+	}{
+		{name: "edited in place", interimTS: "1.1", rich: true, wantUpdated: true},
+		{name: "edited into a card", interimTS: "1.1", richReplies: true, rich: true, wantUpdated: true, wantRich: true},
+		{name: "plain reply never a card", interimTS: "1.1", richReplies: true, wantUpdated: true},
+		{name: "no running message", richReplies: true, rich: true, wantCards: 1},
+		{name: "edit fails", interimTS: "1.1", err: errors.New("message_not_found"), rich: true, wantTexts: 1},
+		{name: "edit times out", interimTS: "1.1", block: true, richReplies: true, rich: true, wantCards: 1},
+		{name: "edit fails, plain reply", interimTS: "1.1", err: errors.New("message_not_found"), richReplies: true, wantTexts: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messenger := &fakeUpdater{fakeCards: &fakeCards{fakeMessenger: newFakeMessenger()}, err: test.err, block: test.block}
+			err := replaceResult(context.Background(), messenger, &Config{RichReplies: test.richReplies}, "C1", test.interimTS, 10*time.Millisecond, card, test.rich)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if updated := len(messenger.updated) == 1; updated != test.wantUpdated {
+				t.Fatalf("updated = %v, want %v", updated, test.wantUpdated)
+			}
+			if test.wantUpdated && messenger.rich[0] != test.wantRich {
+				t.Errorf("edit rich = %v, want %v", messenger.rich[0], test.wantRich)
+			}
+			if len(messenger.cards) != test.wantCards || len(messenger.sent()) != test.wantTexts {
+				t.Errorf("posted %d cards and %d texts, want %d and %d", len(messenger.cards), len(messenger.sent()), test.wantCards, test.wantTexts)
+			}
+		})
+	}
+}
+
+// With update_running_message the running message becomes the result
+func TestHandleMessageUpdatesRunningMessage(t *testing.T) {
+	target, _ := newStubTarget(t)
+	tasks := map[string]Task{"health": {Command: "health", URL: target.URL + "/ok", Method: "GET"}}
+
+	for _, update := range []bool{false, true} {
+		config := &Config{UpdateRunningMessage: update}
+		messenger := &fakeUpdater{fakeCards: &fakeCards{fakeMessenger: newFakeMessenger()}}
+		handleMessageEvent(context.Background(), messenger, messageEvent("U1", "health"), config, newConfigTaskStore(tasks), newBotState(config))
+
+		texts := messenger.texts()
+		wantTexts := 2
+		if update {
+			wantTexts = 1
+		}
+		if len(texts) != wantTexts || !strings.HasPrefix(texts[0], "Running 'health'") {
+			t.Errorf("update %v: posted %q", update, texts)
+		}
+		if got := len(messenger.updated); (got == 1) != update || (update && messenger.updated[0].Text != "Task 'health' executed successfully.") {
+			t.Errorf("update %v: edited %+v", update, messenger.updated)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import "sync"
+
+// concurrencyLimiter keeps a semaphore per command to cap overlapping runs
+type concurrencyLimiter struct {
+	mu         sync.Mutex
+	semaphores map[string]chan struct{}
+}
+
+func newConcurrencyLimiter() *concurrencyLimiter {
+	return &concurrencyLimiter{semaphores: make(map[string]chan struct{})}
+}
+
+// Take a slot for the command without waiting. The returned release function
+// must be called when the run finishes. A limit of 0 means unlimited.
+func (l *concurrencyLimiter) TryAcquire(command string, limit int) (func(), bool) {
+	if limit <= 0 {
+		return func() {}, true
+	}
+
+	l.mu.Lock()
+	sem, ok := l.semaphores[command]
+	if !ok || cap(sem) != limit {
+		sem = make(chan struct{}, limit)
+		l.semaphores[command] = sem
+	}
+	l.mu.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimiterTryAcquire(t *testing.T) {
+	tests := []struct {
+		name        string
+		limit       int
+		attempts    int
+		wantGranted int
+	}{
+		{name: "single slot", limit: 1, attempts: 3, wantGranted: 1},
+		{name: "two slots", limit: 2, attempts: 3, wantGranted: 2},
+		{name: "unlimited", limit: 0, attempts: 5, wantGranted: 5},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limiter := newConcurrencyLimiter()
+			var releases []func()
+			for i := 0; i < test.attempts; i++ {
+				if release, ok := limiter.TryAcquire("reindex", test.limit); ok {
+					releases = append(releases, release)
+				}
+			}
+			if len(releases) != test.wantGranted {
+				t.Errorf("%d slots granted, want %d", len(releases), test.wantGranted)
+			}
+
+			// Releasing a slot lets the next run in; other commands are independent
+			for _, release := range releases {
+				release()
+			}
+			if _, ok := limiter.TryAcquire("reindex", test.limit); !ok {
+				t.Error("slot not freed by release")
+			}
+			if _, ok := limiter.TryAcquire("restart", test.limit); !ok {
+				t.Error("a different command was limited")
+			}
+		})
+	}
+}
+
+// A second invocation while the first is still running is rejected without reaching the target
+func TestHandleMessageConcurrentRunRejected(t *testing.T) {
+	arrived := make(chan struct{}, 2)
+	release := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+	}))
+	defer target.Close()
+
+	config := &Config{}
+	store := newConfigTaskStore(map[string]Task{"reindex": {Command: "reindex", URL: target.URL, Method: "POST", MaxConcurrent: 1}})
+	state := newBotState(config)
+	first := newFakeMessenger()
+	done := make(chan struct{})
+	go func() {
+		handleMessageEvent(context.Background(), first, messageEvent("U1", "reindex"), config, store, state)
+		close(done)
+	}()
+	<-arrived
+
+	second := newFakeMessenger()
+	handleMessageEvent(context.Background(), second, messageEvent("U2", "reindex"), config, store, state)
+	sent := second.sent()
+	if len(sent) != 1 || sent[0].UserID != "U2" || sent[0].Text != "'reindex' is already running, please try again later." {
+		t.Errorf("second invocation sent %+v, want an ephemeral already-running reply", sent)
+	}
+	select {
+	case <-arrived:
+		t.Error("second invocation reached the target")
+	default:
+	}
+
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("first invocation never finished")
+	}
+	if replies := first.results(); len(replies) != 1 || replies[0] != "Task 'reindex' executed successfully." {
+		t.Errorf("first invocation replied %q", replies)
+	}
+}
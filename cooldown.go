@@ -0,0 +1,44 @@
+package main
+
+import (
+	"time"
+)
+
+// Commands and deploy targets whose last run is remembered
+const cooldownCacheSize = 4096
+
+// cooldownTracker remembers when each command (or deploy target) last ran
+type cooldownTracker struct {
+	lastRun *ttlCache[string, time.Time]
+}
+
+func newCooldownTracker() *cooldownTracker {
+	return &cooldownTracker{lastRun: newTTLCache[string, time.Time](cooldownCacheSize)}
+}
+
+// Report whether key may run without reserving the run, so a task can be
+// rejected early and only reserve its run once nothing else stops it.
+// When rejected, returns how long ago the previous run started.
+func (c *cooldownTracker) Check(key string, window time.Duration) (time.Duration, bool) {
+	if window <= 0 {
+		return 0, true
+	}
+	if last, ok := c.lastRun.Get(key); ok && time.Since(last) < window {
+		return time.Since(last), false
+	}
+	return 0, true
+}
+
+// Reserve a run for key unless it already ran within the window.
+// When rejected, returns how long ago the previous run started.
+func (c *cooldownTracker) Allow(key string, window time.Duration) (time.Duration, bool) {
+	if window <= 0 {
+		return 0, true
+	}
+
+	now := time.Now()
+	if last, ok := c.lastRun.SetIfAbsent(key, now, window); ok {
+		return now.Sub(last), false
+	}
+	return 0, true
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCooldownTrackerAllow(t *testing.T) {
+	tests := []struct {
+		name   string
+		window time.Duration
+		wait   time.Duration
+		want   bool // whether the second run is allowed
+	}{
+		{name: "within cooldown", window: time.Minute, want: false},
+		{name: "after cooldown", window: 20 * time.Millisecond, wait: 40 * time.Millisecond, want: true},
+		{name: "no cooldown", window: 0, want: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cooldowns := newCooldownTracker()
+			if _, ok := cooldowns.Allow("deploy api prod", test.window); !ok {
+				t.Fatal("first run rejected")
+			}
+			time.Sleep(test.wait)
+			elapsed, ok := cooldowns.Allow("deploy api prod", test.window)
+			if ok != test.want {
+				t.Errorf("second run allowed = %v, want %v", ok, test.want)
+			}
+			if !ok && (elapsed < 0 || elapsed >= test.window) {
+				t.Errorf("elapsed = %v, want within the %v window", elapsed, test.window)
+			}
+			// Other keys are tracked separately
+			if _, ok := cooldowns.Allow("deploy api staging", test.window); !ok {
+				t.Error("a different target was rejected")
+			}
+		})
+	}
+}
+
+// Concurrent runs of the same command reserve the slot exactly once
+func TestCooldownTrackerConcurrent(t *testing.T) {
+	cooldowns := newCooldownTracker()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := cooldowns.Allow("restart", time.Minute); ok {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 1 {
+		t.Errorf("%d concurrent runs allowed, want 1", allowed)
+	}
+}
+
+// A second run from Slack inside the window is rejected without calling the target
+func TestHandleMessageCooldown(t *testing.T) {
+	target, hits := newStubTarget(t)
+	messenger := newFakeMessenger()
+	config := &Config{}
+	store := newConfigTaskStore(map[string]Task{
+		"restart": {Command: "restart", URL: target.URL + "/ok", Method: "POST", CooldownSeconds: 60},
+	})
+	state := newBotState(config)
+
+	handleMessageEvent(context.Background(), messenger, messageEvent("U1", "restart"), config, store, state)
+	handleMessageEvent(context.Background(), messenger, messageEvent("U2", "restart"), config, store, state)
+
+	if len(hits()) != 1 {
+		t.Errorf("target called %d times, want 1", len(hits()))
+	}
+	replies := messenger.results()
+	if len(replies) != 2 || !strings.Contains(replies[1], "'restart' was last run 0s ago, please wait") {
+		t.Errorf("replies = %q, want the second run rejected", replies)
+	}
+}
+
+// Deploy cooldowns apply per service and env, not to every deploy
+func TestHandleMessageDeployCooldown(t *testing.T) {
+	target, hits := newStubTarget(t)
+	messenger := newFakeMessenger()
+	config := &Config{DebounceMillis: -1, Jenkins: JenkinsConfig{URLFormat: target.URL + "/ok/{service-name}/{env}", CooldownSeconds: 60}}
+	store := newConfigTaskStore(nil)
+	state := newBotState(config)
+
+	for _, text := range []string{"deploy api prod", "deploy API prod", "deploy api staging"} {
+		handleMessageEvent(context.Background(), messenger, messageEvent("U1", text), config, store, state)
+	}
+
+	if got := strings.Join(hits(), ","); got != "/ok/api/prod,/ok/api/staging" {
+		t.Errorf("Jenkins calls = %s, want one per service and env", got)
+	}
+	replies := messenger.results()
+	if len(replies) != 3 || !strings.Contains(replies[1], "'deploy api prod' was last run") {
+		t.Errorf("replies = %q, want the repeated prod deploy rejected", replies)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// Window used when debounce_ms is not configured
+const defaultDebounceWindow = 2 * time.Second
+
+// Most recent messages remembered
+const debounceCacheSize = 1024
+
+// debouncer drops a message identical to one the same user sent in the same
+// channel moments before, whether Slack redelivered it or the user double-sent
+type debouncer struct {
+	seen *ttlCache[string, time.Time]
+}
+
+func newDebouncer() *debouncer {
+	return &debouncer{seen: newTTLCache[string, time.Time](debounceCacheSize)}
+}
+
+// Report whether the message should be handled, recording it if so
+func (d *debouncer) Allow(msg incomingMessage, window time.Duration) bool {
+	if window <= 0 {
+		return true
+	}
+	key := msg.UserID + "|" + msg.ChannelID + "|" + strings.ToLower(strings.Join(strings.Fields(msg.Text), " "))
+	_, seen := d.seen.SetIfAbsent(key, time.Now(), window)
+	return !seen
+}
+
+// Debounce window from debounce_ms; negative values turn debouncing off
+func debounceWindow(config *Config) time.Duration {
+	if config.DebounceMillis == 0 {
+		return defaultDebounceWindow
+	}
+	return time.Duration(config.DebounceMillis) * time.Millisecond
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebouncer(t *testing.T) {
+	first := incomingMessage{Text: "deploy api", ChannelID: "C1", UserID: "U1"}
+
+	tests := []struct {
+		name   string
+		second incomingMessage
+		window time.Duration
+		want   bool
+	}{
+		{name: "identical", second: first, window: time.Minute, want: false},
+		{name: "case and spacing ignored", second: incomingMessage{Text: "  Deploy   API ", ChannelID: "C1", UserID: "U1"}, window: time.Minute, want: false},
+		{name: "other text", second: incomingMessage{Text: "deploy web", ChannelID: "C1", UserID: "U1"}, window: time.Minute, want: true},
+		{name: "other user", second: incomingMessage{Text: "deploy api", ChannelID: "C1", UserID: "U2"}, window: time.Minute, want: true},
+		{name: "other channel", second: incomingMessage{Text: "deploy api", ChannelID: "C2", UserID: "U1"}, window: time.Minute, want: true},
+		{name: "disabled", second: first, window: 0, want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := newDebouncer()
+			if !d.Allow(first, test.window) {
+				t.Fatal("first message dropped")
+			}
+			if got := d.Allow(test.second, test.window); got != test.want {
+				t.Errorf("second message allowed = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDebounceAfterWindow(t *testing.T) {
+	d := newDebouncer()
+	msg := incomingMessage{Text: "status", ChannelID: "C1", UserID: "U1"}
+	d.Allow(msg, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if !d.Allow(msg, time.Millisecond) {
+		t.Error("message dropped after the window passed")
+	}
+}
+
+func TestDebounceWindow(t *testing.T) {
+	tests := []struct {
+		millis int
+		want   time.Duration
+	}{
+		{millis: 0, want: defaultDebounceWindow},
+		{millis: 500, want: 500 * time.Millisecond},
+		{millis: -1, want: -time.Millisecond},
+	}
+
+	for _, test := range tests {
+		if got := debounceWindow(&Config{DebounceMillis: test.millis}); got != test.want {
+			t.Errorf("debounceWindow(%d) = %s, want %s", test.millis, got, test.want)
+		}
+	}
+}
+
+// A double-sent command runs once; debounce_ms below zero runs both
+func TestHandleMessageDebounced(t *testing.T) {
+	target, hits := newStubTarget(t)
+	tasks := map[string]Task{"restart": {Command: "restart", URL: target.URL + "/ok", Method: "POST"}}
+
+	for _, test := range []struct {
+		millis   int
+		wantHits int
+	}{{millis: 0, wantHits: 1}, {millis: -1, wantHits: 2}} {
+		config := &Config{DebounceMillis: test.millis}
+		state := newBotState(config)
+		before := len(hits())
+		for i := 0; i < 2; i++ {
+			handleMessageEvent(context.Background(), newFakeMessenger(), messageEvent("U1", "restart"), config, newConfigTaskStore(tasks), state)
+		}
+		if got := len(hits()) - before; got != test.wantHits {
+			t.Errorf("debounce_ms %d: target called %d times, want %d", test.millis, got, test.wantHits)
+		}
+	}
+}
+
+// /trigger and workflow calls share one user ID, so repeats are deliberate
+// and must all run rather than be dropped as a double-send
+func TestDispatchNotDebounced(t *testing.T) {
+	target, hits := newStubTarget(t)
+	config := &Config{
+		TriggerToken: "trigger-token",
+		AckReaction:  "none",
+		Tasks:        map[string]Task{"health": {Command: "health", URL: target.URL + "/ok", Method: "GET"}},
+	}
+	server, _ := newTriggerServer(t, config)
+
+	before := len(hits())
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("POST", server.URL+"/trigger/health", nil)
+		req.Header.Set("Authorization", "Bearer trigger-token")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("call %d: status = %d, want 200", i+1, resp.StatusCode)
+		}
+	}
+	if got := len(hits()) - before; got != 2 {
+		t.Errorf("target called %d times, want 2", got)
+	}
+}
+
+// A repeated slash command is refused with 429 before it is acknowledged
+func TestSlashCommandDebounced(t *testing.T) {
+	target, _ := newStubTarget(t)
+	responseURL, _ := newResponseURLServer(t)
+	config := &Config{
+		SlackSigningSecret: testSigningSecret,
+		AckReaction:        "none",
+		Tasks:              map[string]Task{"health": {Command: "health", URL: target.URL + "/ok", Method: "GET"}},
+	}
+	mux := http.NewServeMux()
+	registerSlashCommandRoutes(context.Background(), mux, config, newConfigTaskStore(config.Tasks), newBotState(config))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	form := url.Values{"command": {"/bot"}, "text": {"health"}, "user_id": {"U1"}, "channel_id": {"C1"}, "response_url": {responseURL.URL}}.Encode()
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		req, _ := http.NewRequest("POST", server.URL+"/slack/commands", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		signSlackRequest(req, form, testSigningSecret, time.Now())
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("call %d: status = %d, want %d", i+1, resp.StatusCode, want)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Header and query parameter names whose values describe never shows
+var secretNamePattern = regexp.MustCompile(`(?i)(auth|token|secret|password|passwd|api[_-]?key|cookie|signature)`)
+
+// Handle "describe <command>": show the request a task or deploy would send
+// without executing it. Secrets are redacted and the reply is ephemeral.
+func handleDescribeCommand(messenger Messenger, msg incomingMessage, config *Config, store TaskStore, args string) {
+	var response string
+	if fields := strings.Fields(args); len(fields) > 0 && strings.ToLower(fields[0]) == "deploy" {
+		response = describeDeploy(config, msg.UserID, fields[1:])
+	} else {
+		command := strings.ToLower(args)
+		task, exists, err := store.GetTask(command)
+		if err != nil {
+			log.Printf("Error looking up task for command '%s': %v", command, err)
+		}
+		switch {
+		case !exists:
+			response = localize(config, msg.UserID, "unknown_command")
+		case !canDescribe(config, task.AllowedUsers, msg.UserID):
+			log.Printf("User %s is not allowed to describe '%s'", msg.UserID, command)
+			response = localize(config, msg.UserID, "describe_denied", "command", command)
+		default:
+			response = localize(config, msg.UserID, "would_send", "command", command) + "\n" + describeTask(config, task)
+		}
+	}
+	if err := messenger.PostEphemeral(msg.ChannelID, msg.UserID, response); err != nil {
+		log.Printf("Error sending message to Slack: %v", err)
+	}
+}
+
+// describe shows internal URLs and headers, so unlike running a command an
+// empty allowlist is not enough: only admins and users the allowlist names
+// explicitly may see them
+func canDescribe(config *Config, allowedUsers []string, userID string) bool {
+	return isAdminUser(config, userID) || (len(allowedUsers) > 0 && isUserAllowed(config, allowedUsers, userID))
+}
+
+func describeDeploy(config *Config, userID string, args []string) string {
+	if len(args) != 2 && len(args) != 3 {
+		return localize(config, userID, "describe_usage")
+	}
+	if !canDescribe(config, config.Jenkins.AllowedUsers, userID) {
+		log.Printf("User %s is not allowed to describe 'deploy'", userID)
+		return localize(config, userID, "describe_denied", "command", "deploy")
+	}
+	var branch string
+	if len(args) == 3 {
+		branch = args[2]
+	}
+	jenkins := config.Jenkins.forEnv(args[1])
+	var b strings.Builder
+	b.WriteString(localize(config, userID, "would_send", "command", "deploy "+strings.Join(args, " ")) + "\n")
+	jenkinsURL, err := buildJenkinsURL(config.Jenkins.URLFormat, args[0], args[1], branch)
+	if err != nil {
+		return localize(config, userID, "invalid_deploy", "error", err.Error())
+	}
+	fmt.Fprintf(&b, "POST %s\n", redactURL(jenkinsURL))
+	if jenkins.User != "" {
+		fmt.Fprintf(&b, "Authorization: Basic *** (user %s)\n", jenkins.User)
+	}
+	return b.String()
+}
+
+// Describe the request of a task, each target of a multi-target task or
+// each step of a chain
+func describeTask(config *Config, task Task) string {
+	var b strings.Builder
+	switch {
+	case len(task.Steps) > 0:
+		for i, step := range task.Steps {
+			fmt.Fprintf(&b, "Step %d:\n%s", i+1, describeTask(config, step))
+		}
+		return b.String()
+	case task.GitHubWorkflow != nil:
+		workflow := task.GitHubWorkflow
+		apiURL := workflow.APIURL
+		if apiURL == "" {
+			apiURL = defaultGitHubAPIURL
+		}
+		fmt.Fprintf(&b, "POST %s/repos/%s/actions/workflows/%s/dispatches\n", strings.TrimSuffix(apiURL, "/"), workflow.Repo, url.PathEscape(workflow.Workflow))
+		b.WriteString("Authorization: Bearer ***\n")
+		fmt.Fprintf(&b, "Body: {\"ref\": %q} plus inputs %s\n", workflow.Ref, strings.Join(workflow.AllowedInputs, ", "))
+		return b.String()
+	}
+
+	method := "GET"
+	if task.Method == "POST" {
+		method = "POST"
+	}
+	targets := task.URLs
+	if len(targets) == 0 {
+		targets = []string{task.URL}
+	}
+	for _, target := range targets {
+		resolved, err := expandEnvRefs(target, config.StrictEnv)
+		if err != nil {
+			resolved = fmt.Sprintf("%s (%v)", target, err)
+		}
+		fmt.Fprintf(&b, "%s %s\n", method, redactURL(resolved))
+	}
+
+	headers := make([]string, 0, len(task.Headers)+1)
+	if method == "POST" && task.User != "" && task.Token != "" {
+		headers = append(headers, fmt.Sprintf("Authorization: Basic *** (user %s)", task.User))
+	}
+	for key, value := range task.Headers {
+		headers = append(headers, fmt.Sprintf("%s: %s", key, redactValue(key, value)))
+	}
+	sort.Strings(headers)
+	for _, header := range headers {
+		b.WriteString(header + "\n")
+	}
+
+	if method == "POST" {
+		if len(task.FormData) > 0 {
+			keys := make([]string, 0, len(task.FormData))
+			for key := range task.FormData {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			b.WriteString("Form data:\n")
+			for _, key := range keys {
+				fmt.Fprintf(&b, "  %s=%s\n", key, redactValue(key, task.FormData[key]))
+			}
+		} else if task.Body != "" {
+			fmt.Fprintf(&b, "Body: %s\n", redactJSON(task.Body))
+		}
+	}
+	return b.String()
+}
+
+// Hide URL passwords and query parameters that look like credentials
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	query := u.Query()
+	for key := range query {
+		if secretNamePattern.MatchString(key) {
+			query.Set(key, "redacted")
+		}
+	}
+	if len(query) > 0 {
+		u.RawQuery = query.Encode()
+	}
+	return u.Redacted()
+}
+
+func redactValue(name, value string) string {
+	if secretNamePattern.MatchString(name) {
+		return "***"
+	}
+	return value
+}
+
+// String members named like secrets, for bodies that aren't valid JSON,
+// such as templates with unquoted placeholders
+var secretJSONMemberPattern = regexp.MustCompile(`(?i)("[^"]*(?:auth|token|secret|password|passwd|api[_-]?key|cookie|signature)[^"]*"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+// Mask the values of JSON members named like secrets, at any depth
+func redactJSON(body string) string {
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return secretJSONMemberPattern.ReplaceAllString(body, `$1"***"`)
+	}
+	var redact func(v interface{}) interface{}
+	redact = func(v interface{}) interface{} {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for key, value := range v {
+				if secretNamePattern.MatchString(key) {
+					v[key] = "***"
+				} else {
+					v[key] = redact(value)
+				}
+			}
+		case []interface{}:
+			for i, value := range v {
+				v[i] = redact(value)
+			}
+		}
+		return v
+	}
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(redact(doc)); err != nil {
+		return secretJSONMemberPattern.ReplaceAllString(body, `$1"***"`)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Copy of a task with its credentials masked: tokens and secrets, and
+// headers and form fields named like secrets, in its steps and precondition too
+func redactTask(task Task) Task {
+	mask := func(value string) string {
+		if value == "" {
+			return ""
+		}
+		return "***"
+	}
+	redactFields := func(fields map[string]string) map[string]string {
+		if len(fields) == 0 {
+			return fields
+		}
+		redacted := make(map[string]string, len(fields))
+		for key, value := range fields {
+			redacted[key] = redactValue(key, value)
+		}
+		return redacted
+	}
+
+	task.Token = mask(task.Token)
+	task.SigningSecret = mask(task.SigningSecret)
+	task.OnFailurePagerDuty = mask(task.OnFailurePagerDuty)
+	task.Headers = redactFields(task.Headers)
+	task.FormData = redactFields(task.FormData)
+	if task.GitHubWorkflow != nil {
+		workflow := *task.GitHubWorkflow
+		workflow.Token = mask(workflow.Token)
+		task.GitHubWorkflow = &workflow
+	}
+	if task.Precondition != nil {
+		pre := *task.Precondition
+		pre.Headers = redactFields(pre.Headers)
+		task.Precondition = &pre
+	}
+	if len(task.Steps) > 0 {
+		steps := make([]Task, len(task.Steps))
+		for i, step := range task.Steps {
+			steps[i] = redactTask(step)
+		}
+		task.Steps = steps
+	}
+	return task
+}
+
+// Format "list verbose": each command with its method and target hosts,
+// never full URLs or credentials
+func formatVerboseTaskList(config *Config, userID string, tasks map[string]Task) string {
+	commands := make([]string, 0, len(tasks))
+	for command := range tasks {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+
+	var b strings.Builder
+	b.WriteString(localize(config, userID, "commands_header") + "\n")
+	for _, command := range commands {
+		fmt.Fprintf(&b, "- %s: %s\n", command, taskTargetSummary(tasks[command]))
+	}
+	return b.String()
+}
+
+func taskTargetSummary(task Task) string {
+	switch {
+	case len(task.Steps) > 0:
+		parts := make([]string, 0, len(task.Steps))
+		for _, step := range task.Steps {
+			parts = append(parts, taskTargetSummary(step))
+		}
+		return fmt.Sprintf("%d steps (%s)", len(task.Steps), strings.Join(parts, ", "))
+	case task.GitHubWorkflow != nil:
+		apiURL := task.GitHubWorkflow.APIURL
+		if apiURL == "" {
+			apiURL = defaultGitHubAPIURL
+		}
+		return "POST " + targetHost(apiURL) + " (GitHub workflow)"
+	}
+
+	method := "GET"
+	if task.Method == "POST" {
+		method = "POST"
+	}
+	targets := task.URLs
+	if len(targets) == 0 {
+		targets = []string{task.URL}
+	}
+	hosts := make([]string, 0, len(targets))
+	for _, target := range targets {
+		hosts = append(hosts, targetHost(target))
+	}
+	return method + " " + strings.Join(hosts, ", ")
+}
+
+// Host of a task URL after {env:NAME} expansion, or "?" when it can't be parsed
+func targetHost(raw string) string {
+	expanded, _ := expandEnvRefs(raw, false)
+	u, err := url.Parse(expanded)
+	if err != nil || u.Host == "" {
+		return "?"
+	}
+	return u.Host
+}
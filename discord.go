@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// DiscordConfig structure for the Discord backend
+type DiscordConfig struct {
+	BotToken      string `json:"bot_token"`                // Token of the Discord bot user
+	CommandPrefix string `json:"command_prefix,omitempty"` // Prefix that marks a message as a command (default "!")
+}
+
+const (
+	defaultDiscordPrefix = "!"
+	discordMessageLimit  = 2000 // Longest message Discord accepts, in characters
+)
+
+var discordMentionPattern = regexp.MustCompile(`^<@!?[0-9]+>\s*`)
+
+// Slack reaction names used for acknowledgements and their Unicode equivalents for Discord
+var discordReactions = map[string]string{
+	"eyes":                   "👀",
+	"white_check_mark":       "✅",
+	"hourglass":              "⌛",
+	"hourglass_flowing_sand": "⏳",
+	"robot_face":             "🤖",
+}
+
+// discordMessenger posts replies through a Discord gateway session
+type discordMessenger struct {
+	session *discordgo.Session
+	users   *userCache
+	config  *atomic.Pointer[Config] // Current configuration, for the notices' locale
+}
+
+func (m *discordMessenger) PostMessage(channelID, text string) error {
+	_, err := m.session.ChannelMessageSend(channelID, formatDiscordText(text))
+	return err
+}
+
+// Discord bots have no ephemeral channel messages, so private replies go to
+// the user as a direct message. When that fails, for instance because the user
+// blocks DMs, the channel only gets a notice.
+func (m *discordMessenger) PostEphemeral(channelID, userID, text string) error {
+	dm, err := m.session.UserChannelCreate(userID)
+	if err == nil {
+		err = m.PostMessage(dm.ID, text)
+	}
+	if err != nil {
+		log.Printf("Error sending a private reply to %s on Discord: %v", userID, err)
+		config := m.config.Load()
+		return m.PostMessage(channelID, localize(config, userID, "discord_private", "user", userID))
+	}
+	return nil
+}
+
+// Reactions without a known Unicode equivalent are skipped
+func (m *discordMessenger) AddReaction(channelID, timestamp, emoji string) error {
+	if unicode, ok := discordReactions[emoji]; ok {
+		return m.session.MessageReactionAdd(channelID, timestamp, unicode)
+	}
+	return nil
+}
+
+func (m *discordMessenger) RemoveReaction(channelID, timestamp, emoji string) error {
+	if unicode, ok := discordReactions[emoji]; ok {
+		return m.session.MessageReactionRemove(channelID, timestamp, unicode, "@me")
+	}
+	return nil
+}
+
+func (m *discordMessenger) DeleteMessage(channelID, timestamp string) error {
+	return m.session.ChannelMessageDelete(channelID, timestamp)
+}
+
+// Bots can't read user emails on Discord, so only the name is filled in
+func (m *discordMessenger) LookupUser(userID string) (chatUser, error) {
+	return m.users.get(userID, func(userID string) (chatUser, error) {
+		user, err := m.session.User(userID)
+		if err != nil {
+			return chatUser{}, err
+		}
+		return chatUser{Name: user.Username}, nil
+	})
+}
+
+// Convert a Slack-formatted reply into Discord markdown
+func formatDiscordText(text string) string {
+	// Slack bold is a single asterisk, Discord's is two
+	text = slackBoldPattern.ReplaceAllString(text, "**$1**")
+	if utf8.RuneCountInString(text) > discordMessageLimit {
+		text = string([]rune(text)[:discordMessageLimit-3]) + "..."
+	}
+	return text
+}
+
+var slackBoldPattern = regexp.MustCompile(`\*([^*\n]+)\*`)
+
+// Extract the command from a Discord message.
+// Messages must start with the command prefix, optionally after a mention of the bot.
+func parseDiscordText(content, prefix string) (string, bool) {
+	if prefix == "" {
+		prefix = defaultDiscordPrefix
+	}
+	text := discordMentionPattern.ReplaceAllString(strings.TrimSpace(content), "")
+	if !strings.HasPrefix(text, prefix) {
+		return "", false
+	}
+	text = strings.TrimSpace(strings.TrimPrefix(text, prefix))
+	return text, text != ""
+}
+
+// Connect to the Discord gateway and handle commands until ctx is done
+func startDiscord(ctx context.Context, config *Config, store TaskStore, state *botState) error {
+	if config.Discord.BotToken == "" {
+		return errors.New("discord.bot_token is required for the discord backend")
+	}
+	session, err := discordgo.New("Bot " + config.Discord.BotToken)
+	if err != nil {
+		return err
+	}
+	session.Identify.Intents = discordgo.IntentsGuildMessages | discordgo.IntentsDirectMessages | discordgo.IntentMessageContent
+	messenger := &discordMessenger{session: session, users: newUserCache(), config: &state.config}
+
+	session.AddHandler(func(s *discordgo.Session, m *discordgo.MessageCreate) {
+		if m.Author == nil || m.Author.Bot {
+			return
+		}
+		text, ok := parseDiscordText(m.Content, config.Discord.CommandPrefix)
+		if !ok {
+			return
+		}
+		msg := incomingMessage{
+			Text:      text,
+			ChannelID: m.ChannelID,
+			UserID:    m.Author.ID,
+			Timestamp: m.ID,
+		}
+		log.Printf("Discord message received in channel: %s, message: %s", msg.ChannelID, msg.Text)
+
+		go func() {
+			defer state.recoverPanic("a Discord message")
+			handleCommand(ctx, messenger, msg, state.config.Load(), store, state)
+		}()
+	})
+
+	if err := session.Open(); err != nil {
+		return fmt.Errorf("opening Discord session: %w", err)
+	}
+	if state.notifier == nil {
+		state.notifier = messenger
+	}
+
+	go func() {
+		<-ctx.Done()
+		if err := session.Close(); err != nil {
+			log.Printf("Error closing Discord session: %v", err)
+		}
+	}()
+	return nil
+}
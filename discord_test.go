@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestFormatDiscordText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "bold", text: "*Checks*\n`health`", want: "**Checks**\n`health`"},
+		{name: "bold doesn't span lines", text: "a * b\nc * d", want: "a * b\nc * d"},
+		{name: "plain", text: "Task 'health' executed successfully.", want: "Task 'health' executed successfully."},
+		{name: "too long", text: strings.Repeat("x", discordMessageLimit+1), want: strings.Repeat("x", discordMessageLimit-3) + "..."},
+		{name: "limit counts characters", text: strings.Repeat("é", discordMessageLimit), want: strings.Repeat("é", discordMessageLimit)},
+		{name: "cut on a character", text: strings.Repeat("日", discordMessageLimit+1), want: strings.Repeat("日", discordMessageLimit-3) + "..."},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := formatDiscordText(test.text); got != test.want {
+				t.Errorf("formatDiscordText = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseDiscordText(t *testing.T) {
+	tests := []struct {
+		content string
+		prefix  string
+		want    string
+		wantOK  bool
+	}{
+		{content: "!restart api", want: "restart api", wantOK: true},
+		{content: "  ! health ", want: "health", wantOK: true},
+		{content: "<@123456> !health", want: "health", wantOK: true},
+		{content: "<@!123456>!health", want: "health", wantOK: true},
+		{content: "health"},
+		{content: "!"},
+		{content: "<@123456> health"},
+		{content: "bot: deploy api prod", prefix: "bot:", want: "deploy api prod", wantOK: true},
+		{content: "!health", prefix: "bot:"},
+	}
+	for _, test := range tests {
+		t.Run(test.content, func(t *testing.T) {
+			got, ok := parseDiscordText(test.content, test.prefix)
+			if got != test.want || ok != test.wantOK {
+				t.Errorf("parseDiscordText(%q, %q) = %q, %v, want %q, %v", test.content, test.prefix, got, ok, test.want, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestBackendEnabled(t *testing.T) {
+	tests := []struct {
+		backend string
+		want    []string
+	}{
+		{backend: "", want: []string{"slack"}},
+		{backend: "teams", want: []string{"teams"}},
+		{backend: "both", want: []string{"slack", "teams"}},
+		{backend: "slack, discord", want: []string{"slack", "discord"}},
+		{backend: "both,discord", want: []string{"slack", "teams", "discord"}},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, name := range []string{"slack", "teams", "discord"} {
+			if backendEnabled(&Config{Backend: test.backend}, name) {
+				got = append(got, name)
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("backend %q enables %v, want %v", test.backend, got, test.want)
+		}
+	}
+}
+
+func TestStartDiscordNeedsToken(t *testing.T) {
+	config := &Config{}
+	err := startDiscord(context.Background(), config, newConfigTaskStore(nil), newBotState(config))
+	if err == nil || !strings.Contains(err.Error(), "discord.bot_token is required") {
+		t.Errorf("startDiscord without a bot token = %v, want an error", err)
+	}
+}
+
+// A message sent through the fake Discord API
+type discordPost struct {
+	ChannelID string
+	Content   string
+}
+
+// Discord messenger talking to a local fake of the REST API. DMs to dmBlocked
+// fail the way Discord refuses them for users who don't accept DMs.
+func newFakeDiscord(t *testing.T, dmBlocked string) (*discordMessenger, func() []discordPost) {
+	t.Helper()
+	var mu sync.Mutex
+	var posts []discordPost
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		path := strings.TrimPrefix(r.URL.Path, "/api/v"+discordgo.APIVersion)
+		switch {
+		case path == "/users/@me/channels":
+			var body struct {
+				RecipientID string `json:"recipient_id"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "DM-" + body.RecipientID, "type": 1})
+		case strings.HasPrefix(path, "/channels/") && strings.HasSuffix(path, "/messages"):
+			channelID := strings.TrimSuffix(strings.TrimPrefix(path, "/channels/"), "/messages")
+			if channelID == "DM-"+dmBlocked {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"message":"Cannot send messages to this user","code":50007}`))
+				return
+			}
+			var body struct {
+				Content string `json:"content"`
+			}
+			raw, _ := io.ReadAll(r.Body)
+			json.Unmarshal(raw, &body)
+			mu.Lock()
+			posts = append(posts, discordPost{ChannelID: channelID, Content: body.Content})
+			mu.Unlock()
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "M1", "channel_id": channelID, "content": body.Content})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(api.Close)
+	apiURL, _ := url.Parse(api.URL)
+
+	session, err := discordgo.New("Bot test-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r = r.Clone(r.Context())
+		r.URL.Scheme, r.URL.Host = apiURL.Scheme, apiURL.Host
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	return &discordMessenger{session: session, users: newUserCache(), config: &newBotState(&Config{}).config}, func() []discordPost {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]discordPost(nil), posts...)
+	}
+}
+
+// Private replies go to the user's DMs; the channel never sees their text
+func TestDiscordPostEphemeral(t *testing.T) {
+	const private = "You are <@U1> (U1).\nCommands you can run:\n- purge"
+	tests := []struct {
+		name      string
+		dmBlocked string
+		want      []discordPost
+	}{
+		{name: "direct message", want: []discordPost{{ChannelID: "DM-U1", Content: private}}},
+		{name: "DMs blocked", dmBlocked: "U1", want: []discordPost{{ChannelID: "C1", Content: "<@U1> That reply is only for you, but I couldn't send it as a direct message. Allow direct messages from server members to see it."}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messenger, posts := newFakeDiscord(t, test.dmBlocked)
+			if err := messenger.PostEphemeral("C1", "U1", private); err != nil {
+				t.Fatal(err)
+			}
+			got := posts()
+			if len(got) != len(test.want) || got[0] != test.want[0] {
+				t.Errorf("posts = %+v, want %+v", got, test.want)
+			}
+			for _, post := range got {
+				if post.ChannelID == "C1" && strings.Contains(post.Content, "Commands you can run") {
+					t.Errorf("private text posted to the channel: %q", post.Content)
+				}
+			}
+		})
+	}
+}
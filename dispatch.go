@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// CommandMeta describes who sent a command and where, independent of the chat backend
+type CommandMeta struct {
+	UserID    string
+	ChannelID string
+	Timestamp string // Message ID, when the source has one
+}
+
+// Reply is one message produced while handling a command
+type Reply struct {
+	Text      string `json:"text"`
+	Ephemeral bool   `json:"ephemeral,omitempty"` // Meant only for the user who sent the command
+}
+
+// Result collects the replies of a dispatched command in the order they were
+// produced, and how the task or deploy it ran ended
+type Result struct {
+	Replies []Reply
+
+	Command       string // Task or deploy that was run, empty when nothing matched
+	Executed      bool   // false when nothing ran or a check such as a cooldown stopped it
+	Success       bool
+	FailureStreak int // Consecutive failures of the command, including this run
+}
+
+// outcomeRecorder is implemented by messengers that report how a command ended
+type outcomeRecorder interface {
+	RecordOutcome(command string, outcome taskOutcome)
+}
+
+// Report a task's or deploy's outcome to messengers that collect it
+func recordOutcome(messenger Messenger, command string, outcome taskOutcome) {
+	if recorder, ok := messenger.(outcomeRecorder); ok {
+		recorder.RecordOutcome(command, outcome)
+	}
+}
+
+// Dispatcher matches and executes commands without a chat backend, for
+// tests and the command line. Chat backends call handleCommand directly so
+// progress messages and reactions are delivered while the command runs.
+type Dispatcher struct {
+	config *Config
+	store  TaskStore
+	state  *botState
+}
+
+func newDispatcher(config *Config, store TaskStore, state *botState) *Dispatcher {
+	return &Dispatcher{config: config, store: store, state: state}
+}
+
+// Dispatch runs one command and returns every reply it produced. The error
+// is set when the context ended before the command finished. Commands are not
+// debounced: /trigger, workflow and CLI callers share one user ID, so two
+// deliberate calls would look like a repeat, and slash commands are debounced
+// by their handler.
+func (d *Dispatcher) Dispatch(ctx context.Context, cmd string, meta CommandMeta) (Result, error) {
+	collector := &resultMessenger{}
+	msg := incomingMessage{Text: cmd, ChannelID: meta.ChannelID, UserID: meta.UserID, Timestamp: meta.Timestamp, SkipDebounce: true}
+	handleCommand(ctx, collector, msg, d.config, d.store, d.state)
+	return collector.result(), ctx.Err()
+}
+
+// resultMessenger records replies instead of sending them
+type resultMessenger struct {
+	mu      sync.Mutex
+	replies []Reply
+	command string
+	outcome taskOutcome
+}
+
+func (m *resultMessenger) RecordOutcome(command string, outcome taskOutcome) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.command, m.outcome = command, outcome
+}
+
+func (m *resultMessenger) PostMessage(channelID, text string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.replies = append(m.replies, Reply{Text: text})
+	return nil
+}
+
+func (m *resultMessenger) PostEphemeral(channelID, userID, text string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.replies = append(m.replies, Reply{Text: text, Ephemeral: true})
+	return nil
+}
+
+func (m *resultMessenger) AddReaction(channelID, timestamp, emoji string) error    { return nil }
+func (m *resultMessenger) RemoveReaction(channelID, timestamp, emoji string) error { return nil }
+func (m *resultMessenger) DeleteMessage(channelID, timestamp string) error         { return nil }
+
+func (m *resultMessenger) result() Result {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return Result{
+		Replies:       append([]Reply(nil), m.replies...),
+		Command:       m.command,
+		Executed:      m.outcome.Executed,
+		Success:       m.outcome.Success,
+		FailureStreak: m.outcome.FailureStreak,
+	}
+}
+
+// Run a single command from the command line and print its replies
+func runCLICommand(ctx context.Context, config *Config, store TaskStore, state *botState, cmd string) int {
+	user := os.Getenv("USER")
+	if user == "" {
+		user = "cli"
+	}
+	result, err := newDispatcher(config, store, state).Dispatch(ctx, cmd, CommandMeta{UserID: user, ChannelID: "cli"})
+	for _, reply := range result.Replies {
+		fmt.Println(reply.Text)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Command interrupted: %v\n", err)
+		return 1
+	}
+	return 0
+}
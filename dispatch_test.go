@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDispatch(t *testing.T) {
+	target, _ := newStubTarget(t)
+	config := &Config{AckReaction: "none", DebounceMillis: -1}
+	store := newConfigTaskStore(map[string]Task{
+		"restart": {Command: "restart", URL: target.URL + "/ok", Method: "POST"},
+		"purge":   {Command: "purge", URL: target.URL + "/fail", Method: "POST"},
+	})
+	dispatcher := newDispatcher(config, store, newBotState(config))
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		command      string
+		wantCommand  string
+		wantExecuted bool
+		wantSuccess  bool
+		wantReply    string
+		wantPrivate  bool
+		wantErr      bool
+	}{
+		{name: "success", ctx: context.Background(), command: "restart", wantCommand: "restart", wantExecuted: true, wantSuccess: true, wantReply: "Task 'restart' executed successfully."},
+		{name: "failure", ctx: context.Background(), command: "purge", wantCommand: "purge", wantExecuted: true, wantReply: "Task 'purge' failed to execute."},
+		{name: "unknown command", ctx: context.Background(), command: "nope", wantReply: "I don't know your message. Please try again.", wantPrivate: true},
+		{name: "interrupted", ctx: cancelled, command: "restart", wantCommand: "restart", wantExecuted: true, wantReply: "Task 'restart' failed to execute.", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := dispatcher.Dispatch(test.ctx, test.command, CommandMeta{UserID: "U1", ChannelID: "C1"})
+			if (err != nil) != test.wantErr {
+				t.Errorf("Dispatch error = %v, want error %v", err, test.wantErr)
+			}
+			if result.Command != test.wantCommand || result.Executed != test.wantExecuted || result.Success != test.wantSuccess {
+				t.Errorf("result = %+v, want command %q executed %v success %v", result, test.wantCommand, test.wantExecuted, test.wantSuccess)
+			}
+			if len(result.Replies) == 0 {
+				t.Fatal("no replies")
+			}
+			last := result.Replies[len(result.Replies)-1]
+			if !strings.HasPrefix(last.Text, test.wantReply) || last.Ephemeral != test.wantPrivate {
+				t.Errorf("last reply = %+v, want %q (ephemeral %v)", last, test.wantReply, test.wantPrivate)
+			}
+		})
+	}
+}
+
+// Consecutive failures of a command are counted across dispatches
+func TestDispatchFailureStreak(t *testing.T) {
+	target, _ := newStubTarget(t)
+	tasks := map[string]Task{"broken": {Command: "broken", URL: target.URL + "/fail", Method: "GET"}}
+	config := &Config{Tasks: tasks, AckReaction: "none", DebounceMillis: -1}
+	dispatcher := newDispatcher(config, newConfigTaskStore(tasks), newBotState(config))
+
+	for want := 1; want <= 3; want++ {
+		result, err := dispatcher.Dispatch(context.Background(), "broken", CommandMeta{UserID: "U1", ChannelID: "C1"})
+		if err != nil {
+			t.Fatalf("Dispatch: %v", err)
+		}
+		if result.FailureStreak != want {
+			t.Errorf("run %d: FailureStreak = %d, want %d", want, result.FailureStreak, want)
+		}
+	}
+}
+
+// Chat backends call handleCommand with their own messenger and must see the
+// same replies Dispatch collects
+func TestHandleCommandMatchesDispatch(t *testing.T) {
+	target, _ := newStubTarget(t)
+	tasks := map[string]Task{"health": {Command: "health", URL: target.URL + "/ok", Method: "GET"}}
+	config := &Config{Tasks: tasks, AckReaction: "none", DebounceMillis: -1}
+	dispatcher := newDispatcher(config, newConfigTaskStore(tasks), newBotState(config))
+	result, err := dispatcher.Dispatch(context.Background(), "health", CommandMeta{UserID: "U1", ChannelID: "C1"})
+	if err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+
+	messenger := newFakeMessenger()
+	msg := incomingMessage{Text: "health", ChannelID: "C1", UserID: "U1"}
+	handleCommand(context.Background(), messenger, msg, config, newConfigTaskStore(tasks), newBotState(config))
+
+	sent := messenger.sent()
+	if len(sent) != len(result.Replies) {
+		t.Fatalf("messenger got %+v, Dispatch got %+v", sent, result.Replies)
+	}
+	// The running message carries a fresh execution ID, so compare the result
+	want, last := result.Replies[len(result.Replies)-1], sent[len(sent)-1]
+	if last.Text != want.Text || (last.UserID != "") != want.Ephemeral || last.ChannelID != "C1" {
+		t.Errorf("last message = %+v, want %+v in C1", last, want)
+	}
+}
+
+// Capture what fn prints to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		output <- string(b)
+	}()
+	fn()
+	w.Close()
+	return <-output
+}
+
+func TestRunCLICommand(t *testing.T) {
+	target, _ := newStubTarget(t)
+	t.Setenv("USER", "alice")
+	config := &Config{AckReaction: "none"}
+	store := newConfigTaskStore(map[string]Task{
+		"restart": {Command: "restart", URL: target.URL + "/ok", Method: "POST", AllowedUsers: []string{"alice"}},
+		"purge":   {Command: "purge", URL: target.URL + "/ok", Method: "POST", AllowedUsers: []string{"bob"}},
+	})
+	state := newBotState(config)
+
+	var code int
+	output := captureStdout(t, func() { code = runCLICommand(context.Background(), config, store, state, "restart") })
+	if code != 0 || !strings.HasSuffix(output, "\nTask 'restart' executed successfully.\n") {
+		t.Errorf("restart printed %q with exit code %d", output, code)
+	}
+
+	// The CLI runs as $USER, so allowlists still apply
+	output = captureStdout(t, func() { code = runCLICommand(context.Background(), config, store, state, "purge") })
+	if code != 0 || output != "You are not allowed to run 'purge'.\n" {
+		t.Errorf("purge printed %q with exit code %d", output, code)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	captureStdout(t, func() { code = runCLICommand(cancelled, config, store, state, "restart") })
+	if code != 1 {
+		t.Errorf("interrupted command exit code = %d, want 1", code)
+	}
+}
+
+// Input matching no command runs the fallback task, which gets it as {text}
+func TestDispatchFallbackCommand(t *testing.T) {
+	texts := make(chan string, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		texts <- r.URL.Query().Get("q")
+	}))
+	defer target.Close()
+	tasks := map[string]Task{
+		"health": {Command: "health", URL: target.URL + "/health", Method: "GET"},
+		"ask":    {Command: "ask", URL: target.URL + "/ask?q={text}", Method: "GET"},
+	}
+
+	tests := []struct {
+		name      string
+		fallback  string
+		command   string
+		wantReply string
+		wantText  string // Text the fallback task receives, empty when it doesn't run
+	}{
+		{name: "unknown input", fallback: "ask", command: "where is the runbook", wantReply: "Task 'ask' executed successfully.", wantText: "where is the runbook"},
+		{name: "known command", fallback: "ask", command: "health", wantReply: "Task 'health' executed successfully."},
+		{name: "no fallback", command: "where is the runbook", wantReply: "I don't know your message. Please try again."},
+		{name: "fallback not a task", fallback: "missing", command: "where is the runbook", wantReply: "I don't know your message. Please try again."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &Config{Tasks: tasks, AckReaction: "none", DebounceMillis: -1, FallbackCommand: test.fallback}
+			dispatcher := newDispatcher(config, newConfigTaskStore(tasks), newBotState(config))
+			result, err := dispatcher.Dispatch(context.Background(), test.command, CommandMeta{UserID: "U1", ChannelID: "C1"})
+			if err != nil {
+				t.Fatalf("Dispatch: %v", err)
+			}
+			if last := result.Replies[len(result.Replies)-1]; !strings.HasPrefix(last.Text, test.wantReply) {
+				t.Errorf("last reply = %+v, want %q", last, test.wantReply)
+			}
+			got := ""
+			select {
+			case got = <-texts:
+			default:
+			}
+			if test.wantText != "" && got != test.wantText {
+				t.Errorf("fallback got text %q, want %q", got, test.wantText)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// Slack event for an edit of a user's message from one text to another
+func messageChangedEvent(previous, text string, botEdit bool) map[string]interface{} {
+	message := map[string]interface{}{"type": "message", "user": "U1", "text": text, "ts": "1700000000.000100"}
+	if botEdit {
+		message["bot_id"] = "B1"
+	}
+	return map[string]interface{}{
+		"type": "event_callback",
+		"event": map[string]interface{}{
+			"type":             "message",
+			"subtype":          "message_changed",
+			"channel":          "C1",
+			"message":          message,
+			"previous_message": map[string]interface{}{"type": "message", "user": "U1", "text": previous},
+		},
+	}
+}
+
+func TestHandleMessageChanged(t *testing.T) {
+	target, hits := newStubTarget(t)
+	tests := []struct {
+		name        string
+		handleEdits bool
+		event       map[string]interface{}
+		wantRun     bool
+	}{
+		{name: "typo fixed", handleEdits: true, event: messageChangedEvent("retsart", "restart", false), wantRun: true},
+		{name: "edits disabled", handleEdits: false, event: messageChangedEvent("retsart", "restart", false), wantRun: false},
+		{name: "text unchanged", handleEdits: true, event: messageChangedEvent("restart", "restart", false), wantRun: false},
+		{name: "bot message edited", handleEdits: true, event: messageChangedEvent("retsart", "restart", true), wantRun: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := len(hits())
+			messenger := newFakeMessenger()
+			config := &Config{HandleEdits: test.handleEdits}
+			store := newConfigTaskStore(map[string]Task{"restart": {Command: "restart", URL: target.URL + "/ok", Method: "POST"}})
+			handleMessageEvent(context.Background(), messenger, test.event, config, store, newBotState(config))
+
+			if ran := len(hits()) > before; ran != test.wantRun {
+				t.Errorf("task ran = %v, want %v", ran, test.wantRun)
+			}
+			if !test.wantRun && len(messenger.sent()) != 0 {
+				t.Errorf("replied %+v to an ignored edit", messenger.sent())
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Config built from the environment when there is no config file: the whole
+// JSON in BOT_CONFIG_JSON, or else the discrete variables below for simple
+// setups, with tasks kept in task_db. ok is false unless BOT_CONFIG_JSON or a
+// bot token is set: LOG_LEVEL or BASE_PATH alone don't make a configuration.
+func configFromEnv() (config *Config, ok bool, err error) {
+	if raw := os.Getenv("BOT_CONFIG_JSON"); raw != "" {
+		config = &Config{}
+		if err := json.Unmarshal([]byte(raw), config); err != nil {
+			return nil, true, fmt.Errorf("BOT_CONFIG_JSON: %w", err)
+		}
+		return config, true, nil
+	}
+
+	config = &Config{}
+	vars := []struct {
+		name  string
+		field *string
+	}{
+		{"SLACK_TOKEN", &config.SlackToken},
+		{"BOT_BACKEND", &config.Backend},
+		{"JENKINS_USER", &config.Jenkins.User},
+		{"JENKINS_TOKEN", &config.Jenkins.Token},
+		{"JENKINS_URL_FORMAT", &config.Jenkins.URLFormat},
+		{"TASK_DB", &config.TaskDB},
+		{"ADMIN_TOKEN", &config.AdminToken},
+		{"TRIGGER_TOKEN", &config.TriggerToken},
+		{"SLACK_SIGNING_SECRET", &config.SlackSigningSecret},
+		{"NOTIFY_CHANNEL", &config.NotifyChannel},
+		{"LOG_LEVEL", &config.LogLevel},
+		{"BASE_PATH", &config.BasePath},
+	}
+	for _, v := range vars {
+		if value, set := os.LookupEnv(v.name); set {
+			*v.field = value
+		}
+	}
+	if config.SlackToken == "" {
+		return nil, false, nil
+	}
+	return config, true, nil
+}
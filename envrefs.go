@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// {env:NAME} references in task URLs, resolved from the bot's environment
+var envRefPattern = regexp.MustCompile(`\{env:([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Replace {env:NAME} references with environment variable values. Unset
+// variables expand to an empty string, or are an error when strict is on.
+func expandEnvRefs(text string, strict bool) (string, error) {
+	var missing []string
+	expanded := envRefPattern.ReplaceAllStringFunc(text, func(ref string) string {
+		name := envRefPattern.FindStringSubmatch(ref)[1]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if strict && len(missing) > 0 {
+		return "", fmt.Errorf("environment variables not set: %v", missing)
+	}
+	return expanded, nil
+}
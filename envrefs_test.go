@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExpandEnvRefs(t *testing.T) {
+	t.Setenv("BOT_TEST_HOST", "api.example.com")
+	t.Setenv("BOT_TEST_EMPTY", "")
+
+	tests := []struct {
+		name    string
+		text    string
+		strict  bool
+		want    string
+		wantErr bool
+	}{
+		{name: "no references", text: "https://example.com/health", want: "https://example.com/health"},
+		{name: "set variable", text: "https://{env:BOT_TEST_HOST}/health", want: "https://api.example.com/health"},
+		{name: "set but empty", text: "a{env:BOT_TEST_EMPTY}b", strict: true, want: "ab"},
+		{name: "unset expands to empty", text: "https://{env:BOT_TEST_UNSET}/x", want: "https:///x"},
+		{name: "unset is an error when strict", text: "https://{env:BOT_TEST_UNSET}/x", strict: true, wantErr: true},
+		{name: "invalid name left alone", text: "{env:1BAD} {env:}", strict: true, want: "{env:1BAD} {env:}"},
+		{name: "several references", text: "{env:BOT_TEST_HOST}/{env:BOT_TEST_HOST}", want: "api.example.com/api.example.com"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := expandEnvRefs(test.text, test.strict)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("expandEnvRefs(%q) error = %v, wantErr %v", test.text, err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("expandEnvRefs(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+// The task request goes to the expanded URL; with strict_env an unset variable fails the task unsent
+func TestExecuteTaskExpandsEnvRefs(t *testing.T) {
+	var paths []string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+	}))
+	defer target.Close()
+	t.Setenv("BOT_TEST_SERVICE", "billing")
+
+	task := Task{Command: "restart", URL: target.URL + "/{env:BOT_TEST_SERVICE}/restart", Method: "POST"}
+	if result := executeTask(context.Background(), &Config{}, task); !result.Success {
+		t.Error("task with a set variable failed")
+	}
+	task.URL = target.URL + "/{env:BOT_TEST_UNSET}/restart"
+	if result := executeTask(context.Background(), &Config{StrictEnv: true}, task); result.Success {
+		t.Error("task with an unset variable succeeded under strict_env")
+	}
+	if len(paths) != 1 || paths[0] != "/billing/restart" {
+		t.Errorf("requests = %v, want only /billing/restart", paths)
+	}
+}
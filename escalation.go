@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"sync"
+)
+
+// failureStreaks counts consecutive failures per command
+type failureStreaks struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newFailureStreaks() *failureStreaks {
+	return &failureStreaks{counts: make(map[string]int)}
+}
+
+// Record a run and return the command's consecutive failure count, 0 after a success
+func (f *failureStreaks) Record(command string, success bool) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if success {
+		delete(f.counts, command)
+		return 0
+	}
+	f.counts[command]++
+	return f.counts[command]
+}
+
+// Check whether a failure streak just reached an escalation threshold. Past
+// the last threshold, escalation repeats each time the streak doubles.
+func shouldEscalate(thresholds []int, streak int) bool {
+	if streak == 0 || len(thresholds) == 0 {
+		return false
+	}
+	for _, threshold := range thresholds {
+		if streak == threshold {
+			return true
+		}
+	}
+	last := thresholds[len(thresholds)-1]
+	if last <= 0 || streak <= last {
+		return false
+	}
+	for next := last * 2; next <= streak; next *= 2 {
+		if next == streak {
+			return true
+		}
+	}
+	return false
+}
+
+// Post an escalation to the channel when a command keeps failing
+func escalateFailures(messenger Messenger, config *Config, channelID, userID, command string, streak int) {
+	if !shouldEscalate(config.FailureEscalationThresholds, streak) {
+		return
+	}
+	log.Printf("Command '%s' failed %d times in a row, escalating", command, streak)
+	response := localize(config, userID, "escalation", "command", command, "count", strconv.Itoa(streak))
+	if mention := failureMention(config.FailureEscalationMention); mention != "" {
+		response = mention + " " + response
+	}
+	if err := messenger.PostMessage(channelID, response); err != nil {
+		log.Printf("Error sending message to Slack: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFailureStreaks(t *testing.T) {
+	streaks := newFailureStreaks()
+	tests := []struct {
+		command string
+		success bool
+		want    int
+	}{
+		{command: "broken", want: 1},
+		{command: "broken", want: 2},
+		{command: "other", want: 1},
+		{command: "broken", want: 3},
+		{command: "broken", success: true, want: 0},
+		{command: "broken", want: 1},
+		{command: "other", want: 2},
+	}
+
+	for i, test := range tests {
+		if got := streaks.Record(test.command, test.success); got != test.want {
+			t.Errorf("run %d: Record(%q, %v) = %d, want %d", i+1, test.command, test.success, got, test.want)
+		}
+	}
+}
+
+func TestShouldEscalate(t *testing.T) {
+	tests := []struct {
+		name       string
+		thresholds []int
+		want       []int // Streaks from 1 to 40 that escalate
+	}{
+		{name: "off"},
+		{name: "single threshold then doubling", thresholds: []int{3}, want: []int{3, 6, 12, 24}},
+		{name: "several thresholds", thresholds: []int{3, 5, 10}, want: []int{3, 5, 10, 20, 40}},
+		{name: "non-positive last threshold", thresholds: []int{0}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []int
+			for streak := 0; streak <= 40; streak++ {
+				if shouldEscalate(test.thresholds, streak) {
+					got = append(got, streak)
+				}
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("escalating streaks = %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Fatalf("escalating streaks = %v, want %v", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestEscalateFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		mention string
+		streak  int
+		want    string // Empty for no escalation
+	}{
+		{name: "below threshold", streak: 2},
+		{name: "at threshold", streak: 3, want: ":rotating_light: 'broken' has failed 3 times in a row."},
+		{name: "with a user group", mention: "S123", streak: 3, want: "<!subteam^S123> :rotating_light: 'broken' has failed 3 times in a row."},
+		{name: "doubled", mention: "here", streak: 6, want: "<!here> :rotating_light: 'broken' has failed 6 times in a row."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messenger := newFakeMessenger()
+			config := &Config{FailureEscalationThresholds: []int{3}, FailureEscalationMention: test.mention}
+			escalateFailures(messenger, config, "C1", "U1", "broken", test.streak)
+
+			got := messenger.sent()
+			if test.want == "" {
+				if len(got) != 0 {
+					t.Errorf("posted %v, want nothing", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].Text != test.want || got[0].ChannelID != "C1" {
+				t.Errorf("posted %+v, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// The third failure in a row of a chat command posts the escalation after the result
+func TestHandleMessageEscalatesFailures(t *testing.T) {
+	target, _ := newStubTarget(t)
+	tasks := map[string]Task{"purge": {Command: "purge", URL: target.URL + "/fail", Method: "POST"}}
+	config := &Config{DebounceMillis: -1, FailureEscalationThresholds: []int{3}}
+	state := newBotState(config)
+
+	for run := 1; run <= 3; run++ {
+		messenger := newFakeMessenger()
+		handleMessageEvent(context.Background(), messenger, messageEvent("U1", "purge"), config, newConfigTaskStore(tasks), state)
+		replies := messenger.results()
+		if run < 3 && len(replies) != 1 {
+			t.Errorf("run %d replied %q, want only the result", run, replies)
+		}
+		if run == 3 && (len(replies) != 2 || replies[1] != ":rotating_light: 'purge' has failed 3 times in a row.") {
+			t.Errorf("run %d replied %q, want the result and the escalation", run, replies)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Serve the Slack routes registered by registerSlackRoutes on a fresh default mux
+func newSlackEventsServer(t *testing.T, ctx context.Context, config *Config, store TaskStore, state *botState) *httptest.Server {
+	t.Helper()
+	previous := http.DefaultServeMux
+	http.DefaultServeMux = http.NewServeMux()
+	err := registerSlackRoutes(ctx, config, store, state)
+	if err != nil {
+		http.DefaultServeMux = previous
+		t.Fatalf("registerSlackRoutes: %v", err)
+	}
+	server := httptest.NewServer(http.DefaultServeMux)
+	http.DefaultServeMux = previous
+	t.Cleanup(server.Close)
+	return server
+}
+
+// A Slack Web API call captured by fakeSlackAPI
+type slackCall struct {
+	Token   string // Bot token the client authenticated with
+	Method  string // e.g. chat.postMessage
+	Channel string
+	Text    string
+}
+
+// Answer Slack Web API calls made through the default transport locally,
+// recording which token made each call. Other requests pass through.
+func fakeSlackAPI(t *testing.T) func() []slackCall {
+	t.Helper()
+	var mu sync.Mutex
+	var calls []slackCall
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			token = r.FormValue("token")
+		}
+		mu.Lock()
+		calls = append(calls, slackCall{Token: token, Method: strings.TrimPrefix(r.URL.Path, "/api/"), Channel: r.FormValue("channel"), Text: r.FormValue("text")})
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "1.000"}`))
+	}))
+	apiURL, _ := url.Parse(api.URL)
+
+	previous := http.DefaultTransport
+	http.DefaultTransport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Host == "slack.com" {
+			r = r.Clone(r.Context())
+			r.URL.Scheme, r.URL.Host = apiURL.Scheme, apiURL.Host
+		}
+		return previous.RoundTrip(r)
+	})
+	t.Cleanup(func() {
+		http.DefaultTransport = previous
+		api.Close()
+	})
+	return func() []slackCall {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]slackCall(nil), calls...)
+	}
+}
+
+// POST a Slack event body to url, signed with secret unless it is empty
+func postSlackEvent(t *testing.T, url, body, secret string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		signSlackRequest(req, body, secret, time.Now())
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("posting event: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// The events endpoint acknowledges within Slack's 3s budget even when the
+// task behind the message is slow, and still answers URL verification
+func TestSlackEventsAcknowledgedQuickly(t *testing.T) {
+	fakeSlackAPI(t)
+	started := make(chan struct{}, 1)
+	slowTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-r.Context().Done() // never answers on its own
+	}))
+
+	// Cancelling the bot context aborts the slow task so the target can shut down
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		slowTarget.Close()
+	})
+
+	config := &Config{SlackToken: "xoxb-test", SlackSigningSecret: testSigningSecret}
+	store := newConfigTaskStore(map[string]Task{"slow": {Command: "slow", URL: slowTarget.URL, Method: "POST"}})
+	server := newSlackEventsServer(t, ctx, config, store, newBotState(config))
+
+	tests := []struct {
+		name       string
+		body       string
+		wantBody   string
+		wantTarget bool
+	}{
+		{name: "slow task", body: `{"type":"event_callback","event":{"type":"message","channel":"C1","user":"U1","text":"slow"}}`, wantTarget: true},
+		{name: "url verification", body: `{"type":"url_verification","challenge":"3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P"}`, wantBody: "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start := time.Now()
+			resp := postSlackEvent(t, server.URL+"/slack/events", test.body, testSigningSecret)
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("handler took %v, want well under Slack's 3s deadline", elapsed)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status = %d, want 200", resp.StatusCode)
+			}
+			if test.wantBody != "" {
+				var challenge map[string]string
+				json.NewDecoder(resp.Body).Decode(&challenge)
+				if challenge["challenge"] != test.wantBody {
+					t.Errorf("challenge = %q, want %q", challenge["challenge"], test.wantBody)
+				}
+			}
+			if test.wantTarget {
+				// The task still runs, just after the response was written
+				select {
+				case <-started:
+				case <-time.After(5 * time.Second):
+					t.Error("task never reached its target")
+				}
+			}
+		})
+	}
+}
+
+// A bot serving /slack/events with replies going to a fake messenger
+func newEventsServer(t *testing.T, tasks map[string]Task) (*httptest.Server, *fakeMessenger) {
+	t.Helper()
+	config := &Config{Tasks: tasks, AckReaction: "none", SlackSigningSecret: testSigningSecret}
+	messenger := newFakeMessenger()
+	handler := slackEventsHandler(context.Background(), func(teamID string) Messenger { return messenger }, newConfigTaskStore(tasks), newBotState(config))
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server, messenger
+}
+
+func postEvent(t *testing.T, server *httptest.Server, event map[string]interface{}) *http.Response {
+	t.Helper()
+	body, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return postSlackEvent(t, server.URL, string(body), testSigningSecret)
+}
+
+func TestSlackEventsRejectsInvalidJSON(t *testing.T) {
+	server, _ := newEventsServer(t, nil)
+
+	resp := postSlackEvent(t, server.URL, "{", testSigningSecret)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", resp.StatusCode)
+	}
+}
+
+// A message event runs the task against the target and the result is posted back
+func TestSlackEventsEndToEnd(t *testing.T) {
+	requests := make(chan *http.Request, 10)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+		if strings.HasSuffix(r.URL.Path, "/fail") {
+			http.Error(w, "broken", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"version":"1.4.2"}`))
+	}))
+	defer target.Close()
+
+	tasks := map[string]Task{
+		"restart": {Command: "restart", URL: target.URL + "/restart/{service}", Method: "POST", Args: []ArgSpec{{Name: "service", Type: "enum", Values: []string{"api", "web"}}}},
+		"version": {Command: "version", URL: target.URL + "/version", Method: "GET", ResponsePath: "version", SuccessMessage: "{{.Command}} is up"},
+		"broken":  {Command: "broken", URL: target.URL + "/fail", Method: "GET"},
+	}
+
+	tests := []struct {
+		name       string
+		text       string
+		wantPath   string // Empty when the target must not be called
+		wantReply  string
+		wantMethod string
+	}{
+		{name: "task with argument", text: "restart api", wantPath: "/restart/api", wantMethod: "POST", wantReply: "Task 'restart' executed successfully."},
+		{name: "response field", text: "version", wantPath: "/version", wantMethod: "GET", wantReply: "version is up\nversion: 1.4.2"},
+		{name: "failing target", text: "broken", wantPath: "/fail", wantMethod: "GET", wantReply: "Task 'broken' failed to execute."},
+		{name: "invalid argument", text: "restart db", wantReply: "Invalid arguments for 'restart'"},
+		{name: "unknown command", text: "launch rockets", wantReply: "I don't know your message. Please try again."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, messenger := newEventsServer(t, tasks)
+
+			resp := postEvent(t, server, messageEvent("U1", test.text))
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want 200", resp.StatusCode)
+			}
+
+			// Skip the running message; the reply is the last one posted
+			var reply fakeMessage
+			deadline := time.Now().Add(5 * time.Second)
+			for time.Now().Before(deadline) {
+				if sent := messenger.sent(); len(sent) > 0 && !strings.HasPrefix(sent[len(sent)-1].Text, "Running '") {
+					reply = sent[len(sent)-1]
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if !strings.HasPrefix(reply.Text, test.wantReply) {
+				t.Errorf("reply = %q, want prefix %q", reply.Text, test.wantReply)
+			}
+			if reply.ChannelID != "C1" {
+				t.Errorf("reply channel = %q, want C1", reply.ChannelID)
+			}
+
+			select {
+			case r := <-requests:
+				if test.wantPath == "" {
+					t.Fatalf("target called with %s %s, want no call", r.Method, r.URL.Path)
+				}
+				if r.URL.Path != test.wantPath || r.Method != test.wantMethod {
+					t.Errorf("target got %s %s, want %s %s", r.Method, r.URL.Path, test.wantMethod, test.wantPath)
+				}
+			default:
+				if test.wantPath != "" {
+					t.Errorf("target not called, want %s %s", test.wantMethod, test.wantPath)
+				}
+			}
+		})
+	}
+}
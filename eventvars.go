@@ -0,0 +1,59 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// How long looked-up channel names are reused
+const channelCacheTTL = time.Hour
+
+// Most channel names kept by a messenger
+const channelCacheSize = 1024
+
+func newChannelCache() *ttlCache[string, string] {
+	return newTTLCache[string, string](channelCacheSize)
+}
+
+// channelDirectory is implemented by backends that can look up channel names
+type channelDirectory interface {
+	ChannelName(channelID string) (string, error)
+}
+
+// Look up the channel's name (needs the channels:read or groups:read scope)
+func (m *slackMessenger) ChannelName(channelID string) (string, error) {
+	if name, ok := m.channels.Get(channelID); ok {
+		return name, nil
+	}
+	channel, err := m.api.GetConversationInfo(&slack.GetConversationInfoInput{ChannelID: channelID})
+	if err != nil {
+		return "", warnOnAuthError(err)
+	}
+	m.channels.Set(channelID, channel.Name, channelCacheTTL)
+	return channel.Name, nil
+}
+
+// Template variables for a command: the user's, where the message was posted
+// and the command's parsed arguments. The channel name falls back to the ID
+// when it can't be looked up.
+func commandVariables(messenger Messenger, msg incomingMessage) map[string]string {
+	vars := userVariables(messenger, msg.UserID)
+	vars["channel"] = msg.ChannelID
+	vars["channel_name"] = msg.ChannelID
+	vars["thread_ts"] = msg.ThreadTS
+	vars["team_id"] = msg.TeamID
+	if directory, ok := messenger.(channelDirectory); ok && msg.ChannelID != "" {
+		name, err := directory.ChannelName(msg.ChannelID)
+		if err != nil {
+			log.Printf("Error looking up channel %s: %v", msg.ChannelID, err)
+		} else if name != "" {
+			vars["channel_name"] = name
+		}
+	}
+	for name, value := range msg.Args {
+		vars[name] = value
+	}
+	return vars
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+// fakeChannels is a fakeMessenger that can look channel names up
+type fakeChannels struct {
+	*fakeMessenger
+	names map[string]string
+}
+
+func (c *fakeChannels) ChannelName(channelID string) (string, error) {
+	name, ok := c.names[channelID]
+	if !ok {
+		return "", errors.New("channel_not_found")
+	}
+	return name, nil
+}
+
+// The channel name falls back to the ID when it cannot be looked up and
+// arguments win over built-in variables of the same name
+func TestCommandVariables(t *testing.T) {
+	msg := incomingMessage{UserID: "U1", ChannelID: "C1", ThreadTS: "1.2", TeamID: "T1"}
+	base := map[string]string{
+		"user_id": "U1", "user_name": "U1", "user_email": "",
+		"channel": "C1", "thread_ts": "1.2", "team_id": "T1",
+	}
+	with := func(key, value string) map[string]string {
+		vars := map[string]string{key: value}
+		for k, v := range base {
+			if k != key {
+				vars[k] = v
+			}
+		}
+		return vars
+	}
+
+	tests := []struct {
+		name      string
+		messenger Messenger
+		msg       incomingMessage
+		want      map[string]string
+	}{
+		{name: "no channel directory", messenger: newFakeMessenger(), msg: msg, want: with("channel_name", "C1")},
+		{name: "channel name looked up", messenger: &fakeChannels{fakeMessenger: newFakeMessenger(), names: map[string]string{"C1": "ops"}}, msg: msg, want: with("channel_name", "ops")},
+		{name: "lookup fails", messenger: &fakeChannels{fakeMessenger: newFakeMessenger()}, msg: msg, want: with("channel_name", "C1")},
+		{
+			name:      "arguments override built-ins",
+			messenger: newFakeMessenger(),
+			msg:       incomingMessage{UserID: "U1", ChannelID: "C1", ThreadTS: "1.2", TeamID: "T1", Args: map[string]string{"service": "api", "channel": "override"}},
+			want:      map[string]string{"user_id": "U1", "user_name": "U1", "user_email": "", "channel": "override", "channel_name": "C1", "thread_ts": "1.2", "team_id": "T1", "service": "api"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := commandVariables(test.messenger, test.msg); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("commandVariables() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// Channel names are cached, so Slack is asked once per channel
+func TestSlackChannelNameCached(t *testing.T) {
+	var calls atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true,"channel":{"id":"C1","name":"ops"}}`))
+	}))
+	defer api.Close()
+
+	messenger := &slackMessenger{api: slack.New("xoxb-test", slack.OptionAPIURL(api.URL+"/")), channels: newChannelCache()}
+	for i := 0; i < 3; i++ {
+		name, err := messenger.ChannelName("C1")
+		if err != nil || name != "ops" {
+			t.Fatalf("ChannelName() = %q, %v, want ops", name, err)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("conversations.info called %d times, want 1", got)
+	}
+}
+
+// Where the message was posted is filled into the task's URL
+func TestHandleMessageEventVariables(t *testing.T) {
+	target, hits := newStubTarget(t)
+	tasks := map[string]Task{"audit": {Command: "audit", URL: target.URL + "/ok/{team_id}/{channel_name}/{thread_ts}", Method: "GET"}}
+	config := &Config{}
+	messenger := &fakeChannels{fakeMessenger: newFakeMessenger(), names: map[string]string{"C1": "ops"}}
+	event := messageEvent("U1", "audit")
+	event["team_id"] = "T1"
+	event["event"].(map[string]interface{})["thread_ts"] = "1700000000.000050"
+
+	handleMessageEvent(context.Background(), messenger, event, config, newConfigTaskStore(tasks), newBotState(config))
+
+	if got := hits(); len(got) != 1 || got[0] != "/ok/T1/ops/1700000000.000050" {
+		t.Errorf("target hits = %v, want /ok/T1/ops/1700000000.000050", got)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An in-flight command execution that can be cancelled
+type runningExecution struct {
+	ID        string
+	Command   string
+	User      string
+	ChannelID string
+	Started   time.Time
+
+	cancel       context.CancelFunc
+	buildURL     string        // Jenkins build started by a deploy, once known
+	buildJenkins JenkinsConfig // Jenkins settings, with the env's credentials, used to stop the build
+	buildGuard   *targetGuard  // SSRF guard of the deploy, applied when stopping the build
+}
+
+// executionRegistry tracks running executions by ID
+type executionRegistry struct {
+	mu         sync.Mutex
+	executions map[string]*runningExecution
+}
+
+func newExecutionRegistry() *executionRegistry {
+	return &executionRegistry{executions: make(map[string]*runningExecution)}
+}
+
+// Register a new execution and return the context it must run with
+func (r *executionRegistry) Start(ctx context.Context, command, user, channelID string) (context.Context, *runningExecution) {
+	ctx, cancel := context.WithCancel(ctx)
+	exec := &runningExecution{
+		ID:        newExecutionID(),
+		Command:   command,
+		User:      user,
+		ChannelID: channelID,
+		Started:   time.Now(),
+		cancel:    cancel,
+	}
+
+	r.mu.Lock()
+	r.executions[exec.ID] = exec
+	r.mu.Unlock()
+	return ctx, exec
+}
+
+// Remove a finished execution and release its context
+func (r *executionRegistry) Finish(id string) {
+	r.mu.Lock()
+	exec := r.executions[id]
+	delete(r.executions, id)
+	r.mu.Unlock()
+	if exec != nil {
+		exec.cancel()
+	}
+}
+
+func (r *executionRegistry) Get(id string) (*runningExecution, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	exec, ok := r.executions[id]
+	return exec, ok
+}
+
+// Return copies of the running executions, oldest first
+func (r *executionRegistry) List() []runningExecution {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]runningExecution, 0, len(r.executions))
+	for _, exec := range r.executions {
+		list = append(list, *exec)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Started.Before(list[j].Started) })
+	return list
+}
+
+// Remember the Jenkins build so cancel can stop it
+func (r *executionRegistry) SetBuildURL(id, buildURL string, jenkins JenkinsConfig, guard *targetGuard) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if exec, ok := r.executions[id]; ok {
+		exec.buildURL = buildURL
+		exec.buildJenkins = jenkins
+		exec.buildGuard = guard
+	}
+}
+
+// Cancel the execution's context and stop its Jenkins build, if any
+func (r *executionRegistry) Cancel(id string) {
+	r.mu.Lock()
+	exec, ok := r.executions[id]
+	var buildURL string
+	var jenkins JenkinsConfig
+	var guard *targetGuard
+	if ok {
+		buildURL, jenkins, guard = exec.buildURL, exec.buildJenkins, exec.buildGuard
+	}
+	r.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	exec.cancel()
+	if buildURL != "" {
+		if err := stopJenkinsBuild(jenkins, buildURL, guard); err != nil {
+			log.Printf("Error stopping Jenkins build %s: %v", buildURL, err)
+		}
+	}
+}
+
+// Cancel every running execution and return the ones that were cancelled
+func (r *executionRegistry) CancelAll() []runningExecution {
+	running := r.List()
+	for _, exec := range running {
+		r.Cancel(exec.ID)
+	}
+	return running
+}
+
+// Ask Jenkins to abort a running build, through the deploy's SSRF guard
+func stopJenkinsBuild(jenkins JenkinsConfig, buildURL string, guard *targetGuard) error {
+	ctx, cancel := context.WithTimeout(contextWithGuard(context.Background(), guard), 10*time.Second)
+	defer cancel()
+	if err := guard.check(ctx, buildURL); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(buildURL, "/")+"/stop", nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(jenkins.User, jenkins.Token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Jenkins answers /stop with a redirect to the build page
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("stop returned status: %s", resp.Status)
+	}
+	log.Printf("Stopped Jenkins build %s", buildURL)
+	return nil
+}
+
+// Short random ID users can type in "cancel <id>"
+func newExecutionID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%08x", time.Now().UnixNano()&0xffffffff)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Handle "cancel <id>", allowed for the invoker and admin users, and "cancel all" for admins
+func handleCancelCommand(messenger Messenger, msg incomingMessage, config *Config, state *botState, id string) {
+	reply := func(text string) {
+		if err := messenger.PostEphemeral(msg.ChannelID, msg.UserID, text); err != nil {
+			log.Printf("Error sending message to Slack: %v", err)
+		}
+	}
+
+	// "cancel all" aborts everything in flight, for admins during an incident
+	if id == "all" {
+		if !isAdminUser(config, msg.UserID) {
+			reply(localize(config, msg.UserID, "cancel_all_admin"))
+			return
+		}
+		cancelled := state.executions.CancelAll()
+		log.Printf("All %d running executions cancelled by %s", len(cancelled), msg.UserID)
+		if err := messenger.PostMessage(msg.ChannelID, localize(config, msg.UserID, "cancelled_all", "count", strconv.Itoa(len(cancelled)), "user", msg.UserID)); err != nil {
+			log.Printf("Error sending message to Slack: %v", err)
+		}
+		return
+	}
+
+	exec, ok := state.executions.Get(id)
+	if !ok {
+		reply(localize(config, msg.UserID, "no_execution", "id", id))
+		return
+	}
+	if exec.User != msg.UserID && !isAdminUser(config, msg.UserID) {
+		reply(localize(config, msg.UserID, "cancel_not_owner"))
+		return
+	}
+
+	state.executions.Cancel(id)
+	log.Printf("Execution %s (%s) cancelled by %s", id, exec.Command, msg.UserID)
+	if err := messenger.PostMessage(msg.ChannelID, localize(config, msg.UserID, "cancelled", "id", id, "command", exec.Command, "user", msg.UserID)); err != nil {
+		log.Printf("Error sending message to Slack: %v", err)
+	}
+}
+
+// Format the list of running executions for the status command
+func formatRunningExecutions(config *Config, userID string, executions []runningExecution) string {
+	if len(executions) == 0 {
+		return localize(config, userID, "nothing_running")
+	}
+	var b strings.Builder
+	b.WriteString(localize(config, userID, "running_header") + "\n")
+	for _, exec := range executions {
+		b.WriteString(localize(config, userID, "running_entry", "id", exec.ID, "command", exec.Command, "user", exec.User, "elapsed", time.Since(exec.Started).Round(time.Second).String()))
+		if exec.buildURL != "" {
+			fmt.Fprintf(&b, " (%s)", exec.buildURL)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Tell the channel a command started and how to cancel it. Returns the
+// message's timestamp when update_running_message will edit it into the result.
+func postRunningMessage(messenger Messenger, config *Config, channelID string, exec *runningExecution) string {
+	response := localize(config, exec.User, "running_task", "command", exec.Command, "id", exec.ID)
+	if updater, ok := messenger.(messageUpdater); ok && config.UpdateRunningMessage {
+		timestamp, err := updater.PostMessageTS(channelID, response)
+		if err != nil {
+			log.Printf("Error sending message to Slack: %v", err)
+		}
+		return timestamp
+	}
+	if err := messenger.PostMessage(channelID, response); err != nil {
+		log.Printf("Error sending message to Slack: %v", err)
+	}
+	return ""
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var executionIDPattern = regexp.MustCompile(`execution ID ([0-9a-f]+)`)
+
+// Wait for the interim "Running ..." notice and return the execution ID it announces
+func waitForExecutionID(t *testing.T, messenger *fakeMessenger) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, text := range messenger.texts() {
+			if match := executionIDPattern.FindStringSubmatch(text); match != nil {
+				return match[1]
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("no execution ID posted")
+	return ""
+}
+
+func TestCancelStopsInFlightRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		canceller  string
+		wantCancel bool
+		wantReply  string
+	}{
+		{name: "invoker", canceller: "U1", wantCancel: true, wantReply: "cancelled by <@U1>"},
+		{name: "admin", canceller: "UADMIN", wantCancel: true, wantReply: "cancelled by <@UADMIN>"},
+		{name: "other user", canceller: "U2", wantCancel: false, wantReply: "Only the user who started this execution or an admin can cancel it."},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			aborted := make(chan struct{}, 1)
+			release := make(chan struct{})
+			target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-r.Context().Done():
+					aborted <- struct{}{}
+				case <-release:
+				}
+			}))
+			defer target.Close()
+			defer close(release)
+
+			config := &Config{AdminUsers: []string{"UADMIN"}}
+			store := newConfigTaskStore(map[string]Task{"reindex": {Command: "reindex", URL: target.URL, Method: "POST"}})
+			state := newBotState(config)
+			messenger := newFakeMessenger()
+			done := make(chan struct{})
+			go func() {
+				handleMessageEvent(context.Background(), messenger, messageEvent("U1", "reindex"), config, store, state)
+				close(done)
+			}()
+			id := waitForExecutionID(t, messenger)
+
+			canceller := newFakeMessenger()
+			handleMessageEvent(context.Background(), canceller, messageEvent(test.canceller, "cancel "+id), config, store, state)
+			if replies := canceller.texts(); len(replies) != 1 || !strings.Contains(replies[0], test.wantReply) {
+				t.Errorf("cancel replied %q, want %q", replies, test.wantReply)
+			}
+
+			select {
+			case <-aborted:
+				if !test.wantCancel {
+					t.Fatal("request aborted by a user who may not cancel it")
+				}
+			case <-time.After(200 * time.Millisecond):
+				if test.wantCancel {
+					t.Fatal("in-flight request was not aborted")
+				}
+				release <- struct{}{}
+			}
+			<-done
+
+			if _, running := state.executions.Get(id); running {
+				t.Error("finished execution still registered")
+			}
+		})
+	}
+}
+
+func TestCancelUnknownExecution(t *testing.T) {
+	config := &Config{}
+	messenger := newFakeMessenger()
+	handleMessageEvent(context.Background(), messenger, messageEvent("U1", "cancel deadbeef"), config, newConfigTaskStore(nil), newBotState(config))
+
+	sent := messenger.sent()
+	if len(sent) != 1 || sent[0].UserID != "U1" || sent[0].Text != "No running execution with ID 'deadbeef'." {
+		t.Errorf("sent %+v, want an ephemeral not-found reply", sent)
+	}
+}
+
+// Cancelling a deploy also asks Jenkins to stop the build it started
+func TestCancelStopsJenkinsBuild(t *testing.T) {
+	var mu sync.Mutex
+	stopped := false
+	var jenkins *httptest.Server
+	jenkins = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/job/api-prod/build":
+			w.Header().Set("Location", jenkins.URL+"/queue/item/1/")
+			w.WriteHeader(http.StatusCreated)
+		case "/queue/item/1/api/json":
+			fmt.Fprintf(w, `{"executable": {"url": "%s/job/api-prod/7/"}}`, jenkins.URL)
+		case "/job/api-prod/7/api/json":
+			fmt.Fprint(w, `{"building": true}`)
+		case "/job/api-prod/7/stop":
+			mu.Lock()
+			stopped = r.Method == http.MethodPost
+			mu.Unlock()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer jenkins.Close()
+
+	config := &Config{Jenkins: JenkinsConfig{URLFormat: jenkins.URL + "/job/{service-name}-{env}/build", WaitForResult: true, PollIntervalSeconds: 1}}
+	state := newBotState(config)
+	messenger := newFakeMessenger()
+	done := make(chan struct{})
+	go func() {
+		handleMessageEvent(context.Background(), messenger, messageEvent("U1", "deploy api prod"), config, newConfigTaskStore(nil), state)
+		close(done)
+	}()
+	id := waitForExecutionID(t, messenger)
+
+	// Wait until the poller has found the build
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		exec, ok := state.executions.Get(id)
+		state.executions.mu.Lock()
+		found := ok && exec.buildURL != ""
+		state.executions.mu.Unlock()
+		if found {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("build URL never recorded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	handleMessageEvent(context.Background(), newFakeMessenger(), messageEvent("U1", "cancel "+id), config, newConfigTaskStore(nil), state)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("deploy kept polling after cancel")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !stopped {
+		t.Error("Jenkins /stop was not called")
+	}
+}
+
+func TestFormatRunningExecutions(t *testing.T) {
+	started := time.Now().Add(-90 * time.Second)
+	tests := []struct {
+		name       string
+		executions []runningExecution
+		want       string
+	}{
+		{name: "nothing running", want: "Nothing is running right now."},
+		{
+			name:       "task",
+			executions: []runningExecution{{ID: "ab12", Command: "restart", User: "U1", Started: started}},
+			want:       "Running executions:\n- ab12: 'restart' started by <@U1> 1m30s ago\n",
+		},
+		{
+			name:       "deploy with its build",
+			executions: []runningExecution{{ID: "cd34", Command: "deploy api prod", User: "U2", Started: started, buildURL: "https://ci/job/api/7/"}},
+			want:       "Running executions:\n- cd34: 'deploy api prod' started by <@U2> 1m30s ago (https://ci/job/api/7/)\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := formatRunningExecutions(&Config{}, "U1", test.executions); got != test.want {
+				t.Errorf("formatRunningExecutions = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// status lists an in-flight execution, unless a task named status exists; running always lists
+func TestStatusListsRunningExecutions(t *testing.T) {
+	release := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+	}))
+	defer target.Close()
+
+	tasks := map[string]Task{"reindex": {Command: "reindex", URL: target.URL + "/slow", Method: "POST"}}
+	config := &Config{DebounceMillis: -1}
+	state := newBotState(config)
+	messenger := newFakeMessenger()
+	done := make(chan struct{})
+	go func() {
+		handleMessageEvent(context.Background(), messenger, messageEvent("U1", "reindex"), config, newConfigTaskStore(tasks), state)
+		close(done)
+	}()
+	id := waitForExecutionID(t, messenger)
+
+	withStatusTask := map[string]Task{"status": {Command: "status", URL: target.URL + "/status", Method: "GET"}}
+	tests := []struct {
+		text  string
+		tasks map[string]Task
+		want  string
+	}{
+		{text: "status", tasks: tasks, want: fmt.Sprintf("Running executions:\n- %s: 'reindex' started by <@U1> 0s ago\n", id)},
+		{text: "Running", tasks: withStatusTask, want: fmt.Sprintf("Running executions:\n- %s: 'reindex' started by <@U1> 0s ago\n", id)},
+		{text: "status", tasks: withStatusTask, want: "Task 'status' executed successfully."},
+	}
+	for _, test := range tests {
+		replies := newFakeMessenger()
+		handleMessageEvent(context.Background(), replies, messageEvent("U2", test.text), config, newConfigTaskStore(test.tasks), state)
+		if got := replies.results(); len(got) != 1 || got[0] != test.want {
+			t.Errorf("%q replied %q, want %q", test.text, got, test.want)
+		}
+	}
+
+	close(release)
+	<-done
+	replies := newFakeMessenger()
+	handleMessageEvent(context.Background(), replies, messageEvent("U2", "running"), config, newConfigTaskStore(tasks), state)
+	if got := replies.results(); len(got) != 1 || got[0] != "Nothing is running right now." {
+		t.Errorf("after the run finished, running replied %q", got)
+	}
+}
+
+// Only admins may cancel every running execution at once
+func TestCancelAll(t *testing.T) {
+	tests := []struct {
+		name          string
+		userID        string
+		wantCancelled bool
+		wantReply     string
+		wantPrivate   bool
+	}{
+		{name: "admin", userID: "UADMIN", wantCancelled: true, wantReply: "Cancelled 2 running execution(s) at the request of <@UADMIN>."},
+		{name: "not an admin", userID: "U1", wantReply: "Only admins can cancel all executions.", wantPrivate: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &Config{AdminUsers: []string{"UADMIN"}}
+			state := newBotState(config)
+			first, _ := state.executions.Start(context.Background(), "a", "U1", "C1")
+			second, _ := state.executions.Start(context.Background(), "b", "U2", "C1")
+
+			messenger := newFakeMessenger()
+			handleMessageEvent(context.Background(), messenger, messageEvent(test.userID, "cancel all"), config, newConfigTaskStore(nil), state)
+
+			for _, ctx := range []context.Context{first, second} {
+				if cancelled := ctx.Err() != nil; cancelled != test.wantCancelled {
+					t.Errorf("cancelled = %v, want %v", cancelled, test.wantCancelled)
+				}
+			}
+			sent := messenger.sent()
+			if len(sent) != 1 || sent[0].Text != test.wantReply || (sent[0].UserID != "") != test.wantPrivate {
+				t.Errorf("replies = %+v, want %q (ephemeral %v)", sent, test.wantReply, test.wantPrivate)
+			}
+		})
+	}
+}
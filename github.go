@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const defaultGitHubAPIURL = "https://api.github.com"
+
+// GitHubWorkflow runs a GitHub Actions workflow through workflow_dispatch
+// instead of calling the task URL
+type GitHubWorkflow struct {
+	Repo          string   `json:"repo"`                     // owner/name
+	Workflow      string   `json:"workflow"`                 // Workflow file name or ID, e.g. deploy.yml
+	Ref           string   `json:"ref"`                      // Branch or tag the workflow runs on
+	Token         string   `json:"token,omitempty"`          // Token with actions:write on the repo
+	APIURL        string   `json:"api_url,omitempty"`        // GitHub Enterprise API URL (default https://api.github.com)
+	AllowedInputs []string `json:"allowed_inputs,omitempty"` // Inputs users may pass as key=value arguments
+
+	inputs map[string]string // Inputs parsed from the command for this run
+}
+
+// Split "<command> key=value ..." into the command and its workflow inputs.
+// Returns false unless every argument after the command is a key=value pair.
+func splitWorkflowInputs(text string) (string, map[string]string, bool) {
+	args, err := splitArgs(text)
+	if err != nil || len(args) < 2 {
+		return "", nil, false
+	}
+	inputs := make(map[string]string, len(args)-1)
+	for _, arg := range args[1:] {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return "", nil, false
+		}
+		inputs[key] = value
+	}
+	return strings.ToLower(args[0]), inputs, true
+}
+
+// Reject inputs that are not in the workflow's allowed_inputs
+func validateWorkflowInputs(workflow *GitHubWorkflow, inputs map[string]string) error {
+	allowed := make(map[string]bool, len(workflow.AllowedInputs))
+	for _, name := range workflow.AllowedInputs {
+		allowed[name] = true
+	}
+	var unknown []string
+	for name := range inputs {
+		if !allowed[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		if len(workflow.AllowedInputs) == 0 {
+			return fmt.Errorf("this workflow takes no inputs, got: %s", strings.Join(unknown, ", "))
+		}
+		return fmt.Errorf("unknown inputs: %s (allowed: %s)", strings.Join(unknown, ", "), strings.Join(workflow.AllowedInputs, ", "))
+	}
+	return nil
+}
+
+// Copy the task with the inputs to send for this run
+func withWorkflowInputs(task Task, inputs map[string]string) Task {
+	workflow := *task.GitHubWorkflow
+	workflow.inputs = inputs
+	task.GitHubWorkflow = &workflow
+	return task
+}
+
+// Check a workflow task's settings
+func validateGitHubWorkflow(workflow *GitHubWorkflow) error {
+	if workflow.Repo == "" || workflow.Workflow == "" || workflow.Ref == "" {
+		return fmt.Errorf("github_workflow needs repo, workflow and ref")
+	}
+	if !strings.Contains(workflow.Repo, "/") {
+		return fmt.Errorf("github_workflow repo must be owner/name, got %q", workflow.Repo)
+	}
+	return nil
+}
+
+// Trigger the task's workflow with a workflow_dispatch event. GitHub answers
+// 204 once the run is queued.
+func executeGitHubWorkflow(ctx context.Context, config *Config, task Task) taskResult {
+	workflow := task.GitHubWorkflow
+	apiURL := workflow.APIURL
+	if apiURL == "" {
+		apiURL = defaultGitHubAPIURL
+	}
+	dispatchURL := fmt.Sprintf("%s/repos/%s/actions/workflows/%s/dispatches", strings.TrimSuffix(apiURL, "/"), workflow.Repo, url.PathEscape(workflow.Workflow))
+
+	if err := checkTargetAllowed(ctx, config, dispatchURL); err != nil {
+		log.Printf("Blocked workflow task '%s' at %s: %v", task.Command, dispatchURL, err)
+		return taskResult{}
+	}
+	ctx, cancel := requestContext(withTargetGuard(ctx, config), taskRequestTimeout(task))
+	defer cancel()
+
+	body := map[string]interface{}{"ref": workflow.Ref}
+	if len(workflow.inputs) > 0 {
+		body["inputs"] = workflow.inputs
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		log.Printf("Error encoding workflow_dispatch body for task '%s': %v", task.Command, err)
+		return taskResult{}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", dispatchURL, bytes.NewReader(payload))
+	if err != nil {
+		log.Printf("Error creating request for task '%s': %v", task.Command, err)
+		return taskResult{}
+	}
+	result := taskResult{RequestID: setOutboundHeaders(req, config)}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	token := workflow.Token
+	if token == "" {
+		token = task.Token
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		log.Printf("Error dispatching workflow for task '%s' (request ID %s): %v", task.Command, result.RequestID, err)
+		return result
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	result.Body, result.Truncated, _ = readLimited(resp.Body, maxResponseBytes(config))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Printf("Workflow dispatch for task '%s' failed (request ID %s), response status: %s", task.Command, result.RequestID, resp.Status)
+		return result
+	}
+	log.Printf("Workflow %s in %s dispatched for task '%s' (request ID %s)", workflow.Workflow, workflow.Repo, task.Command, result.RequestID)
+	result.Success = true
+	return result
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitWorkflowInputs(t *testing.T) {
+	tests := []struct {
+		text       string
+		wantName   string
+		wantInputs map[string]string
+		wantOK     bool
+	}{
+		{text: "Release env=prod version=1.2", wantName: "release", wantInputs: map[string]string{"env": "prod", "version": "1.2"}, wantOK: true},
+		{text: `release notes="fix the build"`, wantName: "release", wantInputs: map[string]string{"notes": "fix the build"}, wantOK: true},
+		{text: "release dry_run=", wantName: "release", wantInputs: map[string]string{"dry_run": ""}, wantOK: true},
+		{text: "release"},
+		{text: "release prod"},
+		{text: "release env=prod extra"},
+		{text: "release =prod"},
+		{text: `release notes="unterminated`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			name, inputs, ok := splitWorkflowInputs(test.text)
+			if ok != test.wantOK || name != test.wantName || !reflect.DeepEqual(inputs, test.wantInputs) {
+				t.Errorf("splitWorkflowInputs(%q) = %q, %v, %v, want %q, %v, %v", test.text, name, inputs, ok, test.wantName, test.wantInputs, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestValidateWorkflowInputs(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		inputs  map[string]string
+		wantErr string
+	}{
+		{name: "allowed", allowed: []string{"env", "version"}, inputs: map[string]string{"env": "prod"}},
+		{name: "none passed", allowed: []string{"env"}},
+		{name: "unknown", allowed: []string{"env", "version"}, inputs: map[string]string{"zone": "a", "branch": "main", "env": "prod"}, wantErr: "unknown inputs: branch, zone (allowed: env, version)"},
+		{name: "no inputs allowed", inputs: map[string]string{"env": "prod"}, wantErr: "this workflow takes no inputs, got: env"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateWorkflowInputs(&GitHubWorkflow{AllowedInputs: test.allowed}, test.inputs)
+			if test.wantErr == "" && err != nil || test.wantErr != "" && (err == nil || err.Error() != test.wantErr) {
+				t.Errorf("validateWorkflowInputs() error = %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateGitHubWorkflow(t *testing.T) {
+	tests := []struct {
+		name     string
+		workflow GitHubWorkflow
+		wantErr  bool
+	}{
+		{name: "valid", workflow: GitHubWorkflow{Repo: "acme/api", Workflow: "deploy.yml", Ref: "main"}},
+		{name: "missing ref", workflow: GitHubWorkflow{Repo: "acme/api", Workflow: "deploy.yml"}, wantErr: true},
+		{name: "missing workflow", workflow: GitHubWorkflow{Repo: "acme/api", Ref: "main"}, wantErr: true},
+		{name: "repo without owner", workflow: GitHubWorkflow{Repo: "api", Workflow: "deploy.yml", Ref: "main"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := validateGitHubWorkflow(&test.workflow); (err != nil) != test.wantErr {
+				t.Errorf("validateGitHubWorkflow() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecuteGitHubWorkflow(t *testing.T) {
+	type dispatch struct {
+		path string
+		auth string
+		body map[string]interface{}
+	}
+	received := make(chan dispatch, 1)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]interface{}
+		json.Unmarshal(data, &body)
+		received <- dispatch{path: r.URL.EscapedPath(), auth: r.Header.Get("Authorization"), body: body}
+		if strings.Contains(r.URL.Path, "/missing/") {
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer api.Close()
+
+	tests := []struct {
+		name        string
+		task        Task
+		wantPath    string
+		wantAuth    string
+		wantBody    map[string]interface{}
+		wantSuccess bool
+	}{
+		{
+			name:        "dispatched with inputs",
+			task:        withWorkflowInputs(Task{Command: "release", GitHubWorkflow: &GitHubWorkflow{Repo: "acme/api", Workflow: "deploy prod.yml", Ref: "main", Token: "ghp_workflow", APIURL: api.URL + "/"}}, map[string]string{"env": "prod"}),
+			wantPath:    "/repos/acme/api/actions/workflows/deploy%20prod.yml/dispatches",
+			wantAuth:    "Bearer ghp_workflow",
+			wantBody:    map[string]interface{}{"ref": "main", "inputs": map[string]interface{}{"env": "prod"}},
+			wantSuccess: true,
+		},
+		{
+			name:        "task token as fallback, no inputs",
+			task:        Task{Command: "release", Token: "ghp_task", GitHubWorkflow: &GitHubWorkflow{Repo: "acme/api", Workflow: "42", Ref: "v1.0", APIURL: api.URL}},
+			wantPath:    "/repos/acme/api/actions/workflows/42/dispatches",
+			wantAuth:    "Bearer ghp_task",
+			wantBody:    map[string]interface{}{"ref": "v1.0"},
+			wantSuccess: true,
+		},
+		{
+			name:     "GitHub error",
+			task:     Task{Command: "release", GitHubWorkflow: &GitHubWorkflow{Repo: "acme/missing", Workflow: "deploy.yml", Ref: "main", APIURL: api.URL}},
+			wantPath: "/repos/acme/missing/actions/workflows/deploy.yml/dispatches",
+			wantBody: map[string]interface{}{"ref": "main"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := executeGitHubWorkflow(context.Background(), &Config{}, test.task)
+			if result.Success != test.wantSuccess {
+				t.Errorf("Success = %v, want %v", result.Success, test.wantSuccess)
+			}
+			got := <-received
+			if got.path != test.wantPath || got.auth != test.wantAuth || !reflect.DeepEqual(got.body, test.wantBody) {
+				t.Errorf("dispatch = %+v, want path %s, auth %q, body %v", got, test.wantPath, test.wantAuth, test.wantBody)
+			}
+		})
+	}
+
+	// The inputs belong to one run and don't leak into the task definition
+	task := Task{GitHubWorkflow: &GitHubWorkflow{Repo: "acme/api"}}
+	withWorkflowInputs(task, map[string]string{"env": "prod"})
+	if task.GitHubWorkflow.inputs != nil {
+		t.Errorf("withWorkflowInputs changed the original task: %v", task.GitHubWorkflow.inputs)
+	}
+}
+
+// "release env=prod" runs the workflow task, unknown inputs are rejected before anything is sent
+func TestHandleMessageWorkflowInputs(t *testing.T) {
+	dispatches := make(chan map[string]interface{}, 1)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		dispatches <- body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer api.Close()
+	tasks := map[string]Task{
+		"release": {Command: "release", GitHubWorkflow: &GitHubWorkflow{Repo: "acme/api", Workflow: "deploy.yml", Ref: "main", APIURL: api.URL, AllowedInputs: []string{"env"}}},
+	}
+
+	tests := []struct {
+		text       string
+		wantReply  string
+		wantInputs interface{}
+	}{
+		{text: "release env=prod", wantReply: "Task 'release' executed successfully.", wantInputs: map[string]interface{}{"env": "prod"}},
+		{text: "release", wantReply: "Task 'release' executed successfully."},
+		{text: "release zone=a", wantReply: "Invalid inputs for 'release': unknown inputs: zone (allowed: env)."},
+	}
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			config := &Config{}
+			messenger := newFakeMessenger()
+			handleMessageEvent(context.Background(), messenger, messageEvent("U1", test.text), config, newConfigTaskStore(tasks), newBotState(config))
+
+			sent := messenger.sent()
+			if len(sent) == 0 || !strings.HasPrefix(sent[len(sent)-1].Text, test.wantReply) {
+				t.Fatalf("replies = %+v, want %q", sent, test.wantReply)
+			}
+			select {
+			case body := <-dispatches:
+				if !reflect.DeepEqual(body["inputs"], test.wantInputs) {
+					t.Errorf("inputs = %v, want %v", body["inputs"], test.wantInputs)
+				}
+			default:
+				if strings.HasPrefix(test.wantReply, "Task") {
+					t.Error("workflow was not dispatched")
+				}
+			}
+		})
+	}
+}
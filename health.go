@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Timeout for a task's pre-flight health check
+const healthCheckTimeout = 10 * time.Second
+
+// GET the task's health_check_url and report an error unless it answers 2xx
+func checkTaskHealth(ctx context.Context, config *Config, task Task) error {
+	healthURL, err := expandEnvRefs(task.HealthCheckURL, config.StrictEnv)
+	if err != nil {
+		return err
+	}
+	if err := checkTargetAllowed(ctx, config, healthURL); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(withTargetGuard(ctx, config), healthCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", healthURL, nil)
+	if err != nil {
+		return err
+	}
+	setOutboundHeaders(req, config)
+
+	client, err := taskHTTPClient(task)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("health check returned status: %s", resp.Status)
+	}
+	return nil
+}
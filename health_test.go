@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// A health endpoint: /healthy answers 204, /redirect points there, anything else is 503
+func newHealthServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthy":
+			w.WriteHeader(http.StatusNoContent)
+		case "/redirect":
+			http.Redirect(w, r, "/healthy", http.StatusFound)
+		default:
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCheckTaskHealth(t *testing.T) {
+	server := newHealthServer(t)
+	t.Setenv("BOT_TEST_HEALTH", server.URL)
+
+	tests := []struct {
+		name    string
+		url     string
+		config  Config
+		wantErr string
+	}{
+		{name: "healthy", url: server.URL + "/healthy"},
+		{name: "redirect followed", url: server.URL + "/redirect"},
+		{name: "unhealthy", url: server.URL + "/down", wantErr: "health check returned status: 503 Service Unavailable"},
+		{name: "env reference", url: "{env:BOT_TEST_HEALTH}/healthy"},
+		{name: "strict env", url: "{env:BOT_TEST_UNSET}/healthy", config: Config{StrictEnv: true}, wantErr: "environment variables not set"},
+		{name: "blocked by SSRF guard", url: server.URL + "/healthy", config: Config{SSRFGuard: true}, wantErr: "127.0.0.1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkTaskHealth(context.Background(), &test.config, Task{HealthCheckURL: test.url})
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkTaskHealth() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("checkTaskHealth() error = %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+// The task isn't called when its health check fails
+func TestHandleMessageHealthCheck(t *testing.T) {
+	health := newHealthServer(t)
+	target, hits := newStubTarget(t)
+	tasks := map[string]Task{
+		"restart": {Command: "restart", URL: target.URL + "/ok", Method: "POST", HealthCheckURL: health.URL + "/healthy"},
+		"purge":   {Command: "purge", URL: target.URL + "/ok", Method: "POST", HealthCheckURL: health.URL + "/down"},
+	}
+
+	tests := []struct {
+		command   string
+		wantReply string
+		wantHits  int
+	}{
+		{command: "restart", wantReply: "Task 'restart' executed successfully.", wantHits: 1},
+		{command: "purge", wantReply: "'purge' was not run: target unhealthy (health check returned status: 503 Service Unavailable).", wantHits: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.command, func(t *testing.T) {
+			config := &Config{}
+			messenger := newFakeMessenger()
+			before := len(hits())
+			handleMessageEvent(context.Background(), messenger, messageEvent("U1", test.command), config, newConfigTaskStore(tasks), newBotState(config))
+			if replies := messenger.results(); len(replies) != 1 || replies[0] != test.wantReply {
+				t.Errorf("replies = %q, want %q", replies, test.wantReply)
+			}
+			if got := len(hits()) - before; got != test.wantHits {
+				t.Errorf("target called %d times, want %d", got, test.wantHits)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Number of executions kept when history_size is not configured
+const defaultHistorySize = 20
+
+// One recorded command execution
+type historyEntry struct {
+	Command  string
+	User     string
+	Success  bool
+	Time     time.Time
+	Duration time.Duration
+}
+
+// executionHistory is a fixed-size ring buffer of recent executions
+type executionHistory struct {
+	mu      sync.Mutex
+	entries []historyEntry
+	next    int
+	full    bool
+}
+
+func newExecutionHistory(size int) *executionHistory {
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	return &executionHistory{entries: make([]historyEntry, size)}
+}
+
+// Record an execution, overwriting the oldest entry once the buffer is full
+func (h *executionHistory) Add(entry historyEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// Return recorded executions newest first, optionally only for one command
+func (h *executionHistory) Recent(command string) []historyEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	count := h.next
+	if h.full {
+		count = len(h.entries)
+	}
+
+	var recent []historyEntry
+	for i := 1; i <= count; i++ {
+		entry := h.entries[(h.next-i+len(h.entries))%len(h.entries)]
+		name := strings.ToLower(entry.Command)
+		if command == "" || name == command || strings.HasPrefix(name, command+" ") {
+			recent = append(recent, entry)
+		}
+	}
+	return recent
+}
+
+// Format the history reply posted to Slack
+func formatHistory(config *Config, userID string, entries []historyEntry, command string) string {
+	if len(entries) == 0 {
+		if command != "" {
+			return localize(config, userID, "history_none_for", "command", command)
+		}
+		return localize(config, userID, "history_none")
+	}
+
+	var b strings.Builder
+	b.WriteString(localize(config, userID, "history_header") + "\n")
+	for _, entry := range entries {
+		status := localize(config, userID, "run_success")
+		if !entry.Success {
+			status = localize(config, userID, "run_failed")
+		}
+		b.WriteString(fmt.Sprintf("- %s `%s` by <@%s>: %s (%s)\n",
+			entry.Time.UTC().Format("2006-01-02 15:04:05 UTC"), entry.Command, entry.User, status, entry.Duration.Round(time.Millisecond)))
+	}
+	return b.String()
+}
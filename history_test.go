@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecutionHistoryBounded(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      int
+		added     int
+		wantCount int
+		wantFirst string // newest entry
+		wantLast  string // oldest entry still kept
+	}{
+		{name: "partly filled", size: 5, added: 3, wantCount: 3, wantFirst: "task 3", wantLast: "task 1"},
+		{name: "exactly full", size: 3, added: 3, wantCount: 3, wantFirst: "task 3", wantLast: "task 1"},
+		{name: "oldest overwritten", size: 3, added: 7, wantCount: 3, wantFirst: "task 7", wantLast: "task 5"},
+		{name: "default size", size: 0, added: defaultHistorySize + 5, wantCount: defaultHistorySize, wantFirst: fmt.Sprintf("task %d", defaultHistorySize+5), wantLast: "task 6"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			history := newExecutionHistory(test.size)
+			for i := 1; i <= test.added; i++ {
+				history.Add(historyEntry{Command: fmt.Sprintf("task %d", i), User: "U1", Success: true})
+			}
+			recent := history.Recent("")
+			if len(recent) != test.wantCount {
+				t.Fatalf("%d entries, want %d", len(recent), test.wantCount)
+			}
+			if recent[0].Command != test.wantFirst || recent[len(recent)-1].Command != test.wantLast {
+				t.Errorf("entries run %q..%q, want %q..%q", recent[0].Command, recent[len(recent)-1].Command, test.wantFirst, test.wantLast)
+			}
+		})
+	}
+}
+
+func TestExecutionHistoryFilter(t *testing.T) {
+	history := newExecutionHistory(10)
+	for _, command := range []string{"deploy api prod", "restart", "Deploy web staging", "deployment-report"} {
+		history.Add(historyEntry{Command: command})
+	}
+
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{command: "deploy", want: []string{"Deploy web staging", "deploy api prod"}},
+		{command: "restart", want: []string{"restart"}},
+		{command: "deployment-report", want: []string{"deployment-report"}},
+		{command: "missing", want: nil},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, entry := range history.Recent(test.command) {
+			got = append(got, entry.Command)
+		}
+		if strings.Join(got, "|") != strings.Join(test.want, "|") {
+			t.Errorf("Recent(%q) = %q, want %q", test.command, got, test.want)
+		}
+	}
+}
+
+// Executions recorded by the handlers show up in the history reply
+func TestRecordExecutionAppearsInHistory(t *testing.T) {
+	state := newBotState(&Config{HistorySize: 2})
+	config := &Config{}
+	state.recordExecution(config, "restart", "U1", true, 1500*time.Millisecond)
+	state.recordExecution(config, "deploy api prod", "U2", false, 2*time.Second)
+
+	reply := formatHistory(&Config{}, "U1", state.history.Recent(""), "")
+	for _, want := range []string{"`deploy api prod` by <@U2>: failed (2s)", "`restart` by <@U1>: success (1.5s)"} {
+		if !strings.Contains(reply, want) {
+			t.Errorf("reply %q missing %q", reply, want)
+		}
+	}
+	if strings.Index(reply, "deploy api prod") > strings.Index(reply, "restart") {
+		t.Error("history not listed newest first")
+	}
+
+	if got := formatHistory(&Config{}, "U1", nil, ""); got != "No commands have been executed yet." {
+		t.Errorf("empty history reply = %q", got)
+	}
+	if got := formatHistory(&Config{}, "U1", nil, "restart"); got != "No recent executions of 'restart'." {
+		t.Errorf("empty filtered reply = %q", got)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// Category of tasks without one on the Home tab
+const defaultCategory = "Other"
+
+// Recent executions shown on the Home tab
+const homeRecentRuns = 10
+
+// Longest text of one Home tab section, under Slack's 3000 character limit
+const homeSectionLimit = 2900
+
+// homePublisher is implemented by backends with an app Home tab
+type homePublisher interface {
+	PublishHome(userID string, view slack.HomeTabViewRequest) error
+}
+
+func (m *slackMessenger) PublishHome(userID string, view slack.HomeTabViewRequest) error {
+	_, err := m.api.PublishView(userID, view, "")
+	return warnOnAuthError(err)
+}
+
+// Handle an app_home_opened event: publish the user's Home tab with the
+// commands they may run and their recent executions
+func handleAppHomeOpened(messenger Messenger, evt map[string]interface{}, config *Config, store TaskStore, state *botState) {
+	publisher, ok := messenger.(homePublisher)
+	userID, _ := evt["user"].(string)
+	if !ok || userID == "" || evt["tab"] != "home" {
+		return
+	}
+	tasks, err := store.ListTasks()
+	if err != nil {
+		log.Printf("Error listing tasks: %v", err)
+	}
+	var recent []historyEntry
+	for _, entry := range state.history.Recent("") {
+		if entry.User == userID {
+			recent = append(recent, entry)
+			if len(recent) == homeRecentRuns {
+				break
+			}
+		}
+	}
+	if err := publisher.PublishHome(userID, homeView(config, tasks, recent, userID)); err != nil {
+		log.Printf("Error publishing Home tab for %s: %v", userID, err)
+	}
+}
+
+// Build the Home tab: the commands the user may run grouped by category,
+// then their recent executions, newest first
+func homeView(config *Config, tasks map[string]Task, recent []historyEntry, userID string) slack.HomeTabViewRequest {
+	categories := make(map[string][]string)
+	for command, task := range tasks {
+		if !isUserAllowed(config, task.AllowedUsers, userID) {
+			continue
+		}
+		category := task.Category
+		if category == "" {
+			category = defaultCategory
+		}
+		usage := command
+		if len(task.Args) > 0 {
+			usage = argsUsage(command, task.Args)
+		}
+		categories[category] = append(categories[category], "`"+usage+"`")
+	}
+	if config.Jenkins.URLFormat != "" && isUserAllowed(config, config.Jenkins.AllowedUsers, userID) {
+		categories["Deploys"] = append(categories["Deploys"], "`deploy <service-name> <env> [branch]`")
+	}
+
+	names := make([]string, 0, len(categories))
+	for name := range categories {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		// Uncategorized commands go last
+		if (names[i] == defaultCategory) != (names[j] == defaultCategory) {
+			return names[j] == defaultCategory
+		}
+		return names[i] < names[j]
+	})
+
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, "Commands", false, false)),
+	}
+	if len(names) == 0 {
+		blocks = append(blocks, homeSection("No commands are available to you."))
+	}
+	for _, name := range names {
+		commands := categories[name]
+		sort.Strings(commands)
+		blocks = append(blocks, homeSection("*"+name+"*\n"+joinWithinLimit(commands, homeSectionLimit-len(name)-4)))
+	}
+
+	blocks = append(blocks,
+		slack.NewDividerBlock(),
+		slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, "Your recent runs", false, false)),
+	)
+	if len(recent) == 0 {
+		blocks = append(blocks, homeSection("You haven't run any commands yet."))
+	} else {
+		lines := make([]string, 0, len(recent))
+		for _, entry := range recent {
+			icon := ":white_check_mark:"
+			if !entry.Success {
+				icon = ":x:"
+			}
+			lines = append(lines, fmt.Sprintf("%s `%s` %s ago (%s)", icon, entry.Command, time.Since(entry.Time).Round(time.Second), entry.Duration.Round(time.Millisecond)))
+		}
+		blocks = append(blocks, homeSection(joinWithinLimit(lines, homeSectionLimit)))
+	}
+
+	return slack.HomeTabViewRequest{Type: slack.VTHomeTab, Blocks: slack.Blocks{BlockSet: blocks}}
+}
+
+func homeSection(text string) *slack.SectionBlock {
+	return slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil)
+}
+
+// Join lines until the text would exceed limit, then say how many were left out
+func joinWithinLimit(lines []string, limit int) string {
+	var b strings.Builder
+	for i, line := range lines {
+		if b.Len()+len(line)+1 > limit-20 {
+			fmt.Fprintf(&b, "…and %d more", len(lines)-i)
+			break
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
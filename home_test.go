@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// fakeHome is a fakeMessenger that records published Home tabs
+type fakeHome struct {
+	*fakeMessenger
+	views map[string]slack.HomeTabViewRequest
+}
+
+func (h *fakeHome) PublishHome(userID string, view slack.HomeTabViewRequest) error {
+	h.views[userID] = view
+	return nil
+}
+
+// The text of each header and section block of a view
+func viewTexts(view slack.HomeTabViewRequest) []string {
+	var texts []string
+	for _, block := range view.Blocks.BlockSet {
+		switch block := block.(type) {
+		case *slack.HeaderBlock:
+			texts = append(texts, block.Text.Text)
+		case *slack.SectionBlock:
+			texts = append(texts, block.Text.Text)
+		}
+	}
+	return texts
+}
+
+// Commands are grouped by category and only the ones the user may run are listed
+func TestHomeView(t *testing.T) {
+	tasks := map[string]Task{
+		"health":  {Category: "Checks"},
+		"status":  {Category: "Checks"},
+		"restart": {Category: "Services", Args: []ArgSpec{{Name: "service"}}},
+		"misc":    {},
+		"secret":  {Category: "Admin", AllowedUsers: []string{"UADMIN"}},
+	}
+	recent := []historyEntry{
+		{Command: "health", Success: true, Time: time.Now().Add(-time.Minute), Duration: 120 * time.Millisecond},
+		{Command: "restart api", Time: time.Now().Add(-time.Hour), Duration: 2 * time.Second},
+	}
+
+	tests := []struct {
+		name   string
+		config *Config
+		tasks  map[string]Task
+		recent []historyEntry
+		userID string
+		want   []string
+	}{
+		{
+			name:   "categories in order, uncategorized last",
+			config: &Config{},
+			tasks:  tasks,
+			userID: "U1",
+			want: []string{
+				"Commands",
+				"*Checks*\n`health`\n`status`",
+				"*Services*\n`restart <service>`",
+				"*Other*\n`misc`",
+				"Your recent runs",
+				"You haven't run any commands yet.",
+			},
+		},
+		{
+			name:   "allowlisted commands and deploys",
+			config: &Config{Jenkins: JenkinsConfig{URLFormat: "https://ci/{service-name}/{env}", AllowedUsers: []string{"UADMIN"}}},
+			tasks:  map[string]Task{"secret": tasks["secret"]},
+			userID: "UADMIN",
+			want: []string{
+				"Commands",
+				"*Admin*\n`secret`",
+				"*Deploys*\n`deploy <service-name> <env> [branch]`",
+				"Your recent runs",
+				"You haven't run any commands yet.",
+			},
+		},
+		{
+			name:   "nothing allowed, with recent runs",
+			config: &Config{},
+			tasks:  map[string]Task{"secret": tasks["secret"]},
+			recent: recent,
+			userID: "U1",
+			want: []string{
+				"Commands",
+				"No commands are available to you.",
+				"Your recent runs",
+				":white_check_mark: `health` 1m0s ago (120ms)\n:x: `restart api` 1h0m0s ago (2s)",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			view := homeView(test.config, test.tasks, test.recent, test.userID)
+			if view.Type != slack.VTHomeTab {
+				t.Errorf("view type = %q, want home", view.Type)
+			}
+			if got := viewTexts(view); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("view texts = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestJoinWithinLimit(t *testing.T) {
+	lines := []string{strings.Repeat("a", 10), strings.Repeat("b", 10), strings.Repeat("c", 10)}
+
+	tests := []struct {
+		name  string
+		limit int
+		want  string
+	}{
+		{name: "all fit", limit: 100, want: "aaaaaaaaaa\nbbbbbbbbbb\ncccccccccc"},
+		{name: "cut with a count", limit: 45, want: "aaaaaaaaaa\nbbbbbbbbbb\n…and 1 more"},
+		{name: "nothing fits", limit: 25, want: "…and 3 more"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := joinWithinLimit(lines, test.limit); got != test.want {
+				t.Errorf("joinWithinLimit(limit %d) = %q, want %q", test.limit, got, test.want)
+			}
+		})
+	}
+}
+
+// Only opening the Home tab publishes it, with the user's own recent runs
+func TestHandleAppHomeOpened(t *testing.T) {
+	config := &Config{}
+	state := newBotState(config)
+	for i := 0; i < homeRecentRuns+2; i++ {
+		state.history.Add(historyEntry{Command: "health", User: "U1", Success: true, Time: time.Now()})
+	}
+	state.history.Add(historyEntry{Command: "other", User: "U2", Time: time.Now()})
+	store := newConfigTaskStore(map[string]Task{"health": {}})
+
+	tests := []struct {
+		name      string
+		event     map[string]interface{}
+		wantViews int
+	}{
+		{name: "home tab", event: map[string]interface{}{"type": "app_home_opened", "user": "U1", "tab": "home"}, wantViews: 1},
+		{name: "messages tab", event: map[string]interface{}{"type": "app_home_opened", "user": "U1", "tab": "messages"}},
+		{name: "no user", event: map[string]interface{}{"type": "app_home_opened", "tab": "home"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messenger := &fakeHome{fakeMessenger: newFakeMessenger(), views: map[string]slack.HomeTabViewRequest{}}
+			handleMessageEvent(context.Background(), messenger, map[string]interface{}{"event": test.event}, config, store, state)
+			if len(messenger.views) != test.wantViews {
+				t.Fatalf("published %d views, want %d", len(messenger.views), test.wantViews)
+			}
+			if test.wantViews == 0 {
+				return
+			}
+			texts := viewTexts(messenger.views["U1"])
+			runs := strings.Split(texts[len(texts)-1], "\n")
+			if len(runs) != homeRecentRuns || strings.Contains(texts[len(texts)-1], "other") {
+				t.Errorf("recent runs = %q, want the user's last %d", runs, homeRecentRuns)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// Resolver for task, Jenkins and SSRF guard lookups, replaced when dns_server is set
+var targetResolver = net.DefaultResolver
+
+// Dialer for outbound connections. It tries IPv6 and IPv4 addresses of a host
+// in parallel (Happy Eyeballs), so IPv6-only targets work as well.
+var targetDialer = &net.Dialer{
+	Timeout:        30 * time.Second,
+	KeepAlive:      30 * time.Second,
+	FallbackDelay:  300 * time.Millisecond,
+	ControlContext: guardDialControl,
+}
+
+// Connection pool shared by every outbound request so keep-alive connections
+// to task hosts and Jenkins are reused
+var sharedTransport = newPooledTransport()
+
+// Longest one task, Jenkins or GitHub request may take, unless the task sets
+// timeout_seconds, so a hung target can't hold a run and its concurrency slot
+const defaultRequestTimeout = 30 * time.Second
+
+// Client for task, Jenkins and GitHub calls. Each call sets its own deadline
+// with requestContext, so the client has no timeout of its own.
+var httpClient = &http.Client{Transport: tracedTransport, CheckRedirect: guardRedirect}
+
+// Clients for tasks with their own proxy_url or ca_cert_file, by setting
+var (
+	taskClientsMu sync.Mutex
+	taskClients   = make(map[string]*http.Client)
+)
+
+// Derive the context of one outbound request, ending after timeout, or
+// defaultRequestTimeout when it is 0
+func requestContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		timeout = defaultRequestTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Request timeout of a task, from timeout_seconds
+func taskRequestTimeout(task Task) time.Duration {
+	return time.Duration(task.TimeoutSeconds) * time.Second
+}
+
+func newPooledTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialTarget
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = 10
+	transport.IdleConnTimeout = 90 * time.Second
+	return transport
+}
+
+// Return the shared client, or a pooled client for the task's proxy and CA bundle
+func taskHTTPClient(task Task) (*http.Client, error) {
+	if task.ProxyURL == "" && task.CACertFile == "" {
+		return httpClient, nil
+	}
+
+	key := task.ProxyURL + "|" + task.CACertFile
+	taskClientsMu.Lock()
+	defer taskClientsMu.Unlock()
+	if client, ok := taskClients[key]; ok {
+		return client, nil
+	}
+
+	transport := newPooledTransport()
+	if task.ProxyURL != "" {
+		proxy, err := url.Parse(task.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy_url: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if task.CACertFile != "" {
+		pem, err := os.ReadFile(task.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading ca_cert_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_cert_file %s has no PEM certificates", task.CACertFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	client := &http.Client{Transport: tracingTransport{base: transport}, CheckRedirect: guardRedirect}
+	taskClients[key] = client
+	return client, nil
+}
+
+// Resolve outbound hosts through a specific DNS server ("host" or "host:port")
+// instead of the system resolver
+func configureResolver(dnsServer string) {
+	if dnsServer == "" {
+		return
+	}
+	if _, _, err := net.SplitHostPort(dnsServer); err != nil {
+		dnsServer = net.JoinHostPort(dnsServer, "53")
+	}
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, dnsServer)
+		},
+	}
+	targetDialer.Resolver = resolver
+	targetResolver = resolver
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Repeated task runs reuse the pooled keep-alive connection
+func TestSharedTransportReusesConnections(t *testing.T) {
+	var connections atomic.Int32
+	target := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	target.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	target.Start()
+	defer target.Close()
+
+	tests := []struct {
+		name string
+		task Task
+	}{
+		{name: "GET", task: Task{Command: "health", URL: target.URL, Method: "GET"}},
+		{name: "POST", task: Task{Command: "submit", URL: target.URL, Method: "POST", Body: `{"env":"prod"}`}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sharedTransport.CloseIdleConnections()
+			connections.Store(0)
+			for i := 0; i < 3; i++ {
+				if result := sendTaskRequest(context.Background(), &Config{}, test.task); !result.Success {
+					t.Fatalf("run %d failed: %+v", i+1, result)
+				}
+			}
+			if got := connections.Load(); got != 1 {
+				t.Errorf("opened %d connections for 3 runs, want 1", got)
+			}
+		})
+	}
+}
+
+func TestTaskHTTPClient(t *testing.T) {
+	tests := []struct {
+		name       string
+		task       Task
+		wantShared bool
+		wantErr    bool
+	}{
+		{name: "default", task: Task{}, wantShared: true},
+		{name: "proxy", task: Task{ProxyURL: "http://proxy.internal:3128"}},
+		{name: "invalid proxy", task: Task{ProxyURL: "http://[::1"}, wantErr: true},
+		{name: "missing CA bundle", task: Task{CACertFile: "/nonexistent/ca.pem"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := taskHTTPClient(test.task)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("taskHTTPClient() error = %v, want error %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if (client == httpClient) != test.wantShared {
+				t.Errorf("shared client = %v, want %v", client == httpClient, test.wantShared)
+			}
+			// The same settings always get the same pooled client
+			if again, _ := taskHTTPClient(test.task); again != client {
+				t.Error("second call returned a different client")
+			}
+		})
+	}
+}
+
+// A task with ca_cert_file trusts a server the system roots don't know
+func TestTaskCACertFile(t *testing.T) {
+	target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writeFile(t, caFile, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: target.Certificate().Raw})))
+
+	tests := []struct {
+		name        string
+		caCertFile  string
+		wantSuccess bool
+	}{
+		{name: "system roots", wantSuccess: false},
+		{name: "ca_cert_file", caCertFile: caFile, wantSuccess: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := sendTaskRequest(context.Background(), &Config{}, Task{Command: "health", URL: target.URL, Method: "GET", CACertFile: test.caCertFile})
+			if result.Success != test.wantSuccess {
+				t.Errorf("Success = %v, want %v", result.Success, test.wantSuccess)
+			}
+		})
+	}
+}
+
+// dns_server replaces the resolver for both lookups and dials
+func TestConfigureResolver(t *testing.T) {
+	previousResolver, previousDialerResolver := targetResolver, targetDialer.Resolver
+	t.Cleanup(func() { targetResolver, targetDialer.Resolver = previousResolver, previousDialerResolver })
+
+	tests := []struct {
+		name      string
+		dnsServer string
+		want      string // Address DNS queries go to, empty for the system resolver
+	}{
+		{name: "system resolver", dnsServer: ""},
+		{name: "default port", dnsServer: "127.0.0.1", want: "127.0.0.1:53"},
+		{name: "explicit port", dnsServer: "127.0.0.1:5353", want: "127.0.0.1:5353"},
+		{name: "IPv6", dnsServer: "[::1]:5353", want: "[::1]:5353"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			targetResolver, targetDialer.Resolver = net.DefaultResolver, nil
+			configureResolver(test.dnsServer)
+
+			if test.want == "" {
+				if targetResolver != net.DefaultResolver || targetDialer.Resolver != nil {
+					t.Error("resolver replaced without a dns_server")
+				}
+				return
+			}
+			if targetDialer.Resolver != targetResolver || !targetResolver.PreferGo {
+				t.Fatal("dialer and lookups don't share the configured resolver")
+			}
+			// UDP dials don't send anything, so this only checks the address
+			conn, err := targetResolver.Dial(context.Background(), "udp", "198.51.100.1:53")
+			if err != nil {
+				t.Skipf("can't dial %s: %v", test.want, err)
+			}
+			defer conn.Close()
+			if got := conn.RemoteAddr().String(); got != test.want {
+				t.Errorf("queries go to %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+// IPv4-only and IPv6-only targets are both reachable
+func TestDialTargetAddressFamilies(t *testing.T) {
+	tests := []struct {
+		name    string
+		network string
+		address string
+	}{
+		{name: "IPv4", network: "tcp4", address: "127.0.0.1:0"},
+		{name: "IPv6", network: "tcp6", address: "[::1]:0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			listener, err := net.Listen(test.network, test.address)
+			if err != nil {
+				t.Skipf("no %s loopback: %v", test.name, err)
+			}
+			target := &httptest.Server{Listener: listener, Config: &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}}
+			target.Start()
+			defer target.Close()
+
+			if result := sendTaskRequest(context.Background(), &Config{}, Task{Command: "health", URL: target.URL, Method: "GET"}); !result.Success {
+				t.Errorf("request to %s failed: %+v", target.URL, result)
+			}
+		})
+	}
+}
+
+func TestRequestContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		want    time.Duration
+	}{
+		{name: "default", want: defaultRequestTimeout},
+		{name: "task timeout", timeout: 5 * time.Second, want: 5 * time.Second},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := requestContext(context.Background(), test.timeout)
+			defer cancel()
+			deadline, ok := ctx.Deadline()
+			if left := time.Until(deadline); !ok || left > test.want || left < test.want-time.Second {
+				t.Errorf("deadline in %v, want %v", left, test.want)
+			}
+		})
+	}
+}
+
+// A hung target fails the task after timeout_seconds instead of holding the run
+func TestExecuteTaskTimesOut(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer target.Close()
+
+	start := time.Now()
+	result := executeTask(context.Background(), &Config{}, Task{Command: "hang", URL: target.URL, Method: "GET", TimeoutSeconds: 1})
+	if result.Success {
+		t.Error("task against a hung target succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("task took %v, want about 1s", elapsed)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"log"
+	"strings"
+)
+
+// Locale used when neither the user nor the config picks one
+const defaultLocale = "en"
+
+// Built-in reply strings. Placeholders like {command} are filled by localize;
+// other locales come from the "messages" config section.
+var defaultMessages = map[string]string{
+	"unknown_command":   "I don't know your message. Please try again.",
+	"task_success":      "Task '{command}' executed successfully.",
+	"task_failure":      "Task '{command}' failed to execute.",
+	"request_id":        "(request ID {request_id})",
+	"not_allowed":       "You are not allowed to run '{command}'.",
+	"cooldown":          "'{command}' was last run {seconds}s ago, please wait before running it again.",
+	"already_running":   "'{command}' is already running, please try again later.",
+	"paused":            "Automation is paused, the command was not executed.",
+	"target_unhealthy":  "'{command}' was not run: target unhealthy ({error}).",
+	"precondition":      "'{command}' was skipped: precondition not met ({reason}).",
+	"deploy_success":    "Jenkins job for service '{service}' in environment '{env}' executed successfully.",
+	"deploy_failure":    "Failed to execute Jenkins job for service '{service}' in environment '{env}'.",
+	"deploy_usage":      "Invalid deploy command format. Use: deploy <service-name> <env> [branch]",
+	"response_cut":      "(response truncated at {bytes} bytes)",
+	"response_cached":   "(cached {seconds}s ago)",
+	"invalid_deploy":    "Invalid deploy command: {error}.",
+	"invalid_inputs":    "Invalid inputs for '{command}': {error}.",
+	"invalid_args":      "Invalid arguments for '{command}': {error}. Use: {usage}",
+	"slash_ack":         "Working on '{command}'...",
+	"running_task":      "Running '{command}' (execution ID {id}, use `cancel {id}` to stop it)...",
+	"not_admin_pause":   "You are not allowed to pause or resume automation.",
+	"not_admin_config":  "You are not allowed to view the configuration.",
+	"secret_missing":    "'{command}' was not run: one of its secrets could not be loaded, check the bot's logs.",
+	"task_queued":       "'{command}' is number {position} in line for {resource}, it will start when the runs ahead of it finish.",
+	"queue_aborted":     "'{command}' was not run: the bot stopped while it was waiting in line.",
+	"automation_pause":  "Automation paused, commands will be acknowledged but not executed.",
+	"automation_resume": "Automation resumed, commands will be executed again.",
+	"commands_header":   "Here are the available commands:",
+	"run_success":       "success",
+	"run_failed":        "failed",
+	"history_none_for":  "No recent executions of '{command}'.",
+	"history_none":      "No commands have been executed yet.",
+	"history_header":    "Recent executions:",
+	"cancel_all_admin":  "Only admins can cancel all executions.",
+	"cancelled_all":     "Cancelled {count} running execution(s) at the request of <@{user}>.",
+	"no_execution":      "No running execution with ID '{id}'.",
+	"cancel_not_owner":  "Only the user who started this execution or an admin can cancel it.",
+	"cancelled":         "Execution {id} of '{command}' cancelled by <@{user}>.",
+	"nothing_running":   "Nothing is running right now.",
+	"running_header":    "Running executions:",
+	"whoami":            "You are <@{user}> ({user}).",
+	"whoami_admin":      "You are an admin: pause, resume and cancelling other users' executions are available.",
+	"whoami_none":       "You are not allowed to run any commands.",
+	"whoami_commands":   "Commands you can run:",
+	"invalid_run":       "Invalid run command: {error}.",
+	"run_usage":         "Invalid run command format. Use: run [--parallel] <command> <command> ...",
+	"batch_finished":    "Batch finished: {succeeded} of {total} commands succeeded.",
+	"batch_unknown":     "unknown command",
+	"batch_skipped":     "skipped ({reason})",
+	"reload_failed":     "Reload failed, keeping the current configuration:\n{error}",
+	"reloaded":          "Configuration reloaded, {count} commands available.",
+	"describe_usage":    "Use: describe deploy <service-name> <env> [branch]",
+	"describe_denied":   "You are not allowed to describe '{command}'.",
+	"would_send":        "'{command}' would send:",
+	"no_retry":          "There is no previous command to retry.",
+	"stats_none":        "No commands run in the last {since}.",
+	"stats_summary":     "Commands run in the last {since}: {total} ({succeeded} succeeded, {failed} failed, {rate}% success rate)",
+	"stats_top":         "Top commands:",
+	"selftest_none":     "No tasks to self-test.",
+	"selftest_summary":  "Self-test: {up}/{total} endpoints reachable",
+	"selftest_up":       ":white_check_mark: {command} ({host}) up",
+	"selftest_down":     ":x: {command} ({host}) down",
+	"pong":              "pong",
+	"time_window":       "'{command}' can only run {window}.",
+	"window_hours":      "between {hours}",
+	"window_days":       "on {days}",
+	"escalation":        ":rotating_light: '{command}' has failed {count} times in a row.",
+	"response_cut_of":   "(response truncated at {bytes} of {total} bytes)",
+	"jenkins_gave_up":   "Gave up waiting for the Jenkins build after {timeout}, check Jenkins for the result.",
+	"jenkins_console":   "Jenkins build {url} failed, console output:\n```\n{console}\n```",
+	"browse_commands":   "Browse commands",
+	"pick_command":      "Pick a command to run:",
+	"search_commands":   "Search commands",
+	"modal_title":       "Commands",
+	"modal_submit":      "Run",
+	"modal_close":       "Cancel",
+	"modal_command":     "Command",
+	"teams_private":     "That reply is only for you, and Teams can't show private replies, so it wasn't posted.",
+	"discord_private":   "<@{user}> That reply is only for you, but I couldn't send it as a direct message. Allow direct messages from server members to see it.",
+	"running_entry":     "- {id}: '{command}' started by <@{user}> {elapsed} ago",
+}
+
+// Pick the user's locale from user_locales, then the configured default
+func userLocale(config *Config, userID string) string {
+	if locale := config.UserLocales[userID]; locale != "" {
+		return locale
+	}
+	if config.Locale != "" {
+		return config.Locale
+	}
+	return defaultLocale
+}
+
+// Render a reply string in the user's locale. params are name/value pairs
+// for the {name} placeholders. Keys missing from the locale fall back to English.
+func localize(config *Config, userID, key string, params ...string) string {
+	text, ok := config.Messages[userLocale(config, userID)][key]
+	if !ok {
+		text, ok = config.Messages[defaultLocale][key]
+	}
+	if !ok {
+		text, ok = defaultMessages[key]
+	}
+	if !ok {
+		log.Printf("Missing reply string '%s'", key)
+		return key
+	}
+
+	if len(params) == 0 {
+		return text
+	}
+	pairs := make([]string, 0, len(params))
+	for i := 0; i+1 < len(params); i += 2 {
+		pairs = append(pairs, "{"+params[i]+"}", params[i+1])
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
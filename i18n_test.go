@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestUserLocale(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		userID string
+		want   string
+	}{
+		{name: "default", want: defaultLocale},
+		{name: "configured", config: Config{Locale: "fr"}, userID: "U1", want: "fr"},
+		{name: "per user", config: Config{Locale: "fr", UserLocales: map[string]string{"U1": "de"}}, userID: "U1", want: "de"},
+		{name: "other user", config: Config{Locale: "fr", UserLocales: map[string]string{"U1": "de"}}, userID: "U2", want: "fr"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := userLocale(&test.config, test.userID); got != test.want {
+				t.Errorf("userLocale() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	config := &Config{
+		Locale:      "de",
+		UserLocales: map[string]string{"U2": "fr"},
+		Messages: map[string]map[string]string{
+			"de": {"task_success": "'{command}' erfolgreich ausgeführt."},
+			"en": {"paused": "Paused (custom)."},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		userID string
+		key    string
+		params []string
+		want   string
+	}{
+		{name: "locale string", userID: "U1", key: "task_success", params: []string{"command", "deploy"}, want: "'deploy' erfolgreich ausgeführt."},
+		{name: "configured English fallback", userID: "U1", key: "paused", want: "Paused (custom)."},
+		{name: "built-in fallback", userID: "U1", key: "unknown_command", want: "I don't know your message. Please try again."},
+		{name: "unknown locale", userID: "U2", key: "task_failure", params: []string{"command", "x"}, want: "Task 'x' failed to execute."},
+		{name: "several params", userID: "U1", key: "deploy_success", params: []string{"service", "api", "env", "prod"}, want: "Jenkins job for service 'api' in environment 'prod' executed successfully."},
+		{name: "repeated placeholder", userID: "U1", key: "running_task", params: []string{"command", "c", "id", "7"}, want: "Running 'c' (execution ID 7, use `cancel 7` to stop it)..."},
+		{name: "missing key", userID: "U1", key: "no_such_key", want: "no_such_key"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := localize(config, test.userID, test.key, test.params...); got != test.want {
+				t.Errorf("localize(%q) = %q, want %q", test.key, got, test.want)
+			}
+		})
+	}
+}
+
+// Every key passed to localize has a built-in English string, and every
+// built-in string is used
+func TestLocalizeKeysExist(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPattern := regexp.MustCompile(`localize\([^,()]+, [^,()]+, "([a-z_]+)"`)
+	used := map[string]bool{}
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		source, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, match := range keyPattern.FindAllSubmatch(source, -1) {
+			used[string(match[1])] = true
+			if _, ok := defaultMessages[string(match[1])]; !ok {
+				t.Errorf("%s: localize key %q has no entry in defaultMessages", file, match[1])
+			}
+		}
+	}
+
+	// The other way round, so a reply that stops going through localize shows up
+	for key := range defaultMessages {
+		if !used[key] {
+			t.Errorf("defaultMessages key %q is never passed to localize", key)
+		}
+	}
+}
+
+// Replies follow the sender's locale, with English for keys the locale lacks
+func TestHandleMessageLocalized(t *testing.T) {
+	target, _ := newStubTarget(t)
+	tasks := map[string]Task{"restart": {Command: "restart", URL: target.URL + "/ok", Method: "POST"}}
+	config := &Config{
+		DebounceMillis: -1,
+		UserLocales:    map[string]string{"UDE": "de"},
+		Messages: map[string]map[string]string{"de": {
+			"unknown_command": "Unbekannter Befehl.",
+			"task_success":    "'{command}' erfolgreich ausgeführt.",
+		}},
+	}
+
+	tests := []struct {
+		user string
+		text string
+		want string
+	}{
+		{user: "UDE", text: "restart", want: "'restart' erfolgreich ausgeführt."},
+		{user: "UDE", text: "nope", want: "Unbekannter Befehl."},
+		{user: "UDE", text: "deploy api", want: "Invalid deploy command format. Use: deploy <service-name> <env> [branch]"},
+		{user: "U1", text: "nope", want: "I don't know your message. Please try again."},
+	}
+	for _, test := range tests {
+		messenger := newFakeMessenger()
+		handleMessageEvent(context.Background(), messenger, messageEvent(test.user, test.text), config, newConfigTaskStore(tasks), newBotState(config))
+		sent := messenger.sent()
+		if len(sent) == 0 || sent[len(sent)-1].Text != test.want {
+			t.Errorf("%s %q replied %+v, want %q", test.user, test.text, sent, test.want)
+		}
+	}
+}
+
+// Formatted replies such as history and batch summaries use the catalog too
+func TestFormattedRepliesLocalized(t *testing.T) {
+	config := &Config{Locale: "de", Messages: map[string]map[string]string{"de": {
+		"history_none":     "Noch nichts ausgeführt.",
+		"history_none_for": "Keine Ausführungen von '{command}'.",
+		"batch_finished":   "{succeeded} von {total} erfolgreich.",
+		"batch_unknown":    "unbekannt",
+		"time_window":      "'{command}' läuft nur {window}.",
+		"window_hours":     "zwischen {hours}",
+		"window_days":      "an {days}",
+		"modal_submit":     "Ausführen",
+	}}}
+	window := Task{AllowedHours: "09:00-17:00", AllowedDays: []string{"Mon", "Tue"}, Timezone: "Europe/Berlin"}
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{name: "empty history", got: formatHistory(config, "U1", nil, ""), want: "Noch nichts ausgeführt."},
+		{name: "empty command history", got: formatHistory(config, "U1", nil, "deploy"), want: "Keine Ausführungen von 'deploy'."},
+		{name: "batch summary", got: formatBatchResults(config, "U1", []batchResult{{Command: "nope"}}), want: "0 von 1 erfolgreich.\n- nope: unbekannt"},
+		{name: "time window", got: timeWindowMessage(config, "U1", "deploy", window), want: "'deploy' läuft nur zwischen 09:00-17:00 an Mon, Tue (Europe/Berlin)."},
+		{name: "modal submit", got: commandsModal(config, "U1", []string{"deploy"}, "C1").Submit.Text, want: "Ausführen"},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s = %q, want %q", test.name, test.got, test.want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import "github.com/slack-go/slack"
+
+// Bot name and icon a command's replies are posted under, e.g. "DeployBot"
+type botIdentity struct {
+	Username  string
+	IconEmoji string
+	AsUser    bool
+}
+
+// identitySwitcher is implemented by backends that can post under another bot identity
+type identitySwitcher interface {
+	WithIdentity(identity botIdentity) Messenger
+}
+
+// Post a command's replies under its identity when it has one and the backend
+// supports it. Custom names and icons need the chat:write.customize scope.
+func withIdentity(messenger Messenger, identity botIdentity) Messenger {
+	if switcher, ok := messenger.(identitySwitcher); ok && identity != (botIdentity{}) {
+		return switcher.WithIdentity(identity)
+	}
+	return messenger
+}
+
+// The identity configured on a task
+func taskIdentity(task Task) botIdentity {
+	return botIdentity{Username: task.Username, IconEmoji: task.IconEmoji, AsUser: task.AsUser}
+}
+
+func (i botIdentity) options() []slack.MsgOption {
+	var options []slack.MsgOption
+	if i.Username != "" {
+		options = append(options, slack.MsgOptionUsername(i.Username))
+	}
+	if i.IconEmoji != "" {
+		options = append(options, slack.MsgOptionIconEmoji(i.IconEmoji))
+	}
+	if i.AsUser {
+		options = append(options, slack.MsgOptionAsUser(true))
+	}
+	return options
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+// Only backends that can switch identity get a copy with the new one
+func TestWithIdentity(t *testing.T) {
+	plain := newFakeMessenger()
+	slackBot := &slackMessenger{}
+
+	tests := []struct {
+		name      string
+		messenger Messenger
+		identity  botIdentity
+		wantSame  bool
+	}{
+		{name: "backend without identities", messenger: plain, identity: botIdentity{Username: "DeployBot"}, wantSame: true},
+		{name: "no identity configured", messenger: slackBot, wantSame: true},
+		{name: "switched", messenger: slackBot, identity: botIdentity{Username: "DeployBot", IconEmoji: ":rocket:"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := withIdentity(test.messenger, test.identity)
+			if same := got == test.messenger; same != test.wantSame {
+				t.Fatalf("same messenger = %v, want %v", same, test.wantSame)
+			}
+			if !test.wantSame && got.(*slackMessenger).identity != test.identity {
+				t.Errorf("identity = %+v, want %+v", got.(*slackMessenger).identity, test.identity)
+			}
+		})
+	}
+
+	// The original keeps posting under the app's own identity
+	if slackBot.identity != (botIdentity{}) {
+		t.Errorf("original identity changed to %+v", slackBot.identity)
+	}
+}
+
+// Replies carry the identity's username, icon and as_user
+func TestSlackIdentityOptions(t *testing.T) {
+	received := make(chan url.Values, 10)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		received <- r.PostForm
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true,"channel":"C1","ts":"1.1"}`))
+	}))
+	defer api.Close()
+	base := &slackMessenger{api: slack.New("xoxb-test", slack.OptionAPIURL(api.URL+"/")), unfurl: true}
+
+	tests := []struct {
+		name     string
+		identity botIdentity
+		want     map[string]string // Form fields, "" for absent
+	}{
+		{name: "app identity", want: map[string]string{"username": "", "icon_emoji": "", "as_user": ""}},
+		{name: "custom name and icon", identity: botIdentity{Username: "DeployBot", IconEmoji: ":rocket:"}, want: map[string]string{"username": "DeployBot", "icon_emoji": ":rocket:", "as_user": ""}},
+		{name: "as user", identity: botIdentity{AsUser: true}, want: map[string]string{"username": "", "as_user": "true"}},
+		{name: "from a task", identity: taskIdentity(Task{Username: "StatusBot", IconEmoji: ":signal:"}), want: map[string]string{"username": "StatusBot", "icon_emoji": ":signal:"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := withIdentity(base, test.identity).PostMessage("C1", "hello"); err != nil {
+				t.Fatal(err)
+			}
+			form := <-received
+			for field, want := range test.want {
+				if got := form.Get(field); got != want {
+					t.Errorf("%s = %q, want %q", field, got, want)
+				}
+			}
+		})
+	}
+}
+
+// Every reply of a command with an identity is posted under it, other commands keep the app's
+func TestHandleMessageTaskIdentity(t *testing.T) {
+	target, _ := newStubTarget(t)
+	tasks := map[string]Task{
+		"release": {Command: "release", URL: target.URL + "/ok", Method: "POST", Username: "DeployBot", IconEmoji: ":rocket:"},
+		"restart": {Command: "restart", URL: target.URL + "/ok", Method: "POST"},
+	}
+	wantUsername := map[string]string{"release": "DeployBot", "restart": ""}
+
+	for command, want := range wantUsername {
+		api, calls := newSlackAPIRecorder(t)
+		messenger := &slackMessenger{api: slack.New("xoxb-test", slack.OptionAPIURL(api.URL+"/")), users: newUserCache(), channels: newChannelCache()}
+		config := &Config{AckReaction: "none"}
+		handleMessageEvent(context.Background(), messenger, messageEvent("U1", command), config, newConfigTaskStore(tasks), newBotState(config))
+
+		posts := 0
+		for _, call := range calls() {
+			if call.Get("method") != "chat.postMessage" {
+				continue
+			}
+			posts++
+			if call.Get("username") != want {
+				t.Errorf("%s: reply %q posted as %q, want %q", command, call.Get("text"), call.Get("username"), want)
+			}
+		}
+		if posts != 2 {
+			t.Errorf("%s: %d replies posted, want the running message and the result", command, posts)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	defaultJenkinsPollInterval    = 5 * time.Second
+	defaultJenkinsPollMaxInterval = time.Minute
+	defaultJenkinsPollTimeout     = time.Hour
+	defaultConsoleTailLines       = 50
+)
+
+// Returned by waitForJenkinsBuild when the build doesn't finish within poll_timeout_seconds
+var errJenkinsPollTimeout = errors.New("timed out waiting for the Jenkins build")
+
+// Console lines matching any of these are redacted when console_redact_patterns is unset
+var defaultConsoleRedactPatterns = []string{`(?i)(password|passwd|secret|token|api[_-]?key)`}
+
+// Final state of a Jenkins build found by the status poller
+type jenkinsBuild struct {
+	URL    string
+	Result string // SUCCESS, FAILURE, UNSTABLE, ABORTED...
+}
+
+// Poll the queue item returned when the job was triggered, then the build it
+// starts, until the build finishes. onBuild is called once the build URL is known.
+func waitForJenkinsBuild(ctx context.Context, jenkins JenkinsConfig, queueURL string, onBuild func(buildURL string)) (jenkinsBuild, error) {
+	poller := newJenkinsPoller(jenkins)
+
+	// Wait for the queued item to become a build
+	var buildURL string
+	for buildURL == "" {
+		var item struct {
+			Cancelled  bool `json:"cancelled"`
+			Executable *struct {
+				URL string `json:"url"`
+			} `json:"executable"`
+		}
+		if err := getJenkinsJSON(ctx, jenkins, strings.TrimSuffix(queueURL, "/")+"/api/json", &item); err != nil {
+			return jenkinsBuild{}, err
+		}
+		if item.Cancelled {
+			return jenkinsBuild{Result: "CANCELLED"}, nil
+		}
+		if item.Executable != nil && item.Executable.URL != "" {
+			buildURL = item.Executable.URL
+			break
+		}
+		if err := poller.Wait(ctx); err != nil {
+			return jenkinsBuild{}, err
+		}
+	}
+
+	if onBuild != nil {
+		onBuild(buildURL)
+	}
+
+	// Wait for the build to finish
+	for {
+		var build struct {
+			Building bool   `json:"building"`
+			Result   string `json:"result"`
+		}
+		if err := getJenkinsJSON(ctx, jenkins, strings.TrimSuffix(buildURL, "/")+"/api/json", &build); err != nil {
+			return jenkinsBuild{URL: buildURL}, err
+		}
+		if !build.Building && build.Result != "" {
+			log.Printf("Jenkins build %s finished with result: %s", buildURL, build.Result)
+			return jenkinsBuild{URL: buildURL, Result: build.Result}, nil
+		}
+		if err := poller.Wait(ctx); err != nil {
+			return jenkinsBuild{URL: buildURL}, err
+		}
+	}
+}
+
+// jenkinsPoller spaces out status polls with exponential backoff and jitter,
+// so long builds don't hammer the Jenkins master, and gives up after a timeout
+type jenkinsPoller struct {
+	next     time.Duration
+	max      time.Duration
+	deadline time.Time
+}
+
+func newJenkinsPoller(jenkins JenkinsConfig) *jenkinsPoller {
+	min := time.Duration(jenkins.PollIntervalSeconds) * time.Second
+	if min <= 0 {
+		min = defaultJenkinsPollInterval
+	}
+	max := time.Duration(jenkins.PollMaxIntervalSeconds) * time.Second
+	if max <= 0 {
+		max = defaultJenkinsPollMaxInterval
+	}
+	if max < min {
+		max = min
+	}
+	return &jenkinsPoller{next: min, max: max, deadline: time.Now().Add(jenkinsPollTimeout(jenkins))}
+}
+
+// How long to wait for a build before giving up
+func jenkinsPollTimeout(jenkins JenkinsConfig) time.Duration {
+	if jenkins.PollTimeoutSeconds <= 0 {
+		return defaultJenkinsPollTimeout
+	}
+	return time.Duration(jenkins.PollTimeoutSeconds) * time.Second
+}
+
+// Sleep until the next poll, doubling the delay each time up to the maximum.
+// Each delay is randomized between half and all of its value.
+func (p *jenkinsPoller) Wait(ctx context.Context) error {
+	remaining := time.Until(p.deadline)
+	if remaining <= 0 {
+		return errJenkinsPollTimeout
+	}
+
+	delay := p.next/2 + time.Duration(rand.Int63n(int64(p.next/2)+1))
+	if delay > remaining {
+		delay = remaining
+	}
+	p.next *= 2
+	if p.next > p.max {
+		p.next = p.max
+	}
+	return sleepContext(ctx, delay)
+}
+
+// Fetch the last lines of a build's console output with secrets redacted.
+// At most maxBytes of the end of the log are kept in memory.
+func fetchConsoleTail(ctx context.Context, jenkins JenkinsConfig, buildURL string, maxBytes int64) (string, error) {
+	rc, err := openJenkins(ctx, jenkins, strings.TrimSuffix(buildURL, "/")+"/consoleText")
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	body, truncated, err := readTail(rc, maxBytes)
+	if err != nil {
+		return "", err
+	}
+	if truncated {
+		// The first line is most likely cut in the middle
+		if i := bytes.IndexByte(body, '\n'); i >= 0 {
+			body = body[i+1:]
+		}
+	}
+
+	lines := jenkins.ConsoleTailLines
+	if lines <= 0 {
+		lines = defaultConsoleTailLines
+	}
+	patterns := jenkins.ConsoleRedactPatterns
+	if len(patterns) == 0 {
+		patterns = defaultConsoleRedactPatterns
+	}
+	return redactLines(tailLines(string(body), lines), patterns), nil
+}
+
+// Keep only the last n lines of text
+func tailLines(text string, n int) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Replace every line matching one of the patterns
+func redactLines(text string, patterns []string) string {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Printf("Ignoring invalid redact pattern %q: %v", pattern, err)
+			continue
+		}
+		compiled = append(compiled, re)
+	}
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		for _, re := range compiled {
+			if re.MatchString(line) {
+				lines[i] = "[redacted]"
+				break
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func getJenkinsJSON(ctx context.Context, jenkins JenkinsConfig, url string, v interface{}) error {
+	rc, err := openJenkins(ctx, jenkins, url)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return json.NewDecoder(io.LimitReader(rc, defaultMaxResponseBytes)).Decode(v)
+}
+
+// Send an authenticated GET to the Jenkins API and return the response body.
+// Queue and build URLs come from Jenkins, so they're checked against the
+// context's SSRF guard like the job URL.
+func openJenkins(ctx context.Context, jenkins JenkinsConfig, url string) (io.ReadCloser, error) {
+	if err := guardFromContext(ctx).check(ctx, url); err != nil {
+		return nil, err
+	}
+	ctx, cancel := requestContext(ctx, 0)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.SetBasicAuth(jenkins.User, jenkins.Token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("GET %s returned status: %s", url, resp.Status)
+	}
+	return cancelOnClose{ReadCloser: resp.Body, cancel: cancel}, nil
+}
+
+// Response body that ends its request's context when closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// Wait for d or until the context is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Build a Jenkins job path such as /job/folder/job/service/job/main from its
+// components, escaping each job name on its own so slashes stay separators
+func jenkinsJobPath(jobs ...string) string {
+	var b strings.Builder
+	for _, job := range jobs {
+		if job == "" {
+			continue
+		}
+		b.WriteString("/job/")
+		b.WriteString(url.PathEscape(job))
+	}
+	return b.String()
+}
+
+// Fill the url_format placeholders for a deploy. In the path, a service name
+// with slashes ("team/api") is a folder path and expands to one job segment
+// per folder, and {branch} is escaped twice because multibranch jobs encode
+// "/" in branch names as %2F in the job name itself. In the query string
+// values are query-escaped, so "prod&token=x" can't add parameters.
+// Placeholders the format uses must not be empty.
+func buildJenkinsURL(format, serviceName, env, branch string) (string, error) {
+	values := map[string]string{"service-name": serviceName, "env": env, "branch": branch}
+	for _, name := range []string{"service-name", "env", "branch"} {
+		if strings.Contains(format, "{"+name+"}") && values[name] == "" {
+			return "", fmt.Errorf("%s is required", name)
+		}
+	}
+
+	path, query, hasQuery := strings.Cut(format, "?")
+
+	// The format already has the first "/job/" in front of the placeholder
+	servicePath := strings.TrimPrefix(jenkinsJobPath(strings.Split(serviceName, "/")...), "/job/")
+	path = strings.Replace(path, "{service-name}", servicePath, 1)
+	path = strings.Replace(path, "{env}", url.PathEscape(env), 1)
+	path = strings.Replace(path, "{branch}", url.PathEscape(url.PathEscape(branch)), 1)
+	if !hasQuery {
+		return path, nil
+	}
+
+	query = strings.Replace(query, "{service-name}", url.QueryEscape(serviceName), 1)
+	query = strings.Replace(query, "{env}", url.QueryEscape(env), 1)
+	query = strings.Replace(query, "{branch}", url.QueryEscape(branch), 1)
+	return path + "?" + query, nil
+}
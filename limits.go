@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"unicode/utf8"
+)
+
+// Largest response body read when max_response_bytes is not configured
+const defaultMaxResponseBytes = 1 << 20
+
+func maxResponseBytes(config *Config) int64 {
+	if config.MaxResponseBytes <= 0 {
+		return defaultMaxResponseBytes
+	}
+	return config.MaxResponseBytes
+}
+
+// Read at most limit bytes, reporting whether the rest was cut off
+func readLimited(r io.Reader, limit int64) ([]byte, bool, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if int64(len(data)) > limit {
+		return data[:limit], true, err
+	}
+	return data, false, err
+}
+
+// Read the whole stream but keep only its last limit bytes, reporting whether
+// the beginning was dropped. Memory stays bounded however long the stream is.
+func readTail(r io.Reader, limit int64) ([]byte, bool, error) {
+	tail := make([]byte, 0, limit)
+	buf := make([]byte, 32*1024)
+	truncated := false
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			tail = append(tail, buf[:n]...)
+			if int64(len(tail)) > limit {
+				tail = append(tail[:0], tail[int64(len(tail))-limit:]...)
+				truncated = true
+			}
+		}
+		if err == io.EOF {
+			return tail, truncated, nil
+		}
+		if err != nil {
+			return tail, truncated, err
+		}
+	}
+}
+
+// Cut text to at most limit bytes without splitting a UTF-8 character
+func truncateUTF8(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut]
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadLimited(t *testing.T) {
+	tests := []struct {
+		input         string
+		limit         int64
+		want          string
+		wantTruncated bool
+	}{
+		{input: "hello", limit: 10, want: "hello"},
+		{input: "hello", limit: 5, want: "hello"},
+		{input: "hello world", limit: 5, want: "hello", wantTruncated: true},
+		{input: "", limit: 5, want: ""},
+	}
+	for _, test := range tests {
+		got, truncated, err := readLimited(strings.NewReader(test.input), test.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want || truncated != test.wantTruncated {
+			t.Errorf("readLimited(%q, %d) = %q, %v, want %q, %v", test.input, test.limit, got, truncated, test.want, test.wantTruncated)
+		}
+	}
+}
+
+func TestReadTail(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		limit         int64
+		want          string
+		wantTruncated bool
+	}{
+		{name: "short", input: "hello", limit: 10, want: "hello"},
+		{name: "exact", input: "hello", limit: 5, want: "hello"},
+		{name: "cut", input: "hello world", limit: 5, want: "world", wantTruncated: true},
+		{name: "longer than one read", input: strings.Repeat("a", 100*1024) + "the end", limit: 7, want: "the end", wantTruncated: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, truncated, err := readTail(strings.NewReader(test.input), test.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want || truncated != test.wantTruncated {
+				t.Errorf("readTail = %q, %v, want %q, %v", got, truncated, test.want, test.wantTruncated)
+			}
+		})
+	}
+}
+
+// An oversized task response is cut at max_response_bytes and the reply says so
+func TestHandleMessageTruncatedResponse(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 64*1024)))
+	}))
+	defer target.Close()
+	config := &Config{MaxResponseBytes: 1024}
+	store := newConfigTaskStore(map[string]Task{"restart": {Command: "restart", URL: target.URL, Method: "POST"}})
+	messenger := newFakeMessenger()
+
+	handleMessageEvent(context.Background(), messenger, messageEvent("U1", "restart"), config, store, newBotState(config))
+
+	want := "Task 'restart' executed successfully.\n(response truncated at 1024 bytes)"
+	if replies := messenger.results(); len(replies) != 1 || replies[0] != want {
+		t.Errorf("replies = %q, want %q", replies, want)
+	}
+	if result := executeTask(context.Background(), config, store.tasks["restart"]); len(result.Body) != 1024 || !result.Truncated {
+		t.Errorf("body of %d bytes (truncated %v), want 1024 and truncated", len(result.Body), result.Truncated)
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	tests := []struct {
+		text  string
+		limit int
+		want  string
+	}{
+		{text: "hello", limit: 10, want: "hello"},
+		{text: "hello", limit: 3, want: "hel"},
+		{text: "héllo", limit: 2, want: "h"},
+		{text: "héllo", limit: 3, want: "hé"},
+		{text: "日本", limit: 5, want: "日"},
+	}
+	for _, test := range tests {
+		if got := truncateUTF8(test.text, test.limit); got != test.want {
+			t.Errorf("truncateUTF8(%q, %d) = %q, want %q", test.text, test.limit, got, test.want)
+		}
+	}
+}
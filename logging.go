@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Log levels, from most to least verbose
+const (
+	levelDebug int32 = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+// Current log level, info unless log_level says otherwise
+var logLevel atomic.Int32
+
+func init() {
+	logLevel.Store(levelInfo)
+	log.SetOutput(maskingWriter{out: os.Stderr})
+}
+
+// Rotating log file settings, used when log_output is "file"
+type LogFileConfig struct {
+	Path       string `json:"path"`
+	MaxSizeMB  int    `json:"max_size_mb,omitempty"`  // Rotate when the file reaches this size (default 100)
+	MaxAgeDays int    `json:"max_age_days,omitempty"` // Delete rotated files older than this (default: keep them)
+	MaxBackups int    `json:"max_backups,omitempty"`  // Rotated files kept (default: all)
+	Compress   bool   `json:"compress,omitempty"`     // Gzip rotated files
+}
+
+// Send log lines to stderr (default), stdout or a rotating file, still masked
+func setLogOutput(output string, file LogFileConfig) error {
+	var out io.Writer
+	switch strings.ToLower(output) {
+	case "", "stderr":
+		out = os.Stderr
+	case "stdout":
+		out = os.Stdout
+	case "file":
+		if file.Path == "" {
+			return fmt.Errorf("log_output is file but log_file.path is empty")
+		}
+		out = &lumberjack.Logger{
+			Filename:   file.Path,
+			MaxSize:    file.MaxSizeMB,
+			MaxAge:     file.MaxAgeDays,
+			MaxBackups: file.MaxBackups,
+			Compress:   file.Compress,
+		}
+	default:
+		return fmt.Errorf("unknown log_output %q, use stderr, stdout or file", output)
+	}
+	log.SetOutput(maskingWriter{out: out})
+	return nil
+}
+
+// Regexes whose matches are masked in every log line, from log_mask_patterns
+var logMaskPatterns atomic.Pointer[[]*regexp.Regexp]
+
+// maskingWriter replaces log_mask_patterns matches with *** before a line is
+// written, so tokens or PII typed into commands never reach the logs
+type maskingWriter struct {
+	out io.Writer
+}
+
+func (w maskingWriter) Write(p []byte) (int, error) {
+	patterns := logMaskPatterns.Load()
+	if patterns == nil || len(*patterns) == 0 {
+		return w.out.Write(p)
+	}
+	masked := p
+	for _, pattern := range *patterns {
+		masked = pattern.ReplaceAll(masked, []byte("***"))
+	}
+	if _, err := w.out.Write(masked); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Set the patterns masked in log lines; invalid ones are logged and skipped
+func setLogMaskPatterns(patterns []string) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Printf("Invalid log mask pattern %q: %v", pattern, err)
+			continue
+		}
+		compiled = append(compiled, re)
+	}
+	logMaskPatterns.Store(&compiled)
+}
+
+// Set the log level from the config value ("debug", "info", "warn" or "error")
+func setLogLevel(level string) {
+	switch strings.ToLower(level) {
+	case "debug":
+		logLevel.Store(levelDebug)
+	case "warn", "warning":
+		logLevel.Store(levelWarn)
+	case "error":
+		logLevel.Store(levelError)
+	case "", "info":
+		logLevel.Store(levelInfo)
+	default:
+		log.Printf("Unknown log level %q, using info", level)
+		logLevel.Store(levelInfo)
+	}
+}
+
+// Log only when the level is debug, for verbose event dumps
+func debugf(format string, v ...interface{}) {
+	if logLevel.Load() <= levelDebug {
+		log.Printf(format, v...)
+	}
+}
+
+// Longest body logged for a task with debug on
+const debugBodyLimit = 2048
+
+// Log a debug task's request with credentials redacted, whatever the log level
+func logTaskRequest(task Task, req *http.Request, body string) {
+	switch req.Header.Get("Content-Type") {
+	case "application/x-www-form-urlencoded":
+		if form, err := url.ParseQuery(body); err == nil {
+			for key := range form {
+				form.Set(key, redactValue(key, form.Get(key)))
+			}
+			body = form.Encode()
+		}
+	case "application/json":
+		body = redactJSON(body)
+	}
+	log.Printf("[debug %s] request %s %s\n%s\n%s", task.Command, req.Method, redactURL(req.URL.String()), formatDebugHeaders(req.Header), truncateForLog([]byte(body)))
+}
+
+// Log a debug task's response, whatever the log level. JSON responses have
+// members named like secrets, such as an issued token, masked.
+func logTaskResponse(task Task, resp *http.Response, body []byte) {
+	if strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		body = []byte(redactJSON(string(body)))
+	}
+	log.Printf("[debug %s] response %s\n%s\n%s", task.Command, resp.Status, formatDebugHeaders(resp.Header), truncateForLog(body))
+}
+
+func formatDebugHeaders(header http.Header) string {
+	lines := make([]string, 0, len(header))
+	for key, values := range header {
+		lines = append(lines, fmt.Sprintf("%s: %s", key, redactValue(key, strings.Join(values, ", "))))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+func truncateForLog(body []byte) string {
+	if len(body) > debugBodyLimit {
+		return fmt.Sprintf("%s... (%d more bytes)", body[:debugBodyLimit], len(body)-debugBodyLimit)
+	}
+	return string(body)
+}
@@ -1,16 +1,28 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"regexp"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/slack-go/slack"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Task structure to handle static API tasks
@@ -20,20 +32,197 @@ type Task struct {
 	Method  string `json:"method"`
 	User    string `json:"user,omitempty"`  // Optional for authentication
 	Token   string `json:"token,omitempty"` // Optional for authentication
+
+	Steps           []Task `json:"steps,omitempty"`             // Optional sub-tasks executed in order instead of URL
+	ContinueOnError bool   `json:"continue_on_error,omitempty"` // Keep running the chain when this step fails
+	CooldownSeconds int    `json:"cooldown_seconds,omitempty"`  // Minimum time between two runs of this command
+
+	SuccessMessage string `json:"success_message,omitempty"` // Optional text/template for the success reply
+	FailureMessage string `json:"failure_message,omitempty"` // Optional text/template for the failure reply
+
+	MaxConcurrent int  `json:"max_concurrent,omitempty"` // Maximum simultaneous runs of this command (0 = unlimited)
+	SkipSelfTest  bool `json:"skip_self_test,omitempty"` // Leave this task out of the selftest command
+
+	ResponsePath string `json:"response_path,omitempty"` // Dotted path of a JSON response field to include in the reply
+
+	Body     string            `json:"body,omitempty"`      // Raw JSON body sent with POST requests
+	FormData map[string]string `json:"form_data,omitempty"` // Form fields sent url-encoded with POST requests
+
+	URLs        []string `json:"urls,omitempty"`         // Equivalent targets used instead of URL, with fallback on failure
+	URLStrategy string   `json:"url_strategy,omitempty"` // How to pick from URLs: "random" (default) or "round_robin"
+
+	AllowedUsers []string `json:"allowed_users,omitempty"` // Slack user IDs allowed to run this command (empty = everyone)
+
+	SuccessBodyContains string `json:"success_body_contains,omitempty"` // Text the response body must contain to count as success
+	SuccessBodyPattern  string `json:"success_body_pattern,omitempty"`  // Regex the response body must match to count as success
+
+	AllowedHours string   `json:"allowed_hours,omitempty"` // Time window the command may run in, e.g. "09:00-17:00"
+	AllowedDays  []string `json:"allowed_days,omitempty"`  // Days the command may run on, e.g. ["Mon", "Tue"]
+	Timezone     string   `json:"timezone,omitempty"`      // IANA timezone for allowed_hours and allowed_days (default UTC)
+
+	CacheSeconds int `json:"cache_seconds,omitempty"` // Reuse a successful GET response for this long
+
+	OnFailurePagerDuty string `json:"on_failure_pagerduty,omitempty"` // PagerDuty Events API v2 routing key triggered when the task fails
+
+	Headers map[string]string `json:"headers,omitempty"` // Extra request headers, e.g. {"X-Triggered-By": "{user_name}"}
+
+	GitHubWorkflow *GitHubWorkflow `json:"github_workflow,omitempty"` // Dispatch a GitHub Actions workflow instead of calling URL
+
+	ProxyURL   string `json:"proxy_url,omitempty"`    // Proxy for this task's requests instead of HTTP(S)_PROXY
+	CACertFile string `json:"ca_cert_file,omitempty"` // PEM bundle trusted for this task's TLS connections instead of the system roots
+
+	LinkURL string `json:"link_url,omitempty"` // Page the rich reply's button opens, e.g. the service dashboard
+
+	HealthCheckURL string `json:"health_check_url,omitempty"` // GET before running; the task is not run unless it answers 2xx
+
+	SigningSecret string `json:"signing_secret,omitempty"` // Signs the request body in X-Signature (HMAC-SHA256)
+
+	Debug bool `json:"debug,omitempty"` // Log this task's requests and responses, redacted, whatever the log level
+
+	Args []ArgSpec `json:"args,omitempty"` // Positional arguments typed after the command, filling {name} placeholders
+
+	AttachResponseAsFile bool `json:"attach_response_as_file,omitempty"` // Post the response body after the reply, uploaded as a file when it is long
+
+	Retries           int   `json:"retries,omitempty"`             // Extra attempts after a retryable failure
+	RetryDelaySeconds int   `json:"retry_delay_seconds,omitempty"` // Delay before the first retry, doubled each time (default 1)
+	RetryOnStatus     []int `json:"retry_on_status,omitempty"`     // Status codes worth retrying, instead of 429 and 5xx
+
+	MentionOnFailure string `json:"mention_on_failure,omitempty"` // "here", "channel" or a user group ID mentioned when the task fails
+
+	UpdateTimeoutSeconds int `json:"update_timeout_seconds,omitempty"` // Longest wait for editing the running message into the result before posting it instead (default 5)
+
+	ResourceKey string `json:"resource_key,omitempty"` // Tasks sharing this key, e.g. "{service}", run one at a time in FIFO order
+
+	ResponseType string `json:"response_type,omitempty"` // Slash command replies: "ephemeral" (default) or "in_channel"
+
+	Precondition *Precondition `json:"precondition,omitempty"` // Status check that must pass before the task runs, e.g. "only if green"
+
+	StatusMessages map[string]string `json:"status_messages,omitempty"` // Reply templates by response code or range, e.g. {"202": "Accepted, processing", "4xx": "..."}
+
+	Category string `json:"category,omitempty"` // Group the command is listed under on the Home tab (default "Other")
+
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"` // Longest one request to the target may take, each retry on its own (default 30)
+
+	RetryNonIdempotent bool `json:"retry_non_idempotent,omitempty"` // Also retry POST tasks after timeouts and 429/5xx, when repeating them is safe
+
+	Username  string `json:"username,omitempty"`   // Bot name this command's replies are posted under, e.g. "DeployBot"
+	IconEmoji string `json:"icon_emoji,omitempty"` // Bot icon for this command's replies, e.g. ":rocket:"
+	AsUser    bool   `json:"as_user,omitempty"`    // Post as the authed user (legacy bot tokens only)
 }
 
 // JenkinsConfig structure for dynamic Jenkins deployments
 type JenkinsConfig struct {
 	User      string `json:"user,omitempty"`
 	Token     string `json:"token,omitempty"`
-	URLFormat string `json:"url_format"` // URL format with placeholders
+	URLFormat string `json:"url_format"` // URL format with {service-name}, {env} and optional {branch} placeholders
+
+	CooldownSeconds int `json:"cooldown_seconds,omitempty"` // Minimum time between deploys of the same service and env
+
+	SuccessMessage string `json:"success_message,omitempty"` // Optional text/template for the deploy success reply
+	FailureMessage string `json:"failure_message,omitempty"` // Optional text/template for the deploy failure reply
+
+	WaitForResult         bool     `json:"wait_for_result,omitempty"`         // Poll the build status instead of reporting the trigger result
+	PollIntervalSeconds   int      `json:"poll_interval_seconds,omitempty"`   // Initial delay between status polls (default 5)
+	ConsoleTailLines      int      `json:"console_tail_lines,omitempty"`      // Console lines posted when a build fails (default 50)
+	ConsoleRedactPatterns []string `json:"console_redact_patterns,omitempty"` // Regexes for console lines that must not be posted
+
+	PollMaxIntervalSeconds int `json:"poll_max_interval_seconds,omitempty"` // Longest delay between status polls as they back off (default 60)
+	PollTimeoutSeconds     int `json:"poll_timeout_seconds,omitempty"`      // Give up waiting for the build after this long (default 3600)
+
+	AllowedUsers []string `json:"allowed_users,omitempty"` // Slack user IDs allowed to deploy (empty = everyone)
+
+	Credentials map[string]JenkinsCredentials `json:"credentials,omitempty"` // User and token per deploy env, overriding the defaults
+
+	SuccessStatusCodes  []int  `json:"success_status_codes,omitempty"`  // Trigger statuses that count as success (default any 2xx)
+	SuccessBodyContains string `json:"success_body_contains,omitempty"` // Text the trigger response body must contain to count as success
+	SuccessBodyPattern  string `json:"success_body_pattern,omitempty"`  // Regex the trigger response body must match to count as success
+
+	Username  string `json:"username,omitempty"`   // Bot name deploy replies are posted under, e.g. "DeployBot"
+	IconEmoji string `json:"icon_emoji,omitempty"` // Bot icon for deploy replies
+	AsUser    bool   `json:"as_user,omitempty"`    // Post as the authed user (legacy bot tokens only)
+}
+
+// Jenkins credentials for one deploy environment
+type JenkinsCredentials struct {
+	User  string `json:"user"`
+	Token string `json:"token"`
+}
+
+// Return the Jenkins settings with the credentials for env, falling back to the default user and token
+func (j JenkinsConfig) forEnv(env string) JenkinsConfig {
+	if creds, ok := j.Credentials[env]; ok {
+		j.User, j.Token = creds.User, creds.Token
+	}
+	return j
 }
 
 // Config structure to hold Slack token, tasks, and Jenkins details
 type Config struct {
-	SlackToken string          `json:"slack_token"`
-	Tasks      map[string]Task `json:"tasks"`   // Static API tasks
-	Jenkins    JenkinsConfig   `json:"jenkins"` // Jenkins configuration for dynamic deployments
+	SlackToken          string            `json:"slack_token"`
+	SlackTokens         map[string]string `json:"slack_tokens,omitempty"`          // Bot token per extra workspace, keyed by team ID
+	Tasks               map[string]Task   `json:"tasks"`                           // Static API tasks
+	Jenkins             JenkinsConfig     `json:"jenkins"`                         // Jenkins configuration for dynamic deployments
+	CompletionWebhook   string            `json:"completion_webhook,omitempty"`    // Optional URL notified after each execution
+	TaskDB              string            `json:"task_db,omitempty"`               // Optional SQLite database path for runtime-managed tasks
+	AdminToken          string            `json:"admin_token,omitempty"`           // Bearer token for the admin API (disabled when empty)
+	HistorySize         int               `json:"history_size,omitempty"`          // Number of recent executions kept for the history command
+	Backend             string            `json:"backend,omitempty"`               // Chat backends, comma-separated: "slack" (default), "teams", "discord"; "both" is slack and teams
+	Teams               TeamsConfig       `json:"teams,omitempty"`                 // Microsoft Teams webhook settings
+	Discord             DiscordConfig     `json:"discord,omitempty"`               // Discord bot settings
+	ThreadsOnly         bool              `json:"threads_only,omitempty"`          // Ignore messages posted at the channel root
+	ThreadRoot          string            `json:"thread_root,omitempty"`           // Optional ts of the only thread the bot listens to
+	MentionsOnly        bool              `json:"mentions_only,omitempty"`         // Only handle app_mention events, not plain messages
+	CommandPrefix       string            `json:"command_prefix,omitempty"`        // Only messages starting with this prefix are handled, e.g. "!bot "
+	HandleEdits         bool              `json:"handle_edits,omitempty"`          // Re-run commands when a message is edited
+	LogLevel            string            `json:"log_level,omitempty"`             // debug, info (default), warn or error
+	UserAgent           string            `json:"user_agent,omitempty"`            // User-Agent for outbound requests (default automation-bot/<version>)
+	AckReaction         string            `json:"ack_reaction,omitempty"`          // Emoji added when a command is received (default "eyes", "none" disables)
+	AdminUsers          []string          `json:"admin_users,omitempty"`           // Slack user IDs allowed to run admin commands
+	Paused              bool              `json:"paused,omitempty"`                // Start in maintenance mode
+	SSRFGuard           bool              `json:"ssrf_guard,omitempty"`            // Block task URLs targeting private or metadata addresses
+	AllowPrivateTargets []string          `json:"allow_private_targets,omitempty"` // Hosts, IPs or CIDRs exempt from the SSRF guard
+	NotifyChannel       string            `json:"notify_channel,omitempty"`        // Channel ID for operational notifications such as startup and errors
+	StatsAllowedUsers   []string          `json:"stats_allowed_users,omitempty"`   // Slack user IDs allowed to run the stats command (empty = everyone)
+	StrictEnv           bool              `json:"strict_env,omitempty"`            // Fail tasks whose URL references an unset {env:NAME} variable
+	MaxResponseBytes    int64             `json:"max_response_bytes,omitempty"`    // Largest response body read from tasks and Jenkins (default 1 MiB)
+	RichReplies         bool              `json:"rich_replies,omitempty"`          // Post results as colored Block Kit cards on Slack
+	UnfurlLinks         bool              `json:"unfurl_links,omitempty"`          // Let Slack expand links in replies into previews (off by default)
+	BasePath            string            `json:"base_path,omitempty"`             // Path prefix of every route behind a reverse proxy, e.g. "/bot"
+	FallbackCommand     string            `json:"fallback_command,omitempty"`      // Task run with the raw text as {text} when no command matches
+	LogMaskPatterns     []string          `json:"log_mask_patterns,omitempty"`     // Regexes whose matches are replaced with *** in every log line
+	LogOutput           string            `json:"log_output,omitempty"`            // Where logs go: "stderr" (default), "stdout" or "file"
+	LogFile             LogFileConfig     `json:"log_file,omitempty"`              // Path and rotation of the log file when log_output is "file"
+
+	ReactionTriggers map[string]ReactionTrigger `json:"reaction_triggers,omitempty"` // Commands run by reacting to a message, keyed by emoji name, e.g. "white_check_mark"
+
+	DeleteTriggerMessage bool `json:"delete_trigger_message,omitempty"` // Delete the command message after it ran successfully
+	UpdateRunningMessage bool `json:"update_running_message,omitempty"` // Edit the "Running" message into the result instead of posting a second message
+
+	TriggerToken         string `json:"trigger_token,omitempty"`          // Bearer token for POST /trigger/{command} (disabled when empty)
+	TriggerMirrorChannel string `json:"trigger_mirror_channel,omitempty"` // Channel ID where HTTP-triggered results are also posted
+
+	DebounceMillis int `json:"debounce_ms,omitempty"` // Drop identical messages from the same user within this window (default 2000, negative disables)
+
+	FailureEscalationThresholds []int  `json:"failure_escalation_thresholds,omitempty"` // Consecutive failures of a command that post an escalation, e.g. [3, 5, 10]
+	FailureEscalationMention    string `json:"failure_escalation_mention,omitempty"`    // "here", "channel" or a user group ID mentioned in escalations
+
+	CompletionWebhookSecret string `json:"completion_webhook_secret,omitempty"` // Signs completion webhook payloads in X-Signature
+
+	HeartbeatMinutes int `json:"heartbeat_minutes,omitempty"` // Post "bot alive" to notify_channel this often (0 disables)
+
+	DNSServer string `json:"dns_server,omitempty"` // DNS server (host or host:port) for task and Jenkins hosts instead of the system resolver
+
+	AttachThresholdBytes int `json:"attach_threshold_bytes,omitempty"` // Longest response posted inline for attach_response_as_file tasks (default 3000)
+
+	SlackRetryMinutes int `json:"slack_retry_minutes,omitempty"` // Keep retrying replies while Slack is unreachable for this long (default 10, negative disables)
+
+	SlackSigningSecret   string            `json:"slack_signing_secret,omitempty"`   // Verifies Slack requests; required for /slack/events, /slack/workflow, /slack/interactions and /slack/commands are disabled when empty
+	SlackSigningSecrets  map[string]string `json:"slack_signing_secrets,omitempty"`  // Signing secret per extra workspace's app, keyed by team ID
+	WorkflowCommandField string            `json:"workflow_command_field,omitempty"` // Workflow payload field holding the command (default "command")
+
+	Locale      string                       `json:"locale,omitempty"`       // Language of bot replies (default "en")
+	UserLocales map[string]string            `json:"user_locales,omitempty"` // Reply language per user ID, overriding locale
+	Messages    map[string]map[string]string `json:"messages,omitempty"`     // Reply strings per locale and key, e.g. {"de": {"unknown_command": "..."}}
 }
 
 // Structure for parsing Slack's URL verification event
@@ -41,9 +230,24 @@ type ChallengeResponse struct {
 	Challenge string `json:"challenge"`
 }
 
+// Config file used when neither -config nor CONFIG_PATH is given
+const defaultConfigPath = "config.json"
+
 // Load configuration from config.json
 func loadConfig(filePath string) (*Config, error) {
 	file, err := os.Open(filePath)
+	if os.IsNotExist(err) && filePath == defaultConfigPath {
+		// Containers may pass the configuration through the environment instead.
+		// A path given explicitly must exist, so a typo isn't silently ignored.
+		config, ok, envErr := configFromEnv()
+		if ok {
+			if envErr != nil {
+				return nil, envErr
+			}
+			return config, resolveConfigSecrets(config)
+		}
+		return nil, fmt.Errorf("configuration file %s not found and neither BOT_CONFIG_JSON nor SLACK_TOKEN is set: %w", filePath, err)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -51,33 +255,262 @@ func loadConfig(filePath string) (*Config, error) {
 	defer file.Close()
 
 	byteValue, _ := ioutil.ReadAll(file)
+
+	// Apply the environment overlay (e.g. config.prod.json) selected by BOT_ENV
+	byteValue, err = applyOverlay(byteValue, filePath, os.Getenv("BOT_ENV"))
+	if err != nil {
+		return nil, err
+	}
+
 	var config Config
-	json.Unmarshal(byteValue, &config)
+	if err := json.Unmarshal(byteValue, &config); err != nil {
+		return nil, err
+	}
 
+	// Tokens may be file:// or env:// references instead of inline values
+	if err := resolveConfigSecrets(&config); err != nil {
+		return nil, err
+	}
 	return &config, nil
 }
 
+// Pick the config path from the -config flag, then CONFIG_PATH, then config.json
+func configFilePath(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if env := os.Getenv("CONFIG_PATH"); env != "" {
+		return env
+	}
+	return defaultConfigPath
+}
+
+// Clean up base_path into "/prefix" form, or "" to serve routes at the root
+func normalizeBasePath(basePath string) string {
+	basePath = strings.Trim(strings.TrimSpace(basePath), "/")
+	if basePath == "" {
+		return ""
+	}
+	return "/" + basePath
+}
+
+// Strip basePath before routing, so handlers see the same paths with or
+// without a prefix. Requests outside the prefix get a 404.
+func withBasePath(basePath string, handler http.Handler) http.Handler {
+	if basePath == "" {
+		return handler
+	}
+	return http.StripPrefix(basePath, handler)
+}
+
 func main() {
-	// Load configuration from config.json
-	config, err := loadConfig("config.json")
+	configFlag := flag.String("config", "", "path to the configuration file (defaults to $CONFIG_PATH or config.json)")
+	runFlag := flag.String("run", "", "run one command, print the replies and exit instead of starting the bot")
+	validateFlag := flag.Bool("validate", false, "check the configuration, print every problem and exit non-zero if there is any")
+	flag.Parse()
+
+	// A missing file is fine when the configuration comes from the environment
+	configPath := configFilePath(*configFlag)
+	if info, err := os.Stat(configPath); err == nil && info.IsDir() {
+		log.Fatalf("Configuration path %s is a directory, expected a JSON file", configPath)
+	}
+
+	// Check the configuration offline, e.g. in CI, without starting the bot
+	if *validateFlag {
+		os.Exit(runValidate(configPath))
+	}
+
+	// Load configuration from the config file or the environment
+	config, err := loadConfig(configPath)
 	if err != nil {
 		log.Fatalf("Error loading configuration: %v", err)
 	}
+	if err := validateConfig(config); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	if err := setLogOutput(config.LogOutput, config.LogFile); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	setLogLevel(config.LogLevel)
+	setLogMaskPatterns(config.LogMaskPatterns)
+	configureResolver(config.DNSServer)
+
+	// Root context cancelled on shutdown so in-flight task requests are aborted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Open the task store (config.json tasks, or SQLite when task_db is set)
+	store, err := newTaskStore(config)
+	if err != nil {
+		log.Fatalf("Error opening task store: %v", err)
+	}
+
+	// Tracing is exported only when an OTLP endpoint is configured
+	shutdownTracing, err := setupTracing(ctx)
+	if err != nil {
+		log.Fatalf("Error setting up tracing: %v", err)
+	}
+	flushTraces := func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			log.Printf("Error flushing traces: %v", err)
+		}
+	}
+	defer flushTraces()
+
+	// In-memory runtime state such as execution history
+	state := newBotState(config)
+	state.configPath = configPath
+
+	// Run a single command from the command line, without any chat backend
+	if *runFlag != "" {
+		code := runCLICommand(ctx, config, store, state, *runFlag)
+		flushTraces()
+		stop()
+		os.Exit(code)
+	}
+
+	// Microsoft Teams outgoing webhook endpoint
+	if backendEnabled(config, "teams") {
+		messenger := newTeamsMessenger(config.Teams, &state.config)
+		state.notifier = messenger
+		http.HandleFunc("/teams/messages", teamsHandler(ctx, messenger, config, store, state))
+	}
+
+	if backendEnabled(config, "slack") {
+		if err := registerSlackRoutes(ctx, config, store, state); err != nil {
+			log.Fatalf("Error connecting to Slack: %v", err)
+		}
+	}
+
+	// Discord gateway connection
+	if backendEnabled(config, "discord") {
+		if err := startDiscord(ctx, config, store, state); err != nil {
+			log.Fatalf("Error connecting to Discord: %v", err)
+		}
+	}
+
+	// Admin API for managing tasks without a restart
+	registerAdminRoutes(http.DefaultServeMux, config, store, state)
+
+	// HTTP trigger endpoint for CI pipelines
+	registerTriggerRoutes(ctx, http.DefaultServeMux, config, store, state)
+
+	// Slack Workflow Builder webhook endpoint
+	registerWorkflowRoutes(ctx, http.DefaultServeMux, config, store, state)
+
+	// Slack slash command endpoint, replying through response_url
+	registerSlashCommandRoutes(ctx, http.DefaultServeMux, config, store, state)
+
+	state.notify("Bot started (version %s).", version)
+	if config.HeartbeatMinutes > 0 && config.NotifyChannel != "" {
+		go state.runHeartbeat(ctx, time.Duration(config.HeartbeatMinutes)*time.Minute)
+	}
+
+	// Serve every route under base_path, e.g. /bot/slack/events
+	basePath := normalizeBasePath(config.BasePath)
+	server := &http.Server{Addr: ":8081", Handler: withBasePath(basePath, http.DefaultServeMux)}
+
+	// Stop accepting requests once a shutdown signal arrives
+	go func() {
+		<-ctx.Done()
+		log.Println("Shutting down...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Error shutting down server: %v", err)
+		}
+	}()
+
+	log.Printf("Bot is running on port 8081, Slack events at %s/slack/events...", basePath)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
+
+// Report whether a chat backend is enabled by the backend setting
+func backendEnabled(config *Config, name string) bool {
+	if config.Backend == "" {
+		return name == "slack"
+	}
+	for _, backend := range strings.Split(config.Backend, ",") {
+		backend = strings.TrimSpace(backend)
+		if backend == name || (backend == "both" && (name == "slack" || name == "teams")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Register the HTTP handler for Slack events
+func registerSlackRoutes(ctx context.Context, config *Config, store TaskStore, state *botState) error {
+	if config.SlackSigningSecret == "" {
+		return errors.New("slack_signing_secret is required to verify /slack/events requests")
+	}
 
-	// Initialize Slack API with bot token from config
-	api := slack.New(config.SlackToken)
+	// Initialize Slack API with bot token from config, plus one client per extra workspace
+	var outbox *slackOutbox
+	if maxAge := slackRetryAge(config); maxAge > 0 {
+		outbox = newSlackOutbox(maxAge)
+		go outbox.Run(ctx)
+	}
+	defaultMessenger := &slackMessenger{api: slack.New(config.SlackToken), users: newUserCache(), channels: newChannelCache(), unfurl: config.UnfurlLinks, outbox: outbox}
+	if err := defaultMessenger.checkAuth(ctx); err != nil {
+		return err
+	}
+	workspaceMessengers := make(map[string]Messenger, len(config.SlackTokens))
+	for teamID, token := range config.SlackTokens {
+		messenger := &slackMessenger{api: slack.New(token), users: newUserCache(), channels: newChannelCache(), unfurl: config.UnfurlLinks, outbox: outbox}
+		if err := messenger.checkAuth(ctx); err != nil {
+			return fmt.Errorf("workspace %s: %w", teamID, err)
+		}
+		workspaceMessengers[teamID] = messenger
+	}
+	state.notifier = defaultMessenger
 
 	// HTTP handler for Slack events
-	http.HandleFunc("/slack/events", func(w http.ResponseWriter, r *http.Request) {
+	http.Handle("/slack/events", slackEventsHandler(ctx, func(teamID string) Messenger {
+		if messenger, ok := workspaceMessengers[teamID]; ok {
+			return messenger
+		}
+		return defaultMessenger
+	}, store, state))
+
+	// Interactions endpoint for the commands modal, verified with the signing secret
+	if config.SlackSigningSecret != "" {
+		messengerFor := func(teamID string) *slackMessenger {
+			if messenger, ok := workspaceMessengers[teamID].(*slackMessenger); ok {
+				return messenger
+			}
+			return defaultMessenger
+		}
+		http.Handle("/slack/interactions", interactionsHandler(ctx, messengerFor, store, state))
+	}
+	return nil
+}
+
+// Handle Slack Events API requests: answer URL verification and dispatch
+// message events off the request goroutine. messengerFor picks the backend
+// that replies for the event's workspace, so tests can pass a fake one.
+func slackEventsHandler(ctx context.Context, messengerFor func(teamID string) Messenger, store TaskStore, state *botState) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Read the request body
-		var body []byte
-		body, err := ioutil.ReadAll(r.Body)
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
 		if err != nil {
 			log.Printf("Error reading request body: %v", err)
 			http.Error(w, "Can't read body", http.StatusBadRequest)
 			return
 		}
 
+		// Verify the signature before anything in the event is acted on
+		if err := verifySlackRequest(state.config.Load(), r.Header, body); err != nil {
+			log.Printf("Rejected Slack event from %s: %v", r.RemoteAddr, err)
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+
 		// Parse the request body into a map to detect URL verification requests
 		var parsedBody map[string]interface{}
 		err = json.Unmarshal(body, &parsedBody)
@@ -105,199 +538,1005 @@ func main() {
 		}
 
 		// Log the entire incoming event for debugging
-		log.Printf("Event received: %v", parsedBody)
+		debugf("Event received: %v", parsedBody)
+
+		// Reply through the workspace the event came from
+		teamID, _ := parsedBody["team_id"].(string)
+		messenger := messengerFor(teamID)
 
-		// Handle regular messages
-		handleMessageEvent(api, parsedBody, config)
+		// Acknowledge right away so Slack doesn't retry, then handle the
+		// message off the request goroutine since tasks do network I/O
+		w.WriteHeader(http.StatusOK)
+		go handleMessageEvent(ctx, messenger, parsedBody, state.config.Load(), store, state)
 	})
+}
+
+// Matches the "<@U123> " prefix of a message that mentions the bot
+var leadingMentionPattern = regexp.MustCompile(`^\s*<@[A-Za-z0-9]+(\|[^>]*)?>\s*`)
 
-	log.Println("Bot is running on port 8081...")
-	log.Fatal(http.ListenAndServe(":8081", nil))
+// Message subtypes dropped before any processing or logging
+var ignoredMessageSubtypes = map[string]bool{
+	"channel_join":    true,
+	"channel_leave":   true,
+	"group_join":      true,
+	"group_leave":     true,
+	"bot_message":     true,
+	"message_deleted": true,
 }
 
 // Handle incoming messages and trigger tasks
-func handleMessageEvent(api *slack.Client, event map[string]interface{}, config *Config) {
+func handleMessageEvent(ctx context.Context, messenger Messenger, event map[string]interface{}, config *Config, store TaskStore, state *botState) {
+	defer state.recoverPanic("a Slack event")
+
+	ctx, span := tracer.Start(ctx, "slack.event")
+	defer span.End()
+
 	if event["event"] != nil {
 		evt := event["event"].(map[string]interface{})
 
+		// Opening the app's Home tab publishes the command dashboard
+		if evt["type"] == "app_home_opened" {
+			handleAppHomeOpened(messenger, evt, config, store, state)
+			return
+		}
+
+		// Reactions mapped in reaction_triggers run their command
+		if evt["type"] == "reaction_added" {
+			handleReactionEvent(ctx, messenger, evt, config, store, state)
+			return
+		}
+
 		// Ignore bot messages (the bot_id field is present if the message is from a bot)
 		if evt["bot_id"] != nil {
 			log.Println("Ignoring message from bot.")
 			return
 		}
 
+		// Ignore joins, leaves and deletions, which are never commands
+		if subtype, _ := evt["subtype"].(string); ignoredMessageSubtypes[subtype] {
+			return
+		}
+
 		// Log the full event for debugging
-		log.Printf("Full event received: %v", evt)
+		debugf("Full event received: %v", evt)
+
+		// Edited messages are handled like new ones when handle_edits is on
+		if evt["type"] == "message" && evt["subtype"] == "message_changed" && config.HandleEdits {
+			edited, ok := editedMessage(evt)
+			if !ok {
+				return
+			}
+			evt = edited
+		}
 
-		if evt["type"] == "message" && evt["subtype"] == nil {
+		isMention := evt["type"] == "app_mention"
+		if isMention || (evt["type"] == "message" && evt["subtype"] == nil) {
 			log.Printf("Message received: %s", evt["text"])
 
-			messageText := evt["text"].(string)
-			channelID := evt["channel"].(string)
+			messageText, _ := evt["text"].(string)
+			if strings.TrimSpace(messageText) == "" {
+				return
+			}
+
+			// Mentions carry the bot's user ID in front of the command. Plain
+			// messages starting with a mention are left to their app_mention event.
+			if isMention {
+				messageText = leadingMentionPattern.ReplaceAllString(messageText, "")
+			} else if config.MentionsOnly || leadingMentionPattern.MatchString(messageText) {
+				log.Println("Ignoring plain message, waiting for a mention.")
+				return
+			}
+
+			// With a command prefix, only messages starting with it are commands
+			if config.CommandPrefix != "" {
+				if !strings.HasPrefix(messageText, config.CommandPrefix) {
+					debugf("Ignoring message without the command prefix.")
+					return
+				}
+				messageText = strings.TrimSpace(strings.TrimPrefix(messageText, config.CommandPrefix))
+			}
+			channelID, _ := evt["channel"].(string)
+			userID, _ := evt["user"].(string)
+			timestamp, _ := evt["ts"].(string)
+
+			threadTS, _ := evt["thread_ts"].(string)
+
+			// In threads-only mode, skip channel-root messages and other threads
+			if config.ThreadsOnly {
+				if threadTS == "" || (config.ThreadRoot != "" && threadTS != config.ThreadRoot) {
+					log.Println("Ignoring message outside the command thread.")
+					return
+				}
+			}
 
 			// Log the channel ID and message
 			log.Printf("Message received in channel: %s, message: %s", channelID, messageText)
 
-			// Handle the "list" or "list command" request
-			if strings.ToLower(messageText) == "list command" || strings.ToLower(messageText) == "list" {
-				// Generate the list of available commands from the config file
-				var commandsList string
-				for cmd := range config.Tasks {
-					commandsList += fmt.Sprintf("- %s\n", cmd)
-				}
+			teamID, _ := event["team_id"].(string)
+			msg := incomingMessage{Text: messageText, ChannelID: channelID, UserID: userID, Timestamp: timestamp, ThreadTS: threadTS, TeamID: teamID}
+			handleCommand(ctx, messenger, msg, config, store, state)
+		}
+	}
+}
+
+// Turn a message_changed event into a plain message event carrying the new text.
+// Edits that don't change the text (e.g. link unfurls) or come from bots are skipped.
+func editedMessage(evt map[string]interface{}) (map[string]interface{}, bool) {
+	message, ok := evt["message"].(map[string]interface{})
+	if !ok || message["bot_id"] != nil {
+		return nil, false
+	}
+	text, ok := message["text"].(string)
+	if !ok {
+		return nil, false
+	}
+	if previous, ok := evt["previous_message"].(map[string]interface{}); ok && previous["text"] == text {
+		return nil, false
+	}
+
+	edited := make(map[string]interface{}, len(message)+2)
+	for key, value := range message {
+		edited[key] = value
+	}
+	edited["type"] = "message"
+	edited["channel"] = evt["channel"]
+	delete(edited, "subtype")
+	log.Printf("Handling edited message: %s", text)
+	return edited, true
+}
+
+// Match a chat message against the known commands and execute it.
+// Shared by every chat backend, replies go through the messenger.
+func handleCommand(ctx context.Context, messenger Messenger, msg incomingMessage, config *Config, store TaskStore, state *botState) {
+	ctx, span := tracer.Start(ctx, "command.dispatch", trace.WithAttributes(commandAttributes(msg)...))
+	defer span.End()
+
+	// Drop an identical command sent again within the debounce window
+	if !msg.SkipDebounce && !state.debounce.Allow(msg, debounceWindow(config)) {
+		log.Printf("Ignoring repeated message from %s: %s", msg.UserID, msg.Text)
+		return
+	}
+
+	messageText := msg.Text
+	channelID := msg.ChannelID
+	userID := msg.UserID
+	// Handle the "list" or "list command" request
+	if strings.ToLower(messageText) == "list command" || strings.ToLower(messageText) == "list" {
+		// Generate the list of available commands from the task store
+		tasks, err := store.ListTasks()
+		if err != nil {
+			log.Printf("Error listing tasks: %v", err)
+		}
+		var commandsList string
+		for cmd := range tasks {
+			commandsList += fmt.Sprintf("- %s\n", cmd)
+		}
+
+		// Send the list of commands back to the user
+		response := localize(config, userID, "commands_header") + "\n" + commandsList
+		err = messenger.PostMessage(channelID, response)
+		if err != nil {
+			log.Printf("Error sending message to Slack: %v", err)
+		}
+		return
+	}
+
+	// Handle "commands": open the searchable command modal where the backend has one
+	if strings.ToLower(messageText) == "commands" {
+		if browser, ok := messenger.(commandBrowser); ok {
+			if err := browser.PostCommandBrowser(config, channelID, userID); err != nil {
+				log.Printf("Error sending message to Slack: %v", err)
+			}
+			return
+		}
+		msg.Text = "list"
+		handleCommand(ctx, messenger, msg, config, store, state)
+		return
+	}
+
+	// Handle "list verbose" (or "list -v"): commands with their method and target host
+	if lower := strings.ToLower(messageText); lower == "list verbose" || lower == "list -v" {
+		tasks, err := store.ListTasks()
+		if err != nil {
+			log.Printf("Error listing tasks: %v", err)
+		}
+		err = messenger.PostMessage(channelID, formatVerboseTaskList(config, userID, tasks))
+		if err != nil {
+			log.Printf("Error sending message to Slack: %v", err)
+		}
+		return
+	}
+
+	// Handle "ping": a quick check that the bot is alive
+	if strings.ToLower(messageText) == "ping" {
+		if err := messenger.PostMessage(channelID, localize(config, userID, "pong")); err != nil {
+			log.Printf("Error sending message to Slack: %v", err)
+		}
+		return
+	}
+
+	// Handle "retry" to re-run the user's previous command
+	if lower := strings.ToLower(messageText); lower == "retry" || lower == "retry last" {
+		handleRetryCommand(ctx, messenger, msg, config, store, state)
+		return
+	}
+
+	// Handle the "history" or "history <command>" request
+	if lower := strings.ToLower(messageText); lower == "history" || strings.HasPrefix(lower, "history ") {
+		command := strings.TrimSpace(strings.TrimPrefix(lower, "history"))
+		response := formatHistory(config, userID, state.history.Recent(command), command)
+		err := messenger.PostMessage(channelID, response)
+		if err != nil {
+			log.Printf("Error sending message to Slack: %v", err)
+		}
+		return
+	}
+
+	// Handle the "whoami" request: show the caller's ID and permitted commands
+	if strings.ToLower(messageText) == "whoami" {
+		tasks, err := store.ListTasks()
+		if err != nil {
+			log.Printf("Error listing tasks: %v", err)
+		}
+		err = messenger.PostEphemeral(channelID, userID, formatWhoami(config, tasks, userID))
+		if err != nil {
+			log.Printf("Error sending message to Slack: %v", err)
+		}
+		return
+	}
 
-				// Send the list of commands back to the user
-				response := fmt.Sprintf("Here are the available commands:\n%s", commandsList)
-				_, _, err := api.PostMessage(channelID, slack.MsgOptionText(response, false))
+	// Handle the "stats" request: execution counts since startup
+	if strings.ToLower(messageText) == "stats" {
+		if !isUserAllowed(config, config.StatsAllowedUsers, userID) {
+			postNotAllowedMessage(messenger, config, channelID, userID, "stats")
+			return
+		}
+		commands, started := state.stats.Snapshot()
+		err := messenger.PostMessage(channelID, formatStats(config, userID, commands, started))
+		if err != nil {
+			log.Printf("Error sending message to Slack: %v", err)
+		}
+		return
+	}
+
+	// Handle the "selftest" request: check every task host is reachable
+	if strings.ToLower(messageText) == "selftest" {
+		tasks, err := store.ListTasks()
+		if err != nil {
+			log.Printf("Error listing tasks: %v", err)
+		}
+		err = messenger.PostMessage(channelID, formatSelfTest(config, userID, runSelfTest(ctx, config, tasks)))
+		if err != nil {
+			log.Printf("Error sending message to Slack: %v", err)
+		}
+		return
+	}
+
+	// Handle "status" (or "running"): list in-flight executions. A task named
+	// "status" takes precedence, "running" always lists executions.
+	if lower := strings.ToLower(messageText); lower == "running" || (lower == "status" && !taskExists(store, "status")) {
+		err := messenger.PostMessage(channelID, formatRunningExecutions(config, userID, state.executions.List()))
+		if err != nil {
+			log.Printf("Error sending message to Slack: %v", err)
+		}
+		return
+	}
+
+	// Handle "cancel <id>" for a running execution
+	if lower := strings.ToLower(messageText); strings.HasPrefix(lower, "cancel ") {
+		handleCancelCommand(messenger, msg, config, state, strings.TrimSpace(strings.TrimPrefix(lower, "cancel ")))
+		return
+	}
+
+	// Handle "describe <command>": show the request without executing it
+	if lower := strings.ToLower(messageText); strings.HasPrefix(lower, "describe ") {
+		handleDescribeCommand(messenger, msg, config, store, strings.TrimSpace(messageText[len("describe "):]))
+		return
+	}
+
+	// Handle the "config" admin command: the effective settings, redacted
+	if strings.ToLower(messageText) == "config" {
+		handleConfigCommand(messenger, msg, config, store, state)
+		return
+	}
+
+	// Handle the "reload" admin command
+	if strings.ToLower(messageText) == "reload" {
+		handleReloadCommand(messenger, msg, config, store, state)
+		return
+	}
+
+	// Handle the "pause" and "resume" admin commands
+	if lower := strings.ToLower(messageText); lower == "pause" || lower == "resume" {
+		handleMaintenanceCommand(messenger, msg, config, state, lower == "pause")
+		return
+	}
+
+	// Handle "run <cmd> <cmd> ..." to execute several tasks at once
+	if lower := strings.ToLower(messageText); strings.HasPrefix(lower, "run ") {
+		state.lastCommands.Set(userID, messageText)
+		handleBatchCommand(ctx, messenger, msg, config, store, state)
+		return
+	}
+
+	// Parse dynamic command like "deploy <service-name> <env>"
+	if strings.HasPrefix(strings.ToLower(messageText), "deploy ") {
+		state.lastCommands.Set(userID, messageText)
+		args, err := splitArgs(messageText)
+		if err != nil {
+			err = messenger.PostEphemeral(channelID, userID, localize(config, userID, "invalid_deploy", "error", err.Error()))
+			if err != nil {
+				log.Printf("Error sending message to Slack: %v", err)
+			}
+			return
+		}
+		if len(args) == 3 || len(args) == 4 {
+			serviceName := args[1]
+			env := args[2]
+			var branch string
+			if len(args) == 4 {
+				branch = args[3]
+			}
+			// Add this log to check if the URL format is correctly loaded
+			log.Printf("Jenkins URL format from config: %s", config.Jenkins.URLFormat)
+			// Construct the dynamic Jenkins URL using the format from the config
+			jenkinsURL, err := buildJenkinsURL(config.Jenkins.URLFormat, serviceName, env, branch)
+			if err != nil {
+				err = messenger.PostEphemeral(channelID, userID, localize(config, userID, "invalid_deploy", "error", err.Error()))
 				if err != nil {
 					log.Printf("Error sending message to Slack: %v", err)
 				}
 				return
 			}
 
-			// Parse dynamic command like "deploy <service-name> <env>"
-			if strings.HasPrefix(strings.ToLower(messageText), "deploy ") {
-				args := strings.Split(messageText, " ")
-				if len(args) == 3 {
-					serviceName := args[1]
-					env := args[2]
-					// Add this log to check if the URL format is correctly loaded
-					log.Printf("Jenkins URL format from config: %s", config.Jenkins.URLFormat)
-					// Construct the dynamic Jenkins URL using the format from the config
-					jenkinsURL := strings.Replace(config.Jenkins.URLFormat, "{service-name}", serviceName, 1)
-					jenkinsURL = strings.Replace(jenkinsURL, "{env}", env, 1)
-
-					log.Printf("Constructed Jenkins URL: %s", jenkinsURL) // Add this log for debugging
-
-					// Execute the Jenkins job with Basic Authentication
-					success := executeJenkinsJob(jenkinsURL, config.Jenkins.User, config.Jenkins.Token)
-
-					// Send the execution result back to the channel
-					var response string
-					if success {
-						response = fmt.Sprintf("Jenkins job for service '%s' in environment '%s' executed successfully.", serviceName, env)
-					} else {
-						response = fmt.Sprintf("Failed to execute Jenkins job for service '%s' in environment '%s'.", serviceName, env)
-					}
-					_, _, err := api.PostMessage(channelID, slack.MsgOptionText(response, false))
-					if err != nil {
-						log.Printf("Error sending message to Slack: %v", err)
-					}
-				} else {
-					// Invalid deploy command format
-					_, _, err := api.PostMessage(channelID, slack.MsgOptionText("Invalid deploy command format. Use: deploy <service-name> <env>", false))
-					if err != nil {
-						log.Printf("Error sending message to Slack: %v", err)
-					}
-				}
+			log.Printf("Constructed Jenkins URL: %s", jenkinsURL) // Add this log for debugging
+
+			// Only allowlisted users may deploy
+			if !isUserAllowed(config, config.Jenkins.AllowedUsers, userID) {
+				postNotAllowedMessage(messenger, config, channelID, userID, "deploy")
 				return
 			}
 
-			// Handle static API tasks defined in the config.json
-			userCommand := strings.ToLower(messageText)
-			task, exists := config.Tasks[userCommand]
+			// Acknowledge but don't execute while automation is paused
+			if state.paused.Load() {
+				postPausedMessage(messenger, config, channelID, userID)
+				return
+			}
 
-			if exists {
-				log.Printf("Executing task for command: %s", userCommand)
+			// Reject deploys of the same target inside the cooldown window
+			target := fmt.Sprintf("deploy %s %s", strings.ToLower(serviceName), strings.ToLower(env))
+			if elapsed, ok := state.cooldowns.Allow(target, time.Duration(config.Jenkins.CooldownSeconds)*time.Second); !ok {
+				postCooldownMessage(messenger, config, channelID, userID, target, elapsed)
+				return
+			}
 
-				// Execute the task (send HTTP request to the task URL)
-				success := executeTask(task)
+			// Let the user know the command was received before it runs
+			removeAck := acknowledgeMessage(messenger, config, msg)
+			defer removeAck()
 
-				// Send the execution result back to the channel
-				var response string
-				if success {
-					response = fmt.Sprintf("Task '%s' executed successfully.", task.Command)
-				} else {
-					response = fmt.Sprintf("Task '%s' failed to execute.", task.Command)
-				}
-				_, _, err := api.PostMessage(channelID, slack.MsgOptionText(response, false))
-				if err != nil {
-					log.Printf("Error sending message to Slack: %v", err)
-				}
+			// Post the deploy's replies under its own bot identity
+			messenger = withIdentity(messenger, botIdentity{Username: config.Jenkins.Username, IconEmoji: config.Jenkins.IconEmoji, AsUser: config.Jenkins.AsUser})
 
-			} else {
-				// Log if the command was not recognized and respond with a helpful message
-				log.Printf("Unknown command: %s", userCommand)
+			// Track the deploy so it can be cancelled
+			execCtx, exec := state.executions.Start(ctx, messageText, userID, channelID)
+			defer state.executions.Finish(exec.ID)
+			interimTS := postRunningMessage(messenger, config, channelID, exec)
 
-				_, _, err := api.PostMessage(channelID, slack.MsgOptionText("I don't know your message. Please try again.", false))
-				if err != nil {
-					log.Printf("Error sending unrecognized message response: %v", err)
+			// Execute the Jenkins job with Basic Authentication, using the
+			// credentials of the target environment. The queue and build
+			// polls that follow go through the SSRF guard as well.
+			execCtx = withTargetGuard(execCtx, config)
+			start := time.Now()
+			jenkins := config.Jenkins.forEnv(env)
+			result := executeJenkinsJob(execCtx, config, jenkinsURL, jenkins.User, jenkins.Token)
+			success, queueURL := result.Success, result.Location
+
+			// Optionally wait for the build itself and post its console tail on failure
+			linkURL := queueURL
+			if success && config.Jenkins.WaitForResult && queueURL != "" {
+				success = waitForDeployResult(execCtx, messenger, channelID, userID, config, jenkins, state, queueURL, func(buildURL string) {
+					linkURL = buildURL
+					state.executions.SetBuildURL(exec.ID, buildURL, jenkins, guardFromContext(execCtx))
+				})
+			}
+			duration := time.Since(start)
+			streak := state.recordExecution(config, messageText, userID, success, duration)
+
+			// Send the execution result back to the channel
+			var response string
+			if success {
+				response = localize(config, userID, "deploy_success", "service", serviceName, "env", env)
+			} else {
+				response = localize(config, userID, "deploy_failure", "service", serviceName, "env", env)
+				if result.RequestID != "" {
+					response += " " + localize(config, userID, "request_id", "request_id", result.RequestID)
 				}
 			}
+			response = renderReply(replyTemplate(success, config.Jenkins.SuccessMessage, config.Jenkins.FailureMessage), replyData{
+				Command: "deploy",
+				User:    userID,
+				Status:  statusText(success),
+				Args:    map[string]string{"service": serviceName, "env": env, "branch": branch},
+			}, response)
+			err = replaceResult(ctx, messenger, config, channelID, interimTS, defaultUpdateTimeout, resultCard{
+				Text:     response,
+				Command:  messageText,
+				User:     userID,
+				Success:  success,
+				Duration: duration,
+				LinkURL:  linkURL,
+			}, true)
+			if err != nil {
+				log.Printf("Error sending message to Slack: %v", err)
+			}
+			escalateFailures(messenger, config, channelID, userID, messageText, streak)
+			recordOutcome(messenger, messageText, taskOutcome{Response: response, Executed: true, Success: success, Duration: duration, FailureStreak: streak})
+			if success {
+				removeAck()
+				deleteTriggerMessage(messenger, config, msg)
+			}
+		} else {
+			// Invalid deploy command format
+			err := messenger.PostEphemeral(channelID, userID, localize(config, userID, "deploy_usage"))
+			if err != nil {
+				log.Printf("Error sending message to Slack: %v", err)
+			}
+		}
+		return
+	}
+
+	// Handle static API tasks from the task store
+	userCommand, task, args, exists, rejection := resolveTask(config, store, userID, messageText)
+	if rejection != "" {
+		err := messenger.PostEphemeral(channelID, userID, rejection)
+		if err != nil {
+			log.Printf("Error sending message to Slack: %v", err)
+		}
+		return
+	}
+	if args != nil {
+		msg.Args = args
+	}
+
+	// Unrecognized input goes to the fallback task, which receives it as {text}
+	if !exists && config.FallbackCommand != "" {
+		fallback := strings.ToLower(config.FallbackCommand)
+		if fallbackTask, found, _ := store.GetTask(fallback); found {
+			log.Printf("Unknown command '%s', running fallback '%s'", userCommand, fallback)
+			userCommand, task, exists = fallback, fallbackTask, true
+			msg.Args = map[string]string{"text": messageText}
+		} else {
+			log.Printf("Fallback command '%s' is not a task", fallback)
+		}
+	}
+
+	if exists {
+		state.lastCommands.Set(userID, messageText)
+		messenger = withIdentity(messenger, taskIdentity(task))
+		outcome := runTask(ctx, messenger, msg, config, state, userCommand, task)
+		recordOutcome(messenger, userCommand, outcome)
+		var err error
+		if outcome.Ephemeral {
+			err = messenger.PostEphemeral(channelID, userID, outcome.Response)
+		} else {
+			// Checks that stop the task before it ran get a plain reply
+			err = replaceResult(ctx, messenger, config, channelID, outcome.InterimTS, time.Duration(task.UpdateTimeoutSeconds)*time.Second, resultCard{
+				Text:     outcome.Response,
+				Command:  userCommand,
+				User:     userID,
+				Success:  outcome.Success,
+				Duration: outcome.Duration,
+				LinkURL:  task.LinkURL,
+			}, outcome.Executed)
+		}
+		if err != nil {
+			log.Printf("Error sending message to Slack: %v", err)
+		}
+		if len(outcome.ResponseBody) > 0 {
+			postResponseBody(messenger, config, channelID, userID, userCommand, outcome.ResponseBody)
+		}
+		if outcome.Success {
+			deleteTriggerMessage(messenger, config, msg)
+		}
+		escalateFailures(messenger, config, channelID, userID, userCommand, outcome.FailureStreak)
+
+	} else {
+		// Log if the command was not recognized and respond with a helpful message
+		log.Printf("Unknown command: %s", userCommand)
+
+		err := messenger.PostEphemeral(channelID, userID, localize(config, userID, "unknown_command"))
+		if err != nil {
+			log.Printf("Error sending unrecognized message response: %v", err)
+		}
+	}
+}
+
+// Result of trying to run a static task
+type taskOutcome struct {
+	Response  string
+	Executed  bool // false when a check stopped the task before it ran
+	Success   bool
+	Ephemeral bool // the response is a rejection only the invoker should see
+	Duration  time.Duration
+
+	FailureStreak int    // Consecutive failures of the command, including this run
+	ResponseBody  []byte // Posted after the reply for attach_response_as_file tasks
+	InterimTS     string // Running message to edit into the result, when update_running_message is on
+}
+
+// Run a static task after the allowlist, maintenance, concurrency and
+// cooldown checks, and build the reply for the channel
+func runTask(ctx context.Context, messenger Messenger, msg incomingMessage, config *Config, state *botState, userCommand string, task Task) taskOutcome {
+	channelID := msg.ChannelID
+	userID := msg.UserID
+
+	// Only allowlisted users may run the task
+	if !isUserAllowed(config, task.AllowedUsers, userID) {
+		log.Printf("User %s is not allowed to run '%s'", userID, userCommand)
+		return taskOutcome{Response: notAllowedMessage(config, userID, userCommand), Ephemeral: true}
+	}
+
+	// Refuse to run outside the task's allowed time window
+	if !inTimeWindow(task, time.Now()) {
+		return taskOutcome{Response: timeWindowMessage(config, userID, userCommand, task), Ephemeral: true}
+	}
+
+	// Acknowledge but don't execute while automation is paused
+	if state.paused.Load() {
+		return taskOutcome{Response: localize(config, userID, "paused")}
+	}
+
+	// Refuse to start another run while the task is at its concurrency limit
+	release, ok := state.running.TryAcquire(userCommand, task.MaxConcurrent)
+	if !ok {
+		return taskOutcome{Response: localize(config, userID, "already_running", "command", userCommand), Ephemeral: true}
+	}
+	defer release()
+
+	// Reject re-runs inside the task's cooldown window. The run is only
+	// reserved after the health check and precondition, so a skipped run
+	// doesn't use up the window.
+	cooldown := time.Duration(task.CooldownSeconds) * time.Second
+	if elapsed, ok := state.cooldowns.Check(userCommand, cooldown); !ok {
+		return taskOutcome{Response: cooldownMessage(config, userID, userCommand, elapsed), Ephemeral: true}
+	}
+
+	// Wait for earlier runs on the same resource, telling the channel where this one is in line
+	task.ResourceKey = substituteVariables(task.ResourceKey, msg.Args, func(value string) string { return value })
+	if task.ResourceKey != "" {
+		ahead, wait, release := state.resources.Join(task.ResourceKey)
+		defer release()
+		if ahead > 0 {
+			log.Printf("Queued '%s' behind %d run(s) on %s", userCommand, ahead, task.ResourceKey)
+			queued := localize(config, userID, "task_queued", "command", userCommand, "position", strconv.Itoa(ahead+1), "resource", task.ResourceKey)
+			if err := messenger.PostMessage(channelID, queued); err != nil {
+				log.Printf("Error sending message to Slack: %v", err)
+			}
+		}
+		if err := wait(ctx); err != nil {
+			return taskOutcome{Response: localize(config, userID, "queue_aborted", "command", userCommand)}
+		}
+	}
+
+	log.Printf("Executing task for command: %s", userCommand)
+
+	// Let the user know the command was received before it runs
+	removeAck := acknowledgeMessage(messenger, config, msg)
+	defer removeAck()
+
+	// Track the execution so it can be cancelled
+	execCtx, exec := state.executions.Start(ctx, userCommand, userID, channelID)
+	defer state.executions.Finish(exec.ID)
+	interimTS := postRunningMessage(messenger, config, channelID, exec)
+
+	// Look up file:// and env:// credentials now, so rotated secrets are used.
+	// This runs on the configured task before any user input is filled in, so
+	// input such as an argument of "env://SLACK_TOKEN" is sent as typed.
+	task, err := resolveTaskSecrets(task)
+	if err != nil {
+		log.Printf("Error resolving secrets for '%s': %v", userCommand, err)
+		return taskOutcome{Response: localize(config, userID, "secret_missing", "command", userCommand), InterimTS: interimTS}
+	}
+
+	// Fill in the arguments, {user_id}, {user_name} and {user_email} for downstream audit trails
+	task = applyUserVariables(task, commandVariables(messenger, msg))
+
+	// Don't call a target whose pre-flight health check fails
+	if task.HealthCheckURL != "" {
+		if err := checkTaskHealth(execCtx, config, task); err != nil {
+			log.Printf("Health check for '%s' failed: %v", userCommand, err)
+			return taskOutcome{Response: localize(config, userID, "target_unhealthy", "command", userCommand, "error", err.Error()), InterimTS: interimTS}
+		}
+	}
+
+	// Skip the task, with the reason, when its precondition doesn't hold
+	if task.Precondition != nil {
+		if err := checkPrecondition(execCtx, config, task); err != nil {
+			log.Printf("Precondition for '%s' not met: %v", userCommand, err)
+			response := localize(config, userID, "precondition", "command", userCommand, "reason", err.Error())
+			if task.Precondition.Message != "" {
+				response = task.Precondition.Message
+			}
+			return taskOutcome{Response: response, InterimTS: interimTS}
+		}
+	}
+
+	// Reserve the run, unless another one took the cooldown window meanwhile
+	if elapsed, ok := state.cooldowns.Allow(userCommand, cooldown); !ok {
+		return taskOutcome{Response: cooldownMessage(config, userID, userCommand, elapsed), InterimTS: interimTS}
+	}
+
+	// Execute the task (send HTTP request to the task URL, or run each step of a chain)
+	start := time.Now()
+	var success bool
+	var stepReport, extracted, requestID string
+	var truncated bool
+	var cachedAge time.Duration
+	var responseBody []byte
+	var statusCode int
+	var failureReason string // Why the run failed, for the PagerDuty incident
+	if len(task.Steps) > 0 {
+		success, stepReport = executeSteps(execCtx, config, task)
+		failureReason = "a step of the chain failed"
+	} else {
+		result := executeTask(execCtx, config, task)
+		failureReason = taskFailureReason(result)
+		success, requestID, truncated = result.Success, result.RequestID, result.Truncated
+		cachedAge, statusCode = result.CachedAge, result.StatusCode
+		if task.ResponsePath != "" {
+			extracted = formatResponseValue(result.Body, task.ResponsePath)
+		}
+		if task.AttachResponseAsFile {
+			responseBody = result.Body
+		}
+	}
+	duration := time.Since(start)
+	streak := state.recordExecution(config, userCommand, userID, success, duration)
+
+	// Build the execution result for the channel
+	var response string
+	if success {
+		response = localize(config, userID, "task_success", "command", task.Command)
+	} else {
+		response = localize(config, userID, "task_failure", "command", task.Command)
+		if requestID != "" {
+			response += " " + localize(config, userID, "request_id", "request_id", requestID)
+		}
+	}
+	// A status_messages entry for the response code takes precedence over success_message and failure_message
+	tmpl := replyTemplate(success, task.SuccessMessage, task.FailureMessage)
+	if message, ok := statusMessage(task.StatusMessages, statusCode); ok {
+		tmpl = message
+	}
+	response = renderReply(tmpl, replyData{
+		Command:    task.Command,
+		User:       userID,
+		Status:     statusText(success),
+		Args:       msg.Args,
+		StatusCode: statusCode,
+	}, response)
+	if extracted != "" {
+		response += "\n" + extracted
+	}
+	if stepReport != "" {
+		response += "\n" + stepReport
+	}
+	if truncated {
+		response += "\n" + localize(config, userID, "response_cut", "bytes", strconv.FormatInt(maxResponseBytes(config), 10))
+	}
+	if cachedAge > 0 {
+		response += "\n" + localize(config, userID, "response_cached", "seconds", strconv.Itoa(int(cachedAge.Seconds())))
+	}
+
+	// Page on-call for failed runs when the task asks for it
+	if !success {
+		go triggerPagerDuty(task.OnFailurePagerDuty, userCommand, userID, failureReason)
+		if mention := failureMention(task.MentionOnFailure); mention != "" {
+			response = mention + " " + response
 		}
 	}
+	return taskOutcome{Response: response, Executed: true, Success: success, Duration: duration, FailureStreak: streak, ResponseBody: responseBody, InterimTS: interimTS}
+}
+
+// Tell the user the command was not run because automation is paused
+func postPausedMessage(messenger Messenger, config *Config, channelID, userID string) {
+	err := messenger.PostMessage(channelID, localize(config, userID, "paused"))
+	if err != nil {
+		log.Printf("Error sending message to Slack: %v", err)
+	}
+}
+
+// Tell the user a command is still cooling down
+func postCooldownMessage(messenger Messenger, config *Config, channelID, userID, command string, elapsed time.Duration) {
+	err := messenger.PostEphemeral(channelID, userID, cooldownMessage(config, userID, command, elapsed))
+	if err != nil {
+		log.Printf("Error sending message to Slack: %v", err)
+	}
 }
 
-// Execute the Jenkins job using Basic Authentication for dynamic deploy
-func executeJenkinsJob(url, user, token string) bool {
+func cooldownMessage(config *Config, userID, command string, elapsed time.Duration) string {
+	return localize(config, userID, "cooldown", "command", command, "seconds", strconv.Itoa(int(elapsed.Seconds())))
+}
+
+// Wait for a triggered Jenkins build and report whether it succeeded
+func waitForDeployResult(ctx context.Context, messenger Messenger, channelID, userID string, config *Config, jenkins JenkinsConfig, state *botState, queueURL string, onBuild func(buildURL string)) bool {
+	build, err := waitForJenkinsBuild(ctx, jenkins, queueURL, onBuild)
+	if err != nil {
+		log.Printf("Error polling Jenkins build status for %s: %v", queueURL, err)
+		if errors.Is(err, errJenkinsPollTimeout) {
+			response := localize(config, userID, "jenkins_gave_up", "timeout", jenkinsPollTimeout(jenkins).String())
+			if err := messenger.PostMessage(channelID, response); err != nil {
+				log.Printf("Error sending message to Slack: %v", err)
+			}
+		} else if ctx.Err() == nil {
+			state.notify("Error polling Jenkins build status for %s: %v", queueURL, err)
+		}
+		return false
+	}
+	if build.Result == "SUCCESS" {
+		return true
+	}
+
+	if build.Result == "FAILURE" && build.URL != "" {
+		console, err := fetchConsoleTail(ctx, jenkins, build.URL, maxResponseBytes(config))
+		if err != nil {
+			log.Printf("Error fetching Jenkins console output for %s: %v", build.URL, err)
+		} else {
+			response := localize(config, userID, "jenkins_console", "url", build.URL, "console", console)
+			if err := messenger.PostMessage(channelID, response); err != nil {
+				log.Printf("Error sending message to Slack: %v", err)
+			}
+		}
+	}
+	return false
+}
+
+// Execute the Jenkins job using Basic Authentication for dynamic deploy.
+// The result's Location is the queue item URL Jenkins sends back.
+func executeJenkinsJob(ctx context.Context, config *Config, url, user, token string) taskResult {
+	// Refuse to call private or metadata addresses when the SSRF guard is on
+	if err := checkTargetAllowed(ctx, config, url); err != nil {
+		log.Printf("Blocked Jenkins job at %s: %v", url, err)
+		return taskResult{}
+	}
+	ctx = withTargetGuard(ctx, config)
+
+	ctx, cancel := requestContext(ctx, 0)
+	defer cancel()
+
 	// Prepare the POST request with Basic Authentication
-	req, err := http.NewRequest("POST", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
 	if err != nil {
 		log.Printf("Error creating request: %v", err)
-		return false
+		return taskResult{}
 	}
+	result := taskResult{RequestID: setOutboundHeaders(req, config)}
 
 	// Add Basic Authentication header
 	auth := base64.StdEncoding.EncodeToString([]byte(user + ":" + token))
 	req.Header.Add("Authorization", "Basic "+auth)
 
 	// Send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		log.Printf("Error executing Jenkins job at %s: %v", url, err)
-		return false
+		log.Printf("Error executing Jenkins job at %s (request ID %s): %v", url, result.RequestID, err)
+		return result
 	}
 	defer resp.Body.Close()
+	result.StatusCode = resp.StatusCode
+	result.Body, result.Truncated, err = readLimited(resp.Body, maxResponseBytes(config))
+	if err != nil {
+		log.Printf("Error reading Jenkins response from %s: %v", url, err)
+	}
 
-	// Check if the job executed successfully
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		log.Printf("Jenkins job executed successfully at %s, response status: %s", url, resp.Status)
-		return true
+	// Check if the job executed successfully, by default any 2xx such as the 201 queue response
+	if !jenkinsStatusOK(config.Jenkins, resp.StatusCode) {
+		log.Printf("Failed to execute Jenkins job at %s (request ID %s), response status: %s", url, result.RequestID, resp.Status)
+	} else if !bodyMatches(config.Jenkins.SuccessBodyContains, config.Jenkins.SuccessBodyPattern, result.Body, "jenkins") {
+		log.Printf("Failed to execute Jenkins job at %s (request ID %s), response body did not match the success condition", url, result.RequestID)
 	} else {
-		log.Printf("Failed to execute Jenkins job at %s, response status: %s", url, resp.Status)
-		return false
+		log.Printf("Jenkins job executed successfully at %s (request ID %s), response status: %s", url, result.RequestID, resp.Status)
+		result.Success = true
+		result.Location = resp.Header.Get("Location")
 	}
+	return result
+}
+
+// Check the trigger status against success_status_codes, or any 2xx when unset
+func jenkinsStatusOK(jenkins JenkinsConfig, status int) bool {
+	if len(jenkins.SuccessStatusCodes) == 0 {
+		return status >= 200 && status < 300
+	}
+	for _, code := range jenkins.SuccessStatusCodes {
+		if code == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Outcome of executing a static API task or Jenkins job
+type taskResult struct {
+	Success    bool
+	StatusCode int
+	Body       []byte
+	RequestID  string        // X-Request-ID sent with the request
+	Location   string        // Location response header (the Jenkins queue item)
+	Truncated  bool          // Body was cut off at max_response_bytes
+	CachedAge  time.Duration // Age of the cached response this result was served from (0 = fresh)
+	Err        error         // Error sending the request, when no response came back
 }
 
 // Execute the static API task
-func executeTask(task Task) bool {
+func executeTask(ctx context.Context, config *Config, task Task) taskResult {
+	var err error
+
+	// Workflow tasks go through the GitHub API
+	if task.GitHubWorkflow != nil {
+		return executeGitHubWorkflow(ctx, config, task)
+	}
+
+	// Spread the load over several targets when the task lists them
+	if len(task.URLs) > 0 {
+		return executeTaskTargets(ctx, config, task)
+	}
+
+	// Resolve {env:NAME} references from the bot's environment
+	task.URL, err = expandEnvRefs(task.URL, config.StrictEnv)
+	if err != nil {
+		log.Printf("Error building URL for task '%s': %v", task.Command, err)
+		return taskResult{}
+	}
+
+	// Refuse to call private or metadata addresses when the SSRF guard is on
+	if err := checkTargetAllowed(ctx, config, task.URL); err != nil {
+		log.Printf("Blocked task '%s' at %s: %v", task.Command, task.URL, err)
+		return taskResult{}
+	}
+	ctx = withTargetGuard(ctx, config)
+
+	// Serve read-only tasks from the response cache within cache_seconds
+	cacheTTL := time.Duration(task.CacheSeconds) * time.Second
+	cacheKey := responseCacheKey(task)
+	if cacheTTL > 0 && task.Method != "POST" {
+		if cached, age, ok := taskResponseCache.Get(cacheKey, cacheTTL); ok {
+			log.Printf("Task '%s' served from cache (%s old)", task.Command, age.Round(time.Second))
+			cached.CachedAge = age
+			return cached
+		}
+	}
+
+	// Retry transient failures up to task.Retries times
+	result := sendTaskRequest(ctx, config, task)
+	for attempt := 1; attempt <= task.Retries && shouldRetry(task, result); attempt++ {
+		delay := retryDelay(task, attempt)
+		log.Printf("Retrying task '%s' in %s (attempt %d of %d)", task.Command, delay, attempt+1, task.Retries+1)
+		if err := sleepContext(ctx, delay); err != nil {
+			return result
+		}
+		result = sendTaskRequest(ctx, config, task)
+	}
+
+	if result.Success && cacheTTL > 0 && task.Method != "POST" {
+		taskResponseCache.Put(cacheKey, result, cacheTTL)
+	}
+	return result
+}
+
+// Send one request for the task and check the response
+func sendTaskRequest(ctx context.Context, config *Config, task Task) taskResult {
+	ctx, cancel := requestContext(ctx, taskRequestTimeout(task))
+	defer cancel()
+
 	var req *http.Request
 	var err error
+	var requestBody string // Signed when the task has a signing_secret
 
 	if task.Method == "POST" {
-		// Prepare the request for POST method
-		req, err = http.NewRequest("POST", task.URL, nil)
-		if task.User != "" && task.Token != "" {
+		// Prepare the request for POST method, with an optional JSON or form-encoded body
+		var body io.Reader
+		contentType := ""
+		if len(task.FormData) > 0 {
+			form := url.Values{}
+			for key, value := range task.FormData {
+				form.Set(key, value)
+			}
+			requestBody = form.Encode()
+			body = strings.NewReader(requestBody)
+			contentType = "application/x-www-form-urlencoded"
+		} else if task.Body != "" {
+			requestBody = task.Body
+			body = strings.NewReader(requestBody)
+			contentType = "application/json"
+		}
+
+		req, err = http.NewRequestWithContext(ctx, "POST", task.URL, body)
+		if err == nil && contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if err == nil && task.User != "" && task.Token != "" {
 			// Create the Basic Authentication header
 			auth := base64.StdEncoding.EncodeToString([]byte(task.User + ":" + task.Token))
 			req.Header.Add("Authorization", "Basic "+auth)
 		}
 	} else {
 		// For GET, simply create the request
-		req, err = http.NewRequest("GET", task.URL, nil)
+		req, err = http.NewRequestWithContext(ctx, "GET", task.URL, nil)
 	}
 
 	if err != nil {
 		log.Printf("Error creating request for task '%s': %v", task.Command, err)
-		return false
+		return taskResult{}
+	}
+	result := taskResult{RequestID: setOutboundHeaders(req, config)}
+	for key, value := range task.Headers {
+		req.Header.Set(key, value)
+	}
+	if task.SigningSecret != "" {
+		req.Header.Set(signatureHeader, signPayload(task.SigningSecret, []byte(requestBody)))
+	}
+	if task.Debug {
+		logTaskRequest(task, req, requestBody)
 	}
 
-	// Send the request
-	client := &http.Client{}
+	// Send the request, through the task's own proxy or CA bundle when it has one
+	client, err := taskHTTPClient(task)
+	if err != nil {
+		log.Printf("Error preparing client for task '%s': %v", task.Command, err)
+		return result
+	}
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Printf("Error executing task '%s' at %s: %v", task.Command, task.URL, err)
-		return false
+		log.Printf("Error executing task '%s' at %s (request ID %s): %v", task.Command, task.URL, result.RequestID, err)
+		result.Err = err
+		return result
 	}
 	defer resp.Body.Close()
 
-	// Check if the task executed successfully based on the response status code
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		log.Printf("Task '%s' executed successfully at %s, response status: %s", task.Command, task.URL, resp.Status)
-		return true
+	result.StatusCode = resp.StatusCode
+	result.Body, result.Truncated, err = readLimited(resp.Body, maxResponseBytes(config))
+	if result.Truncated {
+		log.Printf("Response for task '%s' truncated at %d bytes", task.Command, maxResponseBytes(config))
+	}
+	if err != nil {
+		log.Printf("Error reading response for task '%s': %v", task.Command, err)
+	}
+	if task.Debug {
+		logTaskResponse(task, resp, result.Body)
+	}
+
+	// Check if the task executed successfully based on the response status code and body
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Printf("Task '%s' failed at %s (request ID %s), response status: %s", task.Command, task.URL, result.RequestID, resp.Status)
+	} else if !responseBodyMatches(task, result.Body) {
+		log.Printf("Task '%s' failed at %s (request ID %s), response body did not match the success condition", task.Command, task.URL, result.RequestID)
 	} else {
-		log.Printf("Task '%s' failed at %s, response status: %s", task.Command, task.URL, resp.Status)
+		log.Printf("Task '%s' executed successfully at %s (request ID %s), response status: %s", task.Command, task.URL, result.RequestID, resp.Status)
+		result.Success = true
+	}
+	return result
+}
+
+// Check the response body against the task's optional success conditions
+func responseBodyMatches(task Task, body []byte) bool {
+	return bodyMatches(task.SuccessBodyContains, task.SuccessBodyPattern, body, "task '"+task.Command+"'")
+}
+
+// Check a response body against optional success_body_contains and
+// success_body_pattern settings; source names their owner in logs
+func bodyMatches(contains, pattern string, body []byte, source string) bool {
+	if contains != "" && !bytes.Contains(body, []byte(contains)) {
 		return false
 	}
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Printf("Invalid success_body_pattern for %s: %v", source, err)
+			return false
+		}
+		if !re.Match(body) {
+			return false
+		}
+	}
+	return true
 }
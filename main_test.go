@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Cancelling the context mid-request makes each execute function return promptly
+func TestExecuteCancelledMidRequest(t *testing.T) {
+	release := make(chan struct{})
+	arrived := make(chan struct{}, 4)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer target.Close()
+	defer close(release)
+
+	tests := []struct {
+		name string
+		run  func(ctx context.Context) bool
+	}{
+		{name: "GET task", run: func(ctx context.Context) bool {
+			return executeTask(ctx, &Config{}, Task{Command: "health", URL: target.URL, Method: "GET"}).Success
+		}},
+		{name: "POST task", run: func(ctx context.Context) bool {
+			return executeTask(ctx, &Config{}, Task{Command: "restart", URL: target.URL, Method: "POST", User: "bot", Token: "secret"}).Success
+		}},
+		{name: "chained task", run: func(ctx context.Context) bool {
+			success, _ := executeSteps(ctx, &Config{}, Task{Command: "release", Steps: []Task{{URL: target.URL, Method: "GET"}}})
+			return success
+		}},
+		{name: "Jenkins job", run: func(ctx context.Context) bool {
+			return executeJenkinsJob(ctx, &Config{}, target.URL, "jenkins", "token").Success
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			result := make(chan bool, 1)
+			go func() { result <- test.run(ctx) }()
+
+			<-arrived
+			cancel()
+			select {
+			case success := <-result:
+				if success {
+					t.Error("cancelled request reported success")
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("call did not return after the context was cancelled")
+			}
+		})
+	}
+}
+
+// An already-cancelled context never reaches the target
+func TestExecuteTaskCancelledBeforeStart(t *testing.T) {
+	hit := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hit = true }))
+	defer target.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if executeTask(ctx, &Config{}, Task{Command: "health", URL: target.URL, Method: "GET"}).Success {
+		t.Error("task succeeded with a cancelled context")
+	}
+	if hit {
+		t.Error("request sent despite the cancelled context")
+	}
+}
+
+// Slack event callback for a plain user message
+func messageEvent(user, text string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "event_callback",
+		"event": map[string]interface{}{
+			"type":    "message",
+			"channel": "C1",
+			"user":    user,
+			"text":    text,
+			"ts":      "1700000000.000100",
+		},
+	}
+}
+
+func TestConfigFilePath(t *testing.T) {
+	tests := []struct {
+		name string
+		flag string
+		env  string
+		want string
+	}{
+		{name: "flag", flag: "/etc/automation-bot/config.json", env: "/srv/config.json", want: "/etc/automation-bot/config.json"},
+		{name: "CONFIG_PATH", env: "/srv/config.json", want: "/srv/config.json"},
+		{name: "default", want: "config.json"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("CONFIG_PATH", test.env)
+			if got := configFilePath(test.flag); got != test.want {
+				t.Errorf("configFilePath(%q) = %q, want %q", test.flag, got, test.want)
+			}
+		})
+	}
+}
+
+// The file named by -config is the one loaded, wherever the bot runs from
+func TestConfigFlagHonored(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.json")
+	writeFile(t, path, `{"slack_token": "xoxb-from-flag"}`)
+	t.Setenv("CONFIG_PATH", filepath.Join(t.TempDir(), "other.json"))
+
+	config, err := loadConfig(configFilePath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.SlackToken != "xoxb-from-flag" {
+		t.Errorf("slack_token = %q, want the value from the -config file", config.SlackToken)
+	}
+}
+
+// POST tasks send their form_data url-encoded or their body as JSON
+func TestExecuteTaskRequestBody(t *testing.T) {
+	type request struct{ contentType, body string }
+	requests := make(chan request, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{r.Header.Get("Content-Type"), string(body)}
+	}))
+	defer target.Close()
+
+	tests := []struct {
+		name            string
+		task            Task
+		wantContentType string
+		wantBody        string
+	}{
+		{name: "form data", task: Task{Method: "POST", FormData: map[string]string{"service": "api", "note": "a&b c"}}, wantContentType: "application/x-www-form-urlencoded", wantBody: "note=a%26b+c&service=api"},
+		{name: "raw body", task: Task{Method: "POST", Body: `{"service":"api"}`}, wantContentType: "application/json", wantBody: `{"service":"api"}`},
+		{name: "no body", task: Task{Method: "POST"}, wantContentType: "", wantBody: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.task.Command, test.task.URL = "legacy", target.URL
+			if !executeTask(context.Background(), &Config{}, test.task).Success {
+				t.Fatal("task failed")
+			}
+			got := <-requests
+			if got.contentType != test.wantContentType || got.body != test.wantBody {
+				t.Errorf("sent Content-Type %q body %q, want %q %q", got.contentType, got.body, test.wantContentType, test.wantBody)
+			}
+		})
+	}
+}
+
+// A 2xx response only counts as success when the body meets the task's conditions
+func TestExecuteTaskSuccessBody(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"status":"deployed","version":"1.4.2"}`)
+	}))
+	defer target.Close()
+
+	tests := []struct {
+		name     string
+		contains string
+		pattern  string
+		want     bool
+	}{
+		{name: "no condition", want: true},
+		{name: "contains", contains: `"status":"deployed"`, want: true},
+		{name: "does not contain", contains: `"status":"failed"`, want: false},
+		{name: "pattern", pattern: `"version":"1\.\d+\.\d+"`, want: true},
+		{name: "pattern does not match", pattern: `"version":"2\.`, want: false},
+		{name: "both must match", contains: "deployed", pattern: `"version":"2\.`, want: false},
+		{name: "invalid pattern", pattern: "(", want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			task := Task{Command: "release", URL: target.URL, Method: "GET", SuccessBodyContains: test.contains, SuccessBodyPattern: test.pattern}
+			if got := executeTask(context.Background(), &Config{}, task).Success; got != test.want {
+				t.Errorf("Success = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// Joins, leaves, deletions and empty messages are dropped before they are logged or run
+func TestHandleMessageIgnoredEvents(t *testing.T) {
+	target, hits := newStubTarget(t)
+	store := newConfigTaskStore(map[string]Task{"restart": {Command: "restart", URL: target.URL + "/ok", Method: "POST"}})
+	tests := []struct {
+		name    string
+		subtype string
+		text    string
+	}{
+		{name: "channel join", subtype: "channel_join", text: "restart"},
+		{name: "channel leave", subtype: "channel_leave", text: "restart"},
+		{name: "group join", subtype: "group_join", text: "restart"},
+		{name: "group leave", subtype: "group_leave", text: "restart"},
+		{name: "bot message", subtype: "bot_message", text: "restart"},
+		{name: "message deleted", subtype: "message_deleted"},
+		{name: "empty text", text: "   "},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logs := captureLog(t)
+			config := &Config{LogLevel: "debug"}
+			event := messageEvent("U1", test.text)
+			if test.subtype != "" {
+				event["event"].(map[string]interface{})["subtype"] = test.subtype
+			}
+			messenger := newFakeMessenger()
+			handleMessageEvent(context.Background(), messenger, event, config, store, newBotState(config))
+
+			if len(hits()) != 0 || len(messenger.sent()) != 0 {
+				t.Errorf("sent %+v and %v requests, want the event ignored", messenger.sent(), hits())
+			}
+			if test.subtype != "" && logs.Len() != 0 {
+				t.Errorf("ignored event was logged:\n%s", logs.String())
+			}
+		})
+	}
+}
+
+func TestNormalizeBasePath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "", want: ""},
+		{in: "/", want: ""},
+		{in: "bot", want: "/bot"},
+		{in: "/bot/", want: "/bot"},
+		{in: " /ops/bot ", want: "/ops/bot"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			if got := normalizeBasePath(test.in); got != test.want {
+				t.Errorf("normalizeBasePath(%q) = %q, want %q", test.in, got, test.want)
+			}
+		})
+	}
+}
+
+// Routes are only served under the prefix once base_path is set
+func TestWithBasePath(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	tests := []struct {
+		name     string
+		basePath string
+		path     string
+		want     int
+	}{
+		{name: "no base path", path: "/health", want: http.StatusOK},
+		{name: "under base path", basePath: "/bot", path: "/bot/health", want: http.StatusOK},
+		{name: "outside base path", basePath: "/bot", path: "/health", want: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			withBasePath(test.basePath, mux).ServeHTTP(rec, httptest.NewRequest("GET", test.path, nil))
+			if rec.Code != test.want {
+				t.Errorf("GET %s = %d, want %d", test.path, rec.Code, test.want)
+			}
+		})
+	}
+}
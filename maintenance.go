@@ -0,0 +1,35 @@
+package main
+
+import "log"
+
+// Check whether the user is allowed to run admin commands
+func isAdminUser(config *Config, userID string) bool {
+	for _, admin := range config.AdminUsers {
+		if admin == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle the "pause" and "resume" admin commands
+func handleMaintenanceCommand(messenger Messenger, msg incomingMessage, config *Config, state *botState, pause bool) {
+	if !isAdminUser(config, msg.UserID) {
+		log.Printf("User %s is not allowed to change maintenance mode", msg.UserID)
+		if err := messenger.PostEphemeral(msg.ChannelID, msg.UserID, localize(config, msg.UserID, "not_admin_pause")); err != nil {
+			log.Printf("Error sending message to Slack: %v", err)
+		}
+		return
+	}
+
+	state.paused.Store(pause)
+	response := localize(config, msg.UserID, "automation_resume")
+	if pause {
+		response = localize(config, msg.UserID, "automation_pause")
+	}
+	log.Printf("Maintenance mode changed by %s: paused=%t", msg.UserID, pause)
+
+	if err := messenger.PostMessage(msg.ChannelID, response); err != nil {
+		log.Printf("Error sending message to Slack: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// Execution is blocked between an admin's pause and resume; list keeps working
+func TestMaintenanceModeBlocksExecution(t *testing.T) {
+	target, hits := newStubTarget(t)
+	config := &Config{
+		AdminUsers:     []string{"UADMIN"},
+		Jenkins:        JenkinsConfig{URLFormat: target.URL + "/ok/{service-name}/{env}"},
+		DebounceMillis: -1,
+	}
+	store := newConfigTaskStore(map[string]Task{"restart": {Command: "restart", URL: target.URL + "/ok", Method: "POST"}})
+	state := newBotState(config)
+
+	steps := []struct {
+		user      string
+		text      string
+		wantReply string
+		wantHits  int // target calls so far
+	}{
+		{user: "U1", text: "pause", wantReply: "You are not allowed to pause or resume automation.", wantHits: 0},
+		{user: "UADMIN", text: "pause", wantReply: "Automation paused", wantHits: 0},
+		{user: "U1", text: "restart", wantReply: defaultMessages["paused"], wantHits: 0},
+		{user: "U1", text: "deploy api prod", wantReply: defaultMessages["paused"], wantHits: 0},
+		{user: "U1", text: "list", wantReply: "- restart", wantHits: 0},
+		{user: "U1", text: "resume", wantReply: "You are not allowed", wantHits: 0},
+		{user: "UADMIN", text: "Resume", wantReply: "Automation resumed", wantHits: 0},
+		{user: "U1", text: "restart", wantReply: "Task 'restart' executed successfully.", wantHits: 1},
+	}
+	for i, step := range steps {
+		messenger := newFakeMessenger()
+		handleMessageEvent(context.Background(), messenger, messageEvent(step.user, step.text), config, store, state)
+
+		replies := messenger.results()
+		if len(replies) != 1 || !strings.Contains(replies[0], step.wantReply) {
+			t.Errorf("step %d: %s %q replied %q, want %q", i+1, step.user, step.text, replies, step.wantReply)
+		}
+		if len(hits()) != step.wantHits {
+			t.Errorf("step %d: target called %d times, want %d", i+1, len(hits()), step.wantHits)
+		}
+	}
+}
+
+// paused in the config starts the bot in maintenance mode
+func TestMaintenanceModeFromConfig(t *testing.T) {
+	target, hits := newStubTarget(t)
+	config := &Config{Paused: true}
+	store := newConfigTaskStore(map[string]Task{"restart": {Command: "restart", URL: target.URL + "/ok", Method: "POST"}})
+	messenger := newFakeMessenger()
+
+	handleMessageEvent(context.Background(), messenger, messageEvent("U1", "restart"), config, store, newBotState(config))
+
+	if len(hits()) != 0 {
+		t.Error("task executed while paused")
+	}
+	if replies := messenger.results(); len(replies) != 1 || replies[0] != defaultMessages["paused"] {
+		t.Errorf("replies = %q, want the paused acknowledgement", replies)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestHandleAppMention(t *testing.T) {
+	target, _ := newStubTarget(t)
+	tests := []struct {
+		name        string
+		eventType   string
+		text        string
+		config      Config
+		wantHandled bool
+	}{
+		{name: "mention runs the command", eventType: "app_mention", text: "<@UBOT> restart", wantHandled: true},
+		{name: "mention with display name", eventType: "app_mention", text: "<@UBOT|automation-bot>   restart", wantHandled: true},
+		{name: "mention in mentions-only mode", eventType: "app_mention", text: "<@UBOT> restart", config: Config{MentionsOnly: true}, wantHandled: true},
+		{name: "plain message", eventType: "message", text: "restart", wantHandled: true},
+		{name: "plain message in mentions-only mode", eventType: "message", text: "restart", config: Config{MentionsOnly: true}, wantHandled: false},
+		{name: "message copy of a mention", eventType: "message", text: "<@UBOT> restart", wantHandled: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messenger := newFakeMessenger()
+			store := newConfigTaskStore(map[string]Task{"restart": {Command: "restart", URL: target.URL + "/ok", Method: "POST"}})
+			event := messageEvent("U1", test.text)
+			event["event"].(map[string]interface{})["type"] = test.eventType
+			handleMessageEvent(context.Background(), messenger, event, &test.config, store, newBotState(&test.config))
+
+			replies := messenger.results()
+			if !test.wantHandled {
+				if len(replies) != 0 {
+					t.Errorf("replies = %q, want the event ignored", replies)
+				}
+				return
+			}
+			if len(replies) != 1 || replies[0] != "Task 'restart' executed successfully." {
+				t.Errorf("replies = %q, want the restart result", replies)
+			}
+		})
+	}
+}
+
+// With command_prefix set, only prefixed messages are commands
+func TestHandleMessageCommandPrefix(t *testing.T) {
+	target, hits := newStubTarget(t)
+	tests := []struct {
+		name    string
+		prefix  string
+		text    string
+		wantRun bool
+	}{
+		{name: "no prefix configured", text: "restart", wantRun: true},
+		{name: "with prefix", prefix: "!bot ", text: "!bot restart", wantRun: true},
+		{name: "extra spaces after the prefix", prefix: "!bot", text: "!bot   restart", wantRun: true},
+		{name: "without prefix", prefix: "!bot ", text: "restart", wantRun: false},
+		{name: "prefix in the middle", prefix: "!bot ", text: "please !bot restart", wantRun: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &Config{CommandPrefix: test.prefix}
+			messenger := newFakeMessenger()
+			store := newConfigTaskStore(map[string]Task{"restart": {Command: "restart", URL: target.URL + "/ok", Method: "POST"}})
+			before := len(hits())
+			handleMessageEvent(context.Background(), messenger, messageEvent("U1", test.text), config, store, newBotState(config))
+
+			if ran := len(hits()) > before; ran != test.wantRun {
+				t.Errorf("ran = %v, want %v", ran, test.wantRun)
+			}
+			if !test.wantRun && len(messenger.sent()) != 0 {
+				t.Errorf("sent %+v, want the message ignored", messenger.sent())
+			}
+		})
+	}
+}
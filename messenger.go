@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/slack-go/slack"
+)
+
+// Messenger is the chat backend used to reply to commands
+type Messenger interface {
+	PostMessage(channelID, text string) error
+	PostEphemeral(channelID, userID, text string) error // Visible only to userID
+	AddReaction(channelID, timestamp, emoji string) error
+	RemoveReaction(channelID, timestamp, emoji string) error
+	DeleteMessage(channelID, timestamp string) error
+}
+
+// A chat message that may contain a command, independent of the backend
+type incomingMessage struct {
+	Text      string
+	ChannelID string
+	UserID    string
+	Timestamp string // Message ID used for reactions, when the backend has one
+	ThreadTS  string // Thread the message was posted in, empty at the channel root
+	TeamID    string // Slack workspace the message came from
+
+	SkipDebounce bool // Every request is deliberate, as for /trigger and workflow calls, or already debounced
+
+	Args map[string]string // Parsed arguments of a task with args, or the fallback's {text}
+}
+
+// slackMessenger posts replies through the Slack Web API
+type slackMessenger struct {
+	api      *slack.Client
+	users    *userCache
+	unfurl   bool         // Let Slack preview links such as build URLs
+	outbox   *slackOutbox // Retries replies while Slack is unreachable, nil to fail fast
+	identity botIdentity  // Name and icon replies are posted under, the app's own when empty
+	botUser  string       // The bot's own user ID, from auth.test
+
+	channels *ttlCache[string, string] // Channel names by ID, for {channel_name}
+}
+
+func (m *slackMessenger) PostMessage(channelID, text string) error {
+	return m.sendOrQueue(channelID, func() error {
+		_, _, err := m.api.PostMessage(channelID, m.messageOptions(slack.MsgOptionText(text, false))...)
+		return err
+	})
+}
+
+// Add the unfurl options to a reply unless unfurl_links is on, and the bot identity when set
+func (m *slackMessenger) messageOptions(options ...slack.MsgOption) []slack.MsgOption {
+	if !m.unfurl {
+		options = append(options, slack.MsgOptionDisableLinkUnfurl(), slack.MsgOptionDisableMediaUnfurl())
+	}
+	return append(options, m.identity.options()...)
+}
+
+// Post replies under the identity, sharing the client, user cache and outbox
+func (m *slackMessenger) WithIdentity(identity botIdentity) Messenger {
+	clone := *m
+	clone.identity = identity
+	return &clone
+}
+
+func (m *slackMessenger) PostEphemeral(channelID, userID, text string) error {
+	_, err := m.api.PostEphemeral(channelID, userID, slack.MsgOptionText(text, false))
+	return warnOnAuthError(err)
+}
+
+func (m *slackMessenger) AddReaction(channelID, timestamp, emoji string) error {
+	return warnOnAuthError(m.api.AddReaction(emoji, slack.NewRefToMessage(channelID, timestamp)))
+}
+
+func (m *slackMessenger) RemoveReaction(channelID, timestamp, emoji string) error {
+	return warnOnAuthError(m.api.RemoveReaction(emoji, slack.NewRefToMessage(channelID, timestamp)))
+}
+
+func (m *slackMessenger) DeleteMessage(channelID, timestamp string) error {
+	_, _, err := m.api.DeleteMessage(channelID, timestamp)
+	return warnOnAuthError(err)
+}
+
+// Look up the user's handle and email (the email needs the users:read.email scope)
+func (m *slackMessenger) LookupUser(userID string) (chatUser, error) {
+	return m.users.get(userID, func(userID string) (chatUser, error) {
+		user, err := m.api.GetUserInfo(userID)
+		if err != nil {
+			return chatUser{}, warnOnAuthError(err)
+		}
+		return chatUser{Name: user.Name, Email: user.Profile.Email}, nil
+	})
+}
+
+// Check the bot token with auth.test so a bad token is caught at startup,
+// and remember the bot's user ID
+func (m *slackMessenger) checkAuth(ctx context.Context) error {
+	resp, err := m.api.AuthTestContext(ctx)
+	if err != nil {
+		if isSlackAuthError(err) {
+			return fmt.Errorf("Slack rejected the bot token (%v): check slack_token in the configuration", err)
+		}
+		return fmt.Errorf("checking Slack token: %w", err)
+	}
+	log.Printf("Connected to Slack workspace %s as %s", resp.Team, resp.User)
+	m.botUser = resp.UserID
+	return nil
+}
+
+// Report Slack errors caused by a revoked or invalid token loudly, as every reply will fail
+func warnOnAuthError(err error) error {
+	if isSlackAuthError(err) {
+		log.Printf("WARNING: Slack rejected the bot token (%v), no replies can be delivered until slack_token is fixed", err)
+	}
+	return err
+}
+
+func isSlackAuthError(err error) bool {
+	var slackErr slack.SlackErrorResponse
+	if !errors.As(err, &slackErr) {
+		return false
+	}
+	return slackErr.Err == "invalid_auth" || slackErr.Err == "account_inactive" || slackErr.Err == "not_authed" || slackErr.Err == "token_revoked"
+}
+
+// Reaction added when a command is received, unless ack_reaction is "none"
+const defaultAckReaction = "eyes"
+
+// React to the triggering message so the user knows the command was received.
+// Returns a function that removes the reaction once the result is posted.
+func acknowledgeMessage(messenger Messenger, config *Config, msg incomingMessage) func() {
+	emoji := config.AckReaction
+	if emoji == "" {
+		emoji = defaultAckReaction
+	}
+	if emoji == "none" || msg.Timestamp == "" {
+		return func() {}
+	}
+
+	if err := messenger.AddReaction(msg.ChannelID, msg.Timestamp, emoji); err != nil {
+		log.Printf("Error adding acknowledgement reaction: %v", err)
+		return func() {}
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if err := messenger.RemoveReaction(msg.ChannelID, msg.Timestamp, emoji); err != nil {
+				log.Printf("Error removing acknowledgement reaction: %v", err)
+			}
+		})
+	}
+}
+
+// Delete the command message after a successful run when delete_trigger_message
+// is on, so parameters don't stay in the channel. Slack only lets bot tokens
+// delete other users' messages in some workspaces, so failures are just logged.
+func deleteTriggerMessage(messenger Messenger, config *Config, msg incomingMessage) {
+	if !config.DeleteTriggerMessage || msg.Timestamp == "" {
+		return
+	}
+	err := messenger.DeleteMessage(msg.ChannelID, msg.Timestamp)
+	var slackErr slack.SlackErrorResponse
+	if errors.As(err, &slackErr) && (slackErr.Err == "cant_delete_message" || slackErr.Err == "missing_scope") {
+		log.Printf("Can't delete the trigger message (%v): the bot lacks permission to delete user messages", err)
+	} else if err != nil {
+		log.Printf("Error deleting trigger message: %v", err)
+	}
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+// A message posted through fakeMessenger
+type fakeMessage struct {
+	ChannelID string
+	UserID    string // Set for ephemeral replies
+	Text      string
+}
+
+// Messenger that records everything the bot sends instead of calling a chat API,
+// so handlers can be tested without a Slack client or network access
+type fakeMessenger struct {
+	mu        sync.Mutex
+	messages  []fakeMessage
+	reactions []string // Reactions currently on messages
+	timeline  []string // Every call in order: "post <text>", "+emoji", "-emoji", "delete <ts>"
+}
+
+func newFakeMessenger() *fakeMessenger {
+	return &fakeMessenger{}
+}
+
+func (m *fakeMessenger) PostMessage(channelID, text string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, fakeMessage{ChannelID: channelID, Text: text})
+	m.timeline = append(m.timeline, "post "+text)
+	return nil
+}
+
+func (m *fakeMessenger) PostEphemeral(channelID, userID, text string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, fakeMessage{ChannelID: channelID, UserID: userID, Text: text})
+	m.timeline = append(m.timeline, "post "+text)
+	return nil
+}
+
+func (m *fakeMessenger) AddReaction(channelID, timestamp, emoji string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reactions = append(m.reactions, emoji)
+	m.timeline = append(m.timeline, "+"+emoji)
+	return nil
+}
+
+func (m *fakeMessenger) RemoveReaction(channelID, timestamp, emoji string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, reaction := range m.reactions {
+		if reaction == emoji {
+			m.reactions = append(m.reactions[:i], m.reactions[i+1:]...)
+			break
+		}
+	}
+	m.timeline = append(m.timeline, "-"+emoji)
+	return nil
+}
+
+func (m *fakeMessenger) DeleteMessage(channelID, timestamp string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.timeline = append(m.timeline, "delete "+timestamp)
+	return nil
+}
+
+// Reactions currently on messages
+func (m *fakeMessenger) currentReactions() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.reactions...)
+}
+
+// Every call made so far, in order
+func (m *fakeMessenger) calls() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.timeline...)
+}
+
+// Every message posted so far, public and ephemeral
+func (m *fakeMessenger) sent() []fakeMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]fakeMessage(nil), m.messages...)
+}
+
+// Text of every message posted so far except the interim "Running ..." notices
+func (m *fakeMessenger) results() []string {
+	var results []string
+	for _, text := range m.texts() {
+		if !strings.HasPrefix(text, "Running '") {
+			results = append(results, text)
+		}
+	}
+	return results
+}
+
+// Text of every message posted so far
+func (m *fakeMessenger) texts() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var texts []string
+	for _, msg := range m.messages {
+		texts = append(texts, msg.Text)
+	}
+	return texts
+}
+
+// Every backend, and both test doubles, satisfy Messenger
+var (
+	_ Messenger = (*slackMessenger)(nil)
+	_ Messenger = (*teamsMessenger)(nil)
+	_ Messenger = (*discordMessenger)(nil)
+	_ Messenger = (*resultMessenger)(nil)
+	_ Messenger = (*fakeMessenger)(nil)
+)
+
+// With a fake messenger the handlers need no Slack client: any call to the
+// Slack API fails the test
+func TestHandleMessageWithoutSlackClient(t *testing.T) {
+	target, _ := newStubTarget(t)
+	previous := http.DefaultTransport
+	http.DefaultTransport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Host == "slack.com" {
+			t.Errorf("Slack API called: %s", r.URL)
+		}
+		return previous.RoundTrip(r)
+	})
+	defer func() { http.DefaultTransport = previous }()
+
+	config := &Config{}
+	store := newConfigTaskStore(map[string]Task{"restart": {Command: "restart", URL: target.URL + "/ok", Method: "POST"}})
+	state := newBotState(config)
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "restart", want: "Task 'restart' executed successfully."},
+		{text: "running", want: "Nothing is running right now."},
+		{text: "nope", want: "I don't know your message. Please try again."},
+	}
+	for _, test := range tests {
+		messenger := newFakeMessenger()
+		handleMessageEvent(context.Background(), messenger, messageEvent("U1", test.text), config, store, state)
+		if got := messenger.results(); len(got) != 1 || got[0] != test.want {
+			t.Errorf("%q replied %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+// The acknowledgement is removed at most once, however often the returned func is called
+func TestAcknowledgeMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		ackReaction string
+		timestamp   string
+		want        []string
+	}{
+		{name: "default emoji", timestamp: "1.2", want: []string{"+eyes", "-eyes"}},
+		{name: "custom emoji", ackReaction: "hourglass", timestamp: "1.2", want: []string{"+hourglass", "-hourglass"}},
+		{name: "disabled", ackReaction: "none", timestamp: "1.2"},
+		{name: "no timestamp"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messenger := newFakeMessenger()
+			done := acknowledgeMessage(messenger, &Config{AckReaction: test.ackReaction}, incomingMessage{ChannelID: "C1", Timestamp: test.timestamp})
+			done()
+			done()
+			if got := messenger.calls(); strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Errorf("calls = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// Unknown commands and usage errors only reach the invoker; results stay public
+func TestHandleMessageEphemeralReplies(t *testing.T) {
+	target, _ := newStubTarget(t)
+	tests := []struct {
+		name          string
+		text          string
+		wantEphemeral bool
+	}{
+		{name: "unknown command", text: "retsart", wantEphemeral: true},
+		{name: "deploy usage error", text: "deploy api", wantEphemeral: true},
+		{name: "task result", text: "restart", wantEphemeral: false},
+		{name: "failed task result", text: "broken", wantEphemeral: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messenger := newFakeMessenger()
+			config := &Config{}
+			store := newConfigTaskStore(map[string]Task{
+				"restart": {Command: "restart", URL: target.URL + "/ok", Method: "POST"},
+				"broken":  {Command: "broken", URL: target.URL + "/fail", Method: "POST"},
+			})
+			handleMessageEvent(context.Background(), messenger, messageEvent("U1", test.text), config, store, newBotState(config))
+
+			var sent []fakeMessage
+			for _, msg := range messenger.sent() {
+				if !strings.HasPrefix(msg.Text, "Running '") {
+					sent = append(sent, msg)
+				}
+			}
+			if len(sent) != 1 {
+				t.Fatalf("sent %+v, want one reply", sent)
+			}
+			if ephemeral := sent[0].UserID != ""; ephemeral != test.wantEphemeral {
+				t.Errorf("reply %+v ephemeral = %v, want %v", sent[0], ephemeral, test.wantEphemeral)
+			}
+			if test.wantEphemeral && sent[0].UserID != "U1" {
+				t.Errorf("ephemeral reply sent to %q, want the invoker U1", sent[0].UserID)
+			}
+		})
+	}
+}
+
+// The Slack backend uses chat.postEphemeral targeted at the user
+func TestSlackMessengerPostEphemeral(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		mu.Lock()
+		calls[r.URL.Path] = r.FormValue("user")
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true, "message_ts": "1.000"}`))
+	}))
+	defer server.Close()
+
+	messenger := &slackMessenger{api: slack.New("xoxb-test", slack.OptionAPIURL(server.URL+"/"))}
+	if err := messenger.PostEphemeral("C1", "U1", "I don't know your message. Please try again."); err != nil {
+		t.Fatal(err)
+	}
+	if user, ok := calls["/chat.postEphemeral"]; !ok || user != "U1" {
+		t.Errorf("Slack API calls = %v, want chat.postEphemeral for U1", calls)
+	}
+	if _, ok := calls["/chat.postMessage"]; ok {
+		t.Error("ephemeral reply also posted publicly")
+	}
+}
+
+// A bad token is reported at startup with a hint at the configuration
+func TestCheckAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		wantErr  string
+	}{
+		{name: "valid token", response: `{"ok":true,"team":"Acme","user":"gobot","user_id":"UBOT"}`},
+		{name: "invalid token", response: `{"ok":false,"error":"invalid_auth"}`, wantErr: "Slack rejected the bot token (invalid_auth): check slack_token"},
+		{name: "revoked token", response: `{"ok":false,"error":"token_revoked"}`, wantErr: "Slack rejected the bot token (token_revoked)"},
+		{name: "other error", response: `{"ok":false,"error":"ratelimited"}`, wantErr: "checking Slack token: ratelimited"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(test.response))
+			}))
+			defer api.Close()
+
+			messenger := &slackMessenger{api: slack.New("xoxb-test", slack.OptionAPIURL(api.URL+"/"))}
+			err := messenger.checkAuth(context.Background())
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkAuth() = %v, want nil", err)
+				}
+				if messenger.botUser != "UBOT" {
+					t.Errorf("botUser = %q, want UBOT", messenger.botUser)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("checkAuth() = %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+// Auth errors on replies are logged as a warning, other errors are not
+func TestSlackMessengerWarnsOnAuthError(t *testing.T) {
+	for _, code := range []string{"invalid_auth", "channel_not_found"} {
+		logs := captureLog(t)
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"ok":false,"error":"` + code + `"}`))
+		}))
+		messenger := &slackMessenger{api: slack.New("xoxb-test", slack.OptionAPIURL(api.URL+"/"))}
+		err := messenger.PostMessage("C1", "hello")
+		api.Close()
+
+		if err == nil {
+			t.Errorf("%s: PostMessage returned no error", code)
+		}
+		if warned := strings.Contains(logs.String(), "WARNING: Slack rejected the bot token"); warned != (code == "invalid_auth") {
+			t.Errorf("%s: warning logged = %v, log:\n%s", code, warned, logs.String())
+		}
+	}
+}
+
+// With delete_trigger_message, only successful commands remove the user's message
+func TestDeleteTriggerMessage(t *testing.T) {
+	target, _ := newStubTarget(t)
+	store := newConfigTaskStore(map[string]Task{
+		"restart": {Command: "restart", URL: target.URL + "/ok", Method: "POST"},
+		"purge":   {Command: "purge", URL: target.URL + "/fail", Method: "POST"},
+	})
+	tests := []struct {
+		name       string
+		text       string
+		enabled    bool
+		wantDelete bool
+	}{
+		{name: "successful task", text: "restart", enabled: true, wantDelete: true},
+		{name: "failing task", text: "purge", enabled: true},
+		{name: "unknown command", text: "launch rockets", enabled: true},
+		{name: "disabled", text: "restart"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messenger := newFakeMessenger()
+			config := &Config{DeleteTriggerMessage: test.enabled}
+			handleMessageEvent(context.Background(), messenger, messageEvent("U1", test.text), config, store, newBotState(config))
+
+			calls := messenger.calls()
+			deleted := calls[len(calls)-1] == "delete 1700000000.000100"
+			if deleted != test.wantDelete {
+				t.Errorf("calls = %q, want the trigger message deleted last: %v", calls, test.wantDelete)
+			}
+		})
+	}
+}
+
+// Permission errors from Slack are logged with a hint rather than failing the command
+func TestDeleteTriggerMessageWithoutPermission(t *testing.T) {
+	logs := captureLog(t)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":false,"error":"cant_delete_message"}`))
+	}))
+	defer api.Close()
+	messenger := &slackMessenger{api: slack.New("xoxb-test", slack.OptionAPIURL(api.URL+"/"))}
+
+	deleteTriggerMessage(messenger, &Config{DeleteTriggerMessage: true}, incomingMessage{ChannelID: "C1", Timestamp: "1.2"})
+	if !strings.Contains(logs.String(), "the bot lacks permission to delete user messages") {
+		t.Errorf("log = %q, want the permission hint", logs.String())
+	}
+}
+
+// Replies and result cards disable link previews unless unfurl_links is on
+func TestSlackUnfurlOptions(t *testing.T) {
+	received := make(chan url.Values, 1)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		received <- r.PostForm
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true,"channel":"C1","ts":"1.1"}`))
+	}))
+	defer api.Close()
+
+	post := func(m *slackMessenger) error {
+		return m.PostMessage("C1", "Build https://jenkins.example.com/job/api/42/")
+	}
+	postCard := func(m *slackMessenger) error {
+		return m.PostResultCard("C1", resultCard{Command: "deploy", Success: true, Text: "Build https://jenkins.example.com/job/api/42/"})
+	}
+	tests := []struct {
+		name      string
+		unfurl    bool
+		send      func(m *slackMessenger) error
+		wantLinks string
+		wantMedia string
+	}{
+		{name: "disabled by default", send: post, wantLinks: "false", wantMedia: "false"},
+		{name: "unfurl_links on", unfurl: true, send: post},
+		{name: "result card", send: postCard, wantLinks: "false", wantMedia: "false"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messenger := &slackMessenger{api: slack.New("xoxb-test", slack.OptionAPIURL(api.URL+"/")), unfurl: test.unfurl}
+			if err := test.send(messenger); err != nil {
+				t.Fatal(err)
+			}
+			form := <-received
+			if got := form.Get("unfurl_links"); got != test.wantLinks {
+				t.Errorf("unfurl_links = %q, want %q", got, test.wantLinks)
+			}
+			if got := form.Get("unfurl_media"); got != test.wantMedia {
+				t.Errorf("unfurl_media = %q, want %q", got, test.wantMedia)
+			}
+		})
+	}
+}
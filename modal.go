@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	openCommandsActionID = "open_commands_modal"
+	runCommandCallbackID = "run_command"
+	commandBlockID       = "command_block"
+	commandActionID      = "command"
+
+	// Slack caps static selects and suggestion lists at 100 options
+	maxSelectOptions = 100
+)
+
+// commandBrowser is implemented by backends that can open the command modal
+type commandBrowser interface {
+	PostCommandBrowser(config *Config, channelID, userID string) error
+}
+
+// Post a button that opens the command modal. Modals need a trigger ID, which
+// Slack only sends with interactions, so the button is the entry point.
+func (m *slackMessenger) PostCommandBrowser(config *Config, channelID, userID string) error {
+	button := slack.NewButtonBlockElement(openCommandsActionID, "", slack.NewTextBlockObject(slack.PlainTextType, localize(config, userID, "browse_commands"), false, false))
+	prompt := localize(config, userID, "pick_command")
+	_, err := m.api.PostEphemeral(channelID, userID,
+		slack.MsgOptionText(prompt, false),
+		slack.MsgOptionBlocks(
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.PlainTextType, prompt, false, false), nil, nil),
+			slack.NewActionBlock("commands_actions", button),
+		))
+	return warnOnAuthError(err)
+}
+
+// Build the modal with a searchable select of commands. Catalogs too large for
+// a static select load their options from the interactions endpoint as the user types.
+func commandsModal(config *Config, userID string, commands []string, channelID string) slack.ModalViewRequest {
+	placeholder := slack.NewTextBlockObject(slack.PlainTextType, localize(config, userID, "search_commands"), false, false)
+	var selectElement *slack.SelectBlockElement
+	if len(commands) <= maxSelectOptions {
+		selectElement = slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, placeholder, commandActionID, commandOptions(commands)...)
+	} else {
+		selectElement = slack.NewOptionsSelectBlockElement(slack.OptTypeExternal, placeholder, commandActionID)
+		minQueryLength := 0
+		selectElement.MinQueryLength = &minQueryLength
+	}
+
+	return slack.ModalViewRequest{
+		Type:            slack.VTModal,
+		CallbackID:      runCommandCallbackID,
+		PrivateMetadata: channelID,
+		Title:           slack.NewTextBlockObject(slack.PlainTextType, localize(config, userID, "modal_title"), false, false),
+		Submit:          slack.NewTextBlockObject(slack.PlainTextType, localize(config, userID, "modal_submit"), false, false),
+		Close:           slack.NewTextBlockObject(slack.PlainTextType, localize(config, userID, "modal_close"), false, false),
+		Blocks: slack.Blocks{BlockSet: []slack.Block{
+			slack.NewInputBlock(commandBlockID, slack.NewTextBlockObject(slack.PlainTextType, localize(config, userID, "modal_command"), false, false), nil, selectElement),
+		}},
+	}
+}
+
+func commandOptions(commands []string) []*slack.OptionBlockObject {
+	options := make([]*slack.OptionBlockObject, 0, len(commands))
+	for _, command := range commands {
+		options = append(options, slack.NewOptionBlockObject(command, slack.NewTextBlockObject(slack.PlainTextType, command, false, false), nil))
+	}
+	return options
+}
+
+// Commands containing the query, capped at what one select can show
+func matchingCommands(commands []string, query string) []string {
+	query = strings.ToLower(query)
+	var matches []string
+	for _, command := range commands {
+		if strings.Contains(strings.ToLower(command), query) {
+			matches = append(matches, command)
+			if len(matches) == maxSelectOptions {
+				break
+			}
+		}
+	}
+	return matches
+}
+
+func sortedCommands(store TaskStore) []string {
+	tasks, err := store.ListTasks()
+	if err != nil {
+		log.Printf("Error listing tasks: %v", err)
+	}
+	commands := make([]string, 0, len(tasks))
+	for command := range tasks {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+	return commands
+}
+
+// Handle Slack interactions: the Browse commands button, option suggestions for
+// large catalogs and the modal's Run submission, which dispatches the command
+func interactionsHandler(ctx context.Context, messengerFor func(teamID string) *slackMessenger, store TaskStore, state *botState) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			log.Printf("Error reading request body: %v", err)
+			http.Error(w, "Can't read body", http.StatusBadRequest)
+			return
+		}
+		if err := verifySlackRequest(state.config.Load(), r.Header, body); err != nil {
+			log.Printf("Rejected interaction from %s: %v", r.RemoteAddr, err)
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			http.Error(w, "Can't parse form", http.StatusBadRequest)
+			return
+		}
+		var callback slack.InteractionCallback
+		if err := json.Unmarshal([]byte(form.Get("payload")), &callback); err != nil {
+			log.Printf("Error parsing interaction payload: %v", err)
+			http.Error(w, "Can't parse payload", http.StatusBadRequest)
+			return
+		}
+		messenger := messengerFor(callback.Team.ID)
+
+		switch callback.Type {
+		case slack.InteractionTypeBlockActions:
+			for _, action := range callback.ActionCallback.BlockActions {
+				if action.ActionID != openCommandsActionID {
+					continue
+				}
+				modal := commandsModal(state.config.Load(), callback.User.ID, sortedCommands(store), callback.Channel.ID)
+				if _, err := messenger.api.OpenViewContext(r.Context(), callback.TriggerID, modal); err != nil {
+					log.Printf("Error opening commands modal: %v", warnOnAuthError(err))
+				}
+			}
+			w.WriteHeader(http.StatusOK)
+
+		case slack.InteractionTypeBlockSuggestion:
+			writeJSON(w, http.StatusOK, slack.OptionsResponse{Options: commandOptions(matchingCommands(sortedCommands(store), callback.Value))})
+
+		case slack.InteractionTypeViewSubmission:
+			w.WriteHeader(http.StatusOK)
+			if callback.View.CallbackID != runCommandCallbackID || callback.View.State == nil {
+				return
+			}
+			command := callback.View.State.Values[commandBlockID][commandActionID].SelectedOption.Value
+			if command == "" || callback.View.PrivateMetadata == "" {
+				return
+			}
+			log.Printf("Command '%s' picked from the commands modal by %s", command, callback.User.ID)
+			msg := incomingMessage{Text: command, ChannelID: callback.View.PrivateMetadata, UserID: callback.User.ID, TeamID: callback.Team.ID}
+			go func() {
+				defer state.recoverPanic("a modal submission")
+				handleCommand(ctx, messenger, msg, state.config.Load(), store, state)
+			}()
+
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+}
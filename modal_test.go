@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+func TestMatchingCommands(t *testing.T) {
+	commands := []string{"deploy-api", "deploy-web", "restart", "status"}
+	many := make([]string, 2*maxSelectOptions)
+	for i := range many {
+		many[i] = fmt.Sprintf("job-%03d", i)
+	}
+
+	tests := []struct {
+		name     string
+		commands []string
+		query    string
+		want     []string
+		wantLen  int
+	}{
+		{name: "empty query matches all", commands: commands, query: "", want: commands},
+		{name: "substring", commands: commands, query: "start", want: []string{"restart"}},
+		{name: "query case ignored", commands: commands, query: "DEPLOY", want: []string{"deploy-api", "deploy-web"}},
+		{name: "command case ignored", commands: []string{"Deploy-API", "restart"}, query: "api", want: []string{"Deploy-API"}},
+		{name: "no match", commands: commands, query: "rollback"},
+		{name: "capped", commands: many, query: "job", wantLen: maxSelectOptions},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := matchingCommands(test.commands, test.query)
+			if test.wantLen > 0 {
+				if len(got) != test.wantLen {
+					t.Errorf("got %d matches, want %d", len(got), test.wantLen)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("matchingCommands(%q) = %v, want %v", test.query, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCommandsModalSelectType(t *testing.T) {
+	tests := []struct {
+		count int
+		want  string
+	}{
+		{count: 3, want: slack.OptTypeStatic},
+		{count: maxSelectOptions, want: slack.OptTypeStatic},
+		{count: maxSelectOptions + 1, want: slack.OptTypeExternal},
+	}
+
+	for _, test := range tests {
+		commands := make([]string, test.count)
+		for i := range commands {
+			commands[i] = fmt.Sprintf("cmd-%d", i)
+		}
+		modal := commandsModal(&Config{}, "U1", commands, "C1")
+		input := modal.Blocks.BlockSet[0].(*slack.InputBlock)
+		element := input.Element.(*slack.SelectBlockElement)
+		if element.Type != test.want {
+			t.Errorf("%d commands: select type = %q, want %q", test.count, element.Type, test.want)
+		}
+		if test.want == slack.OptTypeStatic && len(element.Options) != test.count {
+			t.Errorf("%d commands: %d options, want all of them", test.count, len(element.Options))
+		}
+		if modal.PrivateMetadata != "C1" {
+			t.Errorf("private metadata = %q, want the channel", modal.PrivateMetadata)
+		}
+	}
+}
+
+// Slack API stub recording each call's method, form fields and raw body
+func newSlackAPIRecorder(t *testing.T) (*httptest.Server, func() []url.Values) {
+	t.Helper()
+	var mu sync.Mutex
+	var calls []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		form, _ := url.ParseQuery(string(body))
+		form.Set("method", strings.TrimPrefix(r.URL.Path, "/"))
+		form.Set("body", string(body))
+		mu.Lock()
+		calls = append(calls, form)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"ok": true, "channel": "C1", "ts": "1700000000.000200"}`)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []url.Values {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]url.Values(nil), calls...)
+	}
+}
+
+func TestInteractionsHandler(t *testing.T) {
+	target, _ := newStubTarget(t)
+	api, calls := newSlackAPIRecorder(t)
+	messenger := &slackMessenger{api: slack.New("xoxb-test", slack.OptionAPIURL(api.URL+"/")), users: newUserCache(), channels: newChannelCache()}
+	config := &Config{AckReaction: "none", SlackSigningSecret: testSigningSecret}
+	store := newConfigTaskStore(map[string]Task{
+		"restart": {Command: "restart", URL: target.URL + "/ok", Method: "POST"},
+		"reindex": {Command: "reindex", URL: target.URL + "/ok", Method: "POST"},
+	})
+	handler := interactionsHandler(context.Background(), func(string) *slackMessenger { return messenger }, store, newBotState(config))
+
+	send := func(payload string, sign bool) *httptest.ResponseRecorder {
+		body := url.Values{"payload": {payload}}.Encode()
+		req := httptest.NewRequest("POST", "/slack/interactions", strings.NewReader(body))
+		if sign {
+			signSlackRequest(req, body, testSigningSecret, time.Now())
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := send(`{"type": "block_actions"}`, false); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unsigned interaction: status %d, want 401", rec.Code)
+	}
+
+	// The button opens the modal for the trigger ID
+	rec := send(`{"type": "block_actions", "trigger_id": "T123", "channel": {"id": "C1"}, "actions": [{"block_id": "commands_actions", "action_id": "open_commands_modal"}]}`, true)
+	if got := calls(); rec.Code != http.StatusOK || len(got) != 1 || got[0].Get("method") != "views.open" || !strings.Contains(got[0].Get("body"), `"trigger_id":"T123"`) {
+		t.Fatalf("button: status %d, Slack calls %v, want views.open", rec.Code, got)
+	}
+
+	// Typing in a large catalog's select asks for matching options
+	rec = send(`{"type": "block_suggestion", "value": "re"}`, true)
+	var options slack.OptionsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &options); err != nil {
+		t.Fatal(err)
+	}
+	if len(options.Options) != 2 || options.Options[0].Value != "reindex" || options.Options[1].Value != "restart" {
+		t.Errorf("suggestions = %+v, want reindex and restart", options.Options)
+	}
+
+	// Submitting the modal runs the picked command in the original channel
+	send(`{"type": "view_submission", "user": {"id": "U1"}, "view": {"callback_id": "run_command", "private_metadata": "C1",
+		"state": {"values": {"command_block": {"command": {"type": "static_select", "selected_option": {"value": "restart"}}}}}}}`, true)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got := calls()
+		last := got[len(got)-1]
+		if last.Get("method") == "chat.postMessage" && last.Get("channel") == "C1" && last.Get("text") == "Task 'restart' executed successfully." {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Slack calls %v, want the task result posted to C1", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
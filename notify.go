@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+)
+
+// Post an operational notification to notify_channel, when one is configured.
+// Used for events that have no user to reply to, such as startup or background errors.
+func (s *botState) notify(format string, args ...interface{}) {
+	if s.notifyChannel == "" || s.notifier == nil {
+		return
+	}
+	if err := s.notifier.PostMessage(s.notifyChannel, fmt.Sprintf(format, args...)); err != nil {
+		log.Printf("Error sending notification: %v", err)
+	}
+}
+
+// Recover from a panic in a handler goroutine so one bad event can't take the bot down.
+// Must be called directly with defer.
+func (s *botState) recoverPanic(handler string) {
+	if r := recover(); r != nil {
+		log.Printf("Recovered panic in %s: %v\n%s", handler, r, debug.Stack())
+		s.notify("Recovered from a panic while handling %s: %v", handler, r)
+	}
+}
+
+// Post "bot alive" to notify_channel every interval until the context ends
+func (s *botState) runHeartbeat(ctx context.Context, interval time.Duration) {
+	started := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.notify("Bot alive (version %s, up %s).", version, time.Since(started).Round(time.Minute))
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotify(t *testing.T) {
+	tests := []struct {
+		name      string
+		channel   string
+		notifier  bool
+		wantPosts int
+	}{
+		{name: "configured", channel: "COPS", notifier: true, wantPosts: 1},
+		{name: "no channel", notifier: true},
+		{name: "no backend yet", channel: "COPS"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messenger := newFakeMessenger()
+			state := newBotState(&Config{NotifyChannel: test.channel})
+			if test.notifier {
+				state.notifier = messenger
+			}
+			state.notify("Bot started (version %s).", "1.2.3")
+
+			sent := messenger.sent()
+			if len(sent) != test.wantPosts {
+				t.Fatalf("posted %+v, want %d messages", sent, test.wantPosts)
+			}
+			if test.wantPosts > 0 && (sent[0].ChannelID != "COPS" || sent[0].Text != "Bot started (version 1.2.3).") {
+				t.Errorf("posted %+v", sent[0])
+			}
+		})
+	}
+}
+
+// A malformed event panics inside the handler; the panic is recovered and reported
+func TestHandleMessageEventRecoversPanic(t *testing.T) {
+	captureLog(t)
+	notifier := newFakeMessenger()
+	config := &Config{NotifyChannel: "COPS"}
+	state := newBotState(config)
+	state.notifier = notifier
+
+	handleMessageEvent(context.Background(), newFakeMessenger(), map[string]interface{}{"event": "not an object"}, config, newConfigTaskStore(nil), state)
+
+	sent := notifier.sent()
+	if len(sent) != 1 || sent[0].ChannelID != "COPS" || !strings.HasPrefix(sent[0].Text, "Recovered from a panic while handling a Slack event:") {
+		t.Errorf("posted %+v, want the panic notification", sent)
+	}
+}
+
+func TestRunHeartbeat(t *testing.T) {
+	messenger := newFakeMessenger()
+	state := newBotState(&Config{NotifyChannel: "COPS"})
+	state.notifier = messenger
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		state.runHeartbeat(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(messenger.sent()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	sent := messenger.sent()
+	if len(sent) == 0 || sent[0].ChannelID != "COPS" || !strings.HasPrefix(sent[0].Text, "Bot alive (version "+version+", up ") {
+		t.Fatalf("heartbeats = %+v", sent)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("heartbeat kept running after the context ended")
+	}
+}
+
+func TestPing(t *testing.T) {
+	for _, text := range []string{"ping", "PING"} {
+		config := &Config{}
+		messenger := newFakeMessenger()
+		handleMessageEvent(context.Background(), messenger, messageEvent("U1", text), config, newConfigTaskStore(nil), newBotState(config))
+
+		if sent := messenger.sent(); len(sent) != 1 || sent[0].Text != "pong" || sent[0].UserID != "" {
+			t.Errorf("%q replied %+v, want a pong", text, sent)
+		}
+	}
+}
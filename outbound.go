@@ -0,0 +1,41 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Bot version, overridden at build time with -ldflags "-X main.version=1.2.3"
+var version = "dev"
+
+// Set the User-Agent and an X-Request-ID on an outbound request. The request
+// ID is the trace ID when the request is traced, otherwise a fresh random ID.
+// Returns the request ID so it can be logged and shown to the user.
+func setOutboundHeaders(req *http.Request, config *Config) string {
+	userAgent := config.UserAgent
+	if userAgent == "" {
+		userAgent = "automation-bot/" + version
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	requestID := newRequestID()
+	if sc := trace.SpanContextFromContext(req.Context()); sc.HasTraceID() {
+		requestID = sc.TraceID().String()
+	}
+	req.Header.Set("X-Request-ID", requestID)
+	return requestID
+}
+
+// Random ID used to correlate a request across the bot and gateway logs
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%016x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
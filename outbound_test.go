@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var requestIDPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// Both execute functions send the User-Agent and a fresh X-Request-ID they report back
+func TestOutboundHeaders(t *testing.T) {
+	type sent struct{ userAgent, requestID string }
+	requests := make(chan sent, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- sent{r.Header.Get("User-Agent"), r.Header.Get("X-Request-ID")}
+	}))
+	defer target.Close()
+
+	tests := []struct {
+		name          string
+		config        Config
+		run           func(config *Config) taskResult
+		wantUserAgent string
+	}{
+		{name: "task with default agent", run: func(config *Config) taskResult {
+			return executeTask(context.Background(), config, Task{Command: "health", URL: target.URL, Method: "GET"})
+		}, wantUserAgent: "automation-bot/" + version},
+		{name: "POST task with configured agent", config: Config{UserAgent: "ops-bot/2.0"}, run: func(config *Config) taskResult {
+			return executeTask(context.Background(), config, Task{Command: "restart", URL: target.URL, Method: "POST", Body: "{}"})
+		}, wantUserAgent: "ops-bot/2.0"},
+		{name: "Jenkins job", config: Config{UserAgent: "ops-bot/2.0"}, run: func(config *Config) taskResult {
+			return executeJenkinsJob(context.Background(), config, target.URL, "ci", "token")
+		}, wantUserAgent: "ops-bot/2.0"},
+	}
+	seen := map[string]bool{}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := test.run(&test.config)
+			got := <-requests
+			if got.userAgent != test.wantUserAgent {
+				t.Errorf("User-Agent = %q, want %q", got.userAgent, test.wantUserAgent)
+			}
+			if !requestIDPattern.MatchString(got.requestID) || got.requestID != result.RequestID {
+				t.Errorf("X-Request-ID = %q, result.RequestID = %q, want the same 16 hex digits", got.requestID, result.RequestID)
+			}
+			if seen[got.requestID] {
+				t.Errorf("request ID %s reused", got.requestID)
+			}
+			seen[got.requestID] = true
+		})
+	}
+}
+
+// A failed task's reply and log carry the request ID sent to the target
+func TestFailureReplyIncludesRequestID(t *testing.T) {
+	requestIDs := make(chan string, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestIDs <- r.Header.Get("X-Request-ID")
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer target.Close()
+
+	logs := captureLog(t)
+	config := &Config{}
+	store := newConfigTaskStore(map[string]Task{"restart": {Command: "restart", URL: target.URL, Method: "POST"}})
+	messenger := newFakeMessenger()
+	handleMessageEvent(context.Background(), messenger, messageEvent("U1", "restart"), config, store, newBotState(config))
+
+	id := <-requestIDs
+	want := "Task 'restart' failed to execute. (request ID " + id + ")"
+	if replies := messenger.results(); len(replies) != 1 || replies[0] != want {
+		t.Errorf("replies = %q, want %q", replies, want)
+	}
+	if !strings.Contains(logs.String(), "(request ID "+id+")") {
+		t.Errorf("log does not mention request ID %s:\n%s", id, logs)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// Replies waiting for Slack to come back, beyond which new ones are dropped
+const outboxSize = 100
+
+// How long undelivered replies are retried unless slack_retry_minutes is set
+const defaultSlackRetryAge = 10 * time.Minute
+
+// First wait between redelivery attempts, and the shortest one
+const outboxBaseDelay = time.Second
+
+// A reply that failed with a transient error
+type outboxMessage struct {
+	channelID string
+	send      func() error
+	queued    time.Time
+}
+
+// slackOutbox retries replies that failed because Slack was unreachable,
+// in order and with backoff, until they are delivered or too old
+type slackOutbox struct {
+	queue  chan outboxMessage
+	maxAge time.Duration
+}
+
+// How long replies are retried, 0 when slack_retry_minutes disables the outbox
+func slackRetryAge(config *Config) time.Duration {
+	switch {
+	case config.SlackRetryMinutes < 0:
+		return 0
+	case config.SlackRetryMinutes == 0:
+		return defaultSlackRetryAge
+	}
+	return time.Duration(config.SlackRetryMinutes) * time.Minute
+}
+
+func newSlackOutbox(maxAge time.Duration) *slackOutbox {
+	return &slackOutbox{queue: make(chan outboxMessage, outboxSize), maxAge: maxAge}
+}
+
+// Queue a reply for redelivery. Returns false when the outbox is full.
+func (o *slackOutbox) Enqueue(channelID string, send func() error) bool {
+	select {
+	case o.queue <- outboxMessage{channelID: channelID, send: send, queued: time.Now()}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Deliver queued replies until the context ends
+func (o *slackOutbox) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-o.queue:
+			o.deliver(ctx, msg)
+		}
+	}
+}
+
+func (o *slackOutbox) deliver(ctx context.Context, msg outboxMessage) {
+	delay := outboxBaseDelay
+	for {
+		var rateLimited *slack.RateLimitedError
+		err := msg.send()
+		switch {
+		case err == nil:
+			log.Printf("Delivered queued reply to %s after %s", msg.channelID, time.Since(msg.queued).Round(time.Second))
+			return
+		case !isTransientSlackError(err):
+			log.Printf("Error sending message to Slack: %v", err)
+			return
+		case time.Since(msg.queued) > o.maxAge:
+			log.Printf("Dropping reply to %s, Slack still unreachable after %s: %v", msg.channelID, o.maxAge, err)
+			return
+		case errors.As(err, &rateLimited):
+			// Slack may send no Retry-After, so never wait less than the base delay
+			delay = rateLimited.RetryAfter
+			if delay < outboxBaseDelay {
+				delay = outboxBaseDelay
+			}
+		}
+		if sleepContext(ctx, delay) != nil {
+			return
+		}
+		if delay < time.Minute {
+			delay *= 2
+		}
+	}
+}
+
+// Network failures, Slack 5xx responses and rate limiting are worth retrying;
+// API errors such as channel_not_found are not
+func isTransientSlackError(err error) bool {
+	var netErr net.Error
+	var statusErr slack.StatusCodeError
+	var rateLimited *slack.RateLimitedError
+	switch {
+	case errors.As(err, &rateLimited):
+		return true
+	case errors.As(err, &statusErr):
+		return statusErr.Code >= 500
+	case errors.As(err, &netErr):
+		return true
+	}
+	return false
+}
+
+// Send a reply, queueing it for redelivery when Slack is unreachable. A
+// queued reply counts as sent: nil is returned, so callers don't log it as
+// failed or post it a second way, and the outbox logs its delivery or drop.
+func (m *slackMessenger) sendOrQueue(channelID string, send func() error) error {
+	err := send()
+	if err == nil || m.outbox == nil || !isTransientSlackError(err) {
+		return warnOnAuthError(err)
+	}
+	if !m.outbox.Enqueue(channelID, send) {
+		log.Printf("Slack outbox is full, dropping reply to %s", channelID)
+		return err
+	}
+	log.Printf("Slack unreachable (%v), reply to %s queued for retry", err, channelID)
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// A network error as returned when Slack can't be reached
+var errSlackUnreachable = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+func TestIsTransientSlackError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "network error", err: errSlackUnreachable, want: true},
+		{name: "wrapped network error", err: fmt.Errorf("posting: %w", errSlackUnreachable), want: true},
+		{name: "rate limited", err: &slack.RateLimitedError{RetryAfter: time.Second}, want: true},
+		{name: "server error", err: slack.StatusCodeError{Code: 503, Status: "Service Unavailable"}, want: true},
+		{name: "client error", err: slack.StatusCodeError{Code: 404, Status: "Not Found"}, want: false},
+		{name: "API error", err: slack.SlackErrorResponse{Err: "channel_not_found"}, want: false},
+		{name: "plain error", err: errors.New("boom"), want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isTransientSlackError(test.err); got != test.want {
+				t.Errorf("isTransientSlackError(%v) = %v, want %v", test.err, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSlackRetryAge(t *testing.T) {
+	tests := []struct {
+		minutes int
+		want    time.Duration
+	}{
+		{minutes: 0, want: defaultSlackRetryAge},
+		{minutes: -1, want: 0},
+		{minutes: 3, want: 3 * time.Minute},
+	}
+
+	for _, test := range tests {
+		if got := slackRetryAge(&Config{SlackRetryMinutes: test.minutes}); got != test.want {
+			t.Errorf("slackRetryAge(%d) = %s, want %s", test.minutes, got, test.want)
+		}
+	}
+}
+
+// Transient failures are retried, permanent or stale ones dropped
+func TestOutboxDeliver(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error // Returned by successive attempts, nil afterwards
+		queuedAgo time.Duration
+		want      int           // Attempts made
+		wantDelay time.Duration // Shortest wait before the second attempt
+	}{
+		{name: "delivered first time", want: 1},
+		{name: "retried after a network error", errs: []error{errSlackUnreachable}, want: 2},
+		{name: "rate limited without Retry-After", errs: []error{&slack.RateLimitedError{}}, want: 2, wantDelay: outboxBaseDelay},
+		{name: "permanent error not retried", errs: []error{slack.SlackErrorResponse{Err: "channel_not_found"}}, want: 1},
+		{name: "too old to retry", errs: []error{errSlackUnreachable}, queuedAgo: time.Hour, want: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attempts := 0
+			var times []time.Time
+			send := func() error {
+				attempts++
+				times = append(times, time.Now())
+				if attempts <= len(test.errs) {
+					return test.errs[attempts-1]
+				}
+				return nil
+			}
+			outbox := newSlackOutbox(time.Minute)
+			outbox.deliver(context.Background(), outboxMessage{channelID: "C1", send: send, queued: time.Now().Add(-test.queuedAgo)})
+			if attempts != test.want {
+				t.Errorf("attempts = %d, want %d", attempts, test.want)
+			}
+			if len(times) > 1 && times[1].Sub(times[0]) < test.wantDelay {
+				t.Errorf("retried after %v, want at least %v", times[1].Sub(times[0]), test.wantDelay)
+			}
+		})
+	}
+}
+
+func TestOutboxDeliverStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	attempts := 0
+	send := func() error {
+		attempts++
+		return errSlackUnreachable
+	}
+	newSlackOutbox(time.Minute).deliver(ctx, outboxMessage{channelID: "C1", send: send, queued: time.Now()})
+	if attempts != 1 {
+		t.Errorf("attempts = %d after the context ended, want 1", attempts)
+	}
+}
+
+// Only transient failures are queued, and only while there is room
+func TestSendOrQueue(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		outbox     bool
+		fill       bool // Outbox already full
+		wantErr    bool
+		wantQueued int
+	}{
+		{name: "sent", outbox: true},
+		{name: "transient error queued", err: errSlackUnreachable, outbox: true, wantQueued: 1},
+		{name: "permanent error returned", err: slack.SlackErrorResponse{Err: "channel_not_found"}, outbox: true, wantErr: true},
+		{name: "no outbox", err: errSlackUnreachable, wantErr: true},
+		{name: "full outbox", err: errSlackUnreachable, outbox: true, fill: true, wantErr: true, wantQueued: outboxSize},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messenger := &slackMessenger{}
+			if test.outbox {
+				messenger.outbox = newSlackOutbox(time.Minute)
+			}
+			if test.fill {
+				for i := 0; i < outboxSize; i++ {
+					messenger.outbox.Enqueue("C1", func() error { return nil })
+				}
+			}
+
+			err := messenger.sendOrQueue("C1", func() error { return test.err })
+			if (err != nil) != test.wantErr {
+				t.Errorf("sendOrQueue() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if messenger.outbox != nil {
+				if got := len(messenger.outbox.queue); got != test.wantQueued {
+					t.Errorf("queued %d replies, want %d", got, test.wantQueued)
+				}
+			}
+		})
+	}
+}
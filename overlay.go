@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Path of the overlay for an environment, e.g. config.json -> config.prod.json
+func overlayPath(basePath, env string) string {
+	ext := filepath.Ext(basePath)
+	return strings.TrimSuffix(basePath, ext) + "." + env + ext
+}
+
+// Merge the overlay selected by BOT_ENV on top of the base config JSON.
+// A missing overlay file leaves the base config unchanged.
+func applyOverlay(base []byte, basePath, env string) ([]byte, error) {
+	if env == "" {
+		return base, nil
+	}
+
+	path := overlayPath(basePath, env)
+	overlay, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return base, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var baseValues, overlayValues map[string]interface{}
+	if err := json.Unmarshal(base, &baseValues); err != nil {
+		return nil, fmt.Errorf("parsing base config: %w", err)
+	}
+	if err := json.Unmarshal(overlay, &overlayValues); err != nil {
+		return nil, fmt.Errorf("parsing overlay %s: %w", path, err)
+	}
+
+	return json.Marshal(mergeJSON(baseValues, overlayValues))
+}
+
+// Recursively merge src into dst: nested objects (like the tasks map) are merged
+// key by key, any other value set in src replaces the one in dst.
+func mergeJSON(dst, src map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		dst = make(map[string]interface{})
+	}
+	for key, srcValue := range src {
+		srcMap, srcIsMap := srcValue.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			dst[key] = mergeJSON(dstMap, srcMap)
+		} else {
+			dst[key] = srcValue
+		}
+	}
+	return dst
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfigOverlay(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "config.json")
+	writeFile(t, base, `{
+		"slack_token": "xoxb-base",
+		"admin_token": "base-admin",
+		"jenkins": {"url_format": "https://jenkins.example.com/%s/%s", "user": "ci", "token": "base"},
+		"tasks": {
+			"restart": {"command": "restart", "url": "https://dev.example.com/restart", "method": "POST"},
+			"health": {"command": "health", "url": "https://dev.example.com/health", "method": "GET"}
+		}
+	}`)
+	writeFile(t, filepath.Join(dir, "config.prod.json"), `{
+		"slack_token": "xoxb-prod",
+		"jenkins": {"token": "prod"},
+		"tasks": {
+			"restart": {"url": "https://prod.example.com/restart"},
+			"purge": {"command": "purge", "url": "https://prod.example.com/purge", "method": "POST"}
+		}
+	}`)
+
+	tests := []struct {
+		name        string
+		env         string
+		wantToken   string
+		wantJenkins string
+		wantRestart string
+		wantTasks   int
+	}{
+		{name: "no BOT_ENV", wantToken: "xoxb-base", wantJenkins: "base", wantRestart: "https://dev.example.com/restart", wantTasks: 2},
+		{name: "prod overlay", env: "prod", wantToken: "xoxb-prod", wantJenkins: "prod", wantRestart: "https://prod.example.com/restart", wantTasks: 3},
+		{name: "missing overlay file", env: "staging", wantToken: "xoxb-base", wantJenkins: "base", wantRestart: "https://dev.example.com/restart", wantTasks: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("BOT_ENV", test.env)
+			config, err := loadConfig(base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.SlackToken != test.wantToken || config.Jenkins.Token != test.wantJenkins {
+				t.Errorf("slack_token = %q, jenkins.token = %q, want %q, %q", config.SlackToken, config.Jenkins.Token, test.wantToken, test.wantJenkins)
+			}
+			if len(config.Tasks) != test.wantTasks || config.Tasks["restart"].URL != test.wantRestart {
+				t.Errorf("tasks = %+v, want %d tasks with restart at %s", config.Tasks, test.wantTasks, test.wantRestart)
+			}
+
+			// Keys the overlay leaves unset keep their base values
+			if config.AdminToken != "base-admin" || config.Jenkins.URLFormat != "https://jenkins.example.com/%s/%s" {
+				t.Errorf("base values lost: admin_token = %q, jenkins.url_format = %q", config.AdminToken, config.Jenkins.URLFormat)
+			}
+			if restart := config.Tasks["restart"]; restart.Method != "POST" || restart.Command != "restart" {
+				t.Errorf("restart = %+v, want the base method and command kept", restart)
+			}
+		})
+	}
+}
+
+func TestLoadConfigOverlayInvalidJSON(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "config.json")
+	writeFile(t, base, `{"slack_token": "xoxb-base"}`)
+	writeFile(t, filepath.Join(dir, "config.prod.json"), `{"slack_token":`)
+
+	t.Setenv("BOT_ENV", "prod")
+	if _, err := loadConfig(base); err == nil {
+		t.Error("a broken overlay loaded without error")
+	}
+}
+
+func TestOverlayPath(t *testing.T) {
+	tests := map[string]string{
+		"config.json":          "config.prod.json",
+		"/etc/bot/config.json": "/etc/bot/config.prod.json",
+		"settings":             "settings.prod",
+	}
+	for base, want := range tests {
+		if got := overlayPath(base, "prod"); got != want {
+			t.Errorf("overlayPath(%q) = %q, want %q", base, got, want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// PagerDuty Events API v2 endpoint, a variable so it can point at a stub
+var pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDuty limits the summary to 1024 characters; as bytes that is never more
+const pagerDutySummaryLimit = 1024
+
+// Trigger event sent to the PagerDuty Events API v2
+type pagerDutyEvent struct {
+	RoutingKey  string           `json:"routing_key"`
+	EventAction string           `json:"event_action"`
+	DedupKey    string           `json:"dedup_key"` // One open incident per command
+	Payload     pagerDutyPayload `json:"payload"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Timestamp     time.Time         `json:"timestamp"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+// Describe why a task's request failed for the incident. The URL is left out
+// of transport errors, as it may carry credentials.
+func taskFailureReason(result taskResult) string {
+	var urlErr *url.Error
+	switch {
+	case errors.As(result.Err, &urlErr):
+		return urlErr.Err.Error()
+	case result.Err != nil:
+		return result.Err.Error()
+	case result.StatusCode >= 200 && result.StatusCode < 300:
+		return fmt.Sprintf("the response (status %d) failed the success check", result.StatusCode)
+	case result.StatusCode != 0:
+		return fmt.Sprintf("target answered with status %d", result.StatusCode)
+	}
+	return "the request could not be sent"
+}
+
+// Open (or update) a PagerDuty incident for a failed command.
+// Errors are only logged so they never affect the Slack reply.
+func triggerPagerDuty(routingKey, command, user, reason string) {
+	if routingKey == "" {
+		return
+	}
+
+	summary := truncateUTF8(fmt.Sprintf("automation-bot: task '%s' failed: %s", command, reason), pagerDutySummaryLimit)
+	payload, err := json.Marshal(pagerDutyEvent{
+		RoutingKey:  routingKey,
+		EventAction: "trigger",
+		DedupKey:    "automation-bot/" + command,
+		Payload: pagerDutyPayload{
+			Summary:   summary,
+			Source:    "automation-bot",
+			Severity:  "error",
+			Timestamp: time.Now().UTC(),
+			CustomDetails: map[string]string{
+				"command": command,
+				"user":    user,
+				"reason":  reason,
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error encoding PagerDuty event: %v", err)
+		return
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second, Transport: tracedTransport}
+	resp, err := client.Post(pagerDutyEventsURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		log.Printf("Error sending PagerDuty event for '%s': %v", command, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Printf("PagerDuty returned status %s for '%s'", resp.Status, command)
+		return
+	}
+	log.Printf("PagerDuty incident triggered for '%s'", command)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Check a command's allowlist. An empty allowlist lets everyone run the
+// command, admin users may always run it.
+func isUserAllowed(config *Config, allowedUsers []string, userID string) bool {
+	if len(allowedUsers) == 0 || isAdminUser(config, userID) {
+		return true
+	}
+	for _, allowed := range allowedUsers {
+		if allowed == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// Tell the user they may not run the command
+func postNotAllowedMessage(messenger Messenger, config *Config, channelID, userID, command string) {
+	log.Printf("User %s is not allowed to run '%s'", userID, command)
+	err := messenger.PostEphemeral(channelID, userID, notAllowedMessage(config, userID, command))
+	if err != nil {
+		log.Printf("Error sending message to Slack: %v", err)
+	}
+}
+
+func notAllowedMessage(config *Config, userID, command string) string {
+	return localize(config, userID, "not_allowed", "command", command)
+}
+
+// Build the whoami reply: the caller's ID and the commands they may run
+func formatWhoami(config *Config, tasks map[string]Task, userID string) string {
+	var allowed []string
+	for command, task := range tasks {
+		if isUserAllowed(config, task.AllowedUsers, userID) {
+			allowed = append(allowed, command)
+		}
+	}
+	if config.Jenkins.URLFormat != "" && isUserAllowed(config, config.Jenkins.AllowedUsers, userID) {
+		allowed = append(allowed, "deploy <service-name> <env>")
+	}
+	sort.Strings(allowed)
+
+	var b strings.Builder
+	b.WriteString(localize(config, userID, "whoami", "user", userID) + "\n")
+	if isAdminUser(config, userID) {
+		b.WriteString(localize(config, userID, "whoami_admin") + "\n")
+	}
+	if len(allowed) == 0 {
+		b.WriteString(localize(config, userID, "whoami_none"))
+		return b.String()
+	}
+	b.WriteString(localize(config, userID, "whoami_commands") + "\n")
+	for _, command := range allowed {
+		b.WriteString(fmt.Sprintf("- %s\n", command))
+	}
+	return b.String()
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWhoami(t *testing.T) {
+	config := &Config{
+		AdminUsers: []string{"UADMIN"},
+		Jenkins:    JenkinsConfig{URLFormat: "https://jenkins.example.com/job/{service-name}-{env}/build", AllowedUsers: []string{"UDEPLOY"}},
+	}
+	store := newConfigTaskStore(map[string]Task{
+		"restart": {Command: "restart", URL: "https://example.com/restart", AllowedUsers: []string{"UDEPLOY"}},
+		"status":  {Command: "status", URL: "https://example.com/status"},
+		"purge":   {Command: "purge", URL: "https://example.com/purge", AllowedUsers: []string{"UADMIN"}},
+	})
+
+	tests := []struct {
+		name string
+		user string
+		want string
+	}{
+		{name: "restricted user", user: "U1", want: "You are <@U1> (U1).\nCommands you can run:\n- status\n"},
+		{name: "allowlisted user", user: "UDEPLOY", want: "You are <@UDEPLOY> (UDEPLOY).\nCommands you can run:\n- deploy <service-name> <env>\n- restart\n- status\n"},
+		{name: "unrestricted admin", user: "UADMIN", want: "You are <@UADMIN> (UADMIN).\nYou are an admin: pause, resume and cancelling other users' executions are available.\nCommands you can run:\n- deploy <service-name> <env>\n- purge\n- restart\n- status\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messenger := newFakeMessenger()
+			handleMessageEvent(context.Background(), messenger, messageEvent(test.user, "whoami"), config, store, newBotState(config))
+
+			sent := messenger.sent()
+			if len(sent) != 1 || sent[0].UserID != test.user || sent[0].Text != test.want {
+				t.Errorf("sent %+v, want an ephemeral %q", sent, test.want)
+			}
+		})
+	}
+
+	if got := formatWhoami(&Config{}, map[string]Task{"purge": {AllowedUsers: []string{"UADMIN"}}}, "U1"); got != "You are <@U1> (U1).\nYou are not allowed to run any commands." {
+		t.Errorf("whoami with no permitted commands = %q", got)
+	}
+}
+
+// Allowlisted commands are refused for other users before anything runs
+func TestCommandAllowlist(t *testing.T) {
+	target, hits := newStubTarget(t)
+	config := &Config{AdminUsers: []string{"UADMIN"}, Jenkins: JenkinsConfig{URLFormat: target.URL + "/ok/{service-name}/{env}", AllowedUsers: []string{"UDEPLOY"}}}
+	store := newConfigTaskStore(map[string]Task{"restart": {Command: "restart", URL: target.URL + "/ok", Method: "POST", AllowedUsers: []string{"UDEPLOY"}}})
+
+	tests := []struct {
+		user    string
+		text    string
+		wantRun bool
+	}{
+		{user: "U1", text: "restart", wantRun: false},
+		{user: "U1", text: "deploy api prod", wantRun: false},
+		{user: "UDEPLOY", text: "restart", wantRun: true},
+		{user: "UADMIN", text: "deploy api prod", wantRun: true},
+	}
+	for _, test := range tests {
+		before := len(hits())
+		messenger := newFakeMessenger()
+		handleMessageEvent(context.Background(), messenger, messageEvent(test.user, test.text), config, store, newBotState(config))
+
+		if ran := len(hits()) > before; ran != test.wantRun {
+			t.Errorf("%s %q ran = %v, want %v", test.user, test.text, ran, test.wantRun)
+		}
+		if !test.wantRun {
+			sent := messenger.sent()
+			if len(sent) != 1 || sent[0].UserID != test.user {
+				t.Errorf("%s %q sent %+v, want one ephemeral refusal", test.user, test.text, sent)
+			}
+		}
+	}
+}
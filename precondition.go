@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// Precondition gates a task on a status endpoint, e.g. "deploy only if the
+// build is green". Unlike health_check_url it can check the JSON it returns.
+type Precondition struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Path    string            `json:"path,omitempty"`    // Dotted path of the JSON field checked, e.g. "status.overall" (default the whole body)
+	Equals  string            `json:"equals,omitempty"`  // Value the field must have, compared as text
+	Pattern string            `json:"pattern,omitempty"` // Regex the field must match
+	Message string            `json:"message,omitempty"` // Reply when the precondition fails, instead of the reason
+}
+
+// Timeout for fetching a task's precondition
+const preconditionTimeout = 10 * time.Second
+
+// GET the precondition URL and report why the task must not run, if it must not.
+// The endpoint has to answer 2xx and the checked field has to match.
+func checkPrecondition(ctx context.Context, config *Config, task Task) error {
+	pre := task.Precondition
+	checkURL, err := expandEnvRefs(pre.URL, config.StrictEnv)
+	if err != nil {
+		return err
+	}
+	if err := checkTargetAllowed(ctx, config, checkURL); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(withTargetGuard(ctx, config), preconditionTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", checkURL, nil)
+	if err != nil {
+		return err
+	}
+	setOutboundHeaders(req, config)
+	for key, value := range pre.Headers {
+		req.Header.Set(key, value)
+	}
+
+	client, err := taskHTTPClient(task)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("precondition returned status: %s", resp.Status)
+	}
+	body, _, err := readLimited(resp.Body, maxResponseBytes(config))
+	if err != nil {
+		return err
+	}
+
+	// The field as text: strings as is, anything else as JSON
+	value := string(body)
+	name := "response"
+	if pre.Path != "" {
+		field, err := extractJSONPath(body, pre.Path)
+		if err != nil {
+			return err
+		}
+		if text, ok := field.(string); ok {
+			value = text
+		} else {
+			encoded, _ := json.Marshal(field)
+			value = string(encoded)
+		}
+		name = pre.Path
+	}
+	if pre.Equals != "" && value != pre.Equals {
+		return fmt.Errorf("%s is %q, expected %q", name, value, pre.Equals)
+	}
+	if pre.Pattern != "" {
+		re, err := regexp.Compile(pre.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid precondition pattern: %w", err)
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("%s does not match %s", name, pre.Pattern)
+		}
+	}
+	return nil
+}
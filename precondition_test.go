@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// A status endpoint reporting a green build, and 503 on /down
+func newStatusServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("X-Token") != "" && r.Header.Get("X-Token") != "secret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"build":{"state":"green","number":12}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// The status has to be 2xx and the checked field has to match
+func TestCheckPrecondition(t *testing.T) {
+	server := newStatusServer(t)
+
+	tests := []struct {
+		name    string
+		pre     Precondition
+		wantErr string // Empty when the precondition passes
+	}{
+		{name: "status only", pre: Precondition{URL: server.URL}},
+		{name: "field equals", pre: Precondition{URL: server.URL, Path: "build.state", Equals: "green"}},
+		{name: "field differs", pre: Precondition{URL: server.URL, Path: "build.state", Equals: "red"}, wantErr: `build.state is "green", expected "red"`},
+		{name: "number compared as text", pre: Precondition{URL: server.URL, Path: "build.number", Equals: "12"}},
+		{name: "pattern matches", pre: Precondition{URL: server.URL, Path: "build.state", Pattern: "^(green|yellow)$"}},
+		{name: "pattern fails", pre: Precondition{URL: server.URL, Path: "build.state", Pattern: "^red$"}, wantErr: "build.state does not match ^red$"},
+		{name: "whole body pattern", pre: Precondition{URL: server.URL, Pattern: `"green"`}},
+		{name: "invalid pattern", pre: Precondition{URL: server.URL, Pattern: "("}, wantErr: "invalid precondition pattern"},
+		{name: "missing field", pre: Precondition{URL: server.URL, Path: "build.missing", Equals: "x"}, wantErr: "missing"},
+		{name: "error status", pre: Precondition{URL: server.URL + "/down"}, wantErr: "precondition returned status: 503"},
+		{name: "headers sent", pre: Precondition{URL: server.URL, Headers: map[string]string{"X-Token": "secret"}}},
+		{name: "wrong header", pre: Precondition{URL: server.URL, Headers: map[string]string{"X-Token": "nope"}}, wantErr: "403"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pre := test.pre
+			err := checkPrecondition(context.Background(), &Config{}, Task{Precondition: &pre})
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+// A failing precondition skips the task without calling its target
+func TestPreconditionSkipsTask(t *testing.T) {
+	status := newStatusServer(t)
+	var calls atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { calls.Add(1) }))
+	defer target.Close()
+
+	tests := []struct {
+		name      string
+		pre       Precondition
+		wantRun   bool
+		wantReply string
+	}{
+		{name: "passes", pre: Precondition{URL: status.URL, Path: "build.state", Equals: "green"}, wantRun: true, wantReply: "Task 'gated' executed successfully."},
+		{name: "fails with reason", pre: Precondition{URL: status.URL, Path: "build.state", Equals: "red"}, wantReply: `build.state is "green"`},
+		{name: "fails with message", pre: Precondition{URL: status.URL + "/down", Message: "The build is not green."}, wantReply: "The build is not green."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls.Store(0)
+			pre := test.pre
+			tasks := map[string]Task{"gated": {Command: "gated", URL: target.URL, Method: "POST", Precondition: &pre}}
+			config := &Config{Tasks: tasks, AckReaction: "none", DebounceMillis: -1}
+			dispatcher := newDispatcher(config, newConfigTaskStore(tasks), newBotState(config))
+			result, err := dispatcher.Dispatch(context.Background(), "gated", CommandMeta{UserID: "U1", ChannelID: "C1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ran := calls.Load() > 0; ran != test.wantRun {
+				t.Errorf("target called = %v, want %v", ran, test.wantRun)
+			}
+			if got := result.Replies[len(result.Replies)-1].Text; !strings.Contains(got, test.wantReply) {
+				t.Errorf("reply = %q, want it to contain %q", got, test.wantReply)
+			}
+		})
+	}
+}
+
+// A run skipped by its precondition doesn't use up the cooldown window, and
+// precondition headers can be secret references
+func TestPreconditionBeforeCooldown(t *testing.T) {
+	t.Setenv("BOT_TEST_STATUS_TOKEN", "secret")
+	status := newStatusServer(t)
+	target, hits := newStubTarget(t)
+	pre := Precondition{URL: status.URL, Headers: map[string]string{"X-Token": "env://BOT_TEST_STATUS_TOKEN"}, Path: "build.state", Equals: "red"}
+	tasks := map[string]Task{"gated": {Command: "gated", URL: target.URL + "/ok", Method: "POST", CooldownSeconds: 60, Precondition: &pre}}
+	config := &Config{Tasks: tasks, AckReaction: "none", DebounceMillis: -1}
+	store := newConfigTaskStore(tasks)
+	state := newBotState(config)
+
+	for i := 0; i < 2; i++ {
+		result, err := newDispatcher(config, store, state).Dispatch(context.Background(), "gated", CommandMeta{UserID: "U1", ChannelID: "C1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := result.Replies[len(result.Replies)-1].Text; !strings.Contains(got, `build.state is "green"`) {
+			t.Errorf("run %d: reply = %q, want the precondition reason", i+1, got)
+		}
+	}
+
+	// Once the precondition holds the task runs, then the cooldown applies
+	pre.Equals = "green"
+	for i, want := range []string{"Task 'gated' executed successfully.", "please wait before running it again"} {
+		result, _ := newDispatcher(config, store, state).Dispatch(context.Background(), "gated", CommandMeta{UserID: "U1", ChannelID: "C1"})
+		if got := result.Replies[len(result.Replies)-1].Text; !strings.Contains(got, want) {
+			t.Errorf("run %d after the fix: reply = %q, want %q", i+1, got, want)
+		}
+	}
+	if len(hits()) != 1 {
+		t.Errorf("target called %d times, want once", len(hits()))
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// ReactionTrigger runs a command when an allowed user reacts with its emoji
+type ReactionTrigger struct {
+	Command      string   `json:"command,omitempty"`       // Command to run; empty runs the text of the message reacted to, e.g. a deploy proposal
+	AllowedUsers []string `json:"allowed_users,omitempty"` // Slack user IDs allowed to trigger it (empty = everyone)
+}
+
+// messageReader is implemented by backends that can fetch a posted message's text
+type messageReader interface {
+	MessageText(channelID, timestamp string) (string, error)
+}
+
+// Fetch one message by timestamp (needs the channels:history or groups:history scope)
+func (m *slackMessenger) MessageText(channelID, timestamp string) (string, error) {
+	history, err := m.api.GetConversationHistory(&slack.GetConversationHistoryParameters{
+		ChannelID: channelID,
+		Latest:    timestamp,
+		Oldest:    timestamp,
+		Inclusive: true,
+		Limit:     1,
+	})
+	if err != nil {
+		return "", warnOnAuthError(err)
+	}
+	if len(history.Messages) == 0 {
+		return "", fmt.Errorf("message %s not found in %s", timestamp, channelID)
+	}
+	return history.Messages[0].Text, nil
+}
+
+// Handle a reaction_added event: run the command mapped to the emoji as the
+// user who reacted, so the command's own allowlist and checks apply to them too
+func handleReactionEvent(ctx context.Context, messenger Messenger, evt map[string]interface{}, config *Config, store TaskStore, state *botState) {
+	emoji, _ := evt["reaction"].(string)
+	trigger, ok := config.ReactionTriggers[emoji]
+	if !ok {
+		return
+	}
+	userID, _ := evt["user"].(string)
+	item, _ := evt["item"].(map[string]interface{})
+	channelID, _ := item["channel"].(string)
+	timestamp, _ := item["ts"].(string)
+	if item["type"] != "message" || channelID == "" || userID == "" {
+		return
+	}
+
+	// The bot's own acknowledgement reactions never trigger anything
+	if slackBot, ok := messenger.(*slackMessenger); ok && userID == slackBot.botUser {
+		return
+	}
+
+	if !isUserAllowed(config, trigger.AllowedUsers, userID) {
+		log.Printf("User %s is not allowed to trigger commands with :%s:", userID, emoji)
+		return
+	}
+
+	command := trigger.Command
+	if command == "" {
+		reader, ok := messenger.(messageReader)
+		if !ok {
+			return
+		}
+		text, err := reader.MessageText(channelID, timestamp)
+		if err != nil {
+			log.Printf("Error fetching message reacted to with :%s:: %v", emoji, err)
+			return
+		}
+		command = strings.TrimSpace(leadingMentionPattern.ReplaceAllString(text, ""))
+		if config.CommandPrefix != "" {
+			if !strings.HasPrefix(command, config.CommandPrefix) {
+				return
+			}
+			command = strings.TrimSpace(strings.TrimPrefix(command, config.CommandPrefix))
+		}
+	}
+	if command == "" {
+		return
+	}
+
+	log.Printf("Reaction :%s: by %s in channel %s triggers '%s'", emoji, userID, channelID, command)
+
+	// No timestamp, so the reacted message is neither acknowledged nor deleted
+	msg := incomingMessage{Text: command, ChannelID: channelID, UserID: userID}
+	handleCommand(ctx, messenger, msg, config, store, state)
+}
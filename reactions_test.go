@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fakeReader is a fakeMessenger that can fetch posted messages
+type fakeReader struct {
+	*fakeMessenger
+	messages map[string]string // Text by timestamp
+}
+
+func (r *fakeReader) MessageText(channelID, timestamp string) (string, error) {
+	text, ok := r.messages[timestamp]
+	if !ok {
+		return "", errors.New("message_not_found")
+	}
+	return text, nil
+}
+
+func reactionEvent(emoji, userID, ts string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "reaction_added",
+		"reaction": emoji,
+		"user":     userID,
+		"item":     map[string]interface{}{"type": "message", "channel": "C1", "ts": ts},
+	}
+}
+
+// Mapped reactions run their command, or the text of the message reacted to
+func TestHandleReactionEvent(t *testing.T) {
+	target, _ := newStubTarget(t)
+	tasks := map[string]Task{
+		"health": {Command: "health", URL: target.URL + "/ok", Method: "GET"},
+		"broken": {Command: "broken", URL: target.URL + "/fail", Method: "GET"},
+	}
+	triggers := map[string]ReactionTrigger{
+		"heart":  {Command: "health"},
+		"rocket": {AllowedUsers: []string{"UOPS"}},
+	}
+
+	tests := []struct {
+		name     string
+		prefix   string
+		event    map[string]interface{}
+		messages map[string]string
+		want     string // Prefix of the last reply, empty for no reply
+	}{
+		{name: "mapped command", event: reactionEvent("heart", "U1", "1.1"), want: "Task 'health' executed successfully."},
+		{name: "unmapped emoji", event: reactionEvent("tada", "U1", "1.1")},
+		{name: "not a message", event: map[string]interface{}{"type": "reaction_added", "reaction": "heart", "user": "U1", "item": map[string]interface{}{"type": "file", "channel": "C1"}}},
+		{name: "user not allowed", event: reactionEvent("rocket", "U1", "1.1"), messages: map[string]string{"1.1": "broken"}},
+		{name: "message text runs", event: reactionEvent("rocket", "UOPS", "1.1"), messages: map[string]string{"1.1": "<@UBOT> broken"}, want: "Task 'broken' failed to execute."},
+		{name: "message not found", event: reactionEvent("rocket", "UOPS", "9.9")},
+		{name: "prefix required", prefix: "!", event: reactionEvent("rocket", "UOPS", "1.1"), messages: map[string]string{"1.1": "broken"}},
+		{name: "prefix stripped", prefix: "!", event: reactionEvent("rocket", "UOPS", "1.1"), messages: map[string]string{"1.1": "! health"}, want: "Task 'health' executed successfully."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messenger := &fakeReader{fakeMessenger: newFakeMessenger(), messages: test.messages}
+			config := &Config{Tasks: tasks, ReactionTriggers: triggers, CommandPrefix: test.prefix, AckReaction: "none"}
+			handleMessageEvent(context.Background(), messenger, map[string]interface{}{"event": test.event}, config, newConfigTaskStore(tasks), newBotState(config))
+
+			got := messenger.sent()
+			if test.want == "" {
+				if len(got) != 0 {
+					t.Errorf("posted %v, want nothing", got)
+				}
+				return
+			}
+			if len(got) == 0 || !strings.HasPrefix(got[len(got)-1].Text, test.want) {
+				t.Errorf("posted %v, want %q last", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"log"
+	"strconv"
+)
+
+// Handle the "reload" admin command: re-read the configuration file and swap
+// it in when it is valid. Commands already running keep the old configuration.
+// Tokens, backends, task_db and the HTTP listener only change on restart.
+func handleReloadCommand(messenger Messenger, msg incomingMessage, config *Config, store TaskStore, state *botState) {
+	if !isAdminUser(config, msg.UserID) {
+		postNotAllowedMessage(messenger, config, msg.ChannelID, msg.UserID, "reload")
+		return
+	}
+
+	fresh, err := loadConfig(state.configPath)
+	if err == nil {
+		err = validateConfig(fresh)
+	}
+	if err != nil {
+		log.Printf("Config reload by %s failed: %v", msg.UserID, err)
+		state.notify("Config reload by <@%s> failed: %v", msg.UserID, err)
+		if err := messenger.PostEphemeral(msg.ChannelID, msg.UserID, localize(config, msg.UserID, "reload_failed", "error", err.Error())); err != nil {
+			log.Printf("Error sending message to Slack: %v", err)
+		}
+		return
+	}
+
+	applyConfig(fresh, store, state)
+
+	tasks, err := store.ListTasks()
+	if err != nil {
+		log.Printf("Error listing tasks: %v", err)
+	}
+	log.Printf("Configuration reloaded by %s, %d commands available", msg.UserID, len(tasks))
+	if err := messenger.PostMessage(msg.ChannelID, localize(fresh, msg.UserID, "reloaded", "count", strconv.Itoa(len(tasks)))); err != nil {
+		log.Printf("Error sending message to Slack: %v", err)
+	}
+}
+
+// Swap in a validated configuration
+func applyConfig(fresh *Config, store TaskStore, state *botState) {
+	// Tasks from config.json live in the config store; a SQLite store keeps its own
+	if configStore, ok := store.(*configTaskStore); ok {
+		configStore.Replace(fresh.Tasks)
+	}
+	setLogLevel(fresh.LogLevel)
+	setLogMaskPatterns(fresh.LogMaskPatterns)
+	state.config.Store(fresh)
+}
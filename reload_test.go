@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The new configuration is swapped in only when it loads and validates
+func TestReloadCommand(t *testing.T) {
+	t.Setenv("BOT_ENV", "")
+	tests := []struct {
+		name        string
+		user        string
+		content     string
+		wantReply   string
+		wantPrivate bool
+		wantSwapped bool
+	}{
+		{name: "not an admin", user: "U2", content: `{"tasks": {}}`, wantReply: "You are not allowed to run 'reload'.", wantPrivate: true},
+		{name: "invalid JSON", user: "U1", content: `{"tasks": `, wantReply: "Reload failed, keeping the current configuration:", wantPrivate: true},
+		{name: "invalid task", user: "U1", content: `{"admin_users": ["U1"], "tasks": {"purge": {"url": "https://example.com/purge", "method": "GET", "body": "{}"}}}`, wantReply: "Reload failed, keeping the current configuration:\ntask 'purge': body and form_data require method POST", wantPrivate: true},
+		{name: "valid", user: "U1", content: `{"admin_users": ["U1"], "tasks": {"purge": {"url": "https://example.com/purge", "method": "POST"}, "reindex": {"url": "https://example.com/reindex", "method": "POST"}}}`, wantReply: "Configuration reloaded, 2 commands available.", wantSwapped: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			writeFile(t, path, test.content)
+			config := &Config{AdminUsers: []string{"U1"}}
+			store := newConfigTaskStore(map[string]Task{"restart": {Command: "restart", URL: "https://example.com/restart", Method: "POST"}})
+			state := newBotState(config)
+			state.configPath = path
+			messenger := newFakeMessenger()
+
+			handleMessageEvent(context.Background(), messenger, messageEvent(test.user, "reload"), config, store, state)
+
+			sent := messenger.sent()
+			if len(sent) != 1 || !strings.HasPrefix(sent[0].Text, test.wantReply) || (sent[0].UserID != "") != test.wantPrivate {
+				t.Fatalf("replies = %+v, want %q (ephemeral %v)", sent, test.wantReply, test.wantPrivate)
+			}
+			if swapped := state.config.Load() != config; swapped != test.wantSwapped {
+				t.Errorf("config swapped = %v, want %v", swapped, test.wantSwapped)
+			}
+			_, hasRestart, _ := store.GetTask("restart")
+			_, hasPurge, _ := store.GetTask("purge")
+			if hasRestart == test.wantSwapped || hasPurge != test.wantSwapped {
+				t.Errorf("tasks after reload: restart %v, purge %v", hasRestart, hasPurge)
+			}
+		})
+	}
+}
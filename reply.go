@@ -0,0 +1,109 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Values available to success_message and failure_message templates,
+// e.g. "Deployed {{.Args.service}} to {{.Args.env}} for <@{{.User}}>". Args
+// holds a task's parsed arguments, or the fallback's text.
+type replyData struct {
+	Command string
+	User    string
+	Status  string // "success" or "failure"
+	Args    map[string]string
+
+	StatusCode int // HTTP status of the task's response, 0 when there was none
+}
+
+// Render a reply template, falling back to the default wording when the
+// template is unset or broken
+func renderReply(tmpl string, data replyData, fallback string) string {
+	if tmpl == "" {
+		return fallback
+	}
+
+	t, err := template.New("reply").Option("missingkey=zero").Parse(tmpl)
+	if err != nil {
+		log.Printf("Error parsing reply template for '%s': %v", data.Command, err)
+		return fallback
+	}
+
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		log.Printf("Error rendering reply template for '%s': %v", data.Command, err)
+		return fallback
+	}
+	return b.String()
+}
+
+// Pick the success or failure template
+func replyTemplate(success bool, successMessage, failureMessage string) string {
+	if success {
+		return successMessage
+	}
+	return failureMessage
+}
+
+// Pick the status_messages entry for a response code: the exact code such as
+// "409" first, then the narrowest range such as "400-499" or "4xx"
+func statusMessage(messages map[string]string, code int) (string, bool) {
+	if code == 0 || len(messages) == 0 {
+		return "", false
+	}
+	if message, ok := messages[strconv.Itoa(code)]; ok {
+		return message, true
+	}
+	var best string
+	bestWidth := -1
+	for key, message := range messages {
+		low, high, ok := parseStatusKey(key)
+		if !ok || code < low || code > high {
+			continue
+		}
+		if width := high - low; bestWidth < 0 || width < bestWidth {
+			best, bestWidth = message, width
+		}
+	}
+	return best, bestWidth >= 0
+}
+
+// Parse a status_messages key: "202", "400-499" or "4xx"
+func parseStatusKey(key string) (low, high int, ok bool) {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if len(key) == 3 && strings.HasSuffix(key, "xx") && key[0] >= '1' && key[0] <= '5' {
+		class := int(key[0]-'0') * 100
+		return class, class + 99, true
+	}
+	if from, to, isRange := strings.Cut(key, "-"); isRange {
+		low, errLow := strconv.Atoi(strings.TrimSpace(from))
+		high, errHigh := strconv.Atoi(strings.TrimSpace(to))
+		return low, high, errLow == nil && errHigh == nil && low <= high
+	}
+	code, err := strconv.Atoi(key)
+	return code, code, err == nil
+}
+
+func statusText(success bool) string {
+	if success {
+		return "success"
+	}
+	return "failure"
+}
+
+// Slack mention for mention_on_failure: @here, @channel, a user group (S...) or a user (U...)
+func failureMention(target string) string {
+	switch {
+	case target == "":
+		return ""
+	case target == "here" || target == "channel" || target == "everyone":
+		return "<!" + target + ">"
+	case strings.HasPrefix(target, "U") || strings.HasPrefix(target, "W"):
+		return "<@" + target + ">"
+	default:
+		return "<!subteam^" + target + ">"
+	}
+}
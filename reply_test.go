@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRenderReply(t *testing.T) {
+	data := replyData{
+		Command: "deploy",
+		User:    "U123",
+		Status:  "success",
+		Args:    map[string]string{"service": "api", "env": "prod"},
+	}
+	tests := []struct {
+		name string
+		tmpl string
+		want string
+	}{
+		{name: "unset", tmpl: "", want: "default wording"},
+		{name: "all variables", tmpl: "{{.Command}} of {{.Args.service}} to {{.Args.env}} by <@{{.User}}>: {{.Status}}", want: "deploy of api to prod by <@U123>: success"},
+		{name: "conditional", tmpl: `{{if eq .Args.env "prod"}}:rotating_light: {{end}}{{.Args.service}} deployed`, want: ":rotating_light: api deployed"},
+		{name: "missing arg renders empty", tmpl: "region={{.Args.region}}", want: "region="},
+		{name: "parse error falls back", tmpl: "{{.Command", want: "default wording"},
+		{name: "execution error falls back", tmpl: "{{.Command.Missing}}", want: "default wording"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := renderReply(test.tmpl, data, "default wording"); got != test.want {
+				t.Errorf("renderReply(%q) = %q, want %q", test.tmpl, got, test.want)
+			}
+		})
+	}
+}
+
+// Task and deploy replies use the configured templates, or the default wording when unset
+func TestHandleMessageReplyTemplates(t *testing.T) {
+	target, _ := newStubTarget(t)
+	config := &Config{Jenkins: JenkinsConfig{
+		URLFormat:      target.URL + "/ok/{service-name}/{env}",
+		SuccessMessage: "Deployed {{.Args.service}} to {{.Args.env}} for <@{{.User}}>",
+	}}
+	store := newConfigTaskStore(map[string]Task{
+		"restart": {Command: "restart", URL: target.URL + "/ok", Method: "POST", SuccessMessage: "{{.Command}} done ({{.Status}})"},
+		"broken":  {Command: "broken", URL: target.URL + "/fail", Method: "POST", FailureMessage: "<@{{.User}}>: {{.Command}} {{.Status}}"},
+		"plain":   {Command: "plain", URL: target.URL + "/ok", Method: "GET", FailureMessage: "only on failure"},
+		"scale":   {Command: "scale", URL: target.URL + "/ok", Method: "POST", Args: []ArgSpec{{Name: "service"}}, SuccessMessage: "Scaled {{.Args.service}}"},
+		"crash":   {Command: "crash", URL: target.URL + "/fail", Method: "POST", Args: []ArgSpec{{Name: "service"}}, FailureMessage: "Could not crash {{.Args.service}}"},
+	})
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "restart", want: "restart done (success)"},
+		{text: "broken", want: "<@U1>: broken failure"},
+		{text: "plain", want: "Task 'plain' executed successfully."},
+		{text: "deploy api prod", want: "Deployed api to prod for <@U1>"},
+		{text: "scale api", want: "Scaled api"},
+		{text: "crash web", want: "Could not crash web"},
+	}
+	for _, test := range tests {
+		messenger := newFakeMessenger()
+		handleMessageEvent(context.Background(), messenger, messageEvent("U1", test.text), config, store, newBotState(config))
+		if replies := messenger.results(); len(replies) != 1 || replies[0] != test.want {
+			t.Errorf("%q replied %q, want %q", test.text, replies, test.want)
+		}
+	}
+}
+
+func TestFailureMention(t *testing.T) {
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{target: "", want: ""},
+		{target: "here", want: "<!here>"},
+		{target: "channel", want: "<!channel>"},
+		{target: "everyone", want: "<!everyone>"},
+		{target: "U123", want: "<@U123>"},
+		{target: "W123", want: "<@W123>"},
+		{target: "S123", want: "<!subteam^S123>"},
+	}
+	for _, test := range tests {
+		if got := failureMention(test.target); got != test.want {
+			t.Errorf("failureMention(%q) = %q, want %q", test.target, got, test.want)
+		}
+	}
+}
+
+// Only failed runs mention the task's mention_on_failure target
+func TestHandleMessageMentionOnFailure(t *testing.T) {
+	target, _ := newStubTarget(t)
+	tasks := map[string]Task{
+		"restart": {Command: "restart", URL: target.URL + "/ok", Method: "POST", MentionOnFailure: "S123"},
+		"purge":   {Command: "purge", URL: target.URL + "/fail", Method: "POST", MentionOnFailure: "S123"},
+	}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "restart", want: "Task 'restart' executed successfully."},
+		{text: "purge", want: "<!subteam^S123> Task 'purge' failed to execute."},
+	}
+	for _, test := range tests {
+		config := &Config{}
+		messenger := newFakeMessenger()
+		handleMessageEvent(context.Background(), messenger, messageEvent("U1", test.text), config, newConfigTaskStore(tasks), newBotState(config))
+		if replies := messenger.results(); len(replies) != 1 || !strings.HasPrefix(replies[0], test.want) {
+			t.Errorf("%q replied %q, want %q", test.text, replies, test.want)
+		}
+	}
+}
+
+// An exact code wins over the narrowest matching range
+func TestStatusMessage(t *testing.T) {
+	messages := map[string]string{
+		"202":     "accepted",
+		"4xx":     "client error",
+		"400-404": "bad request",
+		"5xx":     "server error",
+		"bogus":   "never",
+	}
+
+	tests := []struct {
+		code   int
+		want   string
+		wantOK bool
+	}{
+		{code: 202, want: "accepted", wantOK: true},
+		{code: 402, want: "bad request", wantOK: true},
+		{code: 409, want: "client error", wantOK: true},
+		{code: 503, want: "server error", wantOK: true},
+		{code: 200},
+		{code: 0},
+	}
+
+	for _, test := range tests {
+		got, ok := statusMessage(messages, test.code)
+		if got != test.want || ok != test.wantOK {
+			t.Errorf("statusMessage(%d) = %q, %v, want %q, %v", test.code, got, ok, test.want, test.wantOK)
+		}
+	}
+
+	if _, ok := statusMessage(nil, 500); ok {
+		t.Error("statusMessage without messages matched")
+	}
+}
+
+func TestParseStatusKey(t *testing.T) {
+	tests := []struct {
+		key       string
+		low, high int
+		ok        bool
+	}{
+		{key: "202", low: 202, high: 202, ok: true},
+		{key: "4xx", low: 400, high: 499, ok: true},
+		{key: " 5XX ", low: 500, high: 599, ok: true},
+		{key: "400-499", low: 400, high: 499, ok: true},
+		{key: "400 - 404", low: 400, high: 404, ok: true},
+		{key: "499-400"},
+		{key: "6xx"},
+		{key: "abc"},
+		{key: "4xx-5xx"},
+	}
+
+	for _, test := range tests {
+		low, high, ok := parseStatusKey(test.key)
+		if ok != test.ok || (ok && (low != test.low || high != test.high)) {
+			t.Errorf("parseStatusKey(%q) = %d, %d, %v, want %d, %d, %v", test.key, low, high, ok, test.low, test.high, test.ok)
+		}
+	}
+}
+
+// status_messages picks the reply by response code, falling back to the
+// success and failure templates for codes it doesn't cover
+func TestStatusMessagesReply(t *testing.T) {
+	target, _ := newStubTarget(t)
+	statuses := map[string]string{"5xx": "Target down ({{.StatusCode}})", "200": "All good"}
+	tasks := map[string]Task{
+		"good": {Command: "good", URL: target.URL + "/ok", Method: "GET", StatusMessages: statuses},
+		"bad":  {Command: "bad", URL: target.URL + "/fail", Method: "GET", StatusMessages: statuses},
+		"plain": {Command: "plain", URL: target.URL + "/fail", Method: "GET", StatusMessages: map[string]string{"2xx": "fine"},
+			FailureMessage: "plain failed"},
+	}
+	config := &Config{Tasks: tasks, AckReaction: "none", DebounceMillis: -1}
+	dispatcher := newDispatcher(config, newConfigTaskStore(tasks), newBotState(config))
+
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{command: "good", want: "All good"},
+		{command: "bad", want: "Target down (500)"},
+		{command: "plain", want: "plain failed"},
+	}
+
+	for _, test := range tests {
+		result, err := dispatcher.Dispatch(context.Background(), test.command, CommandMeta{UserID: "U1", ChannelID: "C1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := result.Replies[len(result.Replies)-1].Text; !strings.HasPrefix(got, test.want) {
+			t.Errorf("%s: reply = %q, want %q", test.command, got, test.want)
+		}
+	}
+}
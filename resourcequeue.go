@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// resourceQueue runs tasks sharing a resource_key one at a time, in the order
+// they were invoked
+type resourceQueue struct {
+	mu     sync.Mutex
+	queues map[string][]chan struct{} // Per key, the running ticket first, then the waiting ones
+}
+
+func newResourceQueue() *resourceQueue {
+	return &resourceQueue{queues: make(map[string][]chan struct{})}
+}
+
+// Join the queue for key. Returns how many runs are ahead, and wait, which
+// blocks until it is this caller's turn. The returned release function must
+// be called when the run finishes or gives up waiting.
+func (q *resourceQueue) Join(key string) (ahead int, wait func(ctx context.Context) error, release func()) {
+	ticket := make(chan struct{})
+	q.mu.Lock()
+	queue := append(q.queues[key], ticket)
+	q.queues[key] = queue
+	ahead = len(queue) - 1
+	if ahead == 0 {
+		close(ticket)
+	}
+	q.mu.Unlock()
+
+	wait = func(ctx context.Context) error {
+		select {
+		case <-ticket:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	release = func() { q.leave(key, ticket) }
+	return ahead, wait, release
+}
+
+// Drop the ticket and let the next run start if the ticket was running
+func (q *resourceQueue) leave(key string, ticket chan struct{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	queue := q.queues[key]
+	for i, queued := range queue {
+		if queued != ticket {
+			continue
+		}
+		queue = append(queue[:i:i], queue[i+1:]...)
+		if i == 0 && len(queue) > 0 {
+			close(queue[0])
+		}
+		break
+	}
+	if len(queue) == 0 {
+		delete(q.queues, key)
+	} else {
+		q.queues[key] = queue
+	}
+}
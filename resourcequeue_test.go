@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Each join on a key counts the runs queued before it
+func TestResourceQueueAhead(t *testing.T) {
+	q := newResourceQueue()
+	tests := []struct {
+		key       string
+		wantAhead int
+	}{
+		{key: "api", wantAhead: 0},
+		{key: "api", wantAhead: 1},
+		{key: "web", wantAhead: 0},
+		{key: "api", wantAhead: 2},
+	}
+
+	for i, test := range tests {
+		ahead, _, release := q.Join(test.key)
+		defer release()
+		if ahead != test.wantAhead {
+			t.Errorf("join %d on %q: ahead = %d, want %d", i, test.key, ahead, test.wantAhead)
+		}
+	}
+}
+
+// Runs sharing a key start in the order they joined, one at a time
+func TestResourceQueueFIFO(t *testing.T) {
+	q := newResourceQueue()
+	const runs = 5
+
+	var mu sync.Mutex
+	var order []int
+	running := 0
+	var wg sync.WaitGroup
+	for i := 0; i < runs; i++ {
+		_, wait, release := q.Join("api")
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer release()
+			if err := wait(context.Background()); err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			running++
+			if running > 1 {
+				t.Errorf("%d runs at once", running)
+			}
+			order = append(order, i)
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	for i, got := range order {
+		if got != i {
+			t.Fatalf("runs started in order %v, want 0..%d", order, runs-1)
+		}
+	}
+}
+
+// A waiting run that gives up leaves the queue without blocking the next one
+func TestResourceQueueCancelledWaiter(t *testing.T) {
+	q := newResourceQueue()
+	_, _, releaseFirst := q.Join("api")
+	_, waitSecond, releaseSecond := q.Join("api")
+	_, waitThird, releaseThird := q.Join("api")
+	defer releaseThird()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := waitSecond(ctx); err != context.Canceled {
+		t.Fatalf("cancelled wait = %v, want context.Canceled", err)
+	}
+	releaseSecond()
+	releaseFirst()
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := waitThird(ctx); err != nil {
+		t.Fatalf("third run never started: %v", err)
+	}
+	if ahead, _, release := q.Join("api"); ahead != 1 {
+		t.Errorf("ahead = %d after two left, want 1", ahead)
+	} else {
+		release()
+	}
+}
+
+// Keys are dropped once their last run releases
+func TestResourceQueueForgetsIdleKeys(t *testing.T) {
+	q := newResourceQueue()
+	_, _, release := q.Join("api")
+	release()
+	if len(q.queues) != 0 {
+		t.Errorf("queues = %v, want empty once every run released", q.queues)
+	}
+}
+
+// A second run on a busy resource is told its place in line and starts after the first
+func TestHandleMessageQueuesOnResource(t *testing.T) {
+	started := make(chan struct{}, 2)
+	unblock := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-unblock
+	}))
+	defer target.Close()
+	tasks := map[string]Task{"release": {Command: "release", URL: target.URL, Method: "POST", ResourceKey: "api"}}
+	config := &Config{DebounceMillis: -1}
+	store := newConfigTaskStore(tasks)
+	state := newBotState(config)
+	messenger := newFakeMessenger()
+
+	var wg sync.WaitGroup
+	run := func(user string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handleMessageEvent(context.Background(), messenger, messageEvent(user, "release"), config, store, state)
+		}()
+	}
+	run("U1")
+	<-started
+	run("U2")
+
+	isQueued := func() bool {
+		for _, text := range messenger.texts() {
+			if text == "'release' is number 2 in line for api, it will start when the runs ahead of it finish." {
+				return true
+			}
+		}
+		return false
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !isQueued() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !isQueued() {
+		t.Fatalf("posted %q, want the second run queued", messenger.texts())
+	}
+	select {
+	case <-started:
+		t.Fatal("second run started while the first was still running")
+	default:
+	}
+	close(unblock)
+	wg.Wait()
+	if len(started) != 1 {
+		t.Errorf("%d more requests after the first finished, want 1", len(started))
+	}
+}
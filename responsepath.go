@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Look up a dotted path such as "status" or "data.replicas.0.name" in a JSON
+// body. A leading "$." (JSONPath style) is accepted and ignored.
+func extractJSONPath(body []byte, path string) (interface{}, error) {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return nil, fmt.Errorf("response is not JSON: %w", err)
+	}
+
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return value, nil
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			next, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("field '%s' not found", key)
+			}
+			value = next
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, fmt.Errorf("index '%s' out of range", key)
+			}
+			value = node[index]
+		default:
+			return nil, fmt.Errorf("field '%s' not found", key)
+		}
+	}
+	return value, nil
+}
+
+// Format the extracted field as "name: value" for the Slack reply
+func formatResponseValue(body []byte, path string) string {
+	name := path
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		name = path[i+1:]
+	}
+
+	value, err := extractJSONPath(body, path)
+	if err != nil {
+		return fmt.Sprintf("%s: unavailable (%v)", name, err)
+	}
+	if text, ok := value.(string); ok {
+		return fmt.Sprintf("%s: %s", name, text)
+	}
+	encoded, _ := json.Marshal(value)
+	return fmt.Sprintf("%s: %s", name, encoded)
+}
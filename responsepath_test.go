@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const statusBody = `{"status": "green", "replicas": 3, "data": {"region": {"name": "eu-west-1"}, "pods": [{"name": "api-0"}, {"name": "api-1"}]}, "ready": true}`
+
+func TestFormatResponseValue(t *testing.T) {
+	tests := []struct {
+		path string
+		body string
+		want string
+	}{
+		{path: "status", body: statusBody, want: "status: green"},
+		{path: "replicas", body: statusBody, want: "replicas: 3"},
+		{path: "ready", body: statusBody, want: "ready: true"},
+		{path: "data.region.name", body: statusBody, want: "name: eu-west-1"},
+		{path: "$.data.pods.1.name", body: statusBody, want: "name: api-1"},
+		{path: "data.region", body: statusBody, want: `region: {"name":"eu-west-1"}`},
+		{path: "data.missing", body: statusBody, want: "missing: unavailable (field 'missing' not found)"},
+		{path: "data.pods.5.name", body: statusBody, want: "name: unavailable (index '5' out of range)"},
+		{path: "status.color", body: statusBody, want: "color: unavailable (field 'color' not found)"},
+		{path: "status", body: "<html>down</html>", want: "status: unavailable (response is not JSON: invalid character '<' looking for beginning of value)"},
+	}
+	for _, test := range tests {
+		if got := formatResponseValue([]byte(test.body), test.path); got != test.want {
+			t.Errorf("formatResponseValue(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+// The extracted field is appended to the task reply
+func TestHandleMessageResponsePath(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, statusBody)
+	}))
+	defer target.Close()
+
+	config := &Config{}
+	store := newConfigTaskStore(map[string]Task{
+		"status": {Command: "status-api", URL: target.URL, Method: "GET", ResponsePath: "data.region.name"},
+		"plain":  {Command: "plain", URL: target.URL, Method: "GET"},
+	})
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "status", want: "Task 'status-api' executed successfully.\nname: eu-west-1"},
+		{text: "plain", want: "Task 'plain' executed successfully."},
+	}
+	for _, test := range tests {
+		messenger := newFakeMessenger()
+		handleMessageEvent(context.Background(), messenger, messageEvent("U1", test.text), config, store, newBotState(config))
+		if replies := messenger.results(); len(replies) != 1 || replies[0] != test.want {
+			t.Errorf("%q replied %q, want %q", test.text, replies, test.want)
+		}
+	}
+}
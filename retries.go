@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Delay before the first retry when retry_delay_seconds is not configured
+const defaultRetryDelay = time.Second
+
+// Decide whether a failed attempt is worth retrying. A refused connection
+// never reached the target, so it always is. Timeouts and responses on
+// retry_on_status, or 429 and 5xx, may have been processed, so they are only
+// retried for GET tasks unless retry_non_idempotent is set. Other errors,
+// such as a bad URL, proxy or TLS setup or a blocked target, never are.
+func shouldRetry(task Task, result taskResult) bool {
+	if result.Success {
+		return false
+	}
+	if result.StatusCode == 0 {
+		if errors.Is(result.Err, syscall.ECONNREFUSED) {
+			return true
+		}
+		var netErr net.Error
+		return errors.As(result.Err, &netErr) && netErr.Timeout() && retriesAreSafe(task)
+	}
+	if !retriesAreSafe(task) {
+		return false
+	}
+	if len(task.RetryOnStatus) > 0 {
+		for _, status := range task.RetryOnStatus {
+			if status == result.StatusCode {
+				return true
+			}
+		}
+		return false
+	}
+	return result.StatusCode == 429 || result.StatusCode >= 500
+}
+
+// GET tasks can be repeated; POST tasks only when they opt in
+func retriesAreSafe(task Task) bool {
+	return task.Method != "POST" || task.RetryNonIdempotent
+}
+
+// Exponential delay before the given retry attempt, starting at 1
+func retryDelay(task Task, attempt int) time.Duration {
+	delay := time.Duration(task.RetryDelaySeconds) * time.Second
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+	return delay << (attempt - 1)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// A net.Error that timed out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// A refused connection is always retried, timeouts and 5xx only when repeating is safe
+func TestShouldRetry(t *testing.T) {
+	get := Task{Method: "GET"}
+	post := Task{Method: "POST"}
+	postOptIn := Task{Method: "POST", RetryNonIdempotent: true}
+	onlyConflict := Task{Method: "GET", RetryOnStatus: []int{409}}
+	refused := &os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}
+
+	tests := []struct {
+		name   string
+		task   Task
+		result taskResult
+		want   bool
+	}{
+		{name: "success", task: get, result: taskResult{Success: true, StatusCode: 200}, want: false},
+		{name: "GET 503", task: get, result: taskResult{StatusCode: 503}, want: true},
+		{name: "GET 429", task: get, result: taskResult{StatusCode: 429}, want: true},
+		{name: "GET 404", task: get, result: taskResult{StatusCode: 404}, want: false},
+		{name: "POST 503", task: post, result: taskResult{StatusCode: 503}, want: false},
+		{name: "POST 503 opted in", task: postOptIn, result: taskResult{StatusCode: 503}, want: true},
+		{name: "retry_on_status match", task: onlyConflict, result: taskResult{StatusCode: 409}, want: true},
+		{name: "retry_on_status replaces the defaults", task: onlyConflict, result: taskResult{StatusCode: 503}, want: false},
+		{name: "POST connection refused", task: post, result: taskResult{Err: fmt.Errorf("dial: %w", refused)}, want: true},
+		{name: "GET timeout", task: get, result: taskResult{Err: timeoutError{}}, want: true},
+		{name: "POST timeout", task: post, result: taskResult{Err: timeoutError{}}, want: false},
+		{name: "other error", task: get, result: taskResult{Err: errors.New("unsupported protocol scheme")}, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := shouldRetry(test.task, test.result); got != test.want {
+				t.Errorf("shouldRetry() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		delaySeconds int
+		attempt      int
+		want         time.Duration
+	}{
+		{attempt: 1, want: defaultRetryDelay},
+		{attempt: 3, want: 4 * defaultRetryDelay},
+		{delaySeconds: 5, attempt: 1, want: 5 * time.Second},
+		{delaySeconds: 5, attempt: 2, want: 10 * time.Second},
+	}
+	for _, test := range tests {
+		if got := retryDelay(Task{RetryDelaySeconds: test.delaySeconds}, test.attempt); got != test.want {
+			t.Errorf("retryDelay(%ds, attempt %d) = %s, want %s", test.delaySeconds, test.attempt, got, test.want)
+		}
+	}
+}
+
+// A GET task retried after a 503 succeeds on the next attempt; a 404 or a
+// POST is not retried
+func TestExecuteTaskRetries(t *testing.T) {
+	var calls atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+		} else if n == 1 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		}
+	}))
+	defer target.Close()
+
+	tests := []struct {
+		name        string
+		path        string
+		method      string
+		wantSuccess bool
+		wantCalls   int32
+	}{
+		{name: "retried after 503", path: "/flaky", wantSuccess: true, wantCalls: 2},
+		{name: "404 not retried", path: "/missing", wantCalls: 1},
+		{name: "POST not retried after 503", path: "/flaky", method: "POST", wantCalls: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls.Store(0)
+			method := test.method
+			if method == "" {
+				method = "GET"
+			}
+			result := executeTask(context.Background(), &Config{}, Task{Command: "reindex", URL: target.URL + test.path, Method: method, Retries: 2})
+			if result.Success != test.wantSuccess {
+				t.Errorf("Success = %v, want %v", result.Success, test.wantSuccess)
+			}
+			if got := calls.Load(); got != test.wantCalls {
+				t.Errorf("target called %d times, want %d", got, test.wantCalls)
+			}
+		})
+	}
+}
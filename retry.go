@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// lastCommands remembers the last runnable command each user sent, for retry
+type lastCommands struct {
+	mu       sync.Mutex
+	commands map[string]string
+}
+
+func newLastCommands() *lastCommands {
+	return &lastCommands{commands: make(map[string]string)}
+}
+
+func (l *lastCommands) Set(userID, text string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.commands[userID] = text
+}
+
+func (l *lastCommands) Get(userID string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	text, ok := l.commands[userID]
+	return text, ok
+}
+
+// Handle "retry" or "retry last": dispatch the user's previous command again,
+// so it goes through the same allowlist, maintenance and cooldown checks
+func handleRetryCommand(ctx context.Context, messenger Messenger, msg incomingMessage, config *Config, store TaskStore, state *botState) {
+	last, ok := state.lastCommands.Get(msg.UserID)
+	if !ok {
+		err := messenger.PostEphemeral(msg.ChannelID, msg.UserID, localize(config, msg.UserID, "no_retry"))
+		if err != nil {
+			log.Printf("Error sending message to Slack: %v", err)
+		}
+		return
+	}
+
+	log.Printf("Retrying '%s' for user %s", last, msg.UserID)
+	msg.Text = last
+	handleCommand(ctx, messenger, msg, config, store, state)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRetryCommand(t *testing.T) {
+	target, _ := newStubTarget(t)
+	store := newConfigTaskStore(map[string]Task{
+		"restart": {Command: "restart", URL: target.URL + "/ok", Method: "POST"},
+		"purge":   {Command: "purge", URL: target.URL + "/fail", Method: "POST"},
+		"rebuild": {Command: "rebuild", URL: target.URL + "/ok", Method: "POST", CooldownSeconds: 3600},
+	})
+
+	tests := []struct {
+		name          string
+		previous      []string // Commands U1 sent before retrying
+		others        []string // Commands another user sent in between
+		retry         string
+		wantReply     string
+		wantEphemeral bool
+	}{
+		{name: "nothing to retry", retry: "retry", wantReply: "There is no previous command to retry.", wantEphemeral: true},
+		{name: "unknown commands are not remembered", previous: []string{"nope"}, retry: "retry", wantReply: "There is no previous command to retry.", wantEphemeral: true},
+		{name: "retry runs the last task", previous: []string{"purge"}, retry: "retry", wantReply: "Task 'purge' failed to execute."},
+		{name: "retry last", previous: []string{"purge", "restart"}, retry: "RETRY LAST", wantReply: "Task 'restart' executed successfully."},
+		{name: "per user", previous: []string{"restart"}, others: []string{"purge"}, retry: "retry", wantReply: "Task 'restart' executed successfully."},
+		{name: "retry still honours the cooldown", previous: []string{"rebuild"}, retry: "retry", wantReply: "'rebuild' was last run 0s ago, please wait before running it again.", wantEphemeral: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &Config{DebounceMillis: -1}
+			state := newBotState(config)
+			for _, text := range test.previous {
+				handleMessageEvent(context.Background(), newFakeMessenger(), messageEvent("U1", text), config, store, state)
+			}
+			for _, text := range test.others {
+				handleMessageEvent(context.Background(), newFakeMessenger(), messageEvent("U2", text), config, store, state)
+			}
+
+			messenger := newFakeMessenger()
+			handleMessageEvent(context.Background(), messenger, messageEvent("U1", test.retry), config, store, state)
+			sent := messenger.sent()
+			last := sent[len(sent)-1]
+			if !strings.HasPrefix(last.Text, test.wantReply) || (last.UserID != "") != test.wantEphemeral {
+				t.Errorf("last reply = %+v, want %q (ephemeral %v)", last, test.wantReply, test.wantEphemeral)
+			}
+		})
+	}
+}
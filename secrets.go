@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// SecretResolver looks secrets up for one reference scheme. Vault, AWS
+// Secrets Manager and similar backends plug in with registerSecretResolver.
+type SecretResolver interface {
+	// Resolve returns the secret for the reference without its scheme,
+	// e.g. "/run/secrets/slack" for "file:///run/secrets/slack"
+	Resolve(ref string) (string, error)
+}
+
+// Resolvers by scheme, file:// and env:// built in
+var (
+	secretResolversMu sync.RWMutex
+	secretResolvers   = map[string]SecretResolver{
+		"file": fileSecretResolver{},
+		"env":  envSecretResolver{},
+	}
+)
+
+// Make scheme:// references resolve through resolver
+func registerSecretResolver(scheme string, resolver SecretResolver) {
+	secretResolversMu.Lock()
+	defer secretResolversMu.Unlock()
+	secretResolvers[scheme] = resolver
+}
+
+// Reads the secret from a file, such as a mounted Kubernetes or Docker secret
+type fileSecretResolver struct{}
+
+func (fileSecretResolver) Resolve(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// Reads the secret from an environment variable
+type envSecretResolver struct{}
+
+func (envSecretResolver) Resolve(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// Return the secret a "scheme://ref" value points to. Values without a
+// registered scheme, including plain tokens and https URLs, are returned as is.
+func resolveSecret(value string) (string, error) {
+	scheme, ref, ok := strings.Cut(value, "://")
+	if !ok {
+		return value, nil
+	}
+	secretResolversMu.RLock()
+	resolver, ok := secretResolvers[scheme]
+	secretResolversMu.RUnlock()
+	if !ok {
+		return value, nil
+	}
+	secret, err := resolver.Resolve(ref)
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %w", value, err)
+	}
+	return secret, nil
+}
+
+// Resolve each field in place, stopping at the first error, which names the field
+func resolveSecretFields(fields map[string]*string) error {
+	for name, field := range fields {
+		secret, err := resolveSecret(*field)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		*field = secret
+	}
+	return nil
+}
+
+// Resolve secret references in the bot's own tokens. It runs on every load,
+// so a reload picks up rotated secrets.
+func resolveConfigSecrets(config *Config) error {
+	fields := map[string]*string{
+		"slack_token":                   &config.SlackToken,
+		"admin_token":                   &config.AdminToken,
+		"trigger_token":                 &config.TriggerToken,
+		"slack_signing_secret":          &config.SlackSigningSecret,
+		"completion_webhook_secret":     &config.CompletionWebhookSecret,
+		"jenkins.user":                  &config.Jenkins.User,
+		"jenkins.token":                 &config.Jenkins.Token,
+		"teams.outgoing_webhook_secret": &config.Teams.OutgoingWebhookSecret,
+		"discord.bot_token":             &config.Discord.BotToken,
+	}
+	for teamID, token := range config.SlackTokens {
+		secret, err := resolveSecret(token)
+		if err != nil {
+			return fmt.Errorf("slack_tokens.%s: %w", teamID, err)
+		}
+		config.SlackTokens[teamID] = secret
+	}
+	for teamID, signingSecret := range config.SlackSigningSecrets {
+		secret, err := resolveSecret(signingSecret)
+		if err != nil {
+			return fmt.Errorf("slack_signing_secrets.%s: %w", teamID, err)
+		}
+		config.SlackSigningSecrets[teamID] = secret
+	}
+	for env, creds := range config.Jenkins.Credentials {
+		if err := resolveSecretFields(map[string]*string{"user": &creds.User, "token": &creds.Token}); err != nil {
+			return fmt.Errorf("jenkins.credentials.%s.%w", env, err)
+		}
+		config.Jenkins.Credentials[env] = creds
+	}
+	return resolveSecretFields(fields)
+}
+
+// Resolve secret references in a task's credentials right before it runs, so
+// tasks from task_db are covered and rotated secrets are picked up each run
+func resolveTaskSecrets(task Task) (Task, error) {
+	fields := map[string]*string{
+		"user":                 &task.User,
+		"token":                &task.Token,
+		"signing_secret":       &task.SigningSecret,
+		"on_failure_pagerduty": &task.OnFailurePagerDuty,
+	}
+	if err := resolveSecretFields(fields); err != nil {
+		return task, err
+	}
+	if task.GitHubWorkflow != nil {
+		workflow := *task.GitHubWorkflow
+		if err := resolveSecretFields(map[string]*string{"github_workflow.token": &workflow.Token}); err != nil {
+			return task, err
+		}
+		task.GitHubWorkflow = &workflow
+	}
+	if len(task.Headers) > 0 {
+		headers := make(map[string]string, len(task.Headers))
+		for key, value := range task.Headers {
+			secret, err := resolveSecret(value)
+			if err != nil {
+				return task, fmt.Errorf("headers.%s: %w", key, err)
+			}
+			headers[key] = secret
+		}
+		task.Headers = headers
+	}
+	if task.Precondition != nil && len(task.Precondition.Headers) > 0 {
+		pre := *task.Precondition
+		pre.Headers = make(map[string]string, len(task.Precondition.Headers))
+		for key, value := range task.Precondition.Headers {
+			secret, err := resolveSecret(value)
+			if err != nil {
+				return task, fmt.Errorf("precondition.headers.%s: %w", key, err)
+			}
+			pre.Headers[key] = secret
+		}
+		task.Precondition = &pre
+	}
+	if len(task.Steps) > 0 {
+		steps := make([]Task, len(task.Steps))
+		for i, step := range task.Steps {
+			resolved, err := resolveTaskSecrets(step)
+			if err != nil {
+				return task, fmt.Errorf("step %d: %w", i+1, err)
+			}
+			steps[i] = resolved
+		}
+		task.Steps = steps
+	}
+	return task, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timeout for each self-test probe
+const selfTestTimeout = 5 * time.Second
+
+// Result of probing one task's host
+type selfTestResult struct {
+	Command string
+	Host    string
+	Err     error
+}
+
+// Probe the host of every task with a HEAD request to its root, without
+// calling the task URL itself. Any HTTP response counts as reachable.
+func runSelfTest(ctx context.Context, config *Config, tasks map[string]Task) []selfTestResult {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results []selfTestResult
+	)
+
+	for command, task := range tasks {
+		if task.SkipSelfTest {
+			continue
+		}
+		// Chains are probed through their steps, multi-target tasks through each URL and
+		// workflow tasks through the GitHub API host
+		targets := []Task{task}
+		if task.GitHubWorkflow != nil {
+			single := task
+			single.URL = task.GitHubWorkflow.APIURL
+			if single.URL == "" {
+				single.URL = defaultGitHubAPIURL
+			}
+			targets = []Task{single}
+		} else if len(task.Steps) > 0 {
+			targets = task.Steps
+		} else if len(task.URLs) > 0 {
+			targets = nil
+			for _, target := range task.URLs {
+				single := task
+				single.URL = target
+				targets = append(targets, single)
+			}
+		}
+		for _, target := range targets {
+			wg.Add(1)
+			go func(command string, target Task) {
+				defer wg.Done()
+				host, err := probeTaskHost(ctx, config, target)
+				mu.Lock()
+				results = append(results, selfTestResult{Command: command, Host: host, Err: err})
+				mu.Unlock()
+			}(command, target)
+		}
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Command != results[j].Command {
+			return results[i].Command < results[j].Command
+		}
+		return results[i].Host < results[j].Host
+	})
+	return results
+}
+
+func probeTaskHost(ctx context.Context, config *Config, task Task) (string, error) {
+	taskURL, err := expandEnvRefs(task.URL, config.StrictEnv)
+	if err != nil {
+		return task.URL, err
+	}
+	target, err := url.Parse(taskURL)
+	if err != nil {
+		return task.URL, err
+	}
+	root := (&url.URL{Scheme: target.Scheme, Host: target.Host, Path: "/"}).String()
+
+	if err := checkTargetAllowed(ctx, config, root); err != nil {
+		return target.Host, err
+	}
+
+	ctx, cancel := context.WithTimeout(withTargetGuard(ctx, config), selfTestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "HEAD", root, nil)
+	if err != nil {
+		return target.Host, err
+	}
+	if task.User != "" && task.Token != "" {
+		req.SetBasicAuth(task.User, task.Token)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return target.Host, err
+	}
+	resp.Body.Close()
+	return target.Host, nil
+}
+
+// Format the self-test summary posted to Slack
+func formatSelfTest(config *Config, userID string, results []selfTestResult) string {
+	if len(results) == 0 {
+		return localize(config, userID, "selftest_none")
+	}
+
+	var b strings.Builder
+	up := 0
+	for _, result := range results {
+		if result.Err == nil {
+			up++
+			b.WriteString(localize(config, userID, "selftest_up", "command", result.Command, "host", result.Host) + "\n")
+		} else {
+			log.Printf("Self-test failed for '%s' at %s: %v", result.Command, result.Host, result.Err)
+			b.WriteString(localize(config, userID, "selftest_down", "command", result.Command, "host", result.Host) + "\n")
+		}
+	}
+	return localize(config, userID, "selftest_summary", "up", strconv.Itoa(up), "total", strconv.Itoa(len(results))) + "\n" + b.String()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSelfTestCommand(t *testing.T) {
+	var mu sync.Mutex
+	var probes []string
+	reachable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, token, _ := r.BasicAuth()
+		mu.Lock()
+		probes = append(probes, fmt.Sprintf("%s %s %s:%s", r.Method, r.URL.Path, user, token))
+		mu.Unlock()
+		w.WriteHeader(http.StatusNotFound) // any answer means the host is up
+	}))
+	defer reachable.Close()
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	up := strings.TrimPrefix(reachable.URL, "http://")
+	down := strings.TrimPrefix(unreachable.URL, "http://")
+	store := newConfigTaskStore(map[string]Task{
+		"restart": {Command: "restart", URL: reachable.URL + "/api/restart", Method: "POST", User: "bot", Token: "secret"},
+		"purge":   {Command: "purge", URL: unreachable.URL + "/purge", Method: "POST"},
+		"release": {Command: "release", Steps: []Task{{URL: reachable.URL + "/build"}, {URL: unreachable.URL + "/deploy"}}},
+		"legacy":  {Command: "legacy", URL: unreachable.URL + "/legacy", SkipSelfTest: true},
+	})
+	config := &Config{}
+	messenger := newFakeMessenger()
+
+	handleMessageEvent(context.Background(), messenger, messageEvent("U1", "selftest"), config, store, newBotState(config))
+
+	replies := messenger.texts()
+	if len(replies) != 1 {
+		t.Fatalf("replies = %q, want one summary", replies)
+	}
+	lines := strings.Split(strings.TrimSuffix(replies[0], "\n"), "\n")
+	want := []string{
+		"Self-test: 2/4 endpoints reachable",
+		":x: purge (" + down + ") down",
+		":white_check_mark: release (" + up + ") up",
+		":x: release (" + down + ") down",
+		":white_check_mark: restart (" + up + ") up",
+	}
+	if len(lines) != len(want) || lines[0] != want[0] {
+		t.Fatalf("summary = %q, want %q", lines, want)
+	}
+	for _, line := range want[1:] {
+		if !strings.Contains(replies[0], line+"\n") {
+			t.Errorf("summary %q missing %q", replies[0], line)
+		}
+	}
+
+	// Only the host root is probed, never the task URL, and task auth is sent
+	mu.Lock()
+	defer mu.Unlock()
+	got := strings.Join(probes, ",")
+	if !strings.Contains(got, "HEAD / bot:secret") || strings.Contains(got, "restart") || strings.Contains(got, "POST") {
+		t.Errorf("probes = %q, want HEAD requests to / with the task's credentials", probes)
+	}
+}
+
+func TestFormatSelfTestEmpty(t *testing.T) {
+	if got := formatSelfTest(&Config{}, "U1", runSelfTest(context.Background(), &Config{}, map[string]Task{"legacy": {SkipSelfTest: true}})); got != "No tasks to self-test." {
+		t.Errorf("formatSelfTest = %q", got)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Handle the "config" admin command: show the effective settings without
+// any token, password or secret, ephemerally
+func handleConfigCommand(messenger Messenger, msg incomingMessage, config *Config, store TaskStore, state *botState) {
+	response := localize(config, msg.UserID, "not_admin_config")
+	if isAdminUser(config, msg.UserID) {
+		response = formatEffectiveConfig(config, store, state)
+	}
+	if err := messenger.PostEphemeral(msg.ChannelID, msg.UserID, response); err != nil {
+		log.Printf("Error sending message to Slack: %v", err)
+	}
+}
+
+// Render the non-secret settings. Only names, counts and on/off flags are
+// shown, never the values of tokens or secrets.
+func formatEffectiveConfig(config *Config, store TaskStore, state *botState) string {
+	tasks, err := store.ListTasks()
+	if err != nil {
+		log.Printf("Error listing tasks: %v", err)
+	}
+	backend := config.Backend
+	if backend == "" {
+		backend = "slack"
+	}
+	logLevel := config.LogLevel
+	if logLevel == "" {
+		logLevel = "info"
+	}
+	basePath := normalizeBasePath(config.BasePath)
+	if basePath == "" {
+		basePath = "/"
+	}
+
+	var b strings.Builder
+	b.WriteString("Effective configuration:\n")
+	fmt.Fprintf(&b, "- Version: %s\n", version)
+	fmt.Fprintf(&b, "- Mode: %s\n", backend)
+	fmt.Fprintf(&b, "- Port: 8081, base path %s\n", basePath)
+	fmt.Fprintf(&b, "- Log level: %s\n", logLevel)
+	fmt.Fprintf(&b, "- Tasks: %d (store: %s)\n", len(tasks), taskStoreName(config))
+	fmt.Fprintf(&b, "- Jenkins: %s\n", jenkinsSummary(config.Jenkins))
+	fmt.Fprintf(&b, "- Slack workspaces: %d\n", 1+len(config.SlackTokens))
+	fmt.Fprintf(&b, "- Paused: %t\n", state.paused.Load())
+
+	flags := []struct {
+		name    string
+		enabled bool
+	}{
+		{"admin_api", config.AdminToken != ""},
+		{"http_trigger", config.TriggerToken != ""},
+		{"slack_interactions", config.SlackSigningSecret != ""},
+		{"ssrf_guard", config.SSRFGuard},
+		{"strict_env", config.StrictEnv},
+		{"mentions_only", config.MentionsOnly},
+		{"handle_edits", config.HandleEdits},
+		{"rich_replies", config.RichReplies},
+		{"unfurl_links", config.UnfurlLinks},
+		{"delete_trigger_message", config.DeleteTriggerMessage},
+		{"update_running_message", config.UpdateRunningMessage},
+		{"wait_for_result", config.Jenkins.WaitForResult},
+		{"notify_channel", config.NotifyChannel != ""},
+		{"heartbeat", config.HeartbeatMinutes > 0 && config.NotifyChannel != ""},
+		{"failure_escalation", len(config.FailureEscalationThresholds) > 0},
+		{"slack_retry", slackRetryAge(config) > 0},
+	}
+	var on, off []string
+	for _, flag := range flags {
+		if flag.enabled {
+			on = append(on, flag.name)
+		} else {
+			off = append(off, flag.name)
+		}
+	}
+	fmt.Fprintf(&b, "- Enabled: %s\n", listOrNone(on))
+	fmt.Fprintf(&b, "- Disabled: %s\n", listOrNone(off))
+	return b.String()
+}
+
+// Jenkins host and the envs with their own credentials, by name only
+func jenkinsSummary(jenkins JenkinsConfig) string {
+	if jenkins.URLFormat == "" {
+		return "not configured"
+	}
+	summary := targetHost(jenkins.URLFormat)
+	if len(jenkins.Credentials) > 0 {
+		envs := make([]string, 0, len(jenkins.Credentials))
+		for env := range jenkins.Credentials {
+			envs = append(envs, env)
+		}
+		sort.Strings(envs)
+		summary += fmt.Sprintf(" (credentials for %s)", strings.Join(envs, ", "))
+	}
+	return summary
+}
+
+func taskStoreName(config *Config) string {
+	if config.TaskDB != "" {
+		return "sqlite"
+	}
+	return "config"
+}
+
+func listOrNone(items []string) string {
+	if len(items) == 0 {
+		return "none"
+	}
+	return strings.Join(items, ", ")
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// Slack accepts up to 5 delayed responses per response_url, for 30 minutes
+const (
+	maxDelayedResponses = 5
+	responseURLLifetime = 30 * time.Minute
+)
+
+// Register POST /slack/commands for a slash command such as "/bot deploy api prod"
+func registerSlashCommandRoutes(ctx context.Context, mux *http.ServeMux, config *Config, store TaskStore, state *botState) {
+	if config.SlackSigningSecret == "" {
+		return
+	}
+	mux.Handle("/slack/commands", slashCommandHandler(ctx, store, state))
+}
+
+// Acknowledge the slash command within Slack's 3 second window, then run it
+// and deliver the replies to its response_url
+func slashCommandHandler(ctx context.Context, store TaskStore, state *botState) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			log.Printf("Error reading request body: %v", err)
+			http.Error(w, "Can't read body", http.StatusBadRequest)
+			return
+		}
+		if err := verifySlackRequest(state.config.Load(), r.Header, body); err != nil {
+			log.Printf("Rejected slash command from %s: %v", r.RemoteAddr, err)
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		slash, err := slack.SlashCommandParse(r)
+		if err != nil {
+			http.Error(w, "Can't parse form", http.StatusBadRequest)
+			return
+		}
+
+		config := state.config.Load()
+		command := strings.TrimSpace(slash.Text)
+		if command == "" {
+			writeJSON(w, http.StatusOK, slack.Msg{ResponseType: slack.ResponseTypeEphemeral, Text: "Use: " + slash.Command + " <command>"})
+			return
+		}
+		log.Printf("Command '%s' received as %s from %s", command, slash.Command, slash.UserID)
+		if !state.debounce.Allow(incomingMessage{Text: command, ChannelID: slash.ChannelID, UserID: slash.UserID}, debounceWindow(config)) {
+			log.Printf("Ignoring repeated slash command from %s: %s", slash.UserID, command)
+			http.Error(w, "Repeated command", http.StatusTooManyRequests)
+			return
+		}
+		writeJSON(w, http.StatusOK, slack.Msg{ResponseType: slack.ResponseTypeEphemeral, Text: localize(config, slash.UserID, "slash_ack", "command", command)})
+
+		go func() {
+			defer state.recoverPanic("a slash command")
+			runCtx, cancel := context.WithTimeout(ctx, responseURLLifetime)
+			defer cancel()
+			meta := CommandMeta{UserID: slash.UserID, ChannelID: slash.ChannelID}
+			result, _ := newDispatcher(config, store, state).Dispatch(runCtx, command, meta)
+			postDelayedResponses(runCtx, slash.ResponseURL, slashResponseType(store, command), result.Replies)
+		}()
+	})
+}
+
+// The response_type the command's task asks for, ephemeral unless it says in_channel
+func slashResponseType(store TaskStore, command string) string {
+	name := strings.ToLower(command)
+	if fields := strings.Fields(name); len(fields) > 0 {
+		if _, exists, _ := store.GetTask(name); !exists {
+			name = fields[0]
+		}
+	}
+	if task, exists, _ := store.GetTask(name); exists && task.ResponseType == slack.ResponseTypeInChannel {
+		return slack.ResponseTypeInChannel
+	}
+	return slack.ResponseTypeEphemeral
+}
+
+// POST the replies to the response_url, folding any beyond Slack's limit into
+// the last response. Rejections meant only for the user stay ephemeral.
+func postDelayedResponses(ctx context.Context, responseURL, responseType string, replies []Reply) {
+	if len(replies) > maxDelayedResponses {
+		var tail []string
+		for _, reply := range replies[maxDelayedResponses-1:] {
+			tail = append(tail, reply.Text)
+		}
+		last := Reply{Text: strings.Join(tail, "\n"), Ephemeral: replies[len(replies)-1].Ephemeral}
+		replies = append(replies[:maxDelayedResponses-1:maxDelayedResponses-1], last)
+	}
+	for _, reply := range replies {
+		msg := &slack.WebhookMessage{Text: reply.Text, ResponseType: responseType}
+		if reply.Ephemeral {
+			msg.ResponseType = slack.ResponseTypeEphemeral
+		}
+		if err := slack.PostWebhookCustomHTTPContext(ctx, responseURL, httpClient, msg); err != nil {
+			log.Printf("Error sending message to Slack: %v", err)
+		}
+	}
+}
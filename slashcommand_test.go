@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// A response_url receiver handing each delayed response to the test
+func newResponseURLServer(t *testing.T) (*httptest.Server, chan slack.WebhookMessage) {
+	t.Helper()
+	received := make(chan slack.WebhookMessage, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg slack.WebhookMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("decoding delayed response: %v", err)
+		}
+		received <- msg
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+// Tasks opt into in_channel replies, whether or not they take arguments
+func TestSlashResponseType(t *testing.T) {
+	store := newConfigTaskStore(map[string]Task{
+		"status":      {ResponseType: "in_channel"},
+		"restart":     {ResponseType: "in_channel", Args: []ArgSpec{{Name: "service"}}},
+		"health":      {},
+		"status page": {},
+	})
+
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{command: "status", want: "in_channel"},
+		{command: "STATUS", want: "in_channel"},
+		{command: "restart api", want: "in_channel"},
+		{command: "status page", want: "ephemeral"},
+		{command: "health", want: "ephemeral"},
+		{command: "unknown", want: "ephemeral"},
+	}
+
+	for _, test := range tests {
+		if got := slashResponseType(store, test.command); got != test.want {
+			t.Errorf("slashResponseType(%q) = %q, want %q", test.command, got, test.want)
+		}
+	}
+}
+
+// Replies beyond the response_url limit are folded into the last one
+func TestPostDelayedResponses(t *testing.T) {
+	replies := func(n int) []Reply {
+		var out []Reply
+		for i := 1; i <= n; i++ {
+			out = append(out, Reply{Text: "reply " + strconv.Itoa(i)})
+		}
+		return out
+	}
+
+	tests := []struct {
+		name         string
+		responseType string
+		replies      []Reply
+		want         []slack.WebhookMessage
+	}{
+		{
+			name:         "in channel",
+			responseType: "in_channel",
+			replies:      []Reply{{Text: "Running"}, {Text: "Done"}},
+			want:         []slack.WebhookMessage{{Text: "Running", ResponseType: "in_channel"}, {Text: "Done", ResponseType: "in_channel"}},
+		},
+		{
+			name:         "rejections stay ephemeral",
+			responseType: "in_channel",
+			replies:      []Reply{{Text: "You are not allowed", Ephemeral: true}},
+			want:         []slack.WebhookMessage{{Text: "You are not allowed", ResponseType: "ephemeral"}},
+		},
+		{
+			name:         "folded into the last allowed response",
+			responseType: "ephemeral",
+			replies:      replies(7),
+			want: []slack.WebhookMessage{
+				{Text: "reply 1", ResponseType: "ephemeral"},
+				{Text: "reply 2", ResponseType: "ephemeral"},
+				{Text: "reply 3", ResponseType: "ephemeral"},
+				{Text: "reply 4", ResponseType: "ephemeral"},
+				{Text: "reply 5\nreply 6\nreply 7", ResponseType: "ephemeral"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, received := newResponseURLServer(t)
+			postDelayedResponses(context.Background(), server.URL, test.responseType, test.replies)
+			close(received)
+			var got []slack.WebhookMessage
+			for msg := range received {
+				got = append(got, msg)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("responses = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+// Signed commands are acknowledged at once and answered through response_url
+func TestSlashCommandHandler(t *testing.T) {
+	target, _ := newStubTarget(t)
+	responseURL, received := newResponseURLServer(t)
+	config := &Config{
+		SlackSigningSecret: testSigningSecret,
+		AckReaction:        "none",
+		DebounceMillis:     -1,
+		Tasks:              map[string]Task{"health": {Command: "health", URL: target.URL + "/ok", Method: "GET", ResponseType: "in_channel"}},
+	}
+	mux := http.NewServeMux()
+	registerSlashCommandRoutes(context.Background(), mux, config, newConfigTaskStore(config.Tasks), newBotState(config))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		text        string
+		secret      string
+		wantStatus  int
+		wantAck     string
+		wantReplies []string // Prefixes of the delayed responses
+	}{
+		{name: "unsigned", text: "health", wantStatus: http.StatusUnauthorized},
+		{name: "no command", text: " ", secret: testSigningSecret, wantStatus: http.StatusOK, wantAck: "Use: /bot <command>"},
+		{
+			name: "command run", text: "health", secret: testSigningSecret, wantStatus: http.StatusOK,
+			wantAck:     "Working on 'health'...",
+			wantReplies: []string{"Running 'health'", "Task 'health' executed successfully."},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			form := url.Values{
+				"command":      {"/bot"},
+				"text":         {test.text},
+				"user_id":      {"U1"},
+				"channel_id":   {"C1"},
+				"response_url": {responseURL.URL},
+			}.Encode()
+			req, err := http.NewRequest("POST", server.URL+"/slack/commands", strings.NewReader(form))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if test.secret != "" {
+				signSlackRequest(req, form, test.secret, time.Now())
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != test.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, test.wantStatus)
+			}
+			if test.wantAck != "" {
+				var ack slack.Msg
+				if err := json.NewDecoder(resp.Body).Decode(&ack); err != nil {
+					t.Fatal(err)
+				}
+				if ack.Text != test.wantAck || ack.ResponseType != "ephemeral" {
+					t.Errorf("ack = %q (%s), want %q (ephemeral)", ack.Text, ack.ResponseType, test.wantAck)
+				}
+			}
+
+			for _, want := range test.wantReplies {
+				select {
+				case msg := <-received:
+					if !strings.HasPrefix(msg.Text, want) || msg.ResponseType != "in_channel" {
+						t.Errorf("delayed response = %+v, want %q in_channel", msg, want)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatalf("no delayed response %q", want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// Most redirects an outbound request follows
+const maxRedirects = 10
+
+// targetGuard blocks loopback, link-local (including the cloud metadata
+// endpoint 169.254.169.254) and private addresses, except the hosts, IPs and
+// CIDR ranges in allow_private_targets
+type targetGuard struct {
+	allow []string
+}
+
+type targetGuardKey struct{}
+
+// The guard for the config, nil when ssrf_guard is off
+func newTargetGuard(config *Config) *targetGuard {
+	if !config.SSRFGuard {
+		return nil
+	}
+	return &targetGuard{allow: config.AllowPrivateTargets}
+}
+
+// Check that a task URL doesn't point at a blocked address. This gives a
+// clear error up front; the dialer and redirects enforce the same rules for
+// requests made with withTargetGuard, so DNS rebinding or a redirect to a
+// metadata address doesn't get past it.
+func checkTargetAllowed(ctx context.Context, config *Config, rawURL string) error {
+	return newTargetGuard(config).check(ctx, rawURL)
+}
+
+func (g *targetGuard) check(ctx context.Context, rawURL string) error {
+	if g == nil {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	host := u.Hostname()
+	if isAllowlistedHost(g.allow, host) {
+		return nil
+	}
+
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := targetResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return fmt.Errorf("resolving %s: %w", host, err)
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+
+	for _, ip := range ips {
+		if err := g.checkIP(host, ip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *targetGuard) checkIP(host string, ip net.IP) error {
+	if isBlockedIP(ip) && !isAllowlistedIP(g.allow, ip) {
+		return fmt.Errorf("target %s resolves to blocked address %s", host, ip)
+	}
+	return nil
+}
+
+// Make outbound requests with ctx go through the config's SSRF guard, at
+// every connection and redirect
+func withTargetGuard(ctx context.Context, config *Config) context.Context {
+	return contextWithGuard(ctx, newTargetGuard(config))
+}
+
+func contextWithGuard(ctx context.Context, guard *targetGuard) context.Context {
+	if guard == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, targetGuardKey{}, guard)
+}
+
+func guardFromContext(ctx context.Context) *targetGuard {
+	guard, _ := ctx.Value(targetGuardKey{}).(*targetGuard)
+	return guard
+}
+
+// Dial for the shared transport. Hosts in allow_private_targets skip the
+// address check; through a proxy the proxy's address is the one checked.
+func dialTarget(ctx context.Context, network, address string) (net.Conn, error) {
+	if guard := guardFromContext(ctx); guard != nil {
+		if host, _, err := net.SplitHostPort(address); err == nil && isAllowlistedHost(guard.allow, host) {
+			ctx = context.WithValue(ctx, targetGuardKey{}, (*targetGuard)(nil))
+		}
+	}
+	return targetDialer.DialContext(ctx, network, address)
+}
+
+// Refuse the connection when the address it was resolved to is blocked,
+// after DNS, so the name can't be re-pointed between the check and the dial
+func guardDialControl(ctx context.Context, network, address string, _ syscall.RawConn) error {
+	guard := guardFromContext(ctx)
+	if guard == nil {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("dialing unresolved address %s", address)
+	}
+	return guard.checkIP(host, ip)
+}
+
+// Follow redirects only to allowed targets
+func guardRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errors.New("stopped after 10 redirects")
+	}
+	if err := guardFromContext(req.Context()).check(req.Context(), req.URL.String()); err != nil {
+		return fmt.Errorf("redirect blocked: %w", err)
+	}
+	return nil
+}
+
+func isBlockedIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsPrivate() || ip.IsUnspecified()
+}
+
+func isAllowlistedHost(allowlist []string, host string) bool {
+	for _, entry := range allowlist {
+		if strings.EqualFold(entry, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func isAllowlistedIP(allowlist []string, ip net.IP) bool {
+	for _, entry := range allowlist {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if allowed := net.ParseIP(entry); allowed != nil && allowed.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// Loopback, link-local and private ranges are blocked, including IPv4-mapped IPv6
+func TestIsBlockedIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "127.0.0.1", want: true},
+		{ip: "::1", want: true},
+		{ip: "10.1.2.3", want: true},
+		{ip: "172.16.0.1", want: true},
+		{ip: "192.168.1.1", want: true},
+		{ip: "169.254.169.254", want: true},
+		{ip: "fe80::1", want: true},
+		{ip: "fd00::1", want: true},
+		{ip: "0.0.0.0", want: true},
+		{ip: "::ffff:127.0.0.1", want: true},
+		{ip: "8.8.8.8", want: false},
+		{ip: "172.32.0.1", want: false},
+		{ip: "2001:4860:4860::8888", want: false},
+	}
+
+	for _, test := range tests {
+		if got := isBlockedIP(net.ParseIP(test.ip)); got != test.want {
+			t.Errorf("isBlockedIP(%s) = %v, want %v", test.ip, got, test.want)
+		}
+	}
+}
+
+func TestCheckTargetAllowed(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		url       string
+		wantError bool
+	}{
+		{name: "metadata IP", config: Config{SSRFGuard: true}, url: "http://169.254.169.254/latest/meta-data/", wantError: true},
+		{name: "loopback", config: Config{SSRFGuard: true}, url: "http://127.0.0.1:8080/admin", wantError: true},
+		{name: "IPv6 loopback", config: Config{SSRFGuard: true}, url: "http://[::1]/", wantError: true},
+		{name: "private range", config: Config{SSRFGuard: true}, url: "https://10.0.3.7/restart", wantError: true},
+		{name: "unspecified", config: Config{SSRFGuard: true}, url: "http://0.0.0.0/", wantError: true},
+		{name: "public IP", config: Config{SSRFGuard: true}, url: "https://93.184.216.34/health"},
+		{name: "guard off", config: Config{}, url: "http://169.254.169.254/latest/meta-data/"},
+		{name: "allowlisted internal host", config: Config{SSRFGuard: true, AllowPrivateTargets: []string{"jenkins.internal"}}, url: "https://Jenkins.Internal/job/api"},
+		{name: "allowlisted IP", config: Config{SSRFGuard: true, AllowPrivateTargets: []string{"10.0.3.7"}}, url: "https://10.0.3.7/restart"},
+		{name: "allowlisted CIDR", config: Config{SSRFGuard: true, AllowPrivateTargets: []string{"10.0.0.0/16"}}, url: "https://10.0.3.7/restart"},
+		{name: "outside allowlisted CIDR", config: Config{SSRFGuard: true, AllowPrivateTargets: []string{"10.1.0.0/16"}}, url: "https://10.0.3.7/restart", wantError: true},
+		{name: "allowlist does not cover metadata", config: Config{SSRFGuard: true, AllowPrivateTargets: []string{"10.0.0.0/8"}}, url: "http://169.254.169.254/", wantError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkTargetAllowed(context.Background(), &test.config, test.url)
+			if (err != nil) != test.wantError {
+				t.Errorf("checkTargetAllowed(%s) = %v, want error %v", test.url, err, test.wantError)
+			}
+		})
+	}
+}
+
+// Both execute functions refuse blocked targets before sending anything
+func TestExecuteBlockedByGuard(t *testing.T) {
+	target, hits := newStubTarget(t) // listens on 127.0.0.1
+	guarded := &Config{SSRFGuard: true}
+	allowlisted := &Config{SSRFGuard: true, AllowPrivateTargets: []string{"127.0.0.1"}}
+
+	if executeTask(context.Background(), guarded, Task{Command: "health", URL: target.URL + "/ok", Method: "GET"}).Success {
+		t.Error("task to a loopback target succeeded with the guard on")
+	}
+	if executeJenkinsJob(context.Background(), guarded, target.URL+"/ok", "ci", "token").Success {
+		t.Error("Jenkins job to a loopback target succeeded with the guard on")
+	}
+	if len(hits()) != 0 {
+		t.Fatalf("blocked targets were called: %v", hits())
+	}
+
+	if !executeTask(context.Background(), allowlisted, Task{Command: "health", URL: target.URL + "/ok", Method: "GET"}).Success {
+		t.Error("task to an allowlisted target failed")
+	}
+	if !executeJenkinsJob(context.Background(), allowlisted, target.URL+"/ok", "ci", "token").Success {
+		t.Error("Jenkins job to an allowlisted target failed")
+	}
+}
+
+// The dialer enforces the guard itself, so a URL that wasn't checked up front
+// (or a name re-pointed after the check) still can't reach a blocked address
+func TestGuardBlocksAtDialTime(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	tests := []struct {
+		name    string
+		allow   []string
+		url     string
+		wantErr bool
+	}{
+		{name: "loopback blocked", url: server.URL, wantErr: true},
+		{name: "loopback by name blocked", url: "http://localhost:" + serverURL.Port(), wantErr: true},
+		{name: "allowlisted IP", allow: []string{"127.0.0.1"}, url: server.URL},
+		{name: "allowlisted host", allow: []string{"localhost"}, url: "http://localhost:" + serverURL.Port()},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := withTargetGuard(context.Background(), &Config{SSRFGuard: true, AllowPrivateTargets: test.allow})
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, test.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := httpClient.Do(req)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if (err != nil) != test.wantErr {
+				t.Errorf("GET %s error = %v, wantErr %v", test.url, err, test.wantErr)
+			}
+		})
+	}
+}
+
+// Redirects are followed only to allowed targets, and only so many times
+func TestGuardRedirect(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		via     int
+		guard   bool
+		wantErr bool
+	}{
+		{name: "public target", url: "http://8.8.8.8/next", guard: true},
+		{name: "metadata target", url: "http://169.254.169.254/", guard: true, wantErr: true},
+		{name: "guard off", url: "http://169.254.169.254/"},
+		{name: "too many redirects", url: "http://8.8.8.8/next", via: maxRedirects, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := withTargetGuard(context.Background(), &Config{SSRFGuard: test.guard})
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, test.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			via := make([]*http.Request, test.via)
+			if err := guardRedirect(req, via); (err != nil) != test.wantErr {
+				t.Errorf("guardRedirect(%s) error = %v, wantErr %v", test.url, err, test.wantErr)
+			}
+		})
+	}
+}
+
+// An allowed target can't redirect the task to the metadata endpoint
+func TestExecuteTaskRedirectBlocked(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	}))
+	defer target.Close()
+	config := &Config{SSRFGuard: true, AllowPrivateTargets: []string{"127.0.0.1"}}
+
+	if executeTask(context.Background(), config, Task{Command: "health", URL: target.URL, Method: "GET"}).Success {
+		t.Error("task followed a redirect to a blocked address")
+	}
+}
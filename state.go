@@ -0,0 +1,62 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// botState holds the in-memory runtime state shared across event handlers
+type botState struct {
+	history    *executionHistory
+	cooldowns  *cooldownTracker
+	running    *concurrencyLimiter
+	resources  *resourceQueue // Runs tasks sharing a resource_key one at a time
+	executions *executionRegistry
+	stats      *executionStats
+	paused     atomic.Bool // Maintenance mode: commands are acknowledged but not executed
+
+	notifier      Messenger // Backend used for operational notifications
+	notifyChannel string
+
+	lastCommands *lastCommands // Last runnable command per user, for retry
+	debounce     *debouncer
+	failures     *failureStreaks
+
+	config     atomic.Pointer[Config] // Current configuration, swapped by reload
+	configPath string
+}
+
+func newBotState(config *Config) *botState {
+	state := &botState{
+		history:    newExecutionHistory(config.HistorySize),
+		cooldowns:  newCooldownTracker(),
+		running:    newConcurrencyLimiter(),
+		resources:  newResourceQueue(),
+		executions: newExecutionRegistry(),
+		stats:      newExecutionStats(),
+
+		notifyChannel: config.NotifyChannel,
+
+		lastCommands: newLastCommands(),
+		debounce:     newDebouncer(),
+		failures:     newFailureStreaks(),
+	}
+	state.paused.Store(config.Paused)
+	state.config.Store(config)
+	return state
+}
+
+// Record a finished execution in the history and stats and notify the completion
+// webhook. Returns the command's consecutive failure count.
+func (s *botState) recordExecution(config *Config, command, user string, success bool, duration time.Duration) int {
+	s.stats.Add(command, success)
+	s.history.Add(historyEntry{
+		Command:  command,
+		User:     user,
+		Success:  success,
+		Time:     time.Now(),
+		Duration: duration,
+	})
+	go notifyCompletion(config.CompletionWebhook, config.CompletionWebhookSecret, command, user, success, duration)
+	return s.failures.Record(command, success)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Number of commands listed in the stats reply
+const statsTopCommands = 5
+
+// Success and failure counts for one command
+type commandCounts struct {
+	Success int
+	Failure int
+}
+
+// executionStats counts executions per command since startup
+type executionStats struct {
+	mu       sync.Mutex
+	started  time.Time
+	commands map[string]*commandCounts
+}
+
+func newExecutionStats() *executionStats {
+	return &executionStats{started: time.Now(), commands: make(map[string]*commandCounts)}
+}
+
+// Count a finished execution
+func (s *executionStats) Add(command string, success bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts, ok := s.commands[command]
+	if !ok {
+		counts = &commandCounts{}
+		s.commands[command] = counts
+	}
+	if success {
+		counts.Success++
+	} else {
+		counts.Failure++
+	}
+}
+
+// Return a copy of the counters and the time counting started
+func (s *executionStats) Snapshot() (map[string]commandCounts, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	commands := make(map[string]commandCounts, len(s.commands))
+	for command, counts := range s.commands {
+		commands[command] = *counts
+	}
+	return commands, s.started
+}
+
+// Format the stats reply: totals, success rate and the most run commands
+func formatStats(config *Config, userID string, commands map[string]commandCounts, started time.Time) string {
+	var success, failure int
+	names := make([]string, 0, len(commands))
+	for command, counts := range commands {
+		success += counts.Success
+		failure += counts.Failure
+		names = append(names, command)
+	}
+
+	since := time.Since(started).Round(time.Minute)
+	total := success + failure
+	if total == 0 {
+		return localize(config, userID, "stats_none", "since", since.String())
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		a, b := commands[names[i]], commands[names[j]]
+		if a.Success+a.Failure != b.Success+b.Failure {
+			return a.Success+a.Failure > b.Success+b.Failure
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > statsTopCommands {
+		names = names[:statsTopCommands]
+	}
+
+	var b strings.Builder
+	b.WriteString(localize(config, userID, "stats_summary", "since", since.String(), "total", strconv.Itoa(total),
+		"succeeded", strconv.Itoa(success), "failed", strconv.Itoa(failure), "rate", fmt.Sprintf("%.0f", 100*float64(success)/float64(total))) + "\n")
+	b.WriteString(localize(config, userID, "stats_top") + "\n")
+	for _, command := range names {
+		counts := commands[command]
+		fmt.Fprintf(&b, "- %s: %d runs, %d failed\n", command, counts.Success+counts.Failure, counts.Failure)
+	}
+	return b.String()
+}
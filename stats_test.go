@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatStats(t *testing.T) {
+	started := time.Now().Add(-2 * time.Hour)
+	tests := []struct {
+		name     string
+		commands map[string]commandCounts
+		want     string
+	}{
+		{name: "nothing run", want: "No commands run in the last 2h0m0s."},
+		{
+			name:     "totals and rate",
+			commands: map[string]commandCounts{"health": {Success: 3, Failure: 1}},
+			want:     "Commands run in the last 2h0m0s: 4 (3 succeeded, 1 failed, 75% success rate)\nTop commands:\n- health: 4 runs, 1 failed\n",
+		},
+		{
+			name:     "most run first, ties by name, top five only",
+			commands: map[string]commandCounts{"b": {Success: 1}, "a": {Failure: 1}, "c": {Success: 5}, "d": {Success: 2}, "e": {Success: 2}, "f": {Success: 1}},
+			want:     "Commands run in the last 2h0m0s: 12 (11 succeeded, 1 failed, 92% success rate)\nTop commands:\n- c: 5 runs, 0 failed\n- d: 2 runs, 0 failed\n- e: 2 runs, 0 failed\n- a: 1 runs, 1 failed\n- b: 1 runs, 0 failed\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := formatStats(&Config{}, "U1", test.commands, started); got != test.want {
+				t.Errorf("formatStats =\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+// Executions handled by the bot are counted and reported by the stats command
+func TestStatsCommand(t *testing.T) {
+	target, _ := newStubTarget(t)
+	config := &Config{StatsAllowedUsers: []string{"UOPS"}, DebounceMillis: -1}
+	store := newConfigTaskStore(map[string]Task{
+		"restart": {Command: "restart", URL: target.URL + "/ok", Method: "POST"},
+		"purge":   {Command: "purge", URL: target.URL + "/fail", Method: "POST"},
+	})
+	state := newBotState(config)
+	for _, text := range []string{"restart", "restart", "purge"} {
+		handleMessageEvent(context.Background(), newFakeMessenger(), messageEvent("U1", text), config, store, state)
+	}
+
+	messenger := newFakeMessenger()
+	handleMessageEvent(context.Background(), messenger, messageEvent("UOPS", "stats"), config, store, state)
+	want := "Commands run in the last 0s: 3 (2 succeeded, 1 failed, 67% success rate)\nTop commands:\n- restart: 2 runs, 0 failed\n- purge: 1 runs, 1 failed\n"
+	if got := messenger.results(); len(got) != 1 || got[0] != want {
+		t.Errorf("stats reply = %q, want %q", got, want)
+	}
+
+	messenger = newFakeMessenger()
+	handleMessageEvent(context.Background(), messenger, messageEvent("U1", "stats"), config, store, state)
+	if sent := messenger.sent(); len(sent) != 1 || sent[0].UserID != "U1" || !strings.Contains(sent[0].Text, "not allowed") {
+		t.Errorf("sent %+v, want an ephemeral refusal", sent)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Run each step of a chained task in order, stopping at the first failure
+// unless that step is marked continue_on_error. Returns the overall result
+// and a per-step report for Slack.
+func executeSteps(ctx context.Context, config *Config, task Task) (bool, string) {
+	var report strings.Builder
+	success := true
+
+	for i, step := range task.Steps {
+		name := step.Command
+		if name == "" {
+			name = fmt.Sprintf("step %d", i+1)
+		}
+
+		if !success {
+			report.WriteString(fmt.Sprintf(":fast_forward: %s (skipped)\n", name))
+			continue
+		}
+
+		log.Printf("Executing step %d/%d of task '%s': %s", i+1, len(task.Steps), task.Command, name)
+		if executeTask(ctx, config, step).Success {
+			report.WriteString(fmt.Sprintf(":white_check_mark: %s\n", name))
+			continue
+		}
+
+		if step.ContinueOnError {
+			report.WriteString(fmt.Sprintf(":warning: %s (failed, continuing)\n", name))
+			continue
+		}
+		report.WriteString(fmt.Sprintf(":x: %s\n", name))
+		success = false
+	}
+
+	return success, report.String()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Target that answers 200 on /ok and 500 on anything else, recording each path hit
+func newStubTarget(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var hits []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits = append(hits, r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path != "/ok" && !strings.HasPrefix(r.URL.Path, "/ok/") {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), hits...)
+	}
+}
+
+func TestExecuteSteps(t *testing.T) {
+	target, _ := newStubTarget(t)
+	ok := func(name string) Task { return Task{Command: name, URL: target.URL + "/ok/" + name, Method: "GET"} }
+	fail := func(name string) Task { return Task{Command: name, URL: target.URL + "/fail/" + name, Method: "GET"} }
+
+	soft := fail("warm-cache")
+	soft.ContinueOnError = true
+
+	tests := []struct {
+		name        string
+		steps       []Task
+		wantSuccess bool
+		wantReport  []string
+	}{
+		{
+			name:        "all steps pass",
+			steps:       []Task{ok("build"), ok("deploy"), ok("smoke")},
+			wantSuccess: true,
+			wantReport:  []string{":white_check_mark: build", ":white_check_mark: deploy", ":white_check_mark: smoke"},
+		},
+		{
+			name:        "failure stops the chain",
+			steps:       []Task{ok("build"), fail("deploy"), ok("smoke")},
+			wantSuccess: false,
+			wantReport:  []string{":white_check_mark: build", ":x: deploy", ":fast_forward: smoke (skipped)"},
+		},
+		{
+			name:        "continue_on_error step",
+			steps:       []Task{soft, ok("deploy")},
+			wantSuccess: true,
+			wantReport:  []string{":warning: warm-cache (failed, continuing)", ":white_check_mark: deploy"},
+		},
+		{
+			name:        "unnamed steps are numbered",
+			steps:       []Task{{URL: target.URL + "/ok", Method: "GET"}},
+			wantSuccess: true,
+			wantReport:  []string{":white_check_mark: step 1"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			success, report := executeSteps(context.Background(), &Config{}, Task{Command: "release", Steps: test.steps})
+			if success != test.wantSuccess {
+				t.Errorf("success = %v, want %v", success, test.wantSuccess)
+			}
+			if got := strings.Split(strings.TrimSuffix(report, "\n"), "\n"); strings.Join(got, "|") != strings.Join(test.wantReport, "|") {
+				t.Errorf("report = %q, want %q", got, test.wantReport)
+			}
+		})
+	}
+}
+
+// Steps after a failure must not be sent at all
+func TestExecuteStepsSkipsRemainingRequests(t *testing.T) {
+	target, hits := newStubTarget(t)
+	steps := []Task{
+		{Command: "build", URL: target.URL + "/ok/build", Method: "GET"},
+		{Command: "deploy", URL: target.URL + "/fail/deploy", Method: "POST"},
+		{Command: "smoke", URL: target.URL + "/ok/smoke", Method: "GET"},
+	}
+	executeSteps(context.Background(), &Config{}, Task{Command: "release", Steps: steps})
+
+	if got := strings.Join(hits(), ","); got != "/ok/build,/fail/deploy" {
+		t.Errorf("requests = %s, want build and deploy only", got)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// TaskStore is the source of static API tasks used by command dispatch
+type TaskStore interface {
+	GetTask(command string) (Task, bool, error)
+	AddTask(command string, task Task) error
+	RemoveTask(command string) error
+	ListTasks() (map[string]Task, error)
+}
+
+// Returned by RemoveTask when the command does not exist
+var errTaskNotFound = errors.New("task not found")
+
+// Report whether the store has a task for command, treating lookup errors as missing
+func taskExists(store TaskStore, command string) bool {
+	_, exists, err := store.GetTask(command)
+	return err == nil && exists
+}
+
+// Open the task store selected by the configuration.
+// The SQLite store is used when task_db is set, otherwise tasks come from config.json.
+func newTaskStore(config *Config) (TaskStore, error) {
+	if config.TaskDB == "" {
+		return newConfigTaskStore(config.Tasks), nil
+	}
+
+	store, err := newSQLiteTaskStore(config.TaskDB)
+	if err != nil {
+		return nil, err
+	}
+
+	// Seed an empty database with the tasks from config.json
+	tasks, err := store.ListTasks()
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		for command, task := range config.Tasks {
+			if err := store.AddTask(command, task); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return store, nil
+}
+
+// configTaskStore keeps tasks loaded from config.json in memory
+type configTaskStore struct {
+	mu    sync.RWMutex
+	tasks map[string]Task
+}
+
+func newConfigTaskStore(tasks map[string]Task) *configTaskStore {
+	store := &configTaskStore{tasks: make(map[string]Task, len(tasks))}
+	for command, task := range tasks {
+		store.tasks[command] = task
+	}
+	return store
+}
+
+func (s *configTaskStore) GetTask(command string) (Task, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	task, exists := s.tasks[command]
+	return task, exists, nil
+}
+
+func (s *configTaskStore) AddTask(command string, task Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks[command] = task
+	return nil
+}
+
+func (s *configTaskStore) RemoveTask(command string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.tasks[command]; !exists {
+		return fmt.Errorf("%w: %s", errTaskNotFound, command)
+	}
+	delete(s.tasks, command)
+	return nil
+}
+
+// Swap in the tasks of a reloaded configuration
+func (s *configTaskStore) Replace(tasks map[string]Task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks = make(map[string]Task, len(tasks))
+	for command, task := range tasks {
+		s.tasks[command] = task
+	}
+}
+
+func (s *configTaskStore) ListTasks() (map[string]Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tasks := make(map[string]Task, len(s.tasks))
+	for command, task := range s.tasks {
+		tasks[command] = task
+	}
+	return tasks, nil
+}
+
+// Schema migrations, applied in order and recorded in schema_migrations
+var sqliteMigrations = []string{
+	`CREATE TABLE tasks (
+		command    TEXT PRIMARY KEY,
+		definition TEXT NOT NULL
+	)`,
+}
+
+// sqliteTaskStore persists tasks in a SQLite database so they can change at runtime.
+// Each task is stored as its JSON definition so new Task fields need no schema change.
+type sqliteTaskStore struct {
+	db *sql.DB
+}
+
+func newSQLiteTaskStore(path string) (*sqliteTaskStore, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	store := &sqliteTaskStore{db: db}
+	if err := store.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating task database: %w", err)
+	}
+	return store, nil
+}
+
+// Create the schema on first run and apply any pending migrations
+func (s *sqliteTaskStore) migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return err
+	}
+
+	var current int
+	if err := s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+
+	for version := current + 1; version <= len(sqliteMigrations); version++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[version-1]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, version); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteTaskStore) GetTask(command string) (Task, bool, error) {
+	var definition string
+	err := s.db.QueryRow(`SELECT definition FROM tasks WHERE command = ?`, command).Scan(&definition)
+	if err == sql.ErrNoRows {
+		return Task{}, false, nil
+	}
+	if err != nil {
+		return Task{}, false, err
+	}
+
+	var task Task
+	if err := json.Unmarshal([]byte(definition), &task); err != nil {
+		return Task{}, false, fmt.Errorf("decoding task '%s': %w", command, err)
+	}
+	return task, true, nil
+}
+
+func (s *sqliteTaskStore) AddTask(command string, task Task) error {
+	definition, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO tasks (command, definition) VALUES (?, ?)
+		ON CONFLICT(command) DO UPDATE SET definition = excluded.definition`, command, string(definition))
+	return err
+}
+
+func (s *sqliteTaskStore) RemoveTask(command string) error {
+	result, err := s.db.Exec(`DELETE FROM tasks WHERE command = ?`, command)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s", errTaskNotFound, command)
+	}
+	return nil
+}
+
+func (s *sqliteTaskStore) ListTasks() (map[string]Task, error) {
+	rows, err := s.db.Query(`SELECT command, definition FROM tasks`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := make(map[string]Task)
+	for rows.Next() {
+		var command, definition string
+		if err := rows.Scan(&command, &definition); err != nil {
+			return nil, err
+		}
+		var task Task
+		if err := json.Unmarshal([]byte(definition), &task); err != nil {
+			return nil, fmt.Errorf("decoding task '%s': %w", command, err)
+		}
+		tasks[command] = task
+	}
+	return tasks, rows.Err()
+}
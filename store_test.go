@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func openTestSQLiteStore(t *testing.T, path string) *sqliteTaskStore {
+	t.Helper()
+	store, err := newSQLiteTaskStore(path)
+	if err != nil {
+		t.Fatalf("opening %s: %v", path, err)
+	}
+	t.Cleanup(func() { store.db.Close() })
+	return store
+}
+
+// CRUD and lookup through the TaskStore interface, for both implementations
+func TestTaskStoreCRUD(t *testing.T) {
+	stores := map[string]TaskStore{
+		"config": newConfigTaskStore(nil),
+		"sqlite": openTestSQLiteStore(t, filepath.Join(t.TempDir(), "tasks.db")),
+	}
+	restart := Task{Command: "restart", URL: "https://example.com/restart", Method: "POST", User: "bot", Token: "secret"}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			if _, exists, err := store.GetTask("restart"); exists || err != nil {
+				t.Fatalf("GetTask on an empty store = %v, %v", exists, err)
+			}
+
+			if err := store.AddTask("restart", restart); err != nil {
+				t.Fatal(err)
+			}
+			got, exists, err := store.GetTask("restart")
+			if err != nil || !exists || !reflect.DeepEqual(got, restart) {
+				t.Fatalf("GetTask = %+v, %v, %v, want %+v", got, exists, err, restart)
+			}
+
+			// Adding an existing command replaces its definition
+			restart.Method = "PUT"
+			if err := store.AddTask("restart", restart); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.AddTask("health", Task{Command: "health", URL: "https://example.com/health", Method: "GET"}); err != nil {
+				t.Fatal(err)
+			}
+			tasks, err := store.ListTasks()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tasks) != 2 || tasks["restart"].Method != "PUT" || tasks["health"].URL != "https://example.com/health" {
+				t.Errorf("ListTasks = %+v", tasks)
+			}
+
+			if err := store.RemoveTask("restart"); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.RemoveTask("restart"); !errors.Is(err, errTaskNotFound) {
+				t.Errorf("removing a missing task = %v, want errTaskNotFound", err)
+			}
+			if _, exists, _ := store.GetTask("restart"); exists {
+				t.Error("removed task still found")
+			}
+			restart.Method = "POST"
+		})
+	}
+}
+
+// Tasks added at runtime survive a restart and migrations only run once
+func TestSQLiteStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.db")
+
+	first := openTestSQLiteStore(t, path)
+	if err := first.AddTask("health", Task{Command: "health", URL: "https://example.com/health", Method: "GET"}); err != nil {
+		t.Fatal(err)
+	}
+	first.db.Close()
+
+	second := openTestSQLiteStore(t, path)
+	if _, exists, err := second.GetTask("health"); !exists || err != nil {
+		t.Fatalf("task lost after reopening: %v, %v", exists, err)
+	}
+	var migrations int
+	if err := second.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&migrations); err != nil {
+		t.Fatal(err)
+	}
+	if migrations != len(sqliteMigrations) {
+		t.Errorf("%d migrations recorded, want %d", migrations, len(sqliteMigrations))
+	}
+}
+
+// An empty database is seeded from config.json, a populated one is left alone
+func TestNewTaskStoreSeedsDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	seed := map[string]Task{"health": {Command: "health", URL: "https://example.com/health", Method: "GET"}}
+
+	store, err := newTaskStore(&Config{TaskDB: path, Tasks: seed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.(*sqliteTaskStore).db.Close()
+
+	store, err = newTaskStore(&Config{TaskDB: path, Tasks: map[string]Task{"other": {URL: "https://example.com/other"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*sqliteTaskStore).db.Close()
+	tasks, err := store.ListTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tasks["health"]; !ok || len(tasks) != 1 {
+		t.Errorf("tasks = %v, want only the seeded health task", tasks)
+	}
+
+	// Without task_db the tasks come straight from the config
+	store, err = newTaskStore(&Config{Tasks: seed})
+	if _, ok := store.(*configTaskStore); err != nil || !ok {
+		t.Errorf("config without task_db got %T, %v, want the config store", store, err)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Per-task position of the round-robin target selection
+var roundRobin = struct {
+	sync.Mutex
+	next map[string]int
+}{next: make(map[string]int)}
+
+// Order the task's URLs for one invocation: rotated for round_robin,
+// shuffled otherwise. The first entry is tried first.
+func orderTargets(task Task) []string {
+	targets := make([]string, len(task.URLs))
+	switch task.URLStrategy {
+	case "round_robin":
+		key := task.Command + "|" + strings.Join(task.URLs, ",")
+		roundRobin.Lock()
+		start := roundRobin.next[key] % len(task.URLs)
+		roundRobin.next[key] = start + 1
+		roundRobin.Unlock()
+		for i := range task.URLs {
+			targets[i] = task.URLs[(start+i)%len(task.URLs)]
+		}
+	default:
+		for i, j := range rand.Perm(len(task.URLs)) {
+			targets[i] = task.URLs[j]
+		}
+	}
+	return targets
+}
+
+// Execute a task that lists several equivalent URLs, falling back to the
+// next target when one fails. A target that may have processed the request,
+// because it answered or timed out, is only followed by the next one when
+// repeating the task is safe.
+func executeTaskTargets(ctx context.Context, config *Config, task Task) taskResult {
+	var result taskResult
+	for i, target := range orderTargets(task) {
+		single := task
+		single.URL = target
+		single.URLs = nil
+
+		result = executeTask(ctx, config, single)
+		if result.Success || ctx.Err() != nil {
+			return result
+		}
+		if !neverReachedTarget(result) && !retriesAreSafe(task) {
+			log.Printf("Task '%s' failed at %s, not trying the next target since the request may have been processed", task.Command, target)
+			return result
+		}
+		if i < len(task.URLs)-1 {
+			log.Printf("Task '%s' failed at %s, trying the next target", task.Command, target)
+		}
+	}
+	return result
+}
+
+// Report whether a failed request never got to the target: it was not sent,
+// the connection was refused or the host name didn't resolve
+func neverReachedTarget(result taskResult) bool {
+	if result.StatusCode != 0 {
+		return false
+	}
+	if result.Err == nil {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.Is(result.Err, syscall.ECONNREFUSED) || errors.As(result.Err, &dnsErr)
+}
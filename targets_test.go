@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// When the first target returns 500 the next one is tried
+func TestExecuteTaskFallsBackToNextURL(t *testing.T) {
+	target, hits := newStubTarget(t)
+	tests := []struct {
+		name        string
+		urls        []string
+		wantSuccess bool
+		wantHits    string
+	}{
+		{name: "first fails", urls: []string{"/fail/1", "/ok/2"}, wantSuccess: true, wantHits: "/fail/1,/ok/2"},
+		{name: "first succeeds", urls: []string{"/ok/1", "/fail/2"}, wantSuccess: true, wantHits: "/ok/1"},
+		{name: "all fail", urls: []string{"/fail/1", "/fail/2", "/fail/3"}, wantSuccess: false, wantHits: "/fail/1,/fail/2,/fail/3"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := len(hits())
+			task := Task{Command: "fallback " + test.name, Method: "GET", URLStrategy: "round_robin"}
+			for _, path := range test.urls {
+				task.URLs = append(task.URLs, target.URL+path)
+			}
+			if result := executeTask(context.Background(), &Config{}, task); result.Success != test.wantSuccess {
+				t.Errorf("success = %v, want %v", result.Success, test.wantSuccess)
+			}
+			if got := strings.Join(hits()[before:], ","); got != test.wantHits {
+				t.Errorf("requests = %s, want %s", got, test.wantHits)
+			}
+		})
+	}
+}
+
+// POST tasks only fall back when the failed target never got the request,
+// unless retry_non_idempotent says repeating them is safe
+func TestExecuteTaskTargetsNonIdempotent(t *testing.T) {
+	target, hits := newStubTarget(t)
+	refused := httptest.NewServer(http.NotFoundHandler())
+	refused.Close()
+
+	tests := []struct {
+		name        string
+		urls        []string
+		safe        bool
+		wantSuccess bool
+		wantHits    string
+	}{
+		{name: "connection refused", urls: []string{refused.URL + "/x", target.URL + "/ok/2"}, wantSuccess: true, wantHits: "/ok/2"},
+		{name: "unknown host", urls: []string{"http://target.invalid/x", target.URL + "/ok/2"}, wantSuccess: true, wantHits: "/ok/2"},
+		{name: "target answered", urls: []string{target.URL + "/fail/1", target.URL + "/ok/2"}, wantHits: "/fail/1"},
+		{name: "retry_non_idempotent", urls: []string{target.URL + "/fail/1", target.URL + "/ok/2"}, safe: true, wantSuccess: true, wantHits: "/fail/1,/ok/2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := len(hits())
+			task := Task{Command: "post fallback " + test.name, Method: "POST", URLs: test.urls, URLStrategy: "round_robin", RetryNonIdempotent: test.safe}
+			if result := executeTask(context.Background(), &Config{}, task); result.Success != test.wantSuccess {
+				t.Errorf("success = %v, want %v", result.Success, test.wantSuccess)
+			}
+			if got := strings.Join(hits()[before:], ","); got != test.wantHits {
+				t.Errorf("requests = %s, want %s", got, test.wantHits)
+			}
+		})
+	}
+}
+
+func TestOrderTargets(t *testing.T) {
+	urls := []string{"https://a.example.com", "https://b.example.com", "https://c.example.com"}
+
+	// Round robin starts one further along on each invocation
+	task := Task{Command: "order round robin", URLs: urls, URLStrategy: "round_robin"}
+	for i := 0; i < 4; i++ {
+		got := orderTargets(task)
+		if got[0] != urls[i%3] || got[1] != urls[(i+1)%3] || got[2] != urls[(i+2)%3] {
+			t.Errorf("invocation %d order = %v, want rotation starting at %s", i, got, urls[i%3])
+		}
+	}
+
+	// Random order always covers every target once
+	task = Task{Command: "order random", URLs: urls}
+	firsts := map[string]bool{}
+	for i := 0; i < 200; i++ {
+		got := orderTargets(task)
+		firsts[got[0]] = true
+		sorted := append([]string(nil), got...)
+		sort.Strings(sorted)
+		if strings.Join(sorted, ",") != strings.Join(urls, ",") {
+			t.Fatalf("random order %v is not a permutation of %v", got, urls)
+		}
+	}
+	if len(firsts) != len(urls) {
+		t.Errorf("random order only ever started with %v", firsts)
+	}
+}
+
+func TestValidateTaskURLs(t *testing.T) {
+	tests := []struct {
+		task    Task
+		wantErr string
+	}{
+		{task: Task{URLs: []string{"https://a.example.com"}, URLStrategy: "round_robin"}},
+		{task: Task{URL: "https://a.example.com", URLs: []string{"https://b.example.com"}}, wantErr: "url and urls are mutually exclusive"},
+		{task: Task{URLs: []string{"https://a.example.com"}, URLStrategy: "weighted"}, wantErr: "unknown url_strategy 'weighted'"},
+	}
+	for _, test := range tests {
+		err := validateTask("status", test.task)
+		if (err == nil) != (test.wantErr == "") || (err != nil && !strings.Contains(err.Error(), test.wantErr)) {
+			t.Errorf("validateTask(%+v) = %v, want %q", test.task, err, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ArgSpec declares a positional argument of a task, e.g. the count in
+// "scale <service> <count>". Its value fills {name} in the request.
+type ArgSpec struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type,omitempty"`    // "string" (default), "int" or "enum"
+	Pattern string   `json:"pattern,omitempty"` // Regex the whole value must match
+	Values  []string `json:"values,omitempty"`  // Allowed values for enum arguments
+}
+
+// Find the task a command line runs: the whole line as a command, a task with
+// args followed by its arguments ("scale api 3") or a workflow followed by its
+// inputs ("release env=prod"). A non-empty rejection is the reply for invalid
+// arguments or inputs, in which case nothing may run.
+func resolveTask(config *Config, store TaskStore, userID, text string) (command string, task Task, args map[string]string, exists bool, rejection string) {
+	command = strings.ToLower(text)
+	task, exists, err := store.GetTask(command)
+	if err != nil {
+		log.Printf("Error looking up task for command '%s': %v", command, err)
+	}
+
+	// Tasks with args take positional arguments: "<command> <arg> ..."
+	if words, err := splitArgs(text); err == nil && len(words) > 0 && (!exists || len(task.Args) > 0) {
+		name := strings.ToLower(words[0])
+		if argTask, found, _ := store.GetTask(name); found && len(argTask.Args) > 0 {
+			values, err := parseTaskArgs(argTask.Args, words[1:])
+			if err != nil {
+				return name, argTask, nil, true, localize(config, userID, "invalid_args", "command", name, "error", err.Error(), "usage", argsUsage(name, argTask.Args))
+			}
+			return name, argTask, values, true, ""
+		}
+	}
+
+	// Workflow tasks take trailing inputs: "<command> env=prod version=1.2"
+	if !exists {
+		if name, inputs, ok := splitWorkflowInputs(text); ok {
+			if workflowTask, found, _ := store.GetTask(name); found && workflowTask.GitHubWorkflow != nil {
+				if err := validateWorkflowInputs(workflowTask.GitHubWorkflow, inputs); err != nil {
+					return name, workflowTask, nil, true, localize(config, userID, "invalid_inputs", "command", name, "error", err.Error())
+				}
+				return name, withWorkflowInputs(workflowTask, inputs), nil, true, ""
+			}
+		}
+	}
+	return command, task, nil, exists, ""
+}
+
+// Check the arguments typed after the command against the task's specs and
+// return them by name
+func parseTaskArgs(specs []ArgSpec, values []string) (map[string]string, error) {
+	if len(values) != len(specs) {
+		return nil, fmt.Errorf("expected %d arguments, got %d", len(specs), len(values))
+	}
+	args := make(map[string]string, len(specs))
+	for i, spec := range specs {
+		if err := checkArg(spec, values[i]); err != nil {
+			return nil, fmt.Errorf("<%s> %w", spec.Name, err)
+		}
+		args[spec.Name] = values[i]
+	}
+	return args, nil
+}
+
+func checkArg(spec ArgSpec, value string) error {
+	switch spec.Type {
+	case "int":
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("must be a whole number, got '%s'", value)
+		}
+	case "enum":
+		found := false
+		for _, allowed := range spec.Values {
+			if strings.EqualFold(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("must be one of %s, got '%s'", strings.Join(spec.Values, ", "), value)
+		}
+	}
+	if spec.Pattern != "" {
+		re, err := regexp.Compile("^(?:" + spec.Pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("has an invalid pattern: %w", err)
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("must match %s, got '%s'", spec.Pattern, value)
+		}
+	}
+	return nil
+}
+
+// Usage line for a task with arguments, e.g. "scale <service> <count>"
+func argsUsage(command string, specs []ArgSpec) string {
+	parts := []string{command}
+	for _, spec := range specs {
+		parts = append(parts, "<"+spec.Name+">")
+	}
+	return strings.Join(parts, " ")
+}
+
+// Check argument specs when the configuration is loaded
+func validateArgSpecs(specs []ArgSpec) error {
+	var errs []error
+	seen := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		if spec.Name == "" {
+			errs = append(errs, errors.New("args: every argument needs a name"))
+			continue
+		}
+		if seen[spec.Name] {
+			errs = append(errs, fmt.Errorf("args: duplicate argument '%s'", spec.Name))
+		}
+		seen[spec.Name] = true
+		switch spec.Type {
+		case "", "string", "int":
+		case "enum":
+			if len(spec.Values) == 0 {
+				errs = append(errs, fmt.Errorf("args: enum argument '%s' needs values", spec.Name))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("args: unknown type '%s' for argument '%s'", spec.Type, spec.Name))
+		}
+		if spec.Pattern != "" {
+			if _, err := regexp.Compile(spec.Pattern); err != nil {
+				errs = append(errs, fmt.Errorf("args: invalid pattern for argument '%s': %w", spec.Name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
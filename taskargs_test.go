@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTaskArgs(t *testing.T) {
+	specs := []ArgSpec{
+		{Name: "service", Type: "enum", Values: []string{"api", "web"}},
+		{Name: "count", Type: "int"},
+		{Name: "tag", Pattern: `v[0-9]+`},
+	}
+
+	tests := []struct {
+		name    string
+		values  []string
+		want    map[string]string
+		wantErr string
+	}{
+		{name: "valid", values: []string{"api", "3", "v2"}, want: map[string]string{"service": "api", "count": "3", "tag": "v2"}},
+		{name: "enum is case-insensitive", values: []string{"API", "3", "v2"}, want: map[string]string{"service": "API", "count": "3", "tag": "v2"}},
+		{name: "negative int", values: []string{"web", "-1", "v10"}, want: map[string]string{"service": "web", "count": "-1", "tag": "v10"}},
+		{name: "too few", values: []string{"api"}, wantErr: "expected 3 arguments, got 1"},
+		{name: "too many", values: []string{"api", "3", "v2", "x"}, wantErr: "expected 3 arguments, got 4"},
+		{name: "bad enum", values: []string{"db", "3", "v2"}, wantErr: "<service> must be one of api, web, got 'db'"},
+		{name: "bad int", values: []string{"api", "three", "v2"}, wantErr: "<count> must be a whole number, got 'three'"},
+		{name: "pattern must match the whole value", values: []string{"api", "3", "v2-beta"}, wantErr: "<tag> must match v[0-9]+, got 'v2-beta'"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseTaskArgs(specs, test.values)
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Fatalf("error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("args = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestArgsUsage(t *testing.T) {
+	tests := []struct {
+		command string
+		specs   []ArgSpec
+		want    string
+	}{
+		{command: "scale", specs: []ArgSpec{{Name: "service"}, {Name: "count"}}, want: "scale <service> <count>"},
+		{command: "status", want: "status"},
+	}
+
+	for _, test := range tests {
+		if got := argsUsage(test.command, test.specs); got != test.want {
+			t.Errorf("argsUsage(%q) = %q, want %q", test.command, got, test.want)
+		}
+	}
+}
+
+func TestValidateArgSpecs(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []ArgSpec
+		wantErr bool
+	}{
+		{name: "valid", specs: []ArgSpec{{Name: "a"}, {Name: "b", Type: "int"}, {Name: "c", Type: "enum", Values: []string{"x"}}}},
+		{name: "none"},
+		{name: "missing name", specs: []ArgSpec{{Type: "int"}}, wantErr: true},
+		{name: "duplicate name", specs: []ArgSpec{{Name: "a"}, {Name: "a"}}, wantErr: true},
+		{name: "enum without values", specs: []ArgSpec{{Name: "a", Type: "enum"}}, wantErr: true},
+		{name: "unknown type", specs: []ArgSpec{{Name: "a", Type: "float"}}, wantErr: true},
+		{name: "invalid pattern", specs: []ArgSpec{{Name: "a", Pattern: "("}}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := validateArgSpecs(test.specs); (err != nil) != test.wantErr {
+				t.Errorf("validateArgSpecs() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+// Arguments typed after the command fill the request, bad ones get the usage line
+func TestHandleMessageTaskArgs(t *testing.T) {
+	target, hits := newStubTarget(t)
+	scale := Task{Command: "scale", URL: target.URL + "/ok/{service}/{count}", Method: "POST", Args: []ArgSpec{
+		{Name: "service", Type: "enum", Values: []string{"api", "web"}},
+		{Name: "count", Type: "int"},
+	}}
+
+	tests := []struct {
+		name        string
+		text        string
+		wantReply   string
+		wantPrivate bool
+		wantHits    []string
+	}{
+		{name: "valid", text: "scale api 3", wantReply: "Task 'scale' executed successfully", wantHits: []string{"/ok/api/3"}},
+		{name: "command case ignored", text: "SCALE web 2", wantReply: "Task 'scale' executed successfully", wantHits: []string{"/ok/web/2"}},
+		{name: "bad enum", text: "scale db 3", wantReply: "Invalid arguments for 'scale': <service> must be one of api, web, got 'db'. Use: scale <service> <count>", wantPrivate: true},
+		{name: "missing arguments", text: "scale", wantReply: "Invalid arguments for 'scale': expected 2 arguments, got 0. Use: scale <service> <count>", wantPrivate: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &Config{}
+			messenger := newFakeMessenger()
+			before := len(hits())
+			handleMessageEvent(context.Background(), messenger, messageEvent("U1", test.text), config, newConfigTaskStore(map[string]Task{"scale": scale}), newBotState(config))
+
+			sent := messenger.sent()
+			last := sent[len(sent)-1]
+			if !strings.HasPrefix(last.Text, test.wantReply) || (last.UserID != "") != test.wantPrivate {
+				t.Errorf("last reply = %+v, want %q (ephemeral %v)", last, test.wantReply, test.wantPrivate)
+			}
+			if got := hits()[before:]; !reflect.DeepEqual(got, test.wantHits) && len(got)+len(test.wantHits) > 0 {
+				t.Errorf("requests = %v, want %v", got, test.wantHits)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// TeamsConfig structure for the Microsoft Teams backend
+type TeamsConfig struct {
+	IncomingWebhookURL    string `json:"incoming_webhook_url"`    // Where replies are posted
+	OutgoingWebhookSecret string `json:"outgoing_webhook_secret"` // Security token Teams signs requests with
+}
+
+// Message activity sent by a Teams outgoing webhook
+type teamsActivity struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+	Text string `json:"text"`
+	From struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		AADObjectID string `json:"aadObjectId"`
+	} `json:"from"`
+	Conversation struct {
+		ID string `json:"id"`
+	} `json:"conversation"`
+}
+
+var (
+	teamsMentionPattern = regexp.MustCompile(`<at>.*?</at>`)
+	htmlTagPattern      = regexp.MustCompile(`<[^>]+>`)
+	slackMentionPattern = regexp.MustCompile(`<@([A-Za-z0-9]+)>`)
+	slackSpecialPattern = regexp.MustCompile(`<!(?:subteam\^)?([A-Za-z0-9]+)>`)
+)
+
+// Slack emoji codes used in replies and their Unicode equivalents for Teams
+var teamsEmoji = strings.NewReplacer(
+	":white_check_mark:", "✅",
+	":x:", "❌",
+	":warning:", "⚠️",
+	":fast_forward:", "⏩",
+)
+
+// teamsMessenger posts replies to a Teams channel through an incoming webhook
+type teamsMessenger struct {
+	webhookURL string
+	client     *http.Client
+	config     *atomic.Pointer[Config] // Current configuration, for the notices' locale
+}
+
+func newTeamsMessenger(config TeamsConfig, current *atomic.Pointer[Config]) *teamsMessenger {
+	return &teamsMessenger{
+		webhookURL: config.IncomingWebhookURL,
+		client:     &http.Client{Timeout: 10 * time.Second, Transport: tracedTransport},
+		config:     current,
+	}
+}
+
+// The incoming webhook is bound to one channel, so channelID is not used
+func (m *teamsMessenger) PostMessage(channelID, text string) error {
+	payload, err := json.Marshal(map[string]string{"text": formatTeamsText(text)})
+	if err != nil {
+		return err
+	}
+	resp, err := m.client.Post(m.webhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("teams webhook returned status: %s", resp.Status)
+	}
+	return nil
+}
+
+// Webhooks can't target a single user, and replies such as whoami, describe
+// or config must not reach the whole channel, so only a notice is posted
+func (m *teamsMessenger) PostEphemeral(channelID, userID, text string) error {
+	log.Printf("Not posting a private reply to %s in Teams", userID)
+	config := m.config.Load()
+	return m.PostMessage(channelID, localize(config, userID, "teams_private"))
+}
+
+// Teams webhooks can't react to messages, so reactions are skipped
+func (m *teamsMessenger) AddReaction(channelID, timestamp, emoji string) error {
+	return nil
+}
+
+func (m *teamsMessenger) RemoveReaction(channelID, timestamp, emoji string) error {
+	return nil
+}
+
+// Webhooks can't delete the user's message either
+func (m *teamsMessenger) DeleteMessage(channelID, timestamp string) error {
+	return nil
+}
+
+// Convert a Slack-formatted reply into Teams markdown
+func formatTeamsText(text string) string {
+	text = teamsEmoji.Replace(text)
+	text = slackMentionPattern.ReplaceAllString(text, "$1")
+	text = slackSpecialPattern.ReplaceAllString(text, "@$1")
+	// Teams collapses single newlines, so use explicit line breaks
+	return strings.ReplaceAll(strings.TrimRight(text, "\n"), "\n", "<br>")
+}
+
+// Extract the command text from a Teams message, dropping the bot mention and HTML
+func parseTeamsText(text string) string {
+	text = teamsMentionPattern.ReplaceAllString(text, "")
+	text = htmlTagPattern.ReplaceAllString(text, "")
+	text = strings.ReplaceAll(html.UnescapeString(text), "\u00a0", " ")
+	return strings.TrimSpace(text)
+}
+
+// Check the HMAC signature Teams puts in the Authorization header
+func verifyTeamsSignature(secret string, body []byte, header string) bool {
+	key, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	expected := "HMAC " + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(header))
+}
+
+// Stable ID allowlists and admin checks use for the sender: the Azure AD object
+// ID, or the Teams user ID. Display names can be changed and aren't unique.
+func teamsUserID(activity teamsActivity) string {
+	if activity.From.AADObjectID != "" {
+		return activity.From.AADObjectID
+	}
+	return activity.From.ID
+}
+
+// HTTP handler for Teams outgoing webhook messages
+func teamsHandler(ctx context.Context, messenger Messenger, config *Config, store TaskStore, state *botState) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			log.Printf("Error reading request body: %v", err)
+			http.Error(w, "Can't read body", http.StatusBadRequest)
+			return
+		}
+
+		if !verifyTeamsSignature(config.Teams.OutgoingWebhookSecret, body, r.Header.Get("Authorization")) {
+			log.Println("Rejected Teams request with invalid signature.")
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		var activity teamsActivity
+		if err := json.Unmarshal(body, &activity); err != nil {
+			log.Printf("Error parsing JSON: %v", err)
+			http.Error(w, "Can't parse JSON", http.StatusBadRequest)
+			return
+		}
+
+		msg := incomingMessage{
+			Text:      parseTeamsText(activity.Text),
+			ChannelID: activity.Conversation.ID,
+			UserID:    teamsUserID(activity),
+			Timestamp: activity.ID,
+		}
+		log.Printf("Teams message received in conversation: %s, message: %s", msg.ChannelID, msg.Text)
+
+		// Teams expects an answer within 5 seconds, so results are posted through the incoming webhook
+		go func() {
+			defer state.recoverPanic("a Teams message")
+			handleCommand(ctx, messenger, msg, state.config.Load(), store, state)
+		}()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"type": "message",
+			"text": "Command received, working on it...",
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testTeamsSecret = base64.StdEncoding.EncodeToString([]byte("teams-secret"))
+
+// Sign a body the way a Teams outgoing webhook does
+func signTeamsRequest(secret string, body []byte) string {
+	key, _ := base64.StdEncoding.DecodeString(secret)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	return "HMAC " + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestFormatTeamsText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain text", in: "Task 'restart' executed successfully.", want: "Task 'restart' executed successfully."},
+		{name: "emoji codes", in: ":white_check_mark: build\n:x: deploy\n", want: "✅ build<br>❌ deploy"},
+		{name: "skipped and warning", in: ":warning: cache\n:fast_forward: smoke", want: "⚠️ cache<br>⏩ smoke"},
+		{name: "user mentions", in: "`restart` by <@U123>: success", want: "`restart` by U123: success"},
+		{name: "failure mentions", in: "<!here> <!subteam^S123> Task 'restart' failed to execute.", want: "@here @S123 Task 'restart' failed to execute."},
+		{name: "trailing newlines", in: "Here are the available commands:\n- restart\n\n", want: "Here are the available commands:<br>- restart"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := formatTeamsText(test.in); got != test.want {
+				t.Errorf("formatTeamsText(%q) = %q, want %q", test.in, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseTeamsText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "<at>AutomationBot</at> restart", want: "restart"},
+		{in: "<at>AutomationBot</at>&nbsp;deploy api prod", want: "deploy api prod"},
+		{in: "<p><at>Bot</at> list</p>\n", want: "list"},
+		{in: "history deploy &amp; more", want: "history deploy & more"},
+	}
+	for _, test := range tests {
+		if got := parseTeamsText(test.in); got != test.want {
+			t.Errorf("parseTeamsText(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestVerifyTeamsSignature(t *testing.T) {
+	body := []byte(`{"type":"message","text":"restart"}`)
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		want   bool
+	}{
+		{name: "valid", secret: testTeamsSecret, header: signTeamsRequest(testTeamsSecret, body), want: true},
+		{name: "other secret", secret: testTeamsSecret, header: signTeamsRequest(base64.StdEncoding.EncodeToString([]byte("other")), body)},
+		{name: "missing header", secret: testTeamsSecret},
+		{name: "secret not base64", secret: "not base64!", header: signTeamsRequest(testTeamsSecret, body)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := verifyTeamsSignature(test.secret, body, test.header); got != test.want {
+				t.Errorf("verifyTeamsSignature = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// A signed Teams message runs the command and the formatted result goes to the incoming webhook
+func TestTeamsHandlerRunsCommand(t *testing.T) {
+	target, _ := newStubTarget(t)
+	replies := make(chan string, 2)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		if !strings.HasPrefix(payload["text"], "Running '") {
+			replies <- payload["text"]
+		}
+	}))
+	defer webhook.Close()
+
+	config := &Config{Teams: TeamsConfig{IncomingWebhookURL: webhook.URL, OutgoingWebhookSecret: testTeamsSecret}}
+	store := newConfigTaskStore(map[string]Task{
+		"release": {Command: "release", Steps: []Task{{Command: "build", URL: target.URL + "/ok", Method: "GET"}}},
+	})
+	state := newBotState(config)
+	handler := teamsHandler(context.Background(), newTeamsMessenger(config.Teams, &state.config), config, store, state)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	body := []byte(`{"type":"message","id":"1","text":"<at>Bot</at> release","from":{"id":"29:1","name":"Alice"},"conversation":{"id":"19:abc"}}`)
+	post := func(signature string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(string(body)))
+		req.Header.Set("Authorization", signature)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := post("HMAC forged")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("forged request = %d, want 401", resp.StatusCode)
+	}
+
+	resp = post(signTeamsRequest(testTeamsSecret, body))
+	ack, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(ack), "Command received") {
+		t.Fatalf("signed request = %d %s, want the immediate acknowledgement", resp.StatusCode, ack)
+	}
+
+	select {
+	case reply := <-replies:
+		if want := "Task 'release' executed successfully.<br>✅ build"; reply != want {
+			t.Errorf("Teams reply = %q, want %q", reply, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reply posted to the incoming webhook")
+	}
+}
+
+// Allowlists see the Azure AD object ID when Teams sends one
+func TestTeamsUserID(t *testing.T) {
+	tests := []struct {
+		name string
+		aad  string
+		id   string
+		want string
+	}{
+		{name: "Azure AD object ID", aad: "aad-1", id: "29:teams-1", want: "aad-1"},
+		{name: "Teams user ID", id: "29:teams-1", want: "29:teams-1"},
+	}
+
+	for _, test := range tests {
+		var activity teamsActivity
+		activity.From.AADObjectID, activity.From.ID, activity.From.Name = test.aad, test.id, "Ann"
+		if got := teamsUserID(activity); got != test.want {
+			t.Errorf("%s: teamsUserID() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+// Private replies never reach the channel, only a notice does
+func TestTeamsMessenger(t *testing.T) {
+	received := make(chan string, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		received <- payload["text"]
+		if payload["text"] == "fail" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer webhook.Close()
+	config := &Config{UserLocales: map[string]string{"UDE": "de"}, Messages: map[string]map[string]string{"de": {"teams_private": "Diese Antwort ist nur für dich."}}}
+	messenger := newTeamsMessenger(TeamsConfig{IncomingWebhookURL: webhook.URL}, &newBotState(config).config)
+
+	tests := []struct {
+		name    string
+		post    func() error
+		want    string
+		wantErr bool
+	}{
+		{name: "message", post: func() error { return messenger.PostMessage("C1", ":x: broken\n") }, want: "❌ broken"},
+		{name: "private reply withheld", post: func() error { return messenger.PostEphemeral("C1", "U1", "your token is abc") }, want: "That reply is only for you, and Teams can't show private replies, so it wasn't posted."},
+		{name: "notice in the user's locale", post: func() error { return messenger.PostEphemeral("C1", "UDE", "your token is abc") }, want: "Diese Antwort ist nur für dich."},
+		{name: "webhook error", post: func() error { return messenger.PostMessage("C1", "fail") }, want: "fail", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.post(); (err != nil) != test.wantErr {
+				t.Errorf("post error = %v, wantErr %v", err, test.wantErr)
+			}
+			if got := <-received; got != test.want {
+				t.Errorf("posted %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// Allowlists match the sender's ID, never the changeable display name
+func TestTeamsHandlerUserID(t *testing.T) {
+	target, _ := newStubTarget(t)
+	tasks := map[string]Task{"health": {Command: "health", URL: target.URL + "/ok", Method: "GET", AllowedUsers: []string{"aad-1"}}}
+	config := &Config{Tasks: tasks, AckReaction: "none", DebounceMillis: -1, Teams: TeamsConfig{OutgoingWebhookSecret: testTeamsSecret}}
+	messenger := newFakeMessenger()
+	server := httptest.NewServer(teamsHandler(context.Background(), messenger, config, newConfigTaskStore(tasks), newBotState(config)))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		body      string
+		wantReply string
+	}{
+		{
+			name:      "allowed by Azure AD ID",
+			body:      `{"type":"message","id":"1","text":"<at>Bot</at> health","from":{"id":"29:x","name":"Ann","aadObjectId":"aad-1"},"conversation":{"id":"19:chan"}}`,
+			wantReply: "Task 'health' executed successfully.",
+		},
+		{
+			name:      "display name doesn't count",
+			body:      `{"type":"message","id":"2","text":"<at>Bot</at> health","from":{"id":"29:y","name":"aad-1"},"conversation":{"id":"19:chan"}}`,
+			wantReply: "You are not allowed to run 'health'.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := len(messenger.sent())
+			req, err := http.NewRequest("POST", server.URL, strings.NewReader(test.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", signTeamsRequest(testTeamsSecret, []byte(test.body)))
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want 200", resp.StatusCode)
+			}
+
+			// Skip the running message; the reply is the last one posted
+			var reply fakeMessage
+			deadline := time.Now().Add(5 * time.Second)
+			for time.Now().Before(deadline) {
+				if sent := messenger.sent()[before:]; len(sent) > 0 && !strings.HasPrefix(sent[len(sent)-1].Text, "Running '") {
+					reply = sent[len(sent)-1]
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if reply.ChannelID != "19:chan" || !strings.HasPrefix(reply.Text, test.wantReply) {
+				t.Errorf("reply = %+v, want %q in 19:chan", reply, test.wantReply)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestHandleMessageThreadsOnly(t *testing.T) {
+	target, _ := newStubTarget(t)
+	tests := []struct {
+		name        string
+		config      Config
+		threadTS    string
+		wantHandled bool
+	}{
+		{name: "threaded message", config: Config{ThreadsOnly: true}, threadTS: "1700000000.000100", wantHandled: true},
+		{name: "root message", config: Config{ThreadsOnly: true}, wantHandled: false},
+		{name: "configured thread", config: Config{ThreadsOnly: true, ThreadRoot: "1700000000.000100"}, threadTS: "1700000000.000100", wantHandled: true},
+		{name: "other thread", config: Config{ThreadsOnly: true, ThreadRoot: "1700000000.000100"}, threadTS: "1700000099.000200", wantHandled: false},
+		{name: "mode off, root message", config: Config{}, wantHandled: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messenger := newFakeMessenger()
+			store := newConfigTaskStore(map[string]Task{"restart": {Command: "restart", URL: target.URL + "/ok", Method: "POST"}})
+			event := messageEvent("U1", "restart")
+			if test.threadTS != "" {
+				event["event"].(map[string]interface{})["thread_ts"] = test.threadTS
+			}
+			handleMessageEvent(context.Background(), messenger, event, &test.config, store, newBotState(&test.config))
+
+			if handled := len(messenger.sent()) > 0; handled != test.wantHandled {
+				t.Errorf("handled = %v (sent %+v), want %v", handled, messenger.sent(), test.wantHandled)
+			}
+		})
+	}
+}
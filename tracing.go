@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer for spans around event handling, command dispatch and outbound calls.
+// It is a no-op unless setupTracing installs an exporter.
+var tracer = otel.Tracer("gobot")
+
+// Transport for outbound requests: each call gets a client span and a traceparent header
+var tracedTransport http.RoundTripper = tracingTransport{base: sharedTransport}
+
+// Export spans over OTLP/HTTP when an OTLP endpoint is set through the standard
+// OTEL_EXPORTER_OTLP_* environment variables. Returns a function that flushes
+// pending spans on shutdown.
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := tracingResource(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	log.Println("Exporting traces over OTLP")
+	return provider.Shutdown, nil
+}
+
+// Resource describing the bot in exported spans. Later options win, so
+// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults.
+func tracingResource(ctx context.Context) (*resource.Resource, error) {
+	return resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName("automation-bot"), semconv.ServiceVersion(version)),
+		resource.WithFromEnv(),
+	)
+}
+
+// Span attributes describing a command, without its arguments
+func commandAttributes(msg incomingMessage) []attribute.KeyValue {
+	name, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(msg.Text)), " ")
+	return []attribute.KeyValue{
+		attribute.String("bot.command", name),
+		attribute.String("bot.user", msg.UserID),
+		attribute.String("bot.channel", msg.ChannelID),
+	}
+}
+
+// tracingTransport wraps outbound HTTP calls in client spans with status and duration
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func (t tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := tracer.Start(req.Context(), "HTTP "+req.Method, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPMethod(req.Method),
+			semconv.URLFull(req.URL.Redacted()),
+			semconv.ServerAddress(req.URL.Hostname()),
+		))
+	defer span.End()
+
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	span.SetAttributes(attribute.Int64("http.duration_ms", time.Since(start).Milliseconds()))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPStatusCode(resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Record spans in memory and propagate trace context for the rest of the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	previousTracer, previousPropagator := tracer, otel.GetTextMapPropagator()
+	tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("gobot")
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		tracer = previousTracer
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return recorder
+}
+
+func TestCommandAttributes(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "restart", want: "restart"},
+		{text: "  Deploy api prod", want: "deploy"},
+		{text: "restart api --token=secret", want: "restart"},
+		{text: "", want: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			got := map[attribute.Key]string{}
+			for _, attr := range commandAttributes(incomingMessage{Text: test.text, UserID: "U1", ChannelID: "C1"}) {
+				got[attr.Key] = attr.Value.AsString()
+			}
+			if got["bot.command"] != test.want || got["bot.user"] != "U1" || got["bot.channel"] != "C1" {
+				t.Errorf("commandAttributes(%q) = %v, want command %q", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestTracingTransport(t *testing.T) {
+	recorder := recordSpans(t)
+	traceparents := make(chan string, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents <- r.Header.Get("traceparent")
+		if r.URL.Path != "/ok" {
+			http.Error(w, "broken", http.StatusInternalServerError)
+		}
+	}))
+	defer target.Close()
+	client := &http.Client{Transport: tracingTransport{base: http.DefaultTransport}}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus codes.Code
+	}{
+		{name: "success", path: "/ok", wantStatus: codes.Unset},
+		{name: "server error", path: "/fail", wantStatus: codes.Error},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := client.Get(target.URL + test.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if got := <-traceparents; got == "" {
+				t.Error("traceparent header not sent")
+			}
+			spans := recorder.Ended()
+			span := spans[len(spans)-1]
+			if span.Name() != "HTTP GET" || span.Status().Code != test.wantStatus {
+				t.Errorf("span = %q with status %v, want HTTP GET with %v", span.Name(), span.Status().Code, test.wantStatus)
+			}
+		})
+	}
+}
+
+// One trace covers the event, the dispatch and the task request, whose X-Request-ID is the trace ID
+func TestHandleMessageTraced(t *testing.T) {
+	recorder := recordSpans(t)
+	requestIDs := make(chan string, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestIDs <- r.Header.Get("X-Request-ID")
+	}))
+	defer target.Close()
+	config := &Config{}
+	store := newConfigTaskStore(map[string]Task{"restart": {Command: "restart", URL: target.URL, Method: "POST"}})
+
+	handleMessageEvent(context.Background(), newFakeMessenger(), messageEvent("U1", "restart"), config, store, newBotState(config))
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	event, dispatch, request := spans["slack.event"], spans["command.dispatch"], spans["HTTP POST"]
+	if event == nil || dispatch == nil || request == nil {
+		t.Fatalf("spans = %v, want slack.event, command.dispatch and HTTP POST", spans)
+	}
+	if dispatch.Parent().SpanID() != event.SpanContext().SpanID() || request.Parent().TraceID() != event.SpanContext().TraceID() {
+		t.Error("dispatch and request spans are not part of the event's trace")
+	}
+	if got := <-requestIDs; got != event.SpanContext().TraceID().String() {
+		t.Errorf("X-Request-ID = %q, want the trace ID %s", got, event.SpanContext().TraceID())
+	}
+}
+
+// The bot's service name is kept unless the environment overrides it
+func TestTracingResource(t *testing.T) {
+	tests := []struct {
+		name        string
+		serviceName string
+		attributes  string
+		want        map[attribute.Key]string
+	}{
+		{name: "defaults", want: map[attribute.Key]string{"service.name": "automation-bot", "service.version": version}},
+		{name: "OTEL_SERVICE_NAME", serviceName: "ops-bot", want: map[attribute.Key]string{"service.name": "ops-bot", "service.version": version}},
+		{
+			name: "OTEL_RESOURCE_ATTRIBUTES", attributes: "deployment.environment=prod",
+			want: map[attribute.Key]string{"service.name": "automation-bot", "deployment.environment": "prod"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("OTEL_SERVICE_NAME", test.serviceName)
+			t.Setenv("OTEL_RESOURCE_ATTRIBUTES", test.attributes)
+			res, err := tracingResource(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[attribute.Key]string{}
+			for _, attr := range res.Attributes() {
+				got[attr.Key] = attr.Value.Emit()
+			}
+			for key, want := range test.want {
+				if got[key] != want {
+					t.Errorf("%s = %q, want %q (resource %v)", key, got[key], want, got)
+				}
+			}
+		})
+	}
+}
+
+// Completion webhooks and PagerDuty events carry trace context like task requests
+func TestNotificationsTraced(t *testing.T) {
+	recordSpans(t)
+	traceparents := make(chan string, 2)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents <- r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer receiver.Close()
+	previous := pagerDutyEventsURL
+	pagerDutyEventsURL = receiver.URL
+	defer func() { pagerDutyEventsURL = previous }()
+
+	notifyCompletion(receiver.URL, "", "restart", "U1", true, time.Second)
+	triggerPagerDuty("routing-key", "restart", "U1", "target answered with status 500")
+	for _, name := range []string{"completion webhook", "PagerDuty event"} {
+		if got := <-traceparents; got == "" {
+			t.Errorf("%s sent without a traceparent header", name)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// User ID that HTTP-triggered commands run as, for allowlists and history
+const triggerUserID = "trigger"
+
+// JSON reply of the trigger endpoint
+type triggerResponse struct {
+	Command  string  `json:"command"`
+	Executed bool    `json:"executed"` // false when a check such as a cooldown stopped the run
+	Success  bool    `json:"success"`
+	Response string  `json:"response"`
+	Messages []Reply `json:"messages,omitempty"` // Every message the command produced, the response last
+}
+
+// Register POST /trigger/{command} so CI pipelines can run tasks without Slack
+func registerTriggerRoutes(ctx context.Context, mux *http.ServeMux, config *Config, store TaskStore, state *botState) {
+	if config.TriggerToken == "" {
+		return
+	}
+	mux.Handle("/trigger/", requireBearerToken(config.TriggerToken, triggerHandler(ctx, store, state)))
+}
+
+// Run the command in the path like a chat message, so tasks with arguments,
+// deploys, workflow inputs and built-in commands work too, e.g.
+// /trigger/deploy%20api%20prod
+func triggerHandler(ctx context.Context, store TaskStore, state *botState) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		command := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/trigger/"))
+		if command == "" {
+			writeJSONError(w, http.StatusNotFound, "missing command")
+			return
+		}
+		log.Printf("Command '%s' triggered over HTTP from %s", command, r.RemoteAddr)
+
+		// Stop the run when the caller goes away or the bot shuts down
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			select {
+			case <-r.Context().Done():
+				cancel()
+			case <-runCtx.Done():
+			}
+		}()
+
+		config := state.config.Load()
+		result, _ := newDispatcher(config, store, state).Dispatch(runCtx, command, CommandMeta{UserID: triggerUserID, ChannelID: "trigger"})
+		var response string
+		if len(result.Replies) > 0 {
+			response = result.Replies[len(result.Replies)-1].Text
+		}
+
+		if config.TriggerMirrorChannel != "" && state.notifier != nil {
+			text := fmt.Sprintf("'%s' triggered over HTTP: %s", command, response)
+			if err := state.notifier.PostMessage(config.TriggerMirrorChannel, text); err != nil {
+				log.Printf("Error sending message to Slack: %v", err)
+			}
+		}
+
+		// Escalate repeated failures where people will see them
+		escalationChannel := config.TriggerMirrorChannel
+		if escalationChannel == "" {
+			escalationChannel = config.NotifyChannel
+		}
+		if escalationChannel != "" && state.notifier != nil && result.Command != "" {
+			escalateFailures(state.notifier, config, escalationChannel, triggerUserID, result.Command, result.FailureStreak)
+		}
+
+		status := http.StatusOK
+		if !result.Executed {
+			status = http.StatusConflict
+		} else if !result.Success {
+			status = http.StatusBadGateway
+		}
+		writeJSON(w, status, triggerResponse{
+			Command:  command,
+			Executed: result.Executed,
+			Success:  result.Success,
+			Response: response,
+			Messages: result.Replies,
+		})
+	})
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTriggerServer(t *testing.T, config *Config) (*httptest.Server, *fakeMessenger) {
+	t.Helper()
+	state := newBotState(config)
+	messenger := newFakeMessenger()
+	state.notifier = messenger
+	mux := http.NewServeMux()
+	registerTriggerRoutes(context.Background(), mux, config, newConfigTaskStore(config.Tasks), state)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, messenger
+}
+
+func TestTriggerHandler(t *testing.T) {
+	target, _ := newStubTarget(t)
+	config := &Config{
+		TriggerToken:         "trigger-token",
+		TriggerMirrorChannel: "COPS",
+		AckReaction:          "none",
+		DebounceMillis:       -1,
+		Tasks: map[string]Task{
+			"health":  {Command: "health", URL: target.URL + "/ok", Method: "GET"},
+			"restart": {Command: "restart", URL: target.URL + "/ok/{service}", Method: "POST", Args: []ArgSpec{{Name: "service", Type: "enum", Values: []string{"api", "web"}}}},
+			"rebuild": {Command: "rebuild", URL: target.URL + "/fail", Method: "GET"},
+			"purge":   {Command: "purge", URL: target.URL + "/ok", Method: "GET", AllowedUsers: []string{"U1"}},
+		},
+	}
+	server, messenger := newTriggerServer(t, config)
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		token        string
+		wantStatus   int
+		wantResponse string
+		wantMirror   string // Message posted to the mirror channel, empty for none
+	}{
+		{name: "no token", method: "POST", path: "/trigger/health", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", method: "POST", path: "/trigger/health", token: "nope", wantStatus: http.StatusUnauthorized},
+		{name: "GET not allowed", method: "GET", path: "/trigger/health", token: "trigger-token", wantStatus: http.StatusMethodNotAllowed},
+		{name: "missing command", method: "POST", path: "/trigger/", token: "trigger-token", wantStatus: http.StatusNotFound},
+		{
+			name: "success", method: "POST", path: "/trigger/health", token: "trigger-token",
+			wantStatus: http.StatusOK, wantResponse: "Task 'health' executed successfully.",
+			wantMirror: "'health' triggered over HTTP: Task 'health' executed successfully.",
+		},
+		{
+			name: "task failed", method: "POST", path: "/trigger/rebuild", token: "trigger-token",
+			wantStatus: http.StatusBadGateway, wantResponse: "Task 'rebuild' failed to execute.",
+			wantMirror: "'rebuild' triggered over HTTP: Task 'rebuild' failed to execute.",
+		},
+		{
+			name: "not executed", method: "POST", path: "/trigger/purge", token: "trigger-token",
+			wantStatus: http.StatusConflict, wantResponse: "You are not allowed to run 'purge'.",
+			wantMirror: "'purge' triggered over HTTP: You are not allowed to run 'purge'.",
+		},
+		{
+			name: "task with arguments", method: "POST", path: "/trigger/restart%20api", token: "trigger-token",
+			wantStatus: http.StatusOK, wantResponse: "Task 'restart' executed successfully.",
+			wantMirror: "'restart api' triggered over HTTP: Task 'restart' executed successfully.",
+		},
+		{
+			name: "unknown command not executed", method: "POST", path: "/trigger/nope%20now", token: "trigger-token",
+			wantStatus: http.StatusConflict, wantResponse: "I don't know your message. Please try again.",
+			wantMirror: "'nope now' triggered over HTTP: I don't know your message. Please try again.",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := len(messenger.sent())
+			req, err := http.NewRequest(test.method, server.URL+test.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != test.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, test.wantStatus)
+			}
+			if test.wantResponse != "" {
+				var got triggerResponse
+				if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+					t.Fatal(err)
+				}
+				if !strings.HasPrefix(got.Response, test.wantResponse) {
+					t.Errorf("response = %q, want prefix %q", got.Response, test.wantResponse)
+				}
+				if len(got.Messages) == 0 || got.Messages[len(got.Messages)-1].Text != got.Response {
+					t.Errorf("messages = %+v, want the response last", got.Messages)
+				}
+			}
+
+			mirrored := messenger.sent()[before:]
+			if test.wantMirror == "" {
+				if len(mirrored) != 0 {
+					t.Errorf("mirrored %+v, want nothing", mirrored)
+				}
+				return
+			}
+			if len(mirrored) != 1 || mirrored[0].ChannelID != "COPS" || !strings.HasPrefix(mirrored[0].Text, test.wantMirror) {
+				t.Errorf("mirrored %+v, want %q in COPS", mirrored, test.wantMirror)
+			}
+		})
+	}
+}
+
+func TestTriggerRoutesNeedToken(t *testing.T) {
+	server, _ := newTriggerServer(t, &Config{})
+	resp, err := http.Post(server.URL+"/trigger/health", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want 404 without a trigger_token", resp.StatusCode)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// ttlCache is a concurrency-safe map whose entries expire after their own TTL.
+// It holds at most maxSize entries: when full, expired entries are dropped
+// first, then the one closest to expiring.
+type ttlCache[K comparable, V any] struct {
+	mu      sync.Mutex
+	entries map[K]ttlEntry[V]
+	maxSize int
+}
+
+type ttlEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func newTTLCache[K comparable, V any](maxSize int) *ttlCache[K, V] {
+	return &ttlCache[K, V]{entries: make(map[K]ttlEntry[V]), maxSize: maxSize}
+}
+
+// Return the value stored for key unless it expired
+func (c *ttlCache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.get(key, time.Now())
+}
+
+// Store value for key for the next ttl
+func (c *ttlCache[K, V]) Set(key K, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(key, value, ttl, time.Now())
+}
+
+// Store value for key unless it holds an unexpired value, which is returned
+// with true instead. Checking and storing are one step, so concurrent
+// callers can't both claim the key.
+func (c *ttlCache[K, V]) SetIfAbsent(key K, value V, ttl time.Duration) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if existing, ok := c.get(key, now); ok {
+		return existing, true
+	}
+	c.set(key, value, ttl, now)
+	var zero V
+	return zero, false
+}
+
+// Number of entries held, including expired ones not yet evicted
+func (c *ttlCache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+func (c *ttlCache[K, V]) get(key K, now time.Time) (V, bool) {
+	entry, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	if !now.Before(entry.expires) {
+		delete(c.entries, key)
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func (c *ttlCache[K, V]) set(key K, value V, ttl time.Duration, now time.Time) {
+	if _, ok := c.entries[key]; !ok && c.maxSize > 0 && len(c.entries) >= c.maxSize {
+		c.evict(now)
+	}
+	c.entries[key] = ttlEntry[V]{value: value, expires: now.Add(ttl)}
+}
+
+// Make room for one entry
+func (c *ttlCache[K, V]) evict(now time.Time) {
+	var soonestKey K
+	var soonest time.Time
+	found := false
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+			continue
+		}
+		if !found || entry.expires.Before(soonest) {
+			soonestKey, soonest, found = key, entry.expires, true
+		}
+	}
+	if len(c.entries) >= c.maxSize && found {
+		delete(c.entries, soonestKey)
+	}
+}
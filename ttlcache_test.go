@@ -0,0 +1,135 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTTLCacheGet(t *testing.T) {
+	tests := []struct {
+		name   string
+		ttl    time.Duration
+		wait   time.Duration
+		wantOK bool
+	}{
+		{name: "fresh", ttl: time.Minute, wantOK: true},
+		{name: "expired", ttl: time.Millisecond, wait: 5 * time.Millisecond},
+		{name: "zero ttl", ttl: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cache := newTTLCache[string, int](10)
+			cache.Set("a", 1, test.ttl)
+			time.Sleep(test.wait)
+			got, ok := cache.Get("a")
+			if ok != test.wantOK || (ok && got != 1) {
+				t.Errorf("Get() = %d, %v, want 1, %v", got, ok, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestTTLCacheSetIfAbsent(t *testing.T) {
+	cache := newTTLCache[string, int](10)
+	if _, found := cache.SetIfAbsent("a", 1, time.Minute); found {
+		t.Fatal("first SetIfAbsent found a value")
+	}
+	existing, found := cache.SetIfAbsent("a", 2, time.Minute)
+	if !found || existing != 1 {
+		t.Errorf("second SetIfAbsent = %d, %v, want 1, true", existing, found)
+	}
+	if got, _ := cache.Get("a"); got != 1 {
+		t.Errorf("value = %d, want the first one kept", got)
+	}
+
+	cache.SetIfAbsent("b", 1, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, found := cache.SetIfAbsent("b", 2, time.Minute); found {
+		t.Error("SetIfAbsent found an expired value")
+	}
+}
+
+// Only one of many concurrent callers claims a key
+func TestTTLCacheSetIfAbsentConcurrent(t *testing.T) {
+	cache := newTTLCache[string, int](10)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	claimed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, found := cache.SetIfAbsent("key", i, time.Minute); !found {
+				mu.Lock()
+				claimed++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if claimed != 1 {
+		t.Errorf("%d callers claimed the key, want 1", claimed)
+	}
+}
+
+func TestTTLCacheEviction(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(c *ttlCache[string, int])
+		wantKept []string
+		wantGone []string
+	}{
+		{
+			name: "soonest to expire evicted",
+			setup: func(c *ttlCache[string, int]) {
+				c.Set("long", 1, time.Hour)
+				c.Set("short", 2, time.Minute)
+				c.Set("new", 3, time.Hour)
+			},
+			wantKept: []string{"long", "new"},
+			wantGone: []string{"short"},
+		},
+		{
+			name: "expired entries evicted first",
+			setup: func(c *ttlCache[string, int]) {
+				c.Set("expired", 1, time.Millisecond)
+				c.Set("short", 2, time.Minute)
+				time.Sleep(5 * time.Millisecond)
+				c.Set("new", 3, time.Hour)
+			},
+			wantKept: []string{"short", "new"},
+			wantGone: []string{"expired"},
+		},
+		{
+			name: "updating a key evicts nothing",
+			setup: func(c *ttlCache[string, int]) {
+				c.Set("a", 1, time.Minute)
+				c.Set("b", 2, time.Hour)
+				c.Set("a", 3, time.Minute)
+			},
+			wantKept: []string{"a", "b"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cache := newTTLCache[string, int](2)
+			test.setup(cache)
+			if cache.Len() > 2 {
+				t.Errorf("Len() = %d, want at most 2", cache.Len())
+			}
+			for _, key := range test.wantKept {
+				if _, ok := cache.Get(key); !ok {
+					t.Errorf("%q evicted, want kept", key)
+				}
+			}
+			for _, key := range test.wantGone {
+				if _, ok := cache.Get(key); ok {
+					t.Errorf("%q kept, want evicted", key)
+				}
+			}
+		})
+	}
+}
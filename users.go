@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Profile of the user who invoked a command
+type chatUser struct {
+	Name  string
+	Email string
+}
+
+// userDirectory is implemented by backends that can look up user profiles
+type userDirectory interface {
+	LookupUser(userID string) (chatUser, error)
+}
+
+// How long looked-up user profiles are reused
+const userCacheTTL = time.Hour
+
+// Most user profiles kept by a messenger
+const userCacheSize = 4096
+
+// userCache remembers user profiles so each command doesn't cost a lookup
+type userCache struct {
+	entries *ttlCache[string, chatUser]
+}
+
+func newUserCache() *userCache {
+	return &userCache{entries: newTTLCache[string, chatUser](userCacheSize)}
+}
+
+func (c *userCache) get(userID string, lookup func(string) (chatUser, error)) (chatUser, error) {
+	if user, ok := c.entries.Get(userID); ok {
+		return user, nil
+	}
+
+	user, err := lookup(userID)
+	if err != nil {
+		return chatUser{}, err
+	}
+	c.entries.Set(userID, user, userCacheTTL)
+	return user, nil
+}
+
+// Template variables describing the invoking user. The name falls back to
+// the ID when the backend can't look users up.
+func userVariables(messenger Messenger, userID string) map[string]string {
+	vars := map[string]string{"user_id": userID, "user_name": userID, "user_email": ""}
+	if directory, ok := messenger.(userDirectory); ok && userID != "" {
+		user, err := directory.LookupUser(userID)
+		if err != nil {
+			log.Printf("Error looking up user %s: %v", userID, err)
+		} else {
+			if user.Name != "" {
+				vars["user_name"] = user.Name
+			}
+			vars["user_email"] = user.Email
+		}
+	}
+	return vars
+}
+
+// Replace {user_id}, {user_name} and {user_email} in the task's URLs, body,
+// form data and headers, including the steps of a chain. Values are escaped
+// for the place they end up in.
+func applyUserVariables(task Task, vars map[string]string) Task {
+	replace := func(text string, escape func(string) string) string {
+		return substituteVariables(text, vars, escape)
+	}
+	raw := func(value string) string { return value }
+
+	task.URL = replace(task.URL, urlEscape)
+	if len(task.URLs) > 0 {
+		urls := make([]string, len(task.URLs))
+		for i, target := range task.URLs {
+			urls[i] = replace(target, urlEscape)
+		}
+		task.URLs = urls
+	}
+	task.Body = replace(task.Body, jsonEscape)
+	task.ResourceKey = replace(task.ResourceKey, raw)
+	if len(task.FormData) > 0 {
+		form := make(map[string]string, len(task.FormData))
+		for key, value := range task.FormData {
+			form[key] = replace(value, raw)
+		}
+		task.FormData = form
+	}
+	if len(task.Headers) > 0 {
+		headers := make(map[string]string, len(task.Headers))
+		for key, value := range task.Headers {
+			headers[key] = replace(value, raw)
+		}
+		task.Headers = headers
+	}
+	if task.Precondition != nil {
+		pre := *task.Precondition
+		pre.URL = replace(pre.URL, urlEscape)
+		if len(pre.Headers) > 0 {
+			headers := make(map[string]string, len(pre.Headers))
+			for key, value := range pre.Headers {
+				headers[key] = replace(value, raw)
+			}
+			pre.Headers = headers
+		}
+		task.Precondition = &pre
+	}
+	if len(task.Steps) > 0 {
+		steps := make([]Task, len(task.Steps))
+		for i, step := range task.Steps {
+			steps[i] = applyUserVariables(step, vars)
+		}
+		task.Steps = steps
+	}
+	return task
+}
+
+// Replace {name} placeholders in one pass, so a value that itself contains a
+// placeholder is never expanded again
+func substituteVariables(text string, vars map[string]string, escape func(string) string) string {
+	if len(vars) == 0 || !strings.Contains(text, "{") {
+		return text
+	}
+	pairs := make([]string, 0, 2*len(vars))
+	for name, value := range vars {
+		pairs = append(pairs, "{"+name+"}", escape(value))
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// Escape a value for a URL path segment or query parameter
+func urlEscape(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+// Escape a value for use inside a JSON string literal
+func jsonEscape(value string) string {
+	encoded, _ := json.Marshal(value)
+	return string(encoded[1 : len(encoded)-1])
+}
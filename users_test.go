@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// fakeDirectory is a fakeMessenger that can look users up
+type fakeDirectory struct {
+	*fakeMessenger
+	users   map[string]chatUser
+	lookups int
+}
+
+func (d *fakeDirectory) LookupUser(userID string) (chatUser, error) {
+	d.lookups++
+	user, ok := d.users[userID]
+	if !ok {
+		return chatUser{}, errors.New("user_not_found")
+	}
+	return user, nil
+}
+
+// Placeholders are replaced in one pass, so values are never expanded again
+func TestSubstituteVariables(t *testing.T) {
+	vars := map[string]string{"user_name": "ann", "service": "{user_name}"}
+	raw := func(value string) string { return value }
+
+	tests := []struct {
+		name   string
+		text   string
+		escape func(string) string
+		want   string
+	}{
+		{name: "placeholder", text: "hi {user_name}", escape: raw, want: "hi ann"},
+		{name: "unknown placeholder kept", text: "{nope} {user_name}", escape: raw, want: "{nope} ann"},
+		{name: "values not expanded again", text: "svc={service}", escape: raw, want: "svc={user_name}"},
+		{name: "escaped", text: "/run/{user_name}", escape: func(v string) string { return "<" + v + ">" }, want: "/run/<ann>"},
+		{name: "no placeholders", text: "plain", escape: raw, want: "plain"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := substituteVariables(test.text, vars, test.escape); got != test.want {
+				t.Errorf("substituteVariables(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestEscapes(t *testing.T) {
+	tests := []struct {
+		value    string
+		wantURL  string
+		wantJSON string
+	}{
+		{value: "ann", wantURL: "ann", wantJSON: "ann"},
+		{value: "a b&c=d", wantURL: "a%20b%26c%3Dd", wantJSON: `a b\u0026c=d`},
+		{value: `say "hi"` + "\n", wantURL: "say%20%22hi%22%0A", wantJSON: `say \"hi\"\n`},
+		{value: "a/b", wantURL: "a%2Fb", wantJSON: "a/b"},
+	}
+	for _, test := range tests {
+		if got := urlEscape(test.value); got != test.wantURL {
+			t.Errorf("urlEscape(%q) = %q, want %q", test.value, got, test.wantURL)
+		}
+		if got := jsonEscape(test.value); got != test.wantJSON {
+			t.Errorf("jsonEscape(%q) = %q, want %q", test.value, got, test.wantJSON)
+		}
+	}
+}
+
+func TestApplyUserVariables(t *testing.T) {
+	vars := map[string]string{"user_id": "U1", "user_name": `ann "a"`, "user_email": "ann@example.com"}
+	task := Task{
+		URL:      "https://example.com/run?by={user_name}&id={user_id}",
+		URLs:     []string{"https://a/{user_name}"},
+		Body:     `{"by": "{user_name}", "email": "{user_email}"}`,
+		FormData: map[string]string{"by": "{user_name}"},
+		Headers:  map[string]string{"X-By": "{user_name}", "X-Unknown": "{nope}"},
+		Steps:    []Task{{URL: "https://step/{user_name}"}},
+	}
+
+	got := applyUserVariables(task, vars)
+	want := Task{
+		URL:      "https://example.com/run?by=ann%20%22a%22&id=U1",
+		URLs:     []string{"https://a/ann%20%22a%22"},
+		Body:     `{"by": "ann \"a\"", "email": "ann@example.com"}`,
+		FormData: map[string]string{"by": `ann "a"`},
+		Headers:  map[string]string{"X-By": `ann "a"`, "X-Unknown": "{nope}"},
+		Steps:    []Task{{URL: "https://step/ann%20%22a%22"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("applyUserVariables =\n%+v\nwant\n%+v", got, want)
+	}
+	if task.Headers["X-By"] != "{user_name}" || task.FormData["by"] != "{user_name}" || task.Steps[0].URL != "https://step/{user_name}" {
+		t.Error("applyUserVariables modified the original task")
+	}
+}
+
+func TestUserVariables(t *testing.T) {
+	directory := &fakeDirectory{fakeMessenger: newFakeMessenger(), users: map[string]chatUser{"U1": {Name: "ann", Email: "ann@example.com"}}}
+	tests := []struct {
+		name      string
+		messenger Messenger
+		userID    string
+		want      map[string]string
+	}{
+		{name: "looked up", messenger: directory, userID: "U1", want: map[string]string{"user_id": "U1", "user_name": "ann", "user_email": "ann@example.com"}},
+		{name: "lookup fails", messenger: directory, userID: "U2", want: map[string]string{"user_id": "U2", "user_name": "U2", "user_email": ""}},
+		{name: "no directory", messenger: newFakeMessenger(), userID: "U1", want: map[string]string{"user_id": "U1", "user_name": "U1", "user_email": ""}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := userVariables(test.messenger, test.userID); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("userVariables = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// Profiles are looked up once; failed lookups are retried
+func TestUserCache(t *testing.T) {
+	directory := &fakeDirectory{users: map[string]chatUser{"U1": {Name: "ann"}}}
+	cache := newUserCache()
+	for i := 0; i < 3; i++ {
+		if user, err := cache.get("U1", directory.LookupUser); err != nil || user.Name != "ann" {
+			t.Fatalf("get = %+v, %v", user, err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := cache.get("U2", directory.LookupUser); err == nil {
+			t.Error("lookup of an unknown user succeeded")
+		}
+	}
+	if directory.lookups != 3 {
+		t.Errorf("%d lookups, want 1 for U1 and 2 for U2", directory.lookups)
+	}
+}
+
+// The invoking user reaches the task request in its URL, body and headers
+func TestHandleMessageUserVariables(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		requests <- r
+	}))
+	defer target.Close()
+	config := &Config{}
+	store := newConfigTaskStore(map[string]Task{"restart": {
+		Command:  "restart",
+		URL:      target.URL + "/restart/{user_name}",
+		Method:   "POST",
+		FormData: map[string]string{"email": "{user_email}"},
+		Headers:  map[string]string{"X-Triggered-By": "{user_id}"},
+	}})
+	directory := &fakeDirectory{fakeMessenger: newFakeMessenger(), users: map[string]chatUser{"U1": {Name: "ann smith", Email: "ann@example.com"}}}
+
+	handleMessageEvent(context.Background(), directory, messageEvent("U1", "restart"), config, store, newBotState(config))
+
+	r := <-requests
+	if r.URL.Path != "/restart/ann smith" || r.PostForm.Get("email") != "ann@example.com" || r.Header.Get("X-Triggered-By") != "U1" {
+		t.Errorf("request to %s with form %v and X-Triggered-By %q", r.URL.Path, r.PostForm, r.Header.Get("X-Triggered-By"))
+	}
+}
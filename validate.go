@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Check the configuration for mistakes, reporting every problem found
+func validateConfig(config *Config) error {
+	var errs []error
+
+	commands := make([]string, 0, len(config.Tasks))
+	for command := range config.Tasks {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+
+	for _, command := range commands {
+		if err := validateTask(command, config.Tasks[command]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if config.FallbackCommand != "" && config.TaskDB == "" {
+		if _, ok := config.Tasks[strings.ToLower(config.FallbackCommand)]; !ok {
+			errs = append(errs, fmt.Errorf("fallback_command %q is not a task", config.FallbackCommand))
+		}
+	}
+	for _, pattern := range config.LogMaskPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs = append(errs, fmt.Errorf("invalid log_mask_patterns entry %q: %w", pattern, err))
+		}
+	}
+	if err := validateJenkinsURLFormat(config.Jenkins.URLFormat); err != nil {
+		errs = append(errs, err)
+	}
+	if config.Jenkins.SuccessBodyPattern != "" {
+		if _, err := regexp.Compile(config.Jenkins.SuccessBodyPattern); err != nil {
+			errs = append(errs, fmt.Errorf("jenkins: invalid success_body_pattern: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Check that a Jenkins url_format has the placeholders every deploy needs.
+// {branch} is optional since not every job takes a branch.
+func validateJenkinsURLFormat(format string) error {
+	if format == "" {
+		return nil
+	}
+	var errs []error
+	for _, placeholder := range []string{"{service-name}", "{env}"} {
+		if !strings.Contains(format, placeholder) {
+			errs = append(errs, fmt.Errorf("jenkins url_format is missing the %s placeholder", placeholder))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Check a single task definition, including the steps of a chain
+func validateTask(command string, task Task) error {
+	var errs []error
+
+	if task.URL != "" && len(task.URLs) > 0 {
+		errs = append(errs, fmt.Errorf("task '%s': url and urls are mutually exclusive", command))
+	}
+	if task.URLStrategy != "" && task.URLStrategy != "random" && task.URLStrategy != "round_robin" {
+		errs = append(errs, fmt.Errorf("task '%s': unknown url_strategy '%s'", command, task.URLStrategy))
+	}
+	if task.Body != "" && len(task.FormData) > 0 {
+		errs = append(errs, fmt.Errorf("task '%s': body and form_data are mutually exclusive", command))
+	}
+	if (task.Body != "" || len(task.FormData) > 0) && task.Method != "POST" {
+		errs = append(errs, fmt.Errorf("task '%s': body and form_data require method POST", command))
+	}
+	if task.SuccessBodyPattern != "" {
+		if _, err := regexp.Compile(task.SuccessBodyPattern); err != nil {
+			errs = append(errs, fmt.Errorf("task '%s': invalid success_body_pattern: %w", command, err))
+		}
+	}
+	if task.GitHubWorkflow != nil {
+		if err := validateGitHubWorkflow(task.GitHubWorkflow); err != nil {
+			errs = append(errs, fmt.Errorf("task '%s': %w", command, err))
+		}
+	}
+	if task.TimeoutSeconds < 0 {
+		errs = append(errs, fmt.Errorf("task '%s': timeout_seconds must not be negative", command))
+	}
+	if task.CacheSeconds > 0 && task.Method == "POST" {
+		errs = append(errs, fmt.Errorf("task '%s': cache_seconds is only supported for GET tasks", command))
+	}
+	if task.ProxyURL != "" {
+		if _, err := url.Parse(task.ProxyURL); err != nil {
+			errs = append(errs, fmt.Errorf("task '%s': invalid proxy_url: %w", command, err))
+		}
+	}
+	for key := range task.StatusMessages {
+		if _, _, ok := parseStatusKey(key); !ok {
+			errs = append(errs, fmt.Errorf("task '%s': status_messages key %q is not a code, range or class like 4xx", command, key))
+		}
+	}
+	if pre := task.Precondition; pre != nil {
+		if pre.URL == "" {
+			errs = append(errs, fmt.Errorf("task '%s': precondition needs a url", command))
+		}
+		if _, err := regexp.Compile(pre.Pattern); err != nil {
+			errs = append(errs, fmt.Errorf("task '%s': invalid precondition pattern: %w", command, err))
+		}
+	}
+	if err := validateArgSpecs(task.Args); err != nil {
+		errs = append(errs, fmt.Errorf("task '%s': %w", command, err))
+	}
+	if err := validateTimeWindow(task); err != nil {
+		errs = append(errs, fmt.Errorf("task '%s': %w", command, err))
+	}
+	for i, step := range task.Steps {
+		if err := validateTask(fmt.Sprintf("%s step %d", command, i+1), step); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Load and validate the configuration for -validate, printing each problem.
+// Returns the process exit code.
+func runValidate(configPath string) int {
+	config, err := loadConfig(configPath)
+	if err == nil {
+		err = validateConfig(config)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration %s is invalid:\n", configPath)
+		for _, problem := range strings.Split(err.Error(), "\n") {
+			fmt.Fprintf(os.Stderr, "- %s\n", problem)
+		}
+		return 1
+	}
+	fmt.Printf("Configuration %s is valid.\n", configPath)
+	return 0
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateTask(t *testing.T) {
+	tests := []struct {
+		name    string
+		task    Task
+		wantErr string
+	}{
+		{name: "plain task", task: Task{URL: "https://example.com", Method: "GET"}},
+		{name: "form body", task: Task{URL: "https://example.com", Method: "POST", FormData: map[string]string{"a": "1"}}},
+		{name: "raw body", task: Task{URL: "https://example.com", Method: "POST", Body: `{"a":1}`}},
+		{name: "body and form_data", task: Task{URL: "https://example.com", Method: "POST", Body: `{}`, FormData: map[string]string{"a": "1"}}, wantErr: "task 'legacy': body and form_data are mutually exclusive"},
+		{name: "form_data on GET", task: Task{URL: "https://example.com", Method: "GET", FormData: map[string]string{"a": "1"}}, wantErr: "task 'legacy': body and form_data require method POST"},
+		{name: "invalid success pattern", task: Task{URL: "https://example.com", Method: "GET", SuccessBodyPattern: "("}, wantErr: "task 'legacy': invalid success_body_pattern"},
+		{name: "cached POST", task: Task{URL: "https://example.com", Method: "POST", CacheSeconds: 30}, wantErr: "task 'legacy': cache_seconds is only supported for GET tasks"},
+		{name: "invalid step", task: Task{Steps: []Task{{URL: "https://example.com", Method: "GET"}, {URL: "https://example.com", Body: "{}"}}}, wantErr: "task 'legacy step 2': body and form_data require method POST"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateTask("legacy", test.task)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateTask = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("validateTask = %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+// Every invalid task is reported at once
+func TestValidateConfigReportsAllTasks(t *testing.T) {
+	err := validateConfig(&Config{Tasks: map[string]Task{
+		"ok":    {URL: "https://example.com", Method: "GET"},
+		"two":   {URL: "https://example.com", Method: "GET", Body: "{}"},
+		"three": {URL: "https://example.com", Method: "POST", Body: "{}", FormData: map[string]string{"a": "1"}},
+		"four":  {URL: "https://example.com", Method: "POST", Precondition: &Precondition{Pattern: "("}},
+		"five":  {URL: "https://example.com", Method: "GET", StatusMessages: map[string]string{"4xx": "client error", "6xx": "never"}},
+		"six":   {URL: "https://example.com", Method: "GET", TimeoutSeconds: -1},
+	}, Jenkins: JenkinsConfig{SuccessBodyPattern: "("}, FallbackCommand: "ask", LogMaskPatterns: []string{"xoxb-[0-9]+", "["}})
+	if err == nil {
+		t.Fatal("invalid config accepted")
+	}
+	for _, want := range []string{"task 'two'", "task 'three'", "jenkins: invalid success_body_pattern", `fallback_command "ask" is not a task`, `invalid log_mask_patterns entry "["`, "task 'four': precondition needs a url", "task 'four': invalid precondition pattern", `task 'five': status_messages key "6xx"`, "task 'six': timeout_seconds must not be negative"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "task 'ok'") {
+		t.Errorf("error %q mentions the valid task", err)
+	}
+}
+
+func TestValidateJenkinsURLFormat(t *testing.T) {
+	tests := []struct {
+		format  string
+		wantErr []string
+	}{
+		{format: ""},
+		{format: "https://ci/job/{service-name}/buildWithParameters?env={env}"},
+		{format: "https://ci/job/{service-name}/job/{branch}/build?env={env}"},
+		{format: "https://ci/job/{service-name}/build", wantErr: []string{"{env}"}},
+		{format: "https://ci/job/api/build", wantErr: []string{"{service-name}", "{env}"}},
+	}
+
+	for _, test := range tests {
+		err := validateJenkinsURLFormat(test.format)
+		if len(test.wantErr) == 0 {
+			if err != nil {
+				t.Errorf("validateJenkinsURLFormat(%q) = %v, want nil", test.format, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("validateJenkinsURLFormat(%q) = nil, want errors about %v", test.format, test.wantErr)
+			continue
+		}
+		for _, placeholder := range test.wantErr {
+			if !strings.Contains(err.Error(), placeholder) {
+				t.Errorf("validateJenkinsURLFormat(%q) = %v, want it to name %s", test.format, err, placeholder)
+			}
+		}
+	}
+}
+
+// -validate prints every problem and exits 1, or confirms a valid config with 0
+func TestRunValidate(t *testing.T) {
+	t.Setenv("BOT_ENV", "")
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.json")
+	writeFile(t, valid, `{"tasks": {"restart": {"url": "https://example.com/restart", "method": "POST"}}}`)
+	invalid := filepath.Join(dir, "invalid.json")
+	writeFile(t, invalid, `{"tasks": {"purge": {"url": "https://example.com/purge", "method": "GET", "body": "{}"}}, "jenkins": {"url_format": "https://ci/job/{service-name}/build"}}`)
+	broken := filepath.Join(dir, "broken.json")
+	writeFile(t, broken, `{"tasks": `)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantCode   int
+		wantOutput []string
+	}{
+		{name: "valid", path: valid, wantCode: 0, wantOutput: []string{"Configuration " + valid + " is valid.\n"}},
+		{name: "invalid", path: invalid, wantCode: 1, wantOutput: []string{
+			"Configuration " + invalid + " is invalid:\n",
+			"- task 'purge': body and form_data require method POST\n",
+			"- jenkins url_format is missing the {env} placeholder\n",
+		}},
+		{name: "unparsable", path: broken, wantCode: 1, wantOutput: []string{"Configuration " + broken + " is invalid:\n"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stderr := os.Stderr
+			errFile, err := os.Create(filepath.Join(t.TempDir(), "stderr"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			os.Stderr = errFile
+			var code int
+			stdout := captureStdout(t, func() { code = runValidate(test.path) })
+			os.Stderr = stderr
+			errFile.Close()
+			errOutput, _ := os.ReadFile(errFile.Name())
+
+			if code != test.wantCode {
+				t.Errorf("exit code = %d, want %d", code, test.wantCode)
+			}
+			output := stdout + string(errOutput)
+			for _, want := range test.wantOutput {
+				if !strings.Contains(output, want) {
+					t.Errorf("output %q does not contain %q", output, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// CompletionEvent is the JSON summary posted to the completion webhook
+type CompletionEvent struct {
+	Command    string    `json:"command"`
+	User       string    `json:"user"`
+	Status     string    `json:"status"`      // "success" or "failure"
+	DurationMs int64     `json:"duration_ms"` // Execution time in milliseconds
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// Header carrying the HMAC-SHA256 of an outbound body, as "sha256=<hex>"
+const signatureHeader = "X-Signature"
+
+// Notify the external system that a command finished executing, signing the
+// payload when secret is set. Errors are only logged so they never affect the Slack reply.
+func notifyCompletion(webhookURL, secret, command, user string, success bool, duration time.Duration) {
+	if webhookURL == "" {
+		return
+	}
+
+	payload, err := json.Marshal(CompletionEvent{
+		Command:    command,
+		User:       user,
+		Status:     statusText(success),
+		DurationMs: duration.Milliseconds(),
+		Timestamp:  time.Now().UTC(),
+	})
+	if err != nil {
+		log.Printf("Error encoding completion event: %v", err)
+		return
+	}
+
+	req, err := http.NewRequest("POST", webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		log.Printf("Error creating completion webhook request: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set(signatureHeader, signPayload(secret, payload))
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second, Transport: tracedTransport}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("Error sending completion webhook to %s: %v", webhookURL, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Printf("Completion webhook at %s returned status: %s", webhookURL, resp.Status)
+	}
+}
+
+// HMAC-SHA256 of the body with the shared secret, for the X-Signature header
+func signPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
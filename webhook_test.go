@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// The incident tracker parses these field names, so check the JSON itself
+// rather than a round trip through CompletionEvent
+func TestNotifyCompletionPayload(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got %s with Content-Type %q, want a JSON POST", r.Method, r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer receiver.Close()
+
+	notifyCompletion(receiver.URL, "", "deploy api prod", "U123", false, 1500*time.Millisecond)
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(<-bodies, &payload); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"command":     "deploy api prod",
+		"user":        "U123",
+		"status":      "failure",
+		"duration_ms": float64(1500),
+	}
+	for key, value := range want {
+		if payload[key] != value {
+			t.Errorf("%s = %v, want %v", key, payload[key], value)
+		}
+	}
+	timestamp, _ := payload["timestamp"].(string)
+	if sent, err := time.Parse(time.RFC3339, timestamp); err != nil || time.Since(sent) > time.Minute {
+		t.Errorf("timestamp = %q, want the current time in RFC 3339", timestamp)
+	}
+	if len(payload) != len(want)+1 {
+		t.Errorf("payload has fields %v, want only %v and timestamp", payload, want)
+	}
+}
+
+// A failing receiver is only logged; notifyCompletion must still return
+func TestNotifyCompletionReceiverDown(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	receiver.Close()
+
+	done := make(chan struct{})
+	go func() {
+		notifyCompletion(receiver.URL, "", "restart", "U123", true, time.Second)
+		notifyCompletion("", "", "restart", "U123", true, time.Second)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(15 * time.Second):
+		t.Fatal("notifyCompletion blocked on an unreachable receiver")
+	}
+}
+
+func TestSignPayload(t *testing.T) {
+	tests := []struct {
+		secret string
+		body   string
+		want   string
+	}{
+		// RFC 4231 test case 2
+		{secret: "Jefe", body: "what do ya want for nothing?", want: "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"},
+		{secret: "s3cret", body: "", want: "sha256=91dfac70c5348b04e1babb8b421ac92cec08b565b49ca16130dccb72503647b7"},
+	}
+	for _, test := range tests {
+		if got := signPayload(test.secret, []byte(test.body)); got != test.want {
+			t.Errorf("signPayload(%q, %q) = %q, want %q", test.secret, test.body, got, test.want)
+		}
+	}
+}
+
+// Bodies are signed for the webhook and for tasks with a signing_secret
+func TestSignedRequests(t *testing.T) {
+	runTask := func(url, secret string) {
+		task := Task{Command: "restart", URL: url, Method: "POST", Body: `{"service":"api"}`, SigningSecret: secret}
+		executeTask(context.Background(), &Config{}, task)
+	}
+	notify := func(url, secret string) { notifyCompletion(url, secret, "restart", "U1", true, time.Second) }
+
+	tests := []struct {
+		name   string
+		secret string
+		send   func(url, secret string)
+	}{
+		{name: "unsigned webhook", send: notify},
+		{name: "signed webhook", secret: "s3cret", send: notify},
+		{name: "unsigned task", send: runTask},
+		{name: "signed task", secret: "s3cret", send: runTask},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var signature string
+			var body []byte
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				signature = r.Header.Get(signatureHeader)
+				body, _ = io.ReadAll(r.Body)
+			}))
+			defer receiver.Close()
+
+			test.send(receiver.URL, test.secret)
+			want := ""
+			if test.secret != "" {
+				want = signPayload(test.secret, body)
+			}
+			if signature != want || len(body) == 0 {
+				t.Errorf("%s = %q, want %q for body %s", signatureHeader, signature, want, body)
+			}
+		})
+	}
+}
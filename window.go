@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // The runtime image has no zoneinfo, so embed it for task timezones
+)
+
+// Days accepted in allowed_days, by their three-letter name
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Parse an allowed_hours window like "09:00-17:00" into minutes since midnight.
+// The end may be earlier than the start for windows that span midnight.
+func parseAllowedHours(window string) (start, end int, err error) {
+	from, to, ok := strings.Cut(window, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("expected HH:MM-HH:MM, got %q", window)
+	}
+	if start, err = parseClock(strings.TrimSpace(from)); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parseClock(strings.TrimSpace(to)); err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Check the task's allowed_hours, allowed_days and timezone
+func validateTimeWindow(task Task) error {
+	if task.AllowedHours != "" {
+		if _, _, err := parseAllowedHours(task.AllowedHours); err != nil {
+			return fmt.Errorf("invalid allowed_hours: %w", err)
+		}
+	}
+	for _, day := range task.AllowedDays {
+		if _, ok := weekdayNames[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("invalid allowed_days entry %q, expected Mon, Tue, ...", day)
+		}
+	}
+	if _, err := time.LoadLocation(task.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone: %w", err)
+	}
+	return nil
+}
+
+// Report whether the task may run at the given time.
+// Tasks without allowed_hours or allowed_days can always run.
+func inTimeWindow(task Task, now time.Time) bool {
+	if task.AllowedHours == "" && len(task.AllowedDays) == 0 {
+		return true
+	}
+	if loc, err := time.LoadLocation(task.Timezone); err == nil {
+		now = now.In(loc)
+	}
+
+	if len(task.AllowedDays) > 0 {
+		allowed := false
+		for _, day := range task.AllowedDays {
+			if weekday, ok := weekdayNames[strings.ToLower(day)]; ok && weekday == now.Weekday() {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+
+	if task.AllowedHours != "" {
+		start, end, err := parseAllowedHours(task.AllowedHours)
+		if err != nil {
+			return false
+		}
+		minute := now.Hour()*60 + now.Minute()
+		if start <= end {
+			return minute >= start && minute < end
+		}
+		return minute >= start || minute < end
+	}
+	return true
+}
+
+// Tell the user when the command is allowed to run
+func timeWindowMessage(config *Config, userID, command string, task Task) string {
+	var window []string
+	if task.AllowedHours != "" {
+		window = append(window, localize(config, userID, "window_hours", "hours", task.AllowedHours))
+	}
+	if len(task.AllowedDays) > 0 {
+		window = append(window, localize(config, userID, "window_days", "days", strings.Join(task.AllowedDays, ", ")))
+	}
+	if task.Timezone != "" {
+		window = append(window, "("+task.Timezone+")")
+	}
+	return localize(config, userID, "time_window", "command", command, "window", strings.Join(window, " "))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInTimeWindow(t *testing.T) {
+	// Wednesday 10:30 UTC
+	wednesday := time.Date(2024, time.January, 10, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		task Task
+		now  time.Time
+		want bool
+	}{
+		{name: "no window", now: wednesday, want: true},
+		{name: "inside hours", task: Task{AllowedHours: "09:00-17:00"}, now: wednesday, want: true},
+		{name: "before hours", task: Task{AllowedHours: "11:00-17:00"}, now: wednesday, want: false},
+		{name: "end is exclusive", task: Task{AllowedHours: "09:00-10:30"}, now: wednesday, want: false},
+		{name: "overnight window late", task: Task{AllowedHours: "22:00-06:00"}, now: wednesday.Add(12 * time.Hour), want: true},
+		{name: "overnight window early", task: Task{AllowedHours: "22:00-06:00"}, now: wednesday.Add(-7 * time.Hour), want: true},
+		{name: "overnight window midday", task: Task{AllowedHours: "22:00-06:00"}, now: wednesday, want: false},
+		{name: "allowed day", task: Task{AllowedDays: []string{"mon", "Wed"}}, now: wednesday, want: true},
+		{name: "other day", task: Task{AllowedDays: []string{"Sat", "Sun"}}, now: wednesday, want: false},
+		{name: "timezone shifts the hour", task: Task{AllowedHours: "09:00-17:00", Timezone: "America/New_York"}, now: wednesday, want: false},
+		{name: "timezone shifts the day", task: Task{AllowedDays: []string{"Thu"}, Timezone: "Asia/Tokyo"}, now: wednesday.Add(14 * time.Hour), want: true},
+		{name: "broken hours never allowed", task: Task{AllowedHours: "soon"}, now: wednesday, want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := inTimeWindow(test.task, test.now); got != test.want {
+				t.Errorf("inTimeWindow = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateTimeWindow(t *testing.T) {
+	tests := []struct {
+		name    string
+		task    Task
+		wantErr string
+	}{
+		{name: "empty"},
+		{name: "valid", task: Task{AllowedHours: "09:00-17:00", AllowedDays: []string{"Mon"}, Timezone: "Europe/Paris"}},
+		{name: "bad hours", task: Task{AllowedHours: "9-5"}, wantErr: "invalid allowed_hours"},
+		{name: "missing end", task: Task{AllowedHours: "09:00"}, wantErr: "expected HH:MM-HH:MM"},
+		{name: "bad day", task: Task{AllowedDays: []string{"Monday"}}, wantErr: `invalid allowed_days entry "Monday"`},
+		{name: "bad timezone", task: Task{Timezone: "Mars/Olympus"}, wantErr: "invalid timezone"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateTimeWindow(test.task)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateTimeWindow = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("validateTimeWindow = %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+// Outside its window the task is refused privately and never sent
+func TestHandleMessageOutsideTimeWindow(t *testing.T) {
+	target, hits := newStubTarget(t)
+	tomorrow := time.Now().UTC().Add(24 * time.Hour).Format("Mon")
+	config := &Config{}
+	store := newConfigTaskStore(map[string]Task{
+		"purge": {Command: "purge", URL: target.URL + "/ok", Method: "POST", AllowedDays: []string{tomorrow}, Timezone: "UTC"},
+	})
+	messenger := newFakeMessenger()
+	handleMessageEvent(context.Background(), messenger, messageEvent("U1", "purge"), config, store, newBotState(config))
+
+	want := "'purge' can only run on " + tomorrow + " (UTC)."
+	sent := messenger.sent()
+	if len(sent) != 1 || sent[0].UserID != "U1" || sent[0].Text != want {
+		t.Errorf("sent %+v, want an ephemeral %q", sent, want)
+	}
+	if len(hits()) != 0 {
+		t.Errorf("task was sent outside its window: %v", hits())
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// User ID that Workflow Builder commands run as. The payload's user_id is set
+// by whoever builds the workflow, so it's only logged, never trusted for
+// allowlists or admin checks.
+const workflowUserID = "workflow"
+
+// Payload field holding the command unless workflow_command_field is set
+const defaultWorkflowCommandField = "command"
+
+// JSON reply of the workflow endpoint
+type workflowResponse struct {
+	Command  string  `json:"command"`
+	Messages []Reply `json:"messages"`
+}
+
+// Register POST /slack/workflow for Slack Workflow Builder "Send a webhook" steps
+func registerWorkflowRoutes(ctx context.Context, mux *http.ServeMux, config *Config, store TaskStore, state *botState) {
+	if config.SlackSigningSecret == "" {
+		return
+	}
+	mux.Handle("/slack/workflow", workflowHandler(ctx, store, state))
+}
+
+func workflowHandler(ctx context.Context, store TaskStore, state *botState) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			log.Printf("Error reading request body: %v", err)
+			writeJSONError(w, http.StatusBadRequest, "can't read body")
+			return
+		}
+		if err := verifySlackRequest(state.config.Load(), r.Header, body); err != nil {
+			log.Printf("Rejected workflow request from %s: %v", r.RemoteAddr, err)
+			writeJSONError(w, http.StatusUnauthorized, "invalid signature")
+			return
+		}
+
+		var payload map[string]interface{}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "can't parse JSON")
+			return
+		}
+
+		config := state.config.Load()
+		field := config.WorkflowCommandField
+		if field == "" {
+			field = defaultWorkflowCommandField
+		}
+		command, _ := payload[field].(string)
+		command = strings.TrimSpace(command)
+		if command == "" {
+			writeJSONError(w, http.StatusBadRequest, "missing '"+field+"' field")
+			return
+		}
+		meta := CommandMeta{UserID: workflowUserID, ChannelID: "workflow"}
+		channelID, _ := payload["channel_id"].(string)
+		if channelID != "" {
+			meta.ChannelID = channelID
+		}
+		if claimed, _ := payload["user_id"].(string); claimed != "" {
+			log.Printf("Command '%s' triggered by a Slack workflow, on behalf of %s", command, claimed)
+		} else {
+			log.Printf("Command '%s' triggered by a Slack workflow", command)
+		}
+
+		// Stop the run when Slack gives up on the request or the bot shuts down
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			select {
+			case <-r.Context().Done():
+				cancel()
+			case <-runCtx.Done():
+			}
+		}()
+
+		result, _ := newDispatcher(config, store, state).Dispatch(runCtx, command, meta)
+
+		// Workflows can't show the webhook reply, so post it to the channel they passed
+		if channelID != "" && state.notifier != nil {
+			for _, reply := range result.Replies {
+				if reply.Ephemeral {
+					continue
+				}
+				if err := state.notifier.PostMessage(channelID, reply.Text); err != nil {
+					log.Printf("Error sending message to Slack: %v", err)
+				}
+			}
+		}
+		writeJSON(w, http.StatusOK, workflowResponse{Command: command, Messages: result.Replies})
+	})
+}
+
+// Signing secret for requests from a workspace: its app's own from
+// slack_signing_secrets, or slack_signing_secret
+func slackSigningSecret(config *Config, teamID string) string {
+	if secret, ok := config.SlackSigningSecrets[teamID]; ok && teamID != "" {
+		return secret
+	}
+	return config.SlackSigningSecret
+}
+
+// Team ID a Slack request claims to come from: team_id in a JSON body or a
+// form, or team.id in an interaction payload. It only picks the secret that
+// verifies the request, so forging it gains nothing.
+func claimedSlackTeam(body []byte) string {
+	var claimed struct {
+		TeamID string `json:"team_id"`
+		Team   struct {
+			ID string `json:"id"`
+		} `json:"team"`
+	}
+	if err := json.Unmarshal(body, &claimed); err != nil {
+		form, _ := url.ParseQuery(string(body))
+		if form.Get("payload") == "" {
+			return form.Get("team_id")
+		}
+		json.Unmarshal([]byte(form.Get("payload")), &claimed)
+	}
+	if claimed.TeamID != "" {
+		return claimed.TeamID
+	}
+	return claimed.Team.ID
+}
+
+// Check a Slack request's signature with the secret of the workspace it
+// claims to come from
+func verifySlackRequest(config *Config, header http.Header, body []byte) error {
+	return verifySlackSignature(header, body, slackSigningSecret(config, claimedSlackTeam(body)))
+}
+
+// Check the X-Slack-Signature header against the signing secret
+func verifySlackSignature(header http.Header, body []byte, signingSecret string) error {
+	if signingSecret == "" {
+		return errors.New("no signing secret configured")
+	}
+	verifier, err := slack.NewSecretsVerifier(header, signingSecret)
+	if err != nil {
+		return err
+	}
+	if _, err := verifier.Write(body); err != nil {
+		return err
+	}
+	return verifier.Ensure()
+}
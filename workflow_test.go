@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSigningSecret = "signing-secret"
+
+// Sign a request body the way Slack does, with the v0 scheme
+func signSlackRequest(req *http.Request, body, secret string, at time.Time) {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":" + body))
+	req.Header.Set("X-Slack-Request-Timestamp", ts)
+	req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+}
+
+func TestWorkflowHandler(t *testing.T) {
+	target, _ := newStubTarget(t)
+	config := &Config{
+		SlackSigningSecret: testSigningSecret,
+		AckReaction:        "none",
+		DebounceMillis:     -1,
+		Tasks: map[string]Task{
+			"health":   {Command: "health", URL: target.URL + "/ok", Method: "GET"},
+			"restrict": {Command: "restrict", URL: target.URL + "/ok", Method: "GET", AllowedUsers: []string{"UADMIN"}},
+		},
+	}
+	state := newBotState(config)
+	messenger := newFakeMessenger()
+	state.notifier = messenger
+	mux := http.NewServeMux()
+	registerWorkflowRoutes(context.Background(), mux, config, newConfigTaskStore(config.Tasks), state)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		method      string
+		body        string
+		secret      string    // Secret the request is signed with, empty for unsigned
+		signedAt    time.Time // Zero for now
+		wantStatus  int
+		wantReply   string
+		wantChannel []string // Replies posted to the payload's channel
+	}{
+		{name: "GET not allowed", method: "GET", wantStatus: http.StatusMethodNotAllowed},
+		{name: "unsigned", method: "POST", body: `{"command":"health"}`, wantStatus: http.StatusUnauthorized},
+		{name: "wrong secret", method: "POST", body: `{"command":"health"}`, secret: "other", wantStatus: http.StatusUnauthorized},
+		{name: "stale timestamp", method: "POST", body: `{"command":"health"}`, secret: testSigningSecret, signedAt: time.Now().Add(-time.Hour), wantStatus: http.StatusUnauthorized},
+		{name: "invalid JSON", method: "POST", body: `{`, secret: testSigningSecret, wantStatus: http.StatusBadRequest},
+		{name: "missing command", method: "POST", body: `{"channel_id":"C1"}`, secret: testSigningSecret, wantStatus: http.StatusBadRequest},
+		{name: "command without channel", method: "POST", body: `{"command":"health"}`, secret: testSigningSecret, wantStatus: http.StatusOK, wantReply: "Task 'health' executed successfully."},
+		{
+			name: "replies posted to the channel", method: "POST", body: `{"command":" health ","channel_id":"C1"}`, secret: testSigningSecret,
+			wantStatus: http.StatusOK, wantReply: "Task 'health' executed successfully.",
+			wantChannel: []string{"Running 'health'", "Task 'health' executed successfully."},
+		},
+		{
+			name: "runs as the workflow user", method: "POST", body: `{"command":"restrict"}`, secret: testSigningSecret,
+			wantStatus: http.StatusOK, wantReply: "You are not allowed to run 'restrict'.",
+		},
+		{
+			name: "claimed user_id not trusted", method: "POST", body: `{"command":"restrict","user_id":"UADMIN"}`, secret: testSigningSecret,
+			wantStatus: http.StatusOK, wantReply: "You are not allowed to run 'restrict'.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := len(messenger.sent())
+			req, err := http.NewRequest(test.method, server.URL+"/slack/workflow", strings.NewReader(test.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.secret != "" {
+				at := test.signedAt
+				if at.IsZero() {
+					at = time.Now()
+				}
+				signSlackRequest(req, test.body, test.secret, at)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != test.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, test.wantStatus)
+			}
+			if test.wantReply != "" {
+				var got workflowResponse
+				if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+					t.Fatal(err)
+				}
+				if len(got.Messages) == 0 || !strings.HasPrefix(got.Messages[len(got.Messages)-1].Text, test.wantReply) {
+					t.Errorf("messages = %+v, want %q last", got.Messages, test.wantReply)
+				}
+			}
+
+			posted := messenger.sent()[before:]
+			if len(posted) != len(test.wantChannel) {
+				t.Fatalf("posted %+v, want %v", posted, test.wantChannel)
+			}
+			for i, want := range test.wantChannel {
+				if posted[i].ChannelID != "C1" || !strings.HasPrefix(posted[i].Text, want) {
+					t.Errorf("posted[%d] = %+v, want %q in C1", i, posted[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestClaimedSlackTeam(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "event", body: `{"type":"event_callback","team_id":"T1"}`, want: "T1"},
+		{name: "workflow without a team", body: `{"command":"health"}`},
+		{name: "slash command form", body: url.Values{"team_id": {"T2"}, "text": {"health"}}.Encode(), want: "T2"},
+		{name: "interaction payload", body: url.Values{"payload": {`{"type":"block_suggestion","team":{"id":"T3"}}`}}.Encode(), want: "T3"},
+		{name: "garbage", body: "%zz{"},
+	}
+	for _, test := range tests {
+		if got := claimedSlackTeam([]byte(test.body)); got != test.want {
+			t.Errorf("%s: claimedSlackTeam() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+// Workflow, slash command and interaction requests are verified with the
+// signing secret of the workspace they come from, like events
+func TestSlackRequestsVerifiedPerTeam(t *testing.T) {
+	config := &Config{
+		SlackSigningSecret:  testSigningSecret,
+		SlackSigningSecrets: map[string]string{"T2": "team-two-secret"},
+		AckReaction:         "none",
+		DebounceMillis:      -1,
+	}
+	store := newConfigTaskStore(nil)
+	state := newBotState(config)
+	state.notifier = newFakeMessenger()
+	mux := http.NewServeMux()
+	registerWorkflowRoutes(context.Background(), mux, config, store, state)
+	registerSlashCommandRoutes(context.Background(), mux, config, store, state)
+	mux.Handle("/slack/interactions", interactionsHandler(context.Background(), func(string) *slackMessenger { return nil }, store, state))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	bodies := map[string]func(teamID string) string{
+		"/slack/workflow": func(teamID string) string { return fmt.Sprintf(`{"command":"nope","team_id":%q}`, teamID) },
+		"/slack/commands": func(teamID string) string {
+			return url.Values{"command": {"/bot"}, "text": {" "}, "team_id": {teamID}, "user_id": {"U1"}, "channel_id": {"C1"}}.Encode()
+		},
+		"/slack/interactions": func(teamID string) string {
+			return url.Values{"payload": {fmt.Sprintf(`{"type":"block_suggestion","team":{"id":%q},"value":""}`, teamID)}}.Encode()
+		},
+	}
+	tests := []struct {
+		name       string
+		teamID     string
+		secret     string
+		wantStatus int
+	}{
+		{name: "own secret", teamID: "T2", secret: "team-two-secret", wantStatus: http.StatusOK},
+		{name: "default secret for a team with its own", teamID: "T2", secret: testSigningSecret, wantStatus: http.StatusUnauthorized},
+		{name: "default secret for other teams", teamID: "T1", secret: testSigningSecret, wantStatus: http.StatusOK},
+		{name: "other team's secret", teamID: "T1", secret: "team-two-secret", wantStatus: http.StatusUnauthorized},
+	}
+	for path, body := range bodies {
+		for _, test := range tests {
+			t.Run(path+" "+test.name, func(t *testing.T) {
+				payload := body(test.teamID)
+				req, err := http.NewRequest("POST", server.URL+path, strings.NewReader(payload))
+				if err != nil {
+					t.Fatal(err)
+				}
+				signSlackRequest(req, payload, test.secret, time.Now())
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != test.wantStatus {
+					t.Errorf("status = %d, want %d", resp.StatusCode, test.wantStatus)
+				}
+			})
+		}
+	}
+}
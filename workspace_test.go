@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Events from each team are answered with that workspace's bot token
+func TestSlackEventsRoutedByTeam(t *testing.T) {
+	calls := fakeSlackAPI(t)
+	config := &Config{
+		SlackToken:          "xoxb-default",
+		SlackTokens:         map[string]string{"T0001": "xoxb-team-one", "T0002": "xoxb-team-two"},
+		SlackSigningSecret:  testSigningSecret,
+		SlackSigningSecrets: map[string]string{"T0002": "team-two-secret"},
+	}
+	server := newSlackEventsServer(t, context.Background(), config, newConfigTaskStore(nil), newBotState(config))
+
+	tests := []struct {
+		teamID    string
+		channel   string
+		secret    string
+		wantToken string
+	}{
+		{teamID: "T0001", channel: "C1", secret: testSigningSecret, wantToken: "xoxb-team-one"},
+		{teamID: "T0002", channel: "C2", secret: "team-two-secret", wantToken: "xoxb-team-two"},
+		{teamID: "T9999", channel: "C3", secret: testSigningSecret, wantToken: "xoxb-default"},
+		{channel: "C4", secret: testSigningSecret, wantToken: "xoxb-default"},
+	}
+	for _, test := range tests {
+		body := fmt.Sprintf(`{"type":"event_callback","team_id":%q,"event":{"type":"message","channel":%q,"user":"U1","text":"list"}}`, test.teamID, test.channel)
+		if resp := postSlackEvent(t, server.URL+"/slack/events", body, test.secret); resp.StatusCode != http.StatusOK {
+			t.Errorf("team %q: status = %d, want 200", test.teamID, resp.StatusCode)
+		}
+	}
+
+	// Events are handled asynchronously, so wait for every reply
+	tokenByChannel := map[string]string{}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(tokenByChannel) < len(tests) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		for _, call := range calls() {
+			if call.Method == "chat.postMessage" {
+				tokenByChannel[call.Channel] = call.Token
+			}
+		}
+	}
+	for _, test := range tests {
+		if got := tokenByChannel[test.channel]; got != test.wantToken {
+			t.Errorf("team %q replied with token %q, want %q", test.teamID, got, test.wantToken)
+		}
+	}
+}
+
+// Each workspace's events must carry its own app's signature; forged and
+// unsigned events are refused before anything in them runs
+func TestSlackEventsVerifiedPerTeam(t *testing.T) {
+	calls := fakeSlackAPI(t)
+	config := &Config{
+		SlackToken:          "xoxb-default",
+		SlackTokens:         map[string]string{"T0001": "xoxb-team-one", "T0002": "xoxb-team-two"},
+		SlackSigningSecret:  testSigningSecret,
+		SlackSigningSecrets: map[string]string{"T0002": "team-two-secret"},
+	}
+	server := newSlackEventsServer(t, context.Background(), config, newConfigTaskStore(nil), newBotState(config))
+
+	tests := []struct {
+		name   string
+		teamID string
+		secret string
+	}{
+		{name: "unsigned for team one", teamID: "T0001"},
+		{name: "unsigned for team two", teamID: "T0002"},
+		{name: "forged for team one", teamID: "T0001", secret: "forged"},
+		{name: "forged for team two", teamID: "T0002", secret: "forged"},
+		{name: "team one's secret claiming team two", teamID: "T0002", secret: testSigningSecret},
+		{name: "team two's secret claiming team one", teamID: "T0001", secret: "team-two-secret"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"type":"event_callback","team_id":%q,"event":{"type":"message","channel":"C1","user":"U1","text":"list"}}`, test.teamID)
+			if resp := postSlackEvent(t, server.URL+"/slack/events", body, test.secret); resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("status = %d, want 401", resp.StatusCode)
+			}
+		})
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	for _, call := range calls() {
+		if call.Method != "auth.test" {
+			t.Errorf("Slack API called with %+v, want no replies to rejected events", call)
+		}
+	}
+}
+
+// Without a signing secret Slack events can't be verified, so the bot refuses to start
+func TestRegisterSlackRoutesRequiresSigningSecret(t *testing.T) {
+	config := &Config{SlackToken: "xoxb-default"}
+	err := registerSlackRoutes(context.Background(), config, newConfigTaskStore(nil), newBotState(config))
+	if err == nil || !strings.Contains(err.Error(), "slack_signing_secret") {
+		t.Errorf("registerSlackRoutes() = %v, want an error naming slack_signing_secret", err)
+	}
+}